@@ -0,0 +1,86 @@
+package checkpermissions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/traefik/mesh/v2/cmd"
+	"github.com/traefik/mesh/v2/pkg/k8s"
+	"github.com/traefik/paerser/cli"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewCmd builds a new check-permissions command.
+func NewCmd(config *Configuration, loaders []cli.ResourceLoader) *cli.Command {
+	return &cli.Command{
+		Name:          "check-permissions",
+		Description:   `Checks that the current user is granted the permissions needed by the controller and the dns command.`,
+		Configuration: config,
+		Run: func(_ []string) error {
+			return checkPermissionsCommand(config)
+		},
+		Resources: loaders,
+	}
+}
+
+func checkPermissionsCommand(config *Configuration) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	logger, err := cmd.NewLogger(config.LogFormat, config.LogLevel)
+	if err != nil {
+		return fmt.Errorf("could not create logger: %w", err)
+	}
+
+	logger.Debugf("Using masterURL: %q", config.MasterURL)
+	logger.Debugf("Using kubeconfig: %q", config.KubeConfig)
+
+	clients, err := k8s.NewClient(logger, config.MasterURL, config.KubeConfig)
+	if err != nil {
+		return fmt.Errorf("error building clients: %w", err)
+	}
+
+	return checkPermissions(ctx, os.Stdout, clients.KubernetesClient(), config)
+}
+
+// checkPermissions writes the permissions needed for the given configuration which are denied to the current user,
+// and fails when there is at least one of them.
+func checkPermissions(ctx context.Context, w io.Writer, kubeClient kubernetes.Interface, config *Configuration) error {
+	permissions := k8s.ControllerPermissions(config.Namespace, config.ACL)
+
+	if !config.SkipDNS {
+		permissions = append(permissions, k8s.DNSPermissions(config.Namespace, config.DNSNamespace, config.DNSServerSideApply)...)
+		permissions = append(permissions, k8s.EventsPermissions(config.Namespace)...)
+	}
+
+	if config.LeaderElection {
+		permissions = append(permissions, k8s.LeaderElectionPermissions(config.Namespace)...)
+	}
+
+	if config.Settings {
+		permissions = append(permissions, k8s.SettingsPermissions(config.Namespace)...)
+	}
+
+	denied, err := k8s.CheckPermissions(ctx, kubeClient, permissions)
+	if err != nil {
+		return err
+	}
+
+	if len(denied) == 0 {
+		_, err = fmt.Fprintf(w, "All the %d permissions needed are granted\n", len(permissions))
+
+		return err
+	}
+
+	for _, permission := range denied {
+		if _, err = fmt.Fprintf(w, "Missing permission: %s\n", permission); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%d of the %d permissions needed are missing", len(denied), len(permissions))
+}
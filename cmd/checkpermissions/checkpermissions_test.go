@@ -0,0 +1,85 @@
+package checkpermissions
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	tests := []struct {
+		desc            string
+		serverSideApply bool
+		denied          func(attrs *authorizationv1.ResourceAttributes) bool
+		want            string
+		wantErr         bool
+	}{
+		{
+			desc:   "all permissions granted",
+			denied: func(_ *authorizationv1.ResourceAttributes) bool { return false },
+			want:   "All the 28 permissions needed are granted\n",
+		},
+		{
+			desc: "ConfigMap update denied",
+			denied: func(attrs *authorizationv1.ResourceAttributes) bool {
+				return attrs.Resource == "configmaps" && attrs.Verb == "update"
+			},
+			want:    "Missing permission: update configmaps in namespace \"kube-system\"\n",
+			wantErr: true,
+		},
+		{
+			desc:            "ConfigMap update denied with server-side apply",
+			serverSideApply: true,
+			denied: func(attrs *authorizationv1.ResourceAttributes) bool {
+				return attrs.Resource == "configmaps" && attrs.Verb == "update"
+			},
+			want: "All the 28 permissions needed are granted\n",
+		},
+		{
+			desc:            "ConfigMap patch denied with server-side apply",
+			serverSideApply: true,
+			denied: func(attrs *authorizationv1.ResourceAttributes) bool {
+				return attrs.Resource == "configmaps" && attrs.Verb == "patch"
+			},
+			want:    "Missing permission: patch configmaps in namespace \"kube-system\"\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := fake.NewSimpleClientset()
+			kubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+				review := action.(ktesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				review.Status.Allowed = !test.denied(review.Spec.ResourceAttributes)
+
+				return true, review, nil
+			})
+
+			config := NewConfiguration()
+			config.Namespace = "traefik-mesh"
+			config.DNSServerSideApply = test.serverSideApply
+
+			var buf bytes.Buffer
+
+			err := checkPermissions(context.Background(), &buf, kubeClient, config)
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, test.want, buf.String())
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package checkpermissions
+
+import "os"
+
+// Configuration holds the configuration for the check-permissions command.
+type Configuration struct {
+	KubeConfig     string `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL      string `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	LogLevel       string `description:"The log level." export:"true"`
+	LogFormat      string `description:"The log format, either common (text) or json." export:"true"`
+	Namespace      string `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	DNSNamespace   string `description:"The namespace that the cluster DNS provider is installed in." export:"true"`
+	ACL            bool   `description:"Check the permissions needed by the controller in ACL mode." export:"true"`
+	LeaderElection bool   `description:"Check the permissions needed by the leader election." export:"true"`
+	SkipDNS        bool   `description:"Skip the permissions needed to configure the cluster DNS provider." export:"true"`
+	Settings       bool   `description:"Check the permissions needed to watch the settings ConfigMap." export:"true"`
+
+	DNSServerSideApply bool `description:"Check the permissions needed to configure the cluster DNS provider with server-side apply." export:"true"`
+}
+
+// NewConfiguration creates a new check-permissions configuration with default values.
+func NewConfiguration() *Configuration {
+	return &Configuration{
+		KubeConfig:   os.Getenv("KUBECONFIG"),
+		LogLevel:     "error",
+		LogFormat:    "common",
+		Namespace:    "default",
+		DNSNamespace: "kube-system",
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/traefik/mesh/v2/cmd"
 	"github.com/traefik/mesh/v2/pkg/cleanup"
+	"github.com/traefik/mesh/v2/pkg/dns"
 	"github.com/traefik/mesh/v2/pkg/k8s"
 	"github.com/traefik/paerser/cli"
 )
@@ -43,7 +44,12 @@ func cleanupCommand(config *Configuration) error {
 		return fmt.Errorf("error building clients: %w", err)
 	}
 
-	c := cleanup.NewCleanup(logger, clients.KubernetesClient(), config.Namespace)
+	var dnsOpts []dns.ClientOption
+	if config.DNSServerSideApply {
+		dnsOpts = append(dnsOpts, dns.ServerSideApply())
+	}
+
+	c := cleanup.NewCleanup(logger, clients.KubernetesClient(), config.Namespace, config.DNSNamespace, dnsOpts...)
 
 	if err := c.CleanShadowServices(ctx); err != nil {
 		return fmt.Errorf("error encountered during cluster cleanup: %w", err)
@@ -4,19 +4,23 @@ import "os"
 
 // Configuration holds the configuration for the cleanup command.
 type Configuration struct {
-	KubeConfig string `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
-	MasterURL  string `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
-	Namespace  string `description:"The namespace that Traefik Mesh is installed in." export:"true"`
-	LogLevel   string `description:"The log level." export:"true"`
-	LogFormat  string `description:"The log format." export:"true"`
+	KubeConfig   string `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL    string `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	Namespace    string `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	DNSNamespace string `description:"The namespace that the cluster DNS provider is installed in." export:"true"`
+	LogLevel     string `description:"The log level." export:"true"`
+	LogFormat    string `description:"The log format, either common (text) or json." export:"true"`
+
+	DNSServerSideApply bool `description:"Restore the cluster DNS provider configuration with server-side apply." export:"true"`
 }
 
 // NewConfiguration creates a new cleanup configuration with default values.
 func NewConfiguration() *Configuration {
 	return &Configuration{
-		KubeConfig: os.Getenv("KUBECONFIG"),
-		Namespace:  "default",
-		LogLevel:   "error",
-		LogFormat:  "common",
+		KubeConfig:   os.Getenv("KUBECONFIG"),
+		Namespace:    "default",
+		DNSNamespace: "kube-system",
+		LogLevel:     "error",
+		LogFormat:    "common",
 	}
 }
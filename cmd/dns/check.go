@@ -0,0 +1,59 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/traefik/mesh/v2/cmd"
+	"github.com/traefik/mesh/v2/pkg/dns"
+	"github.com/traefik/paerser/cli"
+)
+
+// NewCheckCmd builds a new dns check command.
+func NewCheckCmd(config *CheckConfiguration, loaders []cli.ResourceLoader) *cli.Command {
+	return &cli.Command{
+		Name:          "check",
+		Description:   `Checks that the Traefik Mesh name of a service is resolved by the cluster DNS.`,
+		Configuration: config,
+		Run: func(_ []string) error {
+			return checkCommand(os.Stdout, config)
+		},
+		Resources: loaders,
+	}
+}
+
+func checkCommand(w io.Writer, config *CheckConfiguration) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	logger, err := cmd.NewLogger(config.LogFormat, config.LogLevel)
+	if err != nil {
+		return fmt.Errorf("could not create logger: %w", err)
+	}
+
+	if config.ServiceName == "" {
+		return errors.New("the serviceName option is required")
+	}
+
+	logger.Debugf("Using nameserver: %q", config.Nameserver)
+
+	if config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	addrs, err := dns.CheckResolution(ctx, dns.NewHostResolver(config.Nameserver), config.ServiceNamespace, config.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s.%s.traefik.mesh resolves to %s\n", config.ServiceName, config.ServiceNamespace, strings.Join(addrs, ", "))
+
+	return err
+}
@@ -1,28 +1,88 @@
 package dns
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 // Configuration holds the configuration for the dns command.
 type Configuration struct {
-	KubeConfig  string `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
-	MasterURL   string `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
-	LogLevel    string `description:"The log level." export:"true"`
-	LogFormat   string `description:"The log format." export:"true"`
-	Port        int32  `description:"The DNS server port." export:"true"`
-	Namespace   string `description:"The namespace that Traefik Mesh is installed in." export:"true"`
-	ServiceName string `description:"The DNS service name." export:"true"`
-	ServicePort int32  `description:"The DNS service port." export:"true"`
+	KubeConfig                      string        `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL                       string        `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	LogLevel                        string        `description:"The log level." export:"true"`
+	LogFormat                       string        `description:"The log format, either common (text) or json." export:"true"`
+	Port                            int32         `description:"The DNS server port." export:"true"`
+	Namespace                       string        `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	DNSNamespace                    string        `description:"The namespace that the cluster DNS provider is installed in." export:"true"`
+	DNSNoCreate                     bool          `description:"Never create the cluster DNS provider ConfigMaps, only patch existing ones." export:"true"`
+	DNSServerSideApply              bool          `description:"Update the CoreDNS ConfigMap and Deployment with server-side apply, using the traefik-mesh field manager." export:"true"`
+	ServiceName                     string        `description:"The DNS service name." export:"true"`
+	ServicePort                     int32         `description:"The DNS service port." export:"true"`
+	CoreDNSReady                    bool          `description:"Add the ready plugin to the CoreDNS Traefik Mesh block (CoreDNS >= 1.5)." export:"true"`
+	CoreDNSTLSServerName            string        `description:"Forward queries from the CoreDNS Traefik Mesh block over TLS, verifying the given server name (CoreDNS >= 1.4)." export:"true"`
+	CoreDNSCacheTTL                 time.Duration `description:"Maximum TTL of the entries cached by the CoreDNS Traefik Mesh block, the cache is disabled when zero." export:"true"`
+	CoreDNSServeStale               time.Duration `description:"Serve stale cache entries from the CoreDNS Traefik Mesh block for the given duration when the Traefik Mesh DNS service is unreachable (CoreDNS >= 1.7)." export:"true"`
+	CoreDNSExtraZones               []string      `description:"Additional zones forwarded to the Traefik Mesh DNS service by the CoreDNS Traefik Mesh block." export:"true"`
+	CoreDNSExceptDomains            []string      `description:"Subdomains of the forwarded zones which are not forwarded to the Traefik Mesh DNS service by the CoreDNS Traefik Mesh block." export:"true"`
+	CoreDNSErrorsConsolidate        time.Duration `description:"Consolidate the errors logged by the CoreDNS Traefik Mesh block over the given duration (CoreDNS >= 1.6)." export:"true"`
+	CoreDNSErrorsConsolidatePattern string        `description:"Regular expression matching the errors consolidated by the CoreDNS Traefik Mesh block, all of them when empty." export:"true"`
+	CoreDNSHealthLameduck           time.Duration `description:"Lameduck duration set to the health plugin of the CoreDNS Corefile, which is left unchanged when zero." export:"true"`
+	LeaderElection                  bool          `description:"Enable the leader election, only the leader configures the cluster DNS provider." export:"true"`
+	SkipDNS                         bool          `description:"Skip the cluster DNS provider configuration, only serve DNS queries." export:"true"`
+	RequireDNS                      bool          `description:"Fail when the cluster DNS provider is not supported, instead of only serving DNS queries." export:"true"`
 }
 
 // NewConfiguration creates the dns command configuration with default values.
 func NewConfiguration() *Configuration {
 	return &Configuration{
-		KubeConfig:  os.Getenv("KUBECONFIG"),
-		LogLevel:    "error",
-		LogFormat:   "common",
-		Port:        9053,
-		Namespace:   "default",
-		ServiceName: "traefik-mesh-dns",
-		ServicePort: 53,
+		KubeConfig:      os.Getenv("KUBECONFIG"),
+		LogLevel:        "error",
+		LogFormat:       "common",
+		Port:            9053,
+		Namespace:       "default",
+		DNSNamespace:    "kube-system",
+		ServiceName:     "traefik-mesh-dns",
+		ServicePort:     53,
+		CoreDNSCacheTTL: 30 * time.Second,
+		RequireDNS:      true,
+	}
+}
+
+// ShowConfiguration holds the configuration for the dns show command.
+type ShowConfiguration struct {
+	KubeConfig   string `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL    string `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	LogLevel     string `description:"The log level." export:"true"`
+	LogFormat    string `description:"The log format, either common (text) or json." export:"true"`
+	DNSNamespace string `description:"The namespace that the cluster DNS provider is installed in." export:"true"`
+}
+
+// NewShowConfiguration creates the dns show command configuration with default values.
+func NewShowConfiguration() *ShowConfiguration {
+	return &ShowConfiguration{
+		KubeConfig:   os.Getenv("KUBECONFIG"),
+		LogLevel:     "error",
+		LogFormat:    "common",
+		DNSNamespace: "kube-system",
+	}
+}
+
+// CheckConfiguration holds the configuration for the dns check command.
+type CheckConfiguration struct {
+	LogLevel         string        `description:"The log level." export:"true"`
+	LogFormat        string        `description:"The log format, either common (text) or json." export:"true"`
+	ServiceName      string        `description:"Name of the mesh service whose Traefik Mesh name is resolved." export:"true"`
+	ServiceNamespace string        `description:"Namespace of the mesh service whose Traefik Mesh name is resolved." export:"true"`
+	Nameserver       string        `description:"Address of the DNS server queried, the system resolver when empty." export:"true"`
+	Timeout          time.Duration `description:"Timeout of the resolution." export:"true"`
+}
+
+// NewCheckConfiguration creates the dns check command configuration with default values.
+func NewCheckConfiguration() *CheckConfiguration {
+	return &CheckConfiguration{
+		LogLevel:         "error",
+		LogFormat:        "common",
+		ServiceNamespace: "default",
+		Timeout:          5 * time.Second,
 	}
 }
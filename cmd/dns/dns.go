@@ -2,7 +2,9 @@ package dns
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
@@ -12,11 +14,18 @@ import (
 	"github.com/traefik/mesh/v2/pkg/dns"
 	"github.com/traefik/mesh/v2/pkg/k8s"
 	"github.com/traefik/paerser/cli"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
+// dnsLeaseName is the name of the Lease used for the dns command leader election.
+const dnsLeaseName = "traefik-mesh-dns"
+
 // NewCmd builds a new dns command.
 func NewCmd(config *Configuration, loaders []cli.ResourceLoader) *cli.Command {
 	return &cli.Command{
@@ -48,8 +57,17 @@ func dnsCommand(config *Configuration) error {
 		return fmt.Errorf("error building clients: %w", err)
 	}
 
-	// Configure DNS.
-	if err = configureDNS(ctx, clients.KubernetesClient(), logger, config); err != nil {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: clients.KubernetesClient().CoreV1().Events(""),
+	})
+	defer eventBroadcaster.Shutdown()
+
+	eventRecorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: k8s.AppName})
+
+	errCh := make(chan error)
+
+	if err = setupDNS(ctx, clients.KubernetesClient(), eventRecorder, logger, config, errCh); err != nil {
 		return err
 	}
 
@@ -62,8 +80,6 @@ func dnsCommand(config *Configuration) error {
 	resolver := dns.NewShadowServiceResolver("traefik.mesh", config.Namespace, serviceLister)
 	server := dns.NewServer(config.Port, resolver, logger)
 
-	errCh := make(chan error)
-
 	go func() {
 		if err := server.ListenAndServe(); err != nil {
 			errCh <- fmt.Errorf("DNS server has stopped unexpectedly: %w", err)
@@ -83,17 +99,95 @@ func dnsCommand(config *Configuration) error {
 	return nil
 }
 
-func configureDNS(ctx context.Context, kubeClient kubernetes.Interface, logger logrus.FieldLogger, config *Configuration) error {
-	dnsClient := dns.NewClient(logger, kubeClient)
+// setupDNS configures the cluster DNS provider, unless it is skipped. With leader election, DNS is configured in the
+// background by the leader only, and the errors are sent to the given channel.
+func setupDNS(ctx context.Context, kubeClient kubernetes.Interface, eventRecorder record.EventRecorder, logger logrus.FieldLogger, config *Configuration, errCh chan<- error) error {
+	if config.SkipDNS {
+		logger.Info("Skipping cluster DNS provider configuration")
+		return nil
+	}
+
+	if !config.LeaderElection {
+		return configureDNS(ctx, kubeClient, eventRecorder, logger, config)
+	}
+
+	identity, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("unable to get leader election identity: %w", err)
+	}
+
+	leaderElectionCfg := k8s.NewLeaderElectionConfig(config.Namespace, dnsLeaseName, identity)
+	configure := func(ctx context.Context) error {
+		return configureDNS(ctx, kubeClient, eventRecorder, logger, config)
+	}
+
+	go func() {
+		if err := configureDNSWhenLeading(ctx, kubeClient, leaderElectionCfg, logger, configure); err != nil {
+			errCh <- err
+		}
+	}()
+
+	return nil
+}
+
+// configureDNSWhenLeading runs the leader election and configures DNS with the given function once this instance is
+// elected. It returns once DNS is configured or the given context is canceled, the leadership being kept until then.
+func configureDNSWhenLeading(ctx context.Context, kubeClient kubernetes.Interface, cfg k8s.LeaderElectionConfig, logger logrus.FieldLogger, configure func(ctx context.Context) error) error {
+	configured := make(chan error, 1)
+
+	go func() {
+		onStartedLeading := func(ctx context.Context) { configured <- configure(ctx) }
+
+		if err := k8s.RunLeaderElection(ctx, kubeClient, cfg, logger, onStartedLeading, func() {}); err != nil {
+			configured <- err
+		}
+	}()
+
+	select {
+	case err := <-configured:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// configureDNS patches the configuration of the cluster DNS provider. Unless DNS is required, an unsupported DNS provider,
+// or CoreDNS deployed by a DaemonSet, is reported by a warning event on the Traefik Mesh DNS service and left
+// unconfigured: the mesh routing keeps working, but the names of the Traefik Mesh zone are not resolved by the cluster
+// DNS provider.
+func configureDNS(ctx context.Context, kubeClient kubernetes.Interface, eventRecorder record.EventRecorder, logger logrus.FieldLogger, config *Configuration) error {
+	clientOpts := []dns.ClientOption{dns.SystemNamespace(config.DNSNamespace)}
+	if config.DNSNoCreate {
+		clientOpts = append(clientOpts, dns.NoConfigMapCreation())
+	}
+
+	if config.DNSServerSideApply {
+		clientOpts = append(clientOpts, dns.ServerSideApply())
+	}
+
+	dnsClient := dns.NewClient(logger, kubeClient, clientOpts...)
 
 	dnsProvider, err := dnsClient.CheckDNSProvider(ctx)
+	if errors.Is(err, dns.ErrUnsupportedDNSProvider) && !config.RequireDNS {
+		skipUnsupportedDNSProvider(eventRecorder, logger, config, err)
+
+		return nil
+	}
+
 	if err != nil {
 		return fmt.Errorf("unable to find suitable DNS provider: %w", err)
 	}
 
 	switch dnsProvider {
 	case dns.CoreDNS:
-		if err := dnsClient.ConfigureCoreDNS(ctx, config.Namespace, config.ServiceName, config.ServicePort); err != nil {
+		err = dnsClient.ConfigureCoreDNS(ctx, config.Namespace, config.ServiceName, config.ServicePort, newBlockOptions(config))
+		if errors.Is(err, dns.ErrCoreDNSDaemonSet) && !config.RequireDNS {
+			skipUnsupportedDNSProvider(eventRecorder, logger, config, err)
+
+			return nil
+		}
+
+		if err != nil {
 			return fmt.Errorf("unable to configure CoreDNS: %w", err)
 		}
 
@@ -106,6 +200,35 @@ func configureDNS(ctx context.Context, kubeClient kubernetes.Interface, logger l
 	return nil
 }
 
+// skipUnsupportedDNSProvider reports, with a warning log and a warning event on the Traefik Mesh DNS service, that the
+// cluster DNS provider can't be configured for the given reason.
+func skipUnsupportedDNSProvider(eventRecorder record.EventRecorder, logger logrus.FieldLogger, config *Configuration, reason error) {
+	logger.Warnf("Skipping cluster DNS provider configuration: %v", reason)
+
+	dnsService := &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Namespace:  config.Namespace,
+		Name:       config.ServiceName,
+	}
+	eventRecorder.Eventf(dnsService, corev1.EventTypeWarning, "UnsupportedDNSProvider", "The DNS provider of namespace %q can't be configured, the Traefik Mesh names are not resolved by the cluster DNS: %v", config.DNSNamespace, reason)
+}
+
+// newBlockOptions returns the options of the CoreDNS Traefik Mesh block set by the given configuration.
+func newBlockOptions(config *Configuration) dns.BlockOptions {
+	return dns.BlockOptions{
+		Ready:                    config.CoreDNSReady,
+		TLSServerName:            config.CoreDNSTLSServerName,
+		CacheTTL:                 &config.CoreDNSCacheTTL,
+		ServeStale:               config.CoreDNSServeStale,
+		ExtraZones:               config.CoreDNSExtraZones,
+		ErrorsConsolidate:        config.CoreDNSErrorsConsolidate,
+		ErrorsConsolidatePattern: config.CoreDNSErrorsConsolidatePattern,
+		ExceptDomains:            config.CoreDNSExceptDomains,
+		HealthLameduck:           config.CoreDNSHealthLameduck,
+	}
+}
+
 func newServiceLister(ctx context.Context, kubeClient kubernetes.Interface, config *Configuration) (listers.ServiceLister, error) {
 	kubernetesFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, k8s.ResyncPeriod, informers.WithNamespace(config.Namespace))
 	serviceLister := kubernetesFactory.Core().V1().Services().Lister()
@@ -0,0 +1,214 @@
+package dns
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/v2/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestConfigureDNSWhenLeading(t *testing.T) {
+	tests := []struct {
+		desc           string
+		heldByAnother  bool
+		wantConfigured bool
+	}{
+		{
+			desc:           "leader configures DNS",
+			wantConfigured: true,
+		},
+		{
+			desc:          "non-leader does not configure DNS",
+			heldByAnother: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			var objects []runtime.Object
+
+			if test.heldByAnother {
+				holder := "other-instance"
+				leaseDuration := int32(3600)
+				now := metav1.NewMicroTime(time.Now())
+
+				objects = append(objects, &coordinationv1.Lease{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "traefik-mesh", Name: dnsLeaseName},
+					Spec: coordinationv1.LeaseSpec{
+						HolderIdentity:       &holder,
+						LeaseDurationSeconds: &leaseDuration,
+						AcquireTime:          &now,
+						RenewTime:            &now,
+					},
+				})
+			}
+
+			kubeClient := fake.NewSimpleClientset(objects...)
+
+			cfg := k8s.NewLeaderElectionConfig("traefik-mesh", dnsLeaseName, "instance")
+			cfg.RetryPeriod = 100 * time.Millisecond
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var configured bool
+
+			err := configureDNSWhenLeading(ctx, kubeClient, cfg, logger, func(_ context.Context) error {
+				configured = true
+				return nil
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantConfigured, configured)
+		})
+	}
+}
+
+func TestSetupDNS_SkipDNS(t *testing.T) {
+	tests := []struct {
+		desc           string
+		leaderElection bool
+	}{
+		{
+			desc: "without leader election",
+		},
+		{
+			desc:           "with leader election",
+			leaderElection: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			coreDNSConfigMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns"},
+				Data: map[string]string{
+					"Corefile": ".:53 {\n    forward . /etc/resolv.conf\n}\n",
+				},
+			}
+
+			kubeClient := fake.NewSimpleClientset(coreDNSConfigMap)
+
+			config := NewConfiguration()
+			config.SkipDNS = true
+			config.LeaderElection = test.leaderElection
+
+			errCh := make(chan error, 1)
+
+			err := setupDNS(context.Background(), kubeClient, record.NewFakeRecorder(10), logger, config, errCh)
+			require.NoError(t, err)
+
+			// Neither the DNS provider nor the leader election Lease is looked up.
+			assert.Empty(t, kubeClient.Actions())
+			assert.Empty(t, errCh)
+
+			got, err := kubeClient.CoreV1().ConfigMaps("kube-system").Get(context.Background(), "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, coreDNSConfigMap.Data, got.Data)
+		})
+	}
+}
+
+func TestSetupDNS_UnsupportedDNSProvider(t *testing.T) {
+	coreDNSDaemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: metav1.NamespaceSystem},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "coredns", Image: "coredns/coredns:1.8.0"}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		desc       string
+		objects    []runtime.Object
+		requireDNS bool
+		wantErr    bool
+		wantEvent  bool
+	}{
+		{
+			desc:       "DNS required",
+			requireDNS: true,
+			wantErr:    true,
+		},
+		{
+			desc:      "DNS not required",
+			wantEvent: true,
+		},
+		{
+			desc:       "CoreDNS daemonset, DNS required",
+			objects:    []runtime.Object{coreDNSDaemonSet},
+			requireDNS: true,
+			wantErr:    true,
+		},
+		{
+			desc:      "CoreDNS daemonset, DNS not required",
+			objects:   []runtime.Object{coreDNSDaemonSet},
+			wantEvent: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			// Neither CoreDNS nor KubeDNS is deployed by a Deployment.
+			kubeClient := fake.NewSimpleClientset(test.objects...)
+			recorder := record.NewFakeRecorder(10)
+
+			config := NewConfiguration()
+			config.Namespace = "traefik-mesh"
+			config.RequireDNS = test.requireDNS
+
+			errCh := make(chan error, 1)
+
+			err := setupDNS(context.Background(), kubeClient, recorder, logger, config, errCh)
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if !test.wantEvent {
+				assert.Empty(t, recorder.Events)
+				return
+			}
+
+			require.Len(t, recorder.Events, 1)
+
+			event := <-recorder.Events
+			assert.Contains(t, event, corev1.EventTypeWarning)
+			assert.Contains(t, event, "UnsupportedDNSProvider")
+		})
+	}
+}
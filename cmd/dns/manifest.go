@@ -0,0 +1,81 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/v2/cmd"
+	"github.com/traefik/mesh/v2/pkg/dns"
+	"github.com/traefik/mesh/v2/pkg/k8s"
+	"github.com/traefik/paerser/cli"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// NewManifestCmd builds a new dns manifest command. It takes the same configuration as the dns command, so that the
+// rendered manifest matches the configuration the dns command would apply.
+func NewManifestCmd(config *Configuration, loaders []cli.ResourceLoader) *cli.Command {
+	return &cli.Command{
+		Name:          "manifest",
+		Description:   `Renders the CoreDNS ConfigMap patched with the Traefik Mesh block as a YAML manifest, without applying it.`,
+		Configuration: config,
+		Run: func(_ []string) error {
+			return manifestCommand(os.Stdout, config)
+		},
+		Resources: loaders,
+	}
+}
+
+func manifestCommand(w io.Writer, config *Configuration) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	logger, err := cmd.NewLogger(config.LogFormat, config.LogLevel)
+	if err != nil {
+		return fmt.Errorf("could not create logger: %w", err)
+	}
+
+	logger.Debugf("Using masterURL: %q", config.MasterURL)
+	logger.Debugf("Using kubeconfig: %q", config.KubeConfig)
+
+	clients, err := k8s.NewClient(logger, config.MasterURL, config.KubeConfig)
+	if err != nil {
+		return fmt.Errorf("error building clients: %w", err)
+	}
+
+	return writeManifest(ctx, w, clients.KubernetesClient(), logger, config)
+}
+
+// writeManifest writes the YAML manifest of the CoreDNS ConfigMap patched with the Traefik Mesh block to the given
+// writer. Only CoreDNS is supported.
+func writeManifest(ctx context.Context, w io.Writer, kubeClient kubernetes.Interface, logger logrus.FieldLogger, config *Configuration) error {
+	dnsClient := dns.NewClient(logger, kubeClient, dns.SystemNamespace(config.DNSNamespace))
+
+	dnsProvider, err := dnsClient.CheckDNSProvider(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to find suitable DNS provider: %w", err)
+	}
+
+	if dnsProvider != dns.CoreDNS {
+		return fmt.Errorf("unable to render the configuration of DNS provider %q, only CoreDNS is supported", dnsProvider)
+	}
+
+	configMap, err := dnsClient.BuildCoreDNSConfigMap(ctx, config.Namespace, config.ServiceName, config.ServicePort, newBlockOptions(config))
+	if err != nil {
+		return fmt.Errorf("unable to build CoreDNS ConfigMap: %w", err)
+	}
+
+	manifest, err := yaml.Marshal(configMap)
+	if err != nil {
+		return fmt.Errorf("unable to marshal CoreDNS ConfigMap: %w", err)
+	}
+
+	_, err = w.Write(manifest)
+
+	return err
+}
@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWriteManifest(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	corefile := ".:53 {\n    errors\n    forward . /etc/resolv.conf\n}\n"
+
+	kubeClient := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "traefik-mesh", Name: "traefik-mesh-dns"},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.10.10.10"},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "coredns", Image: "coredns:1.7.0"}},
+						Volumes: []corev1.Volume{{
+							Name: "config-volume",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: "coredns"},
+								},
+							},
+						}},
+					},
+				},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "kube-system",
+				Name:            "coredns",
+				Labels:          map[string]string{"k8s-app": "kube-dns"},
+				ResourceVersion: "42",
+			},
+			Data: map[string]string{"Corefile": corefile},
+		},
+	)
+
+	config := NewConfiguration()
+	config.Namespace = "traefik-mesh"
+
+	var buf bytes.Buffer
+
+	err := writeManifest(context.Background(), &buf, kubeClient, logger, config)
+	require.NoError(t, err)
+
+	var got corev1.ConfigMap
+
+	err = yaml.Unmarshal(buf.Bytes(), &got)
+	require.NoError(t, err)
+
+	assert.Equal(t, "v1", got.APIVersion)
+	assert.Equal(t, "ConfigMap", got.Kind)
+	assert.Equal(t, "coredns", got.Name)
+	assert.Equal(t, "kube-system", got.Namespace)
+	assert.Equal(t, map[string]string{"k8s-app": "kube-dns"}, got.Labels)
+	assert.Empty(t, got.ResourceVersion)
+
+	wantBlock := "#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n"
+	assert.Equal(t, corefile+"\n"+wantBlock, got.Data["Corefile"])
+
+	// The manifest is only rendered, the ConfigMap is left untouched.
+	configMap, err := kubeClient.CoreV1().ConfigMaps("kube-system").Get(context.Background(), "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corefile, configMap.Data["Corefile"])
+}
@@ -0,0 +1,55 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/traefik/mesh/v2/cmd"
+	"github.com/traefik/mesh/v2/pkg/dns"
+	"github.com/traefik/mesh/v2/pkg/k8s"
+	"github.com/traefik/paerser/cli"
+)
+
+// NewShowCmd builds a new dns show command.
+func NewShowCmd(config *ShowConfiguration, loaders []cli.ResourceLoader) *cli.Command {
+	return &cli.Command{
+		Name:          "show",
+		Description:   `Shows the DNS provider configuration, including the Traefik Mesh block.`,
+		Configuration: config,
+		Run: func(_ []string) error {
+			return showCommand(os.Stdout, config)
+		},
+		Resources: loaders,
+	}
+}
+
+func showCommand(w io.Writer, config *ShowConfiguration) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	logger, err := cmd.NewLogger(config.LogFormat, config.LogLevel)
+	if err != nil {
+		return fmt.Errorf("could not create logger: %w", err)
+	}
+
+	logger.Debugf("Using masterURL: %q", config.MasterURL)
+	logger.Debugf("Using kubeconfig: %q", config.KubeConfig)
+
+	clients, err := k8s.NewClient(logger, config.MasterURL, config.KubeConfig)
+	if err != nil {
+		return fmt.Errorf("error building clients: %w", err)
+	}
+
+	dump, err := dns.NewClient(logger, clients.KubernetesClient(), dns.SystemNamespace(config.DNSNamespace)).DumpConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to dump DNS configuration: %w", err)
+	}
+
+	_, err = io.WriteString(w, dump)
+
+	return err
+}
@@ -17,7 +17,7 @@ func parseLogFormat(format string) (logrus.Formatter, error) {
 	switch format {
 	case "json":
 		return &logrus.JSONFormatter{}, nil
-	case "common":
+	case "common", "text":
 		return &logrus.TextFormatter{DisableColors: false, FullTimestamp: true, DisableSorting: true}, nil
 	default:
 		return nil, fmt.Errorf("invalid logging format: %s", format)
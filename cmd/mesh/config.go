@@ -2,39 +2,76 @@ package main
 
 import (
 	"os"
+	"time"
+
+	"github.com/traefik/mesh/v2/pkg/k8s"
 )
 
 // Configuration holds the configuration for the main command.
 type Configuration struct {
-	KubeConfig       string   `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
-	MasterURL        string   `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
-	LogLevel         string   `description:"The log level." export:"true"`
-	LogFormat        string   `description:"The log format." export:"true"`
-	ACL              bool     `description:"Enable ACL mode." export:"true"`
-	DefaultMode      string   `description:"Default mode for mesh services." export:"true"`
-	Namespace        string   `description:"The namespace that Traefik Mesh is installed in." export:"true"`
-	WatchNamespaces  []string `description:"Namespaces to watch." export:"true"`
-	IgnoreNamespaces []string `description:"Namespaces to ignore." export:"true"`
-	APIPort          int32    `description:"API port for the controller." export:"true"`
-	APIHost          string   `description:"API host for the controller to bind to." export:"true"`
-	LimitHTTPPort    int32    `description:"Number of HTTP ports allocated." export:"true"`
-	LimitTCPPort     int32    `description:"Number of TCP ports allocated." export:"true"`
-	LimitUDPPort     int32    `description:"Number of UDP ports allocated." export:"true"`
+	KubeConfig            string        `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL             string        `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	LogLevel              string        `description:"The log level." export:"true"`
+	LogFormat             string        `description:"The log format, either common (text) or json." export:"true"`
+	ACL                   bool          `description:"Enable ACL mode." export:"true"`
+	ACLSourceHeader       string        `description:"Request header set with the identity of the TrafficTarget source which made the request in ACL mode, disabled if empty." export:"true"`
+	ACLDefaultDeny        bool          `description:"Forbid the traffic to the services which are not the destination of any TrafficTarget in ACL mode, allowed for all the clients when disabled." export:"true"`
+	DefaultMode           string        `description:"Default mode for mesh services." export:"true"`
+	Namespace             string        `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	WatchNamespaces       []string      `description:"Namespaces to watch." export:"true"`
+	IgnoreNamespaces      []string      `description:"Namespaces to ignore." export:"true"`
+	APIPort               int32         `description:"API port for the controller." export:"true"`
+	APIHost               string        `description:"API host for the controller to bind to, an IPv4 or IPv6 address. Binds all the interfaces when empty." export:"true"`
+	APISocket             string        `description:"Path of a Unix domain socket the API also listens on, disabled when empty." export:"true"`
+	Debug                 bool          `description:"Enable the debug endpoints of the API." export:"true"`
+	LimitHTTPPort         int32         `description:"Number of HTTP ports allocated." export:"true"`
+	LimitTCPPort          int32         `description:"Number of TCP ports allocated." export:"true"`
+	LimitUDPPort          int32         `description:"Number of UDP ports allocated." export:"true"`
+	ConfigRefreshInterval time.Duration `description:"Window in which the changes are coalesced into a single configuration build, disabled when zero." export:"true"`
+	ResyncPeriod          time.Duration `description:"Period at which the informers resync and the topology is fully rebuilt, disabled when zero." export:"true"`
+	LeaderElection        bool          `description:"Enable the leader election, required to run several controller replicas." export:"true"`
+	NoShadowService       bool          `description:"Disable the shadow services, services are reached directly through their ClusterIP." export:"true"`
+	ShadowTrafficPolicy   string        `description:"Internal traffic policy of the shadow services, either Cluster or Local. The cluster default applies when empty." export:"true"`
+	DefaultMiddlewares    []string      `description:"Middlewares, in the form name or namespace/name, referenced by the routers of every HTTP service unless disabled by its default-middlewares annotation." export:"true"`
+	ZoneAware             bool          `description:"Enable the zone-aware routing of the services annotated with zone-aware, which requires to list and watch the Nodes." export:"true"`
+	ExportFile            string        `description:"Path of a YAML or TOML file the dynamic configuration is exported to, for the Traefik file provider. Disabled when empty." export:"true"`
+	ExportInterval        time.Duration `description:"Interval at which the dynamic configuration is exported to the export file." export:"true"`
+	SettingsConfigMap     string        `description:"Name of a ConfigMap, in the Traefik Mesh namespace, whose logLevel, configRefreshInterval and aclDefaultDeny keys override the matching options at runtime. Disabled when empty." export:"true"`
+	SMILabelSelector      string        `description:"Label selector the TrafficTargets and TrafficSplits must match to be taken into account, all of them when empty." export:"true"`
+	WebhookAddress        string        `description:"Address the admission webhook validating the mesh annotations of the Services listens on over HTTPS, disabled when empty." export:"true"`
+	WebhookCertFile       string        `description:"Path of the TLS certificate of the admission webhook." export:"true"`
+	WebhookKeyFile        string        `description:"Path of the TLS private key of the admission webhook." export:"true"`
 }
 
 // NewConfiguration creates the main command configuration with default values.
 func NewConfiguration() *Configuration {
 	return &Configuration{
-		KubeConfig:    os.Getenv("KUBECONFIG"),
-		LogLevel:      "error",
-		LogFormat:     "common",
-		ACL:           false,
-		DefaultMode:   "http",
-		Namespace:     "default",
-		APIPort:       9000,
-		APIHost:       "",
-		LimitHTTPPort: 10,
-		LimitTCPPort:  25,
-		LimitUDPPort:  25,
+		KubeConfig:            os.Getenv("KUBECONFIG"),
+		LogLevel:              "error",
+		LogFormat:             "common",
+		ACL:                   false,
+		ACLDefaultDeny:        true,
+		DefaultMode:           "http",
+		Namespace:             "default",
+		APIPort:               9000,
+		APIHost:               "",
+		APISocket:             "",
+		Debug:                 false,
+		LimitHTTPPort:         10,
+		LimitTCPPort:          25,
+		LimitUDPPort:          25,
+		ConfigRefreshInterval: 0,
+		ResyncPeriod:          k8s.ResyncPeriod,
+		LeaderElection:        false,
+		NoShadowService:       false,
+		ShadowTrafficPolicy:   "",
+		ZoneAware:             false,
+		ExportFile:            "",
+		ExportInterval:        time.Second,
+		SettingsConfigMap:     "",
+		SMILabelSelector:      "",
+		WebhookAddress:        "",
+		WebhookCertFile:       "",
+		WebhookKeyFile:        "",
 	}
 }
@@ -12,20 +12,31 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/v2/cmd"
+	"github.com/traefik/mesh/v2/cmd/checkpermissions"
 	"github.com/traefik/mesh/v2/cmd/cleanup"
 	"github.com/traefik/mesh/v2/cmd/dns"
+	"github.com/traefik/mesh/v2/cmd/staticconfig"
 	"github.com/traefik/mesh/v2/cmd/version"
+	"github.com/traefik/mesh/v2/pkg/annotations"
 	"github.com/traefik/mesh/v2/pkg/api"
+	"github.com/traefik/mesh/v2/pkg/configfile"
 	"github.com/traefik/mesh/v2/pkg/controller"
 	"github.com/traefik/mesh/v2/pkg/k8s"
 	"github.com/traefik/paerser/cli"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
-	minHTTPPort = int32(5000)
-	minTCPPort  = int32(10000)
-	minUDPPort  = int32(15000)
+	// controllerLeaseName is the name of the Lease used for the controller leader election.
+	controllerLeaseName = "traefik-mesh-controller"
+
+	// webhookPath is the path the admission webhook validating the mesh annotations of the Services is served on.
+	webhookPath = "/validate"
 )
 
 func main() {
@@ -43,7 +54,27 @@ func main() {
 	}
 
 	dnsConfig := dns.NewConfiguration()
-	if err := traefikMeshCmd.AddCommand(dns.NewCmd(dnsConfig, loaders)); err != nil {
+	dnsCmd := dns.NewCmd(dnsConfig, loaders)
+
+	dnsShowConfig := dns.NewShowConfiguration()
+	if err := dnsCmd.AddCommand(dns.NewShowCmd(dnsShowConfig, loaders)); err != nil {
+		stdlog.Println(err)
+		os.Exit(1)
+	}
+
+	dnsCheckConfig := dns.NewCheckConfiguration()
+	if err := dnsCmd.AddCommand(dns.NewCheckCmd(dnsCheckConfig, loaders)); err != nil {
+		stdlog.Println(err)
+		os.Exit(1)
+	}
+
+	dnsManifestConfig := dns.NewConfiguration()
+	if err := dnsCmd.AddCommand(dns.NewManifestCmd(dnsManifestConfig, loaders)); err != nil {
+		stdlog.Println(err)
+		os.Exit(1)
+	}
+
+	if err := traefikMeshCmd.AddCommand(dnsCmd); err != nil {
 		stdlog.Println(err)
 		os.Exit(1)
 	}
@@ -54,6 +85,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	checkPermissionsConfig := checkpermissions.NewConfiguration()
+	if err := traefikMeshCmd.AddCommand(checkpermissions.NewCmd(checkPermissionsConfig, loaders)); err != nil {
+		stdlog.Println(err)
+		os.Exit(1)
+	}
+
+	staticConfigConfig := staticconfig.NewConfiguration()
+	if err := traefikMeshCmd.AddCommand(staticconfig.NewCmd(staticConfigConfig, loaders)); err != nil {
+		stdlog.Println(err)
+		os.Exit(1)
+	}
+
 	if err := traefikMeshCmd.AddCommand(version.NewCmd()); err != nil {
 		stdlog.Println(err)
 		os.Exit(1)
@@ -80,37 +123,102 @@ func traefikMeshCommand(config *Configuration) error {
 	logger.Debugf("Using masterURL: %q", config.MasterURL)
 	logger.Debugf("Using kubeconfig: %q", config.KubeConfig)
 	logger.Debugf("ACL mode enabled: %t", config.ACL)
+	logger.Debugf("Configuration refresh interval: %s", config.ConfigRefreshInterval)
 
 	clients, err := k8s.NewClient(logger, config.MasterURL, config.KubeConfig)
 	if err != nil {
 		return fmt.Errorf("error building clients: %w", err)
 	}
 
-	// Check SMI versions.
-	if err = k8s.CheckSMIVersion(clients.KubernetesClient(), config.ACL); err != nil {
-		return fmt.Errorf("unsupported SMI version: %w", err)
+	var leaderElection *k8s.LeaderElectionConfig
+
+	if config.LeaderElection {
+		identity, hostErr := os.Hostname()
+		if hostErr != nil {
+			return fmt.Errorf("unable to get leader election identity: %w", hostErr)
+		}
+
+		leaderElectionCfg := k8s.NewLeaderElectionConfig(config.Namespace, controllerLeaseName, identity)
+		leaderElection = &leaderElectionCfg
+	}
+
+	defaultMiddlewares := make([]annotations.MiddlewareRef, 0, len(config.DefaultMiddlewares))
+
+	for _, middleware := range config.DefaultMiddlewares {
+		ref, refErr := annotations.ParseMiddlewareRef(middleware)
+		if refErr != nil {
+			return fmt.Errorf("invalid default middleware: %w", refErr)
+		}
+
+		defaultMiddlewares = append(defaultMiddlewares, ref)
+	}
+
+	shadowTrafficPolicy, err := getShadowTrafficPolicy(config.ShadowTrafficPolicy)
+	if err != nil {
+		return err
+	}
+
+	if _, err = labels.Parse(config.SMILabelSelector); err != nil {
+		return fmt.Errorf("invalid SMI label selector %q: %w", config.SMILabelSelector, err)
+	}
+
+	if config.WebhookAddress != "" && (config.WebhookCertFile == "" || config.WebhookKeyFile == "") {
+		return errors.New("the webhook certificate and key files are required to serve the admission webhook")
 	}
 
 	// Start controller and API server.
-	apiServer := api.NewAPI(logger, config.APIPort, config.APIHost, config.Namespace)
+	apiServer := api.NewAPI(logger, config.APIPort, config.APIHost, config.Namespace, config.Debug)
+
+	metricsRegistry := prometheus.NewRegistry()
+	apiServer.EnableMetrics(metricsRegistry)
+
+	var (
+		store        controller.SharedStore = apiServer
+		exportWriter *configfile.Writer
+	)
+
+	if config.ExportFile != "" {
+		if config.ExportInterval <= 0 {
+			return fmt.Errorf("invalid export interval %s, it must be positive", config.ExportInterval)
+		}
+
+		exportWriter, err = configfile.NewWriter(logger, config.ExportFile)
+		if err != nil {
+			return fmt.Errorf("unable to create export file writer: %w", err)
+		}
+
+		store = exportingStore{API: apiServer, writer: exportWriter}
+	}
 
 	ctr := controller.NewMeshController(clients, controller.Config{
-		ACLEnabled:       config.ACL,
-		DefaultMode:      config.DefaultMode,
-		Namespace:        config.Namespace,
-		WatchNamespaces:  config.WatchNamespaces,
-		IgnoreNamespaces: config.IgnoreNamespaces,
-		MinHTTPPort:      minHTTPPort,
-		MaxHTTPPort:      getMaxPort(minHTTPPort, config.LimitHTTPPort),
-		MinTCPPort:       minTCPPort,
-		MaxTCPPort:       getMaxPort(minTCPPort, config.LimitTCPPort),
-		MinUDPPort:       minUDPPort,
-		MaxUDPPort:       getMaxPort(minUDPPort, config.LimitUDPPort),
-	}, apiServer, logger)
+		ACLEnabled:            config.ACL,
+		ACLSourceHeader:       config.ACLSourceHeader,
+		ACLDefaultAllow:       !config.ACLDefaultDeny,
+		DefaultMode:           config.DefaultMode,
+		Namespace:             config.Namespace,
+		WatchNamespaces:       config.WatchNamespaces,
+		IgnoreNamespaces:      config.IgnoreNamespaces,
+		MinHTTPPort:           cmd.MinHTTPPort,
+		MaxHTTPPort:           getMaxPort(cmd.MinHTTPPort, config.LimitHTTPPort),
+		MinTCPPort:            cmd.MinTCPPort,
+		MaxTCPPort:            getMaxPort(cmd.MinTCPPort, config.LimitTCPPort),
+		MinUDPPort:            cmd.MinUDPPort,
+		MaxUDPPort:            getMaxPort(cmd.MinUDPPort, config.LimitUDPPort),
+		ConfigRefreshInterval: config.ConfigRefreshInterval,
+		ResyncPeriod:          config.ResyncPeriod,
+		NoShadowService:       config.NoShadowService,
+		ShadowTrafficPolicy:   shadowTrafficPolicy,
+		ZoneAware:             config.ZoneAware,
+		DefaultMiddlewares:    defaultMiddlewares,
+		LeaderElection:        leaderElection,
+		MetricsRegisterer:     metricsRegistry,
+		SettingsConfigMap:     config.SettingsConfigMap,
+		SMILabelSelector:      config.SMILabelSelector,
+	}, store, logger)
 
 	var wg sync.WaitGroup
 
-	apiErrCh := make(chan error, 1)
+	apiErrCh := make(chan error, 3)
 	ctrlErrCh := make(chan error, 1)
 
 	// Start the API server.
@@ -124,6 +232,47 @@ func traefikMeshCommand(config *Configuration) error {
 		}
 	}()
 
+	// Start the API server on the Unix domain socket.
+	if config.APISocket != "" {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := apiServer.ListenAndServeUnix(config.APISocket); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				apiErrCh <- fmt.Errorf("API server has stopped serving on socket %q: %w", config.APISocket, err)
+			}
+		}()
+	}
+
+	// Start the admission webhook server.
+	var webhookServer *http.Server
+
+	if config.WebhookAddress != "" {
+		webhookServer = newWebhookServer(logger, config.WebhookAddress)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := webhookServer.ListenAndServeTLS(config.WebhookCertFile, config.WebhookKeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				apiErrCh <- fmt.Errorf("admission webhook server has stopped unexpectedly: %w", err)
+			}
+		}()
+	}
+
+	// Start exporting the configuration to the export file.
+	if exportWriter != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			exportWriter.Run(ctx, config.ExportInterval)
+		}()
+	}
+
 	// Start the Controller.
 	wg.Add(1)
 
@@ -144,11 +293,19 @@ func traefikMeshCommand(config *Configuration) error {
 			return fmt.Errorf("unable to stop the API server: %w", err)
 		}
 
+		if err := stopWebhookServer(webhookServer); err != nil {
+			return fmt.Errorf("unable to stop the admission webhook server: %w", err)
+		}
+
 	case err := <-ctrlErrCh:
 		if stopErr := stopAPIServer(apiServer); stopErr != nil {
 			logger.Errorf("Unable to stop the API server: %v", stopErr)
 		}
 
+		if stopErr := stopWebhookServer(webhookServer); stopErr != nil {
+			logger.Errorf("Unable to stop the admission webhook server: %v", stopErr)
+		}
+
 		return err
 
 	case err := <-apiErrCh:
@@ -168,6 +325,55 @@ func stopAPIServer(apiServer *api.API) error {
 	return apiServer.Shutdown(ctx)
 }
 
+// newWebhookServer creates the server of the admission webhook validating the mesh annotations of the Services.
+func newWebhookServer(logger logrus.FieldLogger, address string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(webhookPath, controller.NewAnnotationsWebhook(logger))
+
+	return &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// stopWebhookServer stops the given admission webhook server, if any.
+func stopWebhookServer(server *http.Server) error {
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
+// exportingStore shares the controller state through the API, and the configuration through the export file too.
+type exportingStore struct {
+	*api.API
+
+	writer *configfile.Writer
+}
+
+// SetConfiguration sets the current dynamic configuration of the API and of the export file.
+func (s exportingStore) SetConfiguration(cfg *dynamic.Configuration) {
+	s.API.SetConfiguration(cfg)
+	s.writer.SetConfiguration(cfg)
+}
+
 func getMaxPort(min, limit int32) int32 {
 	return min + limit - 1
 }
+
+// getShadowTrafficPolicy returns the given internal traffic policy of the shadow services, nil when empty.
+func getShadowTrafficPolicy(policy string) (*corev1.ServiceInternalTrafficPolicyType, error) {
+	switch trafficPolicy := corev1.ServiceInternalTrafficPolicyType(policy); trafficPolicy {
+	case "":
+		return nil, nil
+	case corev1.ServiceInternalTrafficPolicyCluster, corev1.ServiceInternalTrafficPolicyLocal:
+		return &trafficPolicy, nil
+	default:
+		return nil, fmt.Errorf("invalid shadow traffic policy %q, it must be either %s or %s", policy, corev1.ServiceInternalTrafficPolicyCluster, corev1.ServiceInternalTrafficPolicyLocal)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNewWebhookServer(t *testing.T) {
+	tests := []struct {
+		desc        string
+		path        string
+		annotations map[string]string
+		wantStatus  int
+		wantAllowed bool
+	}{
+		{
+			desc: "should allow a Service with valid annotations",
+			path: webhookPath,
+			annotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "2",
+			},
+			wantStatus:  http.StatusOK,
+			wantAllowed: true,
+		},
+		{
+			desc: "should deny a Service with invalid annotations",
+			path: webhookPath,
+			annotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "two",
+			},
+			wantStatus:  http.StatusOK,
+			wantAllowed: false,
+		},
+		{
+			desc: "should not serve the webhook on other paths",
+			path: "/",
+			annotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "two",
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			server := httptest.NewUnstartedServer(newWebhookServer(logger, "").Handler)
+			server.StartTLS()
+			defer server.Close()
+
+			svc, err := json.Marshal(&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   "ns",
+					Annotations: test.annotations,
+				},
+			})
+			require.NoError(t, err)
+
+			body, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       "uid",
+					Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Service"},
+					Namespace: "ns",
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: svc},
+				},
+			})
+			require.NoError(t, err)
+
+			resp, err := server.Client().Post(server.URL+test.path, "application/json", bytes.NewReader(body))
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, test.wantStatus, resp.StatusCode)
+
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+
+			var review admissionv1.AdmissionReview
+
+			err = json.NewDecoder(resp.Body).Decode(&review)
+			require.NoError(t, err)
+			require.NotNil(t, review.Response)
+
+			assert.Equal(t, "uid", string(review.Response.UID))
+			assert.Equal(t, test.wantAllowed, review.Response.Allowed)
+		})
+	}
+}
+
+func TestStopWebhookServer_Disabled(t *testing.T) {
+	assert.NoError(t, stopWebhookServer(nil))
+}
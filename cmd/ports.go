@@ -0,0 +1,8 @@
+package cmd
+
+// First ports of the ranges allocated to HTTP, TCP and UDP services on the Traefik Mesh proxies.
+const (
+	MinHTTPPort = int32(5000)
+	MinTCPPort  = int32(10000)
+	MinUDPPort  = int32(15000)
+)
@@ -0,0 +1,28 @@
+package staticconfig
+
+// Configuration holds the configuration for the static-config command.
+type Configuration struct {
+	Namespace         string `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	ControllerService string `description:"The name of the Service exposing the controller API." export:"true"`
+	ClusterDomain     string `description:"The domain of the cluster." export:"true"`
+	APIPort           int32  `description:"API port for the controller." export:"true"`
+	LimitHTTPPort     int32  `description:"Number of HTTP ports allocated." export:"true"`
+	LimitTCPPort      int32  `description:"Number of TCP ports allocated." export:"true"`
+	LimitUDPPort      int32  `description:"Number of UDP ports allocated." export:"true"`
+	Zone              string `description:"Zone of the proxies, which get the configuration of this zone for the zone-aware services." export:"true"`
+
+	ForwardedHeadersTrustedIPs []string `description:"IPs and CIDRs from which the X-Forwarded-* headers are trusted by the HTTP entrypoints, all of them when empty." export:"true"`
+}
+
+// NewConfiguration creates a new static-config configuration with default values.
+func NewConfiguration() *Configuration {
+	return &Configuration{
+		Namespace:         "default",
+		ControllerService: "traefik-mesh-controller",
+		ClusterDomain:     "cluster.local",
+		APIPort:           9000,
+		LimitHTTPPort:     10,
+		LimitTCPPort:      25,
+		LimitUDPPort:      25,
+	}
+}
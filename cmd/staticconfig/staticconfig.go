@@ -0,0 +1,125 @@
+package staticconfig
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"text/template"
+
+	"github.com/traefik/mesh/v2/cmd"
+	"github.com/traefik/paerser/cli"
+)
+
+// staticConfigTemplate is the Traefik static configuration of the Traefik Mesh proxies. The readiness entrypoint
+// serves the readiness router of the dynamic configuration, which forwards to the Traefik ping endpoint. The
+// X-Forwarded-* headers of the requests are trusted from any client, unless trusted IPs are given.
+var staticConfigTemplate = template.Must(template.New("static-config").Parse(`entryPoints:
+  readiness:
+    address: ":1081"
+  liveness:
+    address: ":1082"
+{{- range .HTTPPorts }}
+  http-{{ . }}:
+    address: ":{{ . }}"
+    forwardedHeaders:
+{{- if $.TrustedIPs }}
+      trustedIPs:
+{{- range $.TrustedIPs }}
+        - "{{ . }}"
+{{- end }}
+{{- else }}
+      insecure: true
+{{- end }}
+{{- end }}
+{{- range .TCPPorts }}
+  tcp-{{ . }}:
+    address: ":{{ . }}"
+{{- end }}
+{{- range .UDPPorts }}
+  udp-{{ . }}:
+    address: ":{{ . }}/udp"
+{{- end }}
+
+providers:
+  http:
+    endpoint: "{{ .Endpoint }}"
+    pollInterval: 100ms
+    pollTimeout: 100ms
+
+api:
+  dashboard: false
+  insecure: true
+
+ping: {}
+`))
+
+// NewCmd builds a new static-config command.
+func NewCmd(config *Configuration, loaders []cli.ResourceLoader) *cli.Command {
+	return &cli.Command{
+		Name:          "static-config",
+		Description:   `Shows the Traefik static configuration of the Traefik Mesh proxies matching the controller configuration.`,
+		Configuration: config,
+		Run: func(_ []string) error {
+			return writeStaticConfig(os.Stdout, config)
+		},
+		Resources: loaders,
+	}
+}
+
+// writeStaticConfig writes the Traefik static configuration of the proxies for the given configuration.
+func writeStaticConfig(w io.Writer, config *Configuration) error {
+	for _, trustedIP := range config.ForwardedHeadersTrustedIPs {
+		if !isIPOrCIDR(trustedIP) {
+			return fmt.Errorf("invalid forwarded headers trusted IP %q: must be an IP or a CIDR", trustedIP)
+		}
+	}
+
+	data := struct {
+		HTTPPorts  []int32
+		TCPPorts   []int32
+		UDPPorts   []int32
+		Endpoint   string
+		TrustedIPs []string
+	}{
+		HTTPPorts:  getPorts(cmd.MinHTTPPort, config.LimitHTTPPort),
+		TCPPorts:   getPorts(cmd.MinTCPPort, config.LimitTCPPort),
+		UDPPorts:   getPorts(cmd.MinUDPPort, config.LimitUDPPort),
+		TrustedIPs: config.ForwardedHeadersTrustedIPs,
+		Endpoint: fmt.Sprintf("http://%s.%s.svc.%s:%d/api/configuration",
+			config.ControllerService, config.Namespace, config.ClusterDomain, config.APIPort),
+	}
+
+	if config.Zone != "" {
+		data.Endpoint += "?zone=" + url.QueryEscape(config.Zone)
+	}
+
+	if err := staticConfigTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("unable to write static configuration: %w", err)
+	}
+
+	return nil
+}
+
+// getPorts returns the limit ports starting at min.
+func getPorts(min, limit int32) []int32 {
+	var ports []int32
+
+	for port := min; port < min+limit; port++ {
+		ports = append(ports, port)
+	}
+
+	return ports
+}
+
+// isIPOrCIDR returns true if the given value is an IP or a CIDR, false otherwise.
+func isIPOrCIDR(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+
+	_, _, err := net.ParseCIDR(value)
+
+	return err == nil
+}
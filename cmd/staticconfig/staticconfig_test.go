@@ -0,0 +1,99 @@
+package staticconfig
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStaticConfig(t *testing.T) {
+	config := NewConfiguration()
+	config.Namespace = "traefik-mesh"
+	config.APIPort = 9500
+	config.LimitHTTPPort = 2
+	config.LimitTCPPort = 1
+	config.LimitUDPPort = 0
+
+	var buf bytes.Buffer
+
+	err := writeStaticConfig(&buf, config)
+	require.NoError(t, err)
+
+	want := `entryPoints:
+  readiness:
+    address: ":1081"
+  liveness:
+    address: ":1082"
+  http-5000:
+    address: ":5000"
+    forwardedHeaders:
+      insecure: true
+  http-5001:
+    address: ":5001"
+    forwardedHeaders:
+      insecure: true
+  tcp-10000:
+    address: ":10000"
+
+providers:
+  http:
+    endpoint: "http://traefik-mesh-controller.traefik-mesh.svc.cluster.local:9500/api/configuration"
+    pollInterval: 100ms
+    pollTimeout: 100ms
+
+api:
+  dashboard: false
+  insecure: true
+
+ping: {}
+`
+
+	assert.Equal(t, want, buf.String())
+}
+
+func TestWriteStaticConfigWithZone(t *testing.T) {
+	config := NewConfiguration()
+	config.Namespace = "traefik-mesh"
+	config.Zone = "eu-west-1a"
+
+	var buf bytes.Buffer
+
+	err := writeStaticConfig(&buf, config)
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(),
+		`endpoint: "http://traefik-mesh-controller.traefik-mesh.svc.cluster.local:9000/api/configuration?zone=eu-west-1a"`)
+}
+
+func TestWriteStaticConfigWithForwardedHeadersTrustedIPs(t *testing.T) {
+	config := NewConfiguration()
+	config.LimitHTTPPort = 1
+	config.ForwardedHeadersTrustedIPs = []string{"10.42.0.0/16", "192.168.1.7"}
+
+	var buf bytes.Buffer
+
+	err := writeStaticConfig(&buf, config)
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `  http-5000:
+    address: ":5000"
+    forwardedHeaders:
+      trustedIPs:
+        - "10.42.0.0/16"
+        - "192.168.1.7"
+`)
+	assert.NotContains(t, buf.String(), "insecure: true")
+}
+
+func TestWriteStaticConfigWithInvalidForwardedHeadersTrustedIP(t *testing.T) {
+	config := NewConfiguration()
+	config.ForwardedHeadersTrustedIPs = []string{"10.42.0.0/16", "not-an-ip"}
+
+	var buf bytes.Buffer
+
+	err := writeStaticConfig(&buf, config)
+	require.Error(t, err)
+	assert.Empty(t, buf.String())
+}
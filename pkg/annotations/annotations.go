@@ -3,7 +3,14 @@ package annotations
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
@@ -20,6 +27,9 @@ const (
 	SchemeH2C string = "h2c"
 	// SchemeHTTPS HTTPS scheme.
 	SchemeHTTPS string = "https"
+
+	// LBStrategyRoundRobin round-robin load-balancing strategy.
+	LBStrategyRoundRobin string = "round-robin"
 )
 
 const (
@@ -30,11 +40,91 @@ const (
 	annotationCircuitBreakerExpression = baseAnnotation + "circuit-breaker-expression"
 	annotationRateLimitAverage         = baseAnnotation + "ratelimit-average"
 	annotationRateLimitBurst           = baseAnnotation + "ratelimit-burst"
+	annotationIgnore                   = baseAnnotation + "ignore"
+	annotationIgnorePorts              = baseAnnotation + "ignore-ports"
+	annotationTrafficSplitBackends     = baseAnnotation + "traffic-split-backends"
+	annotationStickyCookieName         = baseAnnotation + "sticky-cookie-name"
+	annotationStickyCookieSecure       = baseAnnotation + "sticky-cookie-secure"
+	annotationStickyCookieHTTPOnly     = baseAnnotation + "sticky-cookie-httponly"
+	annotationTrafficSplitStickyCookie = baseAnnotation + "traffic-split-sticky-cookie-name"
+	annotationHealthCheckPath          = baseAnnotation + "healthcheck-path"
+	annotationHealthCheckInterval      = baseAnnotation + "healthcheck-interval"
+	annotationTLSPassthrough           = baseAnnotation + "tls-passthrough"
+	annotationMaxConn                  = baseAnnotation + "max-conn"
+	annotationMaxRequestBodyBytes      = baseAnnotation + "max-request-body-bytes"
+	annotationMiddlewares              = baseAnnotation + "middlewares"
+	annotationDefaultMiddlewares       = baseAnnotation + "default-middlewares"
+	annotationAccessLog                = baseAnnotation + "access-log"
+	annotationForceHTTPS               = baseAnnotation + "force-https"
+	annotationCompress                 = baseAnnotation + "compress"
+	annotationPassHostHeader           = baseAnnotation + "pass-host-header"
+	annotationFlushInterval            = baseAnnotation + "response-forwarding-flush-interval"
+	annotationDialTimeout              = baseAnnotation + "dial-timeout"
+	annotationResponseHeaderTimeout    = baseAnnotation + "response-header-timeout"
+	annotationIdleConnTimeout          = baseAnnotation + "idle-conn-timeout"
+	annotationMaxIdleConnsPerHost      = baseAnnotation + "max-idle-conns-per-host"
+	annotationDisableHTTP2             = baseAnnotation + "disable-http2"
+	annotationMirrorService            = baseAnnotation + "mirror-service"
+	annotationEntryPoints              = baseAnnotation + "entrypoints"
+	annotationStripPrefix              = baseAnnotation + "strip-prefix"
+	annotationTLSInsecure              = baseAnnotation + "tls-insecure"
+	annotationTLSServerName            = baseAnnotation + "tls-servername"
+	annotationLBStrategy               = baseAnnotation + "lb-strategy"
+	annotationCORSAllowOrigin          = baseAnnotation + "cors-allow-origin"
+	annotationCORSAllowMethods         = baseAnnotation + "cors-allow-methods"
+	annotationBasicAuthSecret          = baseAnnotation + "basic-auth-secret"
+	annotationHeaderRoute              = baseAnnotation + "header-route"
+	annotationRouterRule               = baseAnnotation + "router-rule"
+	annotationZoneAware                = baseAnnotation + "zone-aware"
 )
 
+// middlewareAnnotations are the annotations which only configure the middlewares of a service.
+var middlewareAnnotations = map[string]struct{}{
+	annotationRetryAttempts:            {},
+	annotationCircuitBreakerExpression: {},
+	annotationRateLimitAverage:         {},
+	annotationRateLimitBurst:           {},
+	annotationMaxConn:                  {},
+	annotationMaxRequestBodyBytes:      {},
+	annotationMiddlewares:              {},
+	annotationDefaultMiddlewares:       {},
+	annotationAccessLog:                {},
+	annotationForceHTTPS:               {},
+	annotationCompress:                 {},
+	annotationStripPrefix:              {},
+	annotationCORSAllowOrigin:          {},
+	annotationCORSAllowMethods:         {},
+	annotationBasicAuthSecret:          {},
+}
+
 // ErrNotFound indicates that the annotation hasn't been found.
 var ErrNotFound = errors.New("annotation not found")
 
+// OnlyMiddlewaresChanged returns true if the given annotations differ, and only by annotations configuring the
+// middlewares of a service.
+func OnlyMiddlewaresChanged(oldAnnotations, newAnnotations map[string]string) bool {
+	var changed bool
+
+	for _, annotations := range []map[string]string{oldAnnotations, newAnnotations} {
+		for name := range annotations {
+			oldValue, oldExists := oldAnnotations[name]
+			newValue, newExists := newAnnotations[name]
+
+			if oldExists == newExists && oldValue == newValue {
+				continue
+			}
+
+			if _, ok := middlewareAnnotations[name]; !ok {
+				return false
+			}
+
+			changed = true
+		}
+	}
+
+	return changed
+}
+
 // GetTrafficType returns the value of the traffic-type annotation.
 func GetTrafficType(annotations map[string]string) (string, error) {
 	trafficType, exists := annotations[annotationServiceType]
@@ -58,6 +148,75 @@ func SetTrafficType(trafficType string, annotations map[string]string) {
 	annotations[annotationServiceType] = trafficType
 }
 
+// IsIgnored returns true if the ignore annotation is set to true, meaning the service must not be part of the mesh.
+func IsIgnored(annotations map[string]string) (bool, error) {
+	ignore, exists := annotations[annotationIgnore]
+	if !exists {
+		return false, nil
+	}
+
+	ignored, err := strconv.ParseBool(ignore)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q: %w", annotationIgnore, err)
+	}
+
+	return ignored, nil
+}
+
+// IgnoredPorts holds the service ports excluded from the mesh by the ignore-ports annotation, by name and number.
+type IgnoredPorts struct {
+	names   map[string]struct{}
+	numbers map[int32]struct{}
+}
+
+// Contains returns true if the service port with the given name and number is ignored.
+func (p IgnoredPorts) Contains(name string, port int32) bool {
+	if _, ok := p.numbers[port]; ok {
+		return true
+	}
+
+	_, ok := p.names[name]
+
+	return ok
+}
+
+// GetIgnoredPorts returns the ports listed in the ignore-ports annotation. The annotation holds a comma separated list
+// of service port names or numbers.
+func GetIgnoredPorts(annotations map[string]string) (IgnoredPorts, error) {
+	rawPorts, exists := annotations[annotationIgnorePorts]
+	if !exists {
+		return IgnoredPorts{}, ErrNotFound
+	}
+
+	ports := IgnoredPorts{
+		names:   make(map[string]struct{}),
+		numbers: make(map[int32]struct{}),
+	}
+
+	for _, rawPort := range strings.Split(rawPorts, ",") {
+		rawPort = strings.TrimSpace(rawPort)
+
+		port, err := strconv.ParseInt(rawPort, 10, 32)
+		if err != nil {
+			if errs := validation.IsValidPortName(rawPort); len(errs) > 0 {
+				return IgnoredPorts{}, fmt.Errorf("invalid value %q: port %q must be a port name or number: %s", annotationIgnorePorts, rawPort, strings.Join(errs, ", "))
+			}
+
+			ports.names[rawPort] = struct{}{}
+
+			continue
+		}
+
+		if port <= 0 || port > 65535 {
+			return IgnoredPorts{}, fmt.Errorf("invalid value %q: port %d is out of range", annotationIgnorePorts, port)
+		}
+
+		ports.numbers[int32(port)] = struct{}{}
+	}
+
+	return ports, nil
+}
+
 // GetScheme returns the value of the scheme annotation.
 func GetScheme(annotations map[string]string) (string, error) {
 	scheme, exists := annotations[annotationScheme]
@@ -76,6 +235,24 @@ func GetScheme(annotations map[string]string) (string, error) {
 	return scheme, nil
 }
 
+// GetLBStrategy returns the value of the lb-strategy annotation, the round-robin strategy if the annotation is not set.
+// The annotation is reserved for future strategies, round-robin being the only one of the Traefik v2 servers
+// load-balancer, so it is only validated.
+func GetLBStrategy(annotations map[string]string) (string, error) {
+	strategy, exists := annotations[annotationLBStrategy]
+	if !exists {
+		return LBStrategyRoundRobin, nil
+	}
+
+	switch strategy {
+	case LBStrategyRoundRobin:
+	default:
+		return strategy, fmt.Errorf("unsupported load-balancing strategy %q: %q", annotationLBStrategy, strategy)
+	}
+
+	return strategy, nil
+}
+
 // GetRetryAttempts returns the value of the retry-attempts annotation.
 func GetRetryAttempts(annotations map[string]string) (int, error) {
 	retryAttempts, exists := annotations[annotationRetryAttempts]
@@ -91,6 +268,44 @@ func GetRetryAttempts(annotations map[string]string) (int, error) {
 	return attempts, nil
 }
 
+// GetMaxConn returns the value of the max-conn annotation.
+func GetMaxConn(annotations map[string]string) (int, error) {
+	maxConn, exists := annotations[annotationMaxConn]
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	amount, err := strconv.Atoi(maxConn)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", annotationMaxConn, err)
+	}
+
+	if amount <= 0 {
+		return 0, fmt.Errorf("invalid value %q: %d must be greater than 0", annotationMaxConn, amount)
+	}
+
+	return amount, nil
+}
+
+// GetMaxRequestBodyBytes returns the value of the max-request-body-bytes annotation.
+func GetMaxRequestBodyBytes(annotations map[string]string) (int64, error) {
+	maxRequestBodyBytes, exists := annotations[annotationMaxRequestBodyBytes]
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	size, err := strconv.ParseInt(maxRequestBodyBytes, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", annotationMaxRequestBodyBytes, err)
+	}
+
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid value %q: %d must be greater than 0", annotationMaxRequestBodyBytes, size)
+	}
+
+	return size, nil
+}
+
 // GetCircuitBreakerExpression returns the value of the circuit-breaker-expression annotation.
 func GetCircuitBreakerExpression(annotations map[string]string) (string, error) {
 	circuitBreakerExpression, exists := annotations[annotationCircuitBreakerExpression]
@@ -130,3 +345,604 @@ func GetRateLimitAverage(annotations map[string]string) (int, error) {
 
 	return average, nil
 }
+
+// TrafficSplitBackend is a backend of a traffic split defined with the traffic-split-backends annotation.
+type TrafficSplitBackend struct {
+	Service string
+	// Namespace of the backend service. Empty when the service lives in the namespace of the annotated service.
+	Namespace string
+	Weight    int
+}
+
+// GetTrafficSplitBackends returns the value of the traffic-split-backends annotation. The annotation holds a comma
+// separated list of `service:weight` pairs, where each service lives in the namespace of the annotated service unless
+// it is in the form `name.namespace`. A backend can have a zero weight, but at least one of them must have a positive
+// weight.
+func GetTrafficSplitBackends(annotations map[string]string) ([]TrafficSplitBackend, error) {
+	trafficSplitBackends, exists := annotations[annotationTrafficSplitBackends]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var (
+		backends    []TrafficSplitBackend
+		totalWeight int
+	)
+
+	services := make(map[string]struct{})
+
+	for _, backend := range strings.Split(trafficSplitBackends, ",") {
+		parts := strings.Split(strings.TrimSpace(backend), ":")
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid value %q: backend %q must be in the form service:weight", annotationTrafficSplitBackends, backend)
+		}
+
+		if _, exists = services[parts[0]]; exists {
+			return nil, fmt.Errorf("invalid value %q: backend %q is defined more than once", annotationTrafficSplitBackends, parts[0])
+		}
+
+		services[parts[0]] = struct{}{}
+
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q: %w", annotationTrafficSplitBackends, err)
+		}
+
+		if weight < 0 {
+			return nil, fmt.Errorf("invalid value %q: backend %q must have a non-negative weight", annotationTrafficSplitBackends, parts[0])
+		}
+
+		totalWeight += weight
+
+		tsBackend := TrafficSplitBackend{
+			Service: parts[0],
+			Weight:  weight,
+		}
+
+		if svcParts := strings.SplitN(parts[0], ".", 2); len(svcParts) == 2 {
+			tsBackend.Service, tsBackend.Namespace = svcParts[0], svcParts[1]
+		}
+
+		backends = append(backends, tsBackend)
+	}
+
+	if totalWeight == 0 {
+		return nil, fmt.Errorf("invalid value %q: at least one backend must have a positive weight", annotationTrafficSplitBackends)
+	}
+
+	return backends, nil
+}
+
+// Mirror is the service requests are mirrored to, defined with the mirror-service annotation.
+type Mirror struct {
+	Service string
+	// Namespace of the mirror service. Empty when the service lives in the namespace of the annotated service.
+	Namespace string
+	// Percent is the percentage of requests mirrored to the service.
+	Percent int
+}
+
+// GetMirror returns the value of the mirror-service annotation. The annotation is in the form `service[:percent]`,
+// where the service lives in the namespace of the annotated service unless it is in the form `name.namespace`. All
+// requests are mirrored when the percentage is omitted.
+func GetMirror(annotations map[string]string) (Mirror, error) {
+	mirrorService, exists := annotations[annotationMirrorService]
+	if !exists {
+		return Mirror{}, ErrNotFound
+	}
+
+	parts := strings.Split(strings.TrimSpace(mirrorService), ":")
+	if len(parts) > 2 || parts[0] == "" {
+		return Mirror{}, fmt.Errorf("invalid value %q: mirror %q must be in the form service[:percent]", annotationMirrorService, mirrorService)
+	}
+
+	mirror := Mirror{
+		Service: parts[0],
+		Percent: 100,
+	}
+
+	if len(parts) == 2 {
+		percent, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return Mirror{}, fmt.Errorf("invalid value %q: %w", annotationMirrorService, err)
+		}
+
+		if percent < 0 || percent > 100 {
+			return Mirror{}, fmt.Errorf("invalid value %q: percent must be between 0 and 100", annotationMirrorService)
+		}
+
+		mirror.Percent = percent
+	}
+
+	if svcParts := strings.SplitN(parts[0], ".", 2); len(svcParts) == 2 {
+		mirror.Service, mirror.Namespace = svcParts[0], svcParts[1]
+	}
+
+	return mirror, nil
+}
+
+// HeaderRoute is the service the requests carrying a header value are routed to, defined with the header-route
+// annotation.
+type HeaderRoute struct {
+	Header  string
+	Value   string
+	Service string
+	// Namespace of the service. Empty when the service lives in the namespace of the annotated service.
+	Namespace string
+}
+
+// headerNameRegexp matches the HTTP header names which can be used in a router rule.
+var headerNameRegexp = regexp.MustCompile("^[!#$%&'*+.^_|~0-9A-Za-z-]+$")
+
+// GetHeaderRoute returns the value of the header-route annotation. The annotation is in the form
+// `header=value:service`, where the service lives in the namespace of the annotated service unless it is in the form
+// `name.namespace`.
+func GetHeaderRoute(annotations map[string]string) (HeaderRoute, error) {
+	headerRoute, exists := annotations[annotationHeaderRoute]
+	if !exists {
+		return HeaderRoute{}, ErrNotFound
+	}
+
+	headerRoute = strings.TrimSpace(headerRoute)
+
+	sep := strings.LastIndex(headerRoute, ":")
+	if sep < 0 {
+		return HeaderRoute{}, fmt.Errorf("invalid value %q: route %q must be in the form header=value:service", annotationHeaderRoute, headerRoute)
+	}
+
+	match, service := headerRoute[:sep], strings.TrimSpace(headerRoute[sep+1:])
+
+	parts := strings.SplitN(match, "=", 2)
+	if len(parts) != 2 || service == "" {
+		return HeaderRoute{}, fmt.Errorf("invalid value %q: route %q must be in the form header=value:service", annotationHeaderRoute, headerRoute)
+	}
+
+	route := HeaderRoute{
+		Header:  strings.TrimSpace(parts[0]),
+		Value:   strings.TrimSpace(parts[1]),
+		Service: service,
+	}
+
+	if !headerNameRegexp.MatchString(route.Header) {
+		return HeaderRoute{}, fmt.Errorf("invalid value %q: invalid header name %q", annotationHeaderRoute, route.Header)
+	}
+
+	if route.Value == "" || strings.ContainsAny(route.Value, "`\r\n") {
+		return HeaderRoute{}, fmt.Errorf("invalid value %q: header value %q must be non-empty and must not contain backquotes or line breaks", annotationHeaderRoute, route.Value)
+	}
+
+	if svcParts := strings.SplitN(service, ".", 2); len(svcParts) == 2 {
+		route.Service, route.Namespace = svcParts[0], svcParts[1]
+	}
+
+	return route, nil
+}
+
+// GetRouterRule returns the value of the router-rule annotation, a raw Traefik rule replacing the rule matching the
+// service host.
+func GetRouterRule(annotations map[string]string) (string, error) {
+	rule, exists := annotations[annotationRouterRule]
+	if !exists {
+		return "", ErrNotFound
+	}
+
+	rule = strings.TrimSpace(rule)
+	if rule == "" {
+		return "", fmt.Errorf("invalid value %q: rule must not be empty", annotationRouterRule)
+	}
+
+	return rule, nil
+}
+
+// GetStickyCookieName returns the value of the sticky-cookie-name annotation.
+func GetStickyCookieName(annotations map[string]string) (string, error) {
+	name, exists := annotations[annotationStickyCookieName]
+	if !exists {
+		return "", ErrNotFound
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("invalid value %q: cookie name must not be empty", annotationStickyCookieName)
+	}
+
+	return name, nil
+}
+
+// GetTrafficSplitStickyCookieName returns the value of the traffic-split-sticky-cookie-name annotation.
+func GetTrafficSplitStickyCookieName(annotations map[string]string) (string, error) {
+	name, exists := annotations[annotationTrafficSplitStickyCookie]
+	if !exists {
+		return "", ErrNotFound
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("invalid value %q: cookie name must not be empty", annotationTrafficSplitStickyCookie)
+	}
+
+	return name, nil
+}
+
+// IsStickyCookieSecure returns true if the sticky-cookie-secure annotation is set to true.
+func IsStickyCookieSecure(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationStickyCookieSecure)
+}
+
+// IsStickyCookieHTTPOnly returns true if the sticky-cookie-httponly annotation is set to true.
+func IsStickyCookieHTTPOnly(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationStickyCookieHTTPOnly)
+}
+
+// GetHealthCheckPath returns the value of the healthcheck-path annotation.
+func GetHealthCheckPath(annotations map[string]string) (string, error) {
+	path, exists := annotations[annotationHealthCheckPath]
+	if !exists {
+		return "", ErrNotFound
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("invalid value %q: path %q must start with a slash", annotationHealthCheckPath, path)
+	}
+
+	return path, nil
+}
+
+// GetStripPrefixes returns the prefixes listed in the strip-prefix annotation, in the order they are defined.
+func GetStripPrefixes(annotations map[string]string) ([]string, error) {
+	rawPrefixes, exists := annotations[annotationStripPrefix]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var prefixes []string
+
+	for _, prefix := range strings.Split(rawPrefixes, ",") {
+		prefix = strings.TrimSpace(prefix)
+
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid value %q: prefix %q must start with a slash", annotationStripPrefix, prefix)
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// GetCORSAllowOrigins returns the origins listed in the cors-allow-origin annotation. Each origin is either "*" or an
+// http or https origin without a path.
+func GetCORSAllowOrigins(annotations map[string]string) ([]string, error) {
+	rawOrigins, exists := annotations[annotationCORSAllowOrigin]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var origins []string
+
+	for _, origin := range strings.Split(rawOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+
+		if origin != "*" {
+			u, err := url.Parse(origin)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+				return nil, fmt.Errorf("invalid value %q: origin %q must be \"*\" or an http(s) scheme and host", annotationCORSAllowOrigin, origin)
+			}
+		}
+
+		origins = append(origins, origin)
+	}
+
+	return origins, nil
+}
+
+// corsMethods are the HTTP methods accepted by the cors-allow-methods annotation.
+var corsMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodOptions: {},
+	http.MethodConnect: {},
+	http.MethodTrace:   {},
+}
+
+// GetCORSAllowMethods returns the upper-cased HTTP methods listed in the cors-allow-methods annotation.
+func GetCORSAllowMethods(annotations map[string]string) ([]string, error) {
+	rawMethods, exists := annotations[annotationCORSAllowMethods]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var methods []string
+
+	for _, method := range strings.Split(rawMethods, ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+
+		if _, ok := corsMethods[method]; !ok {
+			return nil, fmt.Errorf("invalid value %q: unsupported method %q", annotationCORSAllowMethods, method)
+		}
+
+		methods = append(methods, method)
+	}
+
+	return methods, nil
+}
+
+// GetBasicAuthSecret returns the name of the secret, in the namespace of the service, holding the htpasswd users of
+// the basic-auth middleware.
+func GetBasicAuthSecret(annotations map[string]string) (string, error) {
+	name, exists := annotations[annotationBasicAuthSecret]
+	if !exists {
+		return "", ErrNotFound
+	}
+
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid value %q: secret %q has an invalid name: %s", annotationBasicAuthSecret, name, strings.Join(errs, ", "))
+	}
+
+	return name, nil
+}
+
+// GetHealthCheckInterval returns the value of the healthcheck-interval annotation.
+func GetHealthCheckInterval(annotations map[string]string) (time.Duration, error) {
+	return getDuration(annotations, annotationHealthCheckInterval)
+}
+
+// GetResponseForwardingFlushInterval returns the value of the response-forwarding-flush-interval annotation. A
+// negative interval means the response is flushed after each write, as required by streaming responses.
+func GetResponseForwardingFlushInterval(annotations map[string]string) (time.Duration, error) {
+	rawInterval, exists := annotations[annotationFlushInterval]
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	interval, err := time.ParseDuration(rawInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", annotationFlushInterval, err)
+	}
+
+	if interval == 0 {
+		return 0, fmt.Errorf("invalid value %q: duration must not be zero", annotationFlushInterval)
+	}
+
+	return interval, nil
+}
+
+// GetDialTimeout returns the value of the dial-timeout annotation.
+func GetDialTimeout(annotations map[string]string) (time.Duration, error) {
+	return getDuration(annotations, annotationDialTimeout)
+}
+
+// GetResponseHeaderTimeout returns the value of the response-header-timeout annotation.
+func GetResponseHeaderTimeout(annotations map[string]string) (time.Duration, error) {
+	return getDuration(annotations, annotationResponseHeaderTimeout)
+}
+
+// GetIdleConnTimeout returns the value of the idle-conn-timeout annotation.
+func GetIdleConnTimeout(annotations map[string]string) (time.Duration, error) {
+	return getDuration(annotations, annotationIdleConnTimeout)
+}
+
+// GetMaxIdleConnsPerHost returns the value of the max-idle-conns-per-host annotation, the maximum number of idle
+// keep-alive connections kept open to each pod of the service.
+func GetMaxIdleConnsPerHost(annotations map[string]string) (int, error) {
+	maxIdleConnsPerHost, exists := annotations[annotationMaxIdleConnsPerHost]
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	amount, err := strconv.Atoi(maxIdleConnsPerHost)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", annotationMaxIdleConnsPerHost, err)
+	}
+
+	if amount <= 0 {
+		return 0, fmt.Errorf("invalid value %q: %d must be greater than 0", annotationMaxIdleConnsPerHost, amount)
+	}
+
+	return amount, nil
+}
+
+// IsHTTP2Disabled returns true if the disable-http2 annotation is set to true, meaning the requests must be forwarded
+// to the pods of the service over HTTP/1.1 connections.
+func IsHTTP2Disabled(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationDisableHTTP2)
+}
+
+// IsTLSPassthrough returns true if the tls-passthrough annotation is set to true, meaning the service terminates TLS
+// itself and must be routed using SNI.
+func IsTLSPassthrough(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationTLSPassthrough)
+}
+
+// IsTLSInsecure returns true if the tls-insecure annotation is set to true, meaning the certificate of an https service
+// must not be verified.
+func IsTLSInsecure(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationTLSInsecure)
+}
+
+// IsZoneAware returns true if the zone-aware annotation is set to true, meaning the proxies of a zone must prefer the
+// pods of the service running in the same zone.
+func IsZoneAware(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationZoneAware)
+}
+
+// GetTLSServerName returns the value of the tls-servername annotation, the server name used to verify the certificate
+// of an https service.
+func GetTLSServerName(annotations map[string]string) (string, error) {
+	serverName, exists := annotations[annotationTLSServerName]
+	if !exists {
+		return "", ErrNotFound
+	}
+
+	if serverName == "" {
+		return "", fmt.Errorf("invalid value %q: server name must not be empty", annotationTLSServerName)
+	}
+
+	return serverName, nil
+}
+
+// IsAccessLogEnabled returns true if the access-log annotation is set to true, meaning the requests to the service must
+// be marked for access logging.
+func IsAccessLogEnabled(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationAccessLog)
+}
+
+// IsForceHTTPS returns true if the force-https annotation is set to true, meaning the HTTP requests to the service must
+// be redirected to HTTPS.
+func IsForceHTTPS(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationForceHTTPS)
+}
+
+// IsCompressEnabled returns true if the compress annotation is set to true, meaning the responses of the service must be
+// compressed.
+func IsCompressEnabled(annotations map[string]string) (bool, error) {
+	return getBool(annotations, annotationCompress)
+}
+
+// MiddlewareRef references a Traefik middleware which is not managed by Traefik Mesh.
+type MiddlewareRef struct {
+	// Namespace of the middleware. Empty when the middleware lives in the namespace of the annotated service.
+	Namespace string
+	Name      string
+}
+
+// IsPassHostHeader returns the value of the pass-host-header annotation, true if the annotation is not set, meaning the
+// Host header of the requests is forwarded as is to the service.
+func IsPassHostHeader(annotations map[string]string) (bool, error) {
+	if _, exists := annotations[annotationPassHostHeader]; !exists {
+		return true, nil
+	}
+
+	return getBool(annotations, annotationPassHostHeader)
+}
+
+// GetMiddlewares returns the middleware references listed in the middlewares annotation, in the order they are
+// defined. Each reference is either in the form name or namespace/name.
+func GetMiddlewares(annotations map[string]string) ([]MiddlewareRef, error) {
+	middlewares, exists := annotations[annotationMiddlewares]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var refs []MiddlewareRef
+
+	for _, middleware := range strings.Split(middlewares, ",") {
+		ref, err := ParseMiddlewareRef(middleware)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q: %w", annotationMiddlewares, err)
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// ParseMiddlewareRef parses the given middleware reference, in the form name or namespace/name.
+func ParseMiddlewareRef(middleware string) (MiddlewareRef, error) {
+	middleware = strings.TrimSpace(middleware)
+
+	var ref MiddlewareRef
+
+	parts := strings.Split(middleware, "/")
+	switch len(parts) {
+	case 1:
+		ref.Name = parts[0]
+	case 2:
+		ref.Namespace, ref.Name = parts[0], parts[1]
+
+		if errs := validation.IsDNS1123Label(ref.Namespace); len(errs) > 0 {
+			return MiddlewareRef{}, fmt.Errorf("middleware %q has an invalid namespace: %s", middleware, strings.Join(errs, ", "))
+		}
+	default:
+		return MiddlewareRef{}, fmt.Errorf("middleware %q must be in the form name or namespace/name", middleware)
+	}
+
+	if errs := validation.IsDNS1123Subdomain(ref.Name); len(errs) > 0 {
+		return MiddlewareRef{}, fmt.Errorf("middleware %q has an invalid name: %s", middleware, strings.Join(errs, ", "))
+	}
+
+	return ref, nil
+}
+
+// IsDefaultMiddlewares returns the value of the default-middlewares annotation, true if the annotation is not set,
+// meaning the mesh-wide default middlewares are applied to the service.
+func IsDefaultMiddlewares(annotations map[string]string) (bool, error) {
+	if _, exists := annotations[annotationDefaultMiddlewares]; !exists {
+		return true, nil
+	}
+
+	return getBool(annotations, annotationDefaultMiddlewares)
+}
+
+// GetEntryPoints returns the value of the entrypoints annotation, indexed by service port. The annotation holds a comma
+// separated list of `port:entrypoint` pairs, and a port can be listed several times to be attached to several
+// entrypoints.
+func GetEntryPoints(annotations map[string]string) (map[int32][]string, error) {
+	rawEntryPoints, exists := annotations[annotationEntryPoints]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	entryPoints := make(map[int32][]string)
+
+	for _, entryPoint := range strings.Split(rawEntryPoints, ",") {
+		parts := strings.Split(strings.TrimSpace(entryPoint), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid value %q: entrypoint %q must be in the form port:entrypoint", annotationEntryPoints, entryPoint)
+		}
+
+		port, err := strconv.ParseInt(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q: %w", annotationEntryPoints, err)
+		}
+
+		if port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid value %q: port %d is out of range", annotationEntryPoints, port)
+		}
+
+		if parts[1] == "" {
+			return nil, fmt.Errorf("invalid value %q: entrypoint of port %d must not be empty", annotationEntryPoints, port)
+		}
+
+		entryPoints[int32(port)] = append(entryPoints[int32(port)], parts[1])
+	}
+
+	return entryPoints, nil
+}
+
+// getDuration returns the positive duration value of the given annotation, ErrNotFound if the annotation is not set.
+func getDuration(annotations map[string]string, annotation string) (time.Duration, error) {
+	rawDuration, exists := annotations[annotation]
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	duration, err := time.ParseDuration(rawDuration)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", annotation, err)
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("invalid value %q: duration must be positive", annotation)
+	}
+
+	return duration, nil
+}
+
+// getBool returns the boolean value of the given annotation, false if the annotation is not set.
+func getBool(annotations map[string]string, annotation string) (bool, error) {
+	value, exists := annotations[annotation]
+	if !exists {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q: %w", annotation, err)
+	}
+
+	return b, nil
+}
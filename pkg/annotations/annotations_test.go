@@ -3,6 +3,7 @@ package annotations
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -131,6 +132,182 @@ func TestGetScheme(t *testing.T) {
 	}
 }
 
+func TestGetLBStrategy(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        string
+		err         bool
+	}{
+		{
+			desc: "unknown strategy",
+			annotations: map[string]string{
+				"mesh.traefik.io/lb-strategy": "least-conn",
+			},
+			err: true,
+		},
+		{
+			desc:        "returns the default strategy if not set",
+			annotations: map[string]string{},
+			want:        LBStrategyRoundRobin,
+		},
+		{
+			desc: "round-robin",
+			annotations: map[string]string{
+				"mesh.traefik.io/lb-strategy": "round-robin",
+			},
+			want: LBStrategyRoundRobin,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			strategy, err := GetLBStrategy(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, strategy)
+		})
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/ignore": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/ignore": "true",
+			},
+			want: true,
+		},
+		{
+			desc: "false",
+			annotations: map[string]string{
+				"mesh.traefik.io/ignore": "false",
+			},
+			want: false,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ignored, err := IsIgnored(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, ignored)
+		})
+	}
+}
+
+func TestGetIgnoredPorts(t *testing.T) {
+	type servicePort struct {
+		Name string
+		Port int32
+	}
+
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		ignored      []servicePort
+		meshed       []servicePort
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "names and numbers",
+			annotations: map[string]string{
+				"mesh.traefik.io/ignore-ports": "debug, 9090",
+			},
+			ignored: []servicePort{{Name: "debug", Port: 6060}, {Name: "metrics", Port: 9090}},
+			meshed:  []servicePort{{Name: "web", Port: 80}, {Port: 8080}},
+		},
+		{
+			desc: "invalid port name",
+			annotations: map[string]string{
+				"mesh.traefik.io/ignore-ports": "not_a_port",
+			},
+			err: true,
+		},
+		{
+			desc: "port out of range",
+			annotations: map[string]string{
+				"mesh.traefik.io/ignore-ports": "70000",
+			},
+			err: true,
+		},
+		{
+			desc: "empty port",
+			annotations: map[string]string{
+				"mesh.traefik.io/ignore-ports": "80,",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ports, err := GetIgnoredPorts(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			if test.wantNotFound {
+				assert.True(t, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+
+			for _, port := range test.ignored {
+				assert.True(t, ports.Contains(port.Name, port.Port), "port %s/%d", port.Name, port.Port)
+			}
+
+			for _, port := range test.meshed {
+				assert.False(t, ports.Contains(port.Name, port.Port), "port %s/%d", port.Name, port.Port)
+			}
+		})
+	}
+}
+
 func TestGetRetryAttempts(t *testing.T) {
 	tests := []struct {
 		desc         string
@@ -315,3 +492,1812 @@ func TestGetRateLimitAverage(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTrafficSplitBackends(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         []TrafficSplitBackend
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:80, svc-green:20",
+			},
+			want: []TrafficSplitBackend{
+				{Service: "svc-blue", Weight: 80},
+				{Service: "svc-green", Weight: 20},
+			},
+		},
+		{
+			desc: "backend in another namespace",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:80,svc-green.canary:20",
+			},
+			want: []TrafficSplitBackend{
+				{Service: "svc-blue", Weight: 80},
+				{Service: "svc-green", Namespace: "canary", Weight: 20},
+			},
+		},
+		{
+			desc: "missing weight",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:80,svc-green",
+			},
+			err: true,
+		},
+		{
+			desc: "missing service",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": ":80",
+			},
+			err: true,
+		},
+		{
+			desc: "invalid weight",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:hello",
+			},
+			err: true,
+		},
+		{
+			desc: "negative weight",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:-1",
+			},
+			err: true,
+		},
+		{
+			desc: "zero weight",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:100,svc-green:0",
+			},
+			want: []TrafficSplitBackend{
+				{Service: "svc-blue", Weight: 100},
+				{Service: "svc-green", Weight: 0},
+			},
+		},
+		{
+			desc: "all zero weights",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:0,svc-green:0",
+			},
+			err: true,
+		},
+		{
+			desc: "duplicate backend",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:80,svc-blue:20",
+			},
+			err: true,
+		},
+		{
+			desc: "trailing comma",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-backends": "svc-blue:80,svc-green:20,",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			backends, err := GetTrafficSplitBackends(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, backends)
+		})
+	}
+}
+
+func TestGetMirror(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         Mirror
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/mirror-service": "svc-canary:20",
+			},
+			want: Mirror{Service: "svc-canary", Percent: 20},
+		},
+		{
+			desc: "without percent",
+			annotations: map[string]string{
+				"mesh.traefik.io/mirror-service": "svc-canary",
+			},
+			want: Mirror{Service: "svc-canary", Percent: 100},
+		},
+		{
+			desc: "service in another namespace",
+			annotations: map[string]string{
+				"mesh.traefik.io/mirror-service": "svc-canary.canary:50",
+			},
+			want: Mirror{Service: "svc-canary", Namespace: "canary", Percent: 50},
+		},
+		{
+			desc: "missing service",
+			annotations: map[string]string{
+				"mesh.traefik.io/mirror-service": ":20",
+			},
+			err: true,
+		},
+		{
+			desc: "invalid percent",
+			annotations: map[string]string{
+				"mesh.traefik.io/mirror-service": "svc-canary:hello",
+			},
+			err: true,
+		},
+		{
+			desc: "percent out of range",
+			annotations: map[string]string{
+				"mesh.traefik.io/mirror-service": "svc-canary:101",
+			},
+			err: true,
+		},
+		{
+			desc: "too many parts",
+			annotations: map[string]string{
+				"mesh.traefik.io/mirror-service": "svc-canary:20:30",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			mirror, err := GetMirror(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, mirror)
+		})
+	}
+}
+
+func TestGetHeaderRoute(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         HeaderRoute
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "X-Canary=true:svc-canary",
+			},
+			want: HeaderRoute{Header: "X-Canary", Value: "true", Service: "svc-canary"},
+		},
+		{
+			desc: "service in another namespace",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "X-Canary=true:svc-canary.canary",
+			},
+			want: HeaderRoute{Header: "X-Canary", Value: "true", Service: "svc-canary", Namespace: "canary"},
+		},
+		{
+			desc: "value containing a colon",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "X-Version=v1:beta:svc-canary",
+			},
+			want: HeaderRoute{Header: "X-Version", Value: "v1:beta", Service: "svc-canary"},
+		},
+		{
+			desc: "missing service",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "X-Canary=true:",
+			},
+			err: true,
+		},
+		{
+			desc: "missing value",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "X-Canary=:svc-canary",
+			},
+			err: true,
+		},
+		{
+			desc: "missing header",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "true:svc-canary",
+			},
+			err: true,
+		},
+		{
+			desc: "invalid header name",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "X Canary=true:svc-canary",
+			},
+			err: true,
+		},
+		{
+			desc: "value containing a backquote",
+			annotations: map[string]string{
+				"mesh.traefik.io/header-route": "X-Canary=tr`ue:svc-canary",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			headerRoute, err := GetHeaderRoute(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, headerRoute)
+		})
+	}
+}
+
+func TestGetRouterRule(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/router-rule": " Host(`api.example.com`) && PathPrefix(`/v1`) ",
+			},
+			want: "Host(`api.example.com`) && PathPrefix(`/v1`)",
+		},
+		{
+			desc: "empty",
+			annotations: map[string]string{
+				"mesh.traefik.io/router-rule": "  ",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rule, err := GetRouterRule(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, rule)
+		})
+	}
+}
+
+func TestGetStickyCookieName(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/sticky-cookie-name": "session",
+			},
+			want: "session",
+		},
+		{
+			desc: "empty",
+			annotations: map[string]string{
+				"mesh.traefik.io/sticky-cookie-name": "",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			name, err := GetStickyCookieName(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, name)
+		})
+	}
+}
+
+func TestGetTrafficSplitStickyCookieName(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-sticky-cookie-name": "canary",
+			},
+			want: "canary",
+		},
+		{
+			desc: "empty",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-split-sticky-cookie-name": "",
+			},
+			err: true,
+		},
+		{
+			desc: "not set",
+			annotations: map[string]string{
+				"mesh.traefik.io/sticky-cookie-name": "session",
+			},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			name, err := GetTrafficSplitStickyCookieName(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, name)
+		})
+	}
+}
+
+func TestIsStickyCookieSecureAndHTTPOnly(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		wantSecure   bool
+		wantHTTPOnly bool
+		err          bool
+	}{
+		{
+			desc: "both set",
+			annotations: map[string]string{
+				"mesh.traefik.io/sticky-cookie-secure":   "true",
+				"mesh.traefik.io/sticky-cookie-httponly": "true",
+			},
+			wantSecure:   true,
+			wantHTTPOnly: true,
+		},
+		{
+			desc: "secure only",
+			annotations: map[string]string{
+				"mesh.traefik.io/sticky-cookie-secure": "true",
+			},
+			wantSecure: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+		},
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/sticky-cookie-secure":   "hello",
+				"mesh.traefik.io/sticky-cookie-httponly": "hello",
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			secure, err := IsStickyCookieSecure(test.annotations)
+			if test.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.wantSecure, secure)
+			}
+
+			httpOnly, err := IsStickyCookieHTTPOnly(test.annotations)
+			if test.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.wantHTTPOnly, httpOnly)
+			}
+		})
+	}
+}
+
+func TestGetHealthCheckPath(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/healthcheck-path": "/health",
+			},
+			want: "/health",
+		},
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/healthcheck-path": "health",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			path, err := GetHealthCheckPath(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, path)
+		})
+	}
+}
+
+func TestGetStripPrefixes(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         []string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/strip-prefix": "/api,/v1",
+			},
+			want: []string{"/api", "/v1"},
+		},
+		{
+			desc: "prefix without leading slash",
+			annotations: map[string]string{
+				"mesh.traefik.io/strip-prefix": "api",
+			},
+			err: true,
+		},
+		{
+			desc: "empty prefix",
+			annotations: map[string]string{
+				"mesh.traefik.io/strip-prefix": "/api,",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			prefixes, err := GetStripPrefixes(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, prefixes)
+		})
+	}
+}
+
+func TestGetCORSAllowOrigins(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         []string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin": "https://app.example.com, http://localhost:8080",
+			},
+			want: []string{"https://app.example.com", "http://localhost:8080"},
+		},
+		{
+			desc: "wildcard",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin": "*",
+			},
+			want: []string{"*"},
+		},
+		{
+			desc: "origin without scheme",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin": "app.example.com",
+			},
+			err: true,
+		},
+		{
+			desc: "origin with a path",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin": "https://app.example.com/api",
+			},
+			err: true,
+		},
+		{
+			desc: "empty origin",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin": "https://app.example.com,",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			origins, err := GetCORSAllowOrigins(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, origins)
+		})
+	}
+}
+
+func TestGetCORSAllowMethods(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         []string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-methods": "GET, post,Options",
+			},
+			want: []string{"GET", "POST", "OPTIONS"},
+		},
+		{
+			desc: "unsupported method",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-methods": "GET,FETCH",
+			},
+			err: true,
+		},
+		{
+			desc: "empty method",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-methods": "GET,",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			methods, err := GetCORSAllowMethods(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, methods)
+		})
+	}
+}
+
+func TestGetHealthCheckInterval(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         time.Duration
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/healthcheck-interval": "10s",
+			},
+			want: 10 * time.Second,
+		},
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/healthcheck-interval": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "negative",
+			annotations: map[string]string{
+				"mesh.traefik.io/healthcheck-interval": "-10s",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			interval, err := GetHealthCheckInterval(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, interval)
+		})
+	}
+}
+
+func TestGetTimeouts(t *testing.T) {
+	getters := map[string]func(map[string]string) (time.Duration, error){
+		"mesh.traefik.io/response-forwarding-flush-interval": GetResponseForwardingFlushInterval,
+		"mesh.traefik.io/dial-timeout":                       GetDialTimeout,
+		"mesh.traefik.io/response-header-timeout":            GetResponseHeaderTimeout,
+		"mesh.traefik.io/idle-conn-timeout":                  GetIdleConnTimeout,
+	}
+
+	tests := []struct {
+		desc         string
+		value        string
+		want         time.Duration
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc:  "valid",
+			value: "100ms",
+			want:  100 * time.Millisecond,
+		},
+		{
+			desc:  "invalid",
+			value: "hello",
+			err:   true,
+		},
+		{
+			desc:  "zero",
+			value: "0s",
+			err:   true,
+		},
+		{
+			desc:         "not set",
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for annotation, getter := range getters {
+		for _, test := range tests {
+			annotation, getter, test := annotation, getter, test
+			t.Run(annotation+" "+test.desc, func(t *testing.T) {
+				t.Parallel()
+
+				annotations := map[string]string{}
+				if test.value != "" {
+					annotations[annotation] = test.value
+				}
+
+				timeout, err := getter(annotations)
+				if test.err {
+					require.Error(t, err)
+					assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, test.want, timeout)
+			})
+		}
+	}
+}
+
+func TestGetMaxIdleConnsPerHost(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         int
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-idle-conns-per-host": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "zero",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-idle-conns-per-host": "0",
+			},
+			err: true,
+		},
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-idle-conns-per-host": "100",
+			},
+			want: 100,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			amount, err := GetMaxIdleConnsPerHost(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, amount)
+		})
+	}
+}
+
+func TestGetResponseForwardingFlushInterval(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        time.Duration
+		err         bool
+	}{
+		{
+			desc: "positive",
+			annotations: map[string]string{
+				"mesh.traefik.io/response-forwarding-flush-interval": "10ms",
+			},
+			want: 10 * time.Millisecond,
+		},
+		{
+			desc: "negative flushes after each write",
+			annotations: map[string]string{
+				"mesh.traefik.io/response-forwarding-flush-interval": "-1ms",
+			},
+			want: -time.Millisecond,
+		},
+		{
+			desc: "zero",
+			annotations: map[string]string{
+				"mesh.traefik.io/response-forwarding-flush-interval": "0s",
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			interval, err := GetResponseForwardingFlushInterval(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, interval)
+		})
+	}
+}
+
+func TestIsTLSPassthrough(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/tls-passthrough": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/tls-passthrough": "true",
+			},
+			want: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			passthrough, err := IsTLSPassthrough(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, passthrough)
+		})
+	}
+}
+
+func TestIsTLSInsecure(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/tls-insecure": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/tls-insecure": "true",
+			},
+			want: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			insecure, err := IsTLSInsecure(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, insecure)
+		})
+	}
+}
+
+func TestIsHTTP2Disabled(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/disable-http2": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/disable-http2": "true",
+			},
+			want: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			disabled, err := IsHTTP2Disabled(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, disabled)
+		})
+	}
+}
+
+func TestIsZoneAware(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/zone-aware": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/zone-aware": "true",
+			},
+			want: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			zoneAware, err := IsZoneAware(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, zoneAware)
+		})
+	}
+}
+
+func TestGetTLSServerName(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/tls-servername": "svc.my-ns.svc.cluster.local",
+			},
+			want: "svc.my-ns.svc.cluster.local",
+		},
+		{
+			desc: "empty",
+			annotations: map[string]string{
+				"mesh.traefik.io/tls-servername": "",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			serverName, err := GetTLSServerName(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, serverName)
+		})
+	}
+}
+
+func TestGetBasicAuthSecret(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/basic-auth-secret": "admin-users",
+			},
+			want: "admin-users",
+		},
+		{
+			desc: "invalid name",
+			annotations: map[string]string{
+				"mesh.traefik.io/basic-auth-secret": "other-ns/admin-users",
+			},
+			err: true,
+		},
+		{
+			desc: "empty",
+			annotations: map[string]string{
+				"mesh.traefik.io/basic-auth-secret": "",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			secret, err := GetBasicAuthSecret(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, secret)
+		})
+	}
+}
+
+func TestIsAccessLogEnabled(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/access-log": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/access-log": "true",
+			},
+			want: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			enabled, err := IsAccessLogEnabled(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, enabled)
+		})
+	}
+}
+
+func TestIsForceHTTPS(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/force-https": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/force-https": "true",
+			},
+			want: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			forceHTTPS, err := IsForceHTTPS(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, forceHTTPS)
+		})
+	}
+}
+
+func TestIsCompressEnabled(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/compress": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/compress": "true",
+			},
+			want: true,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			compress, err := IsCompressEnabled(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, compress)
+		})
+	}
+}
+
+func TestIsPassHostHeader(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/pass-host-header": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/pass-host-header": "true",
+			},
+			want: true,
+		},
+		{
+			desc: "false",
+			annotations: map[string]string{
+				"mesh.traefik.io/pass-host-header": "false",
+			},
+			want: false,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			passHostHeader, err := IsPassHostHeader(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, passHostHeader)
+		})
+	}
+}
+
+func TestGetMaxConn(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         int
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-conn": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "zero",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-conn": "0",
+			},
+			err: true,
+		},
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-conn": "100",
+			},
+			want: 100,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			maxConn, err := GetMaxConn(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, maxConn)
+		})
+	}
+}
+
+func TestGetMaxRequestBodyBytes(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         int64
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-request-body-bytes": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "zero",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-request-body-bytes": "0",
+			},
+			err: true,
+		},
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-request-body-bytes": "1048576",
+			},
+			want: 1048576,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			size, err := GetMaxRequestBodyBytes(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, size)
+		})
+	}
+}
+
+func TestGetMiddlewares(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         []MiddlewareRef
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "name only",
+			annotations: map[string]string{
+				"mesh.traefik.io/middlewares": "auth",
+			},
+			want: []MiddlewareRef{
+				{Name: "auth"},
+			},
+		},
+		{
+			desc: "names with and without namespace keep their order",
+			annotations: map[string]string{
+				"mesh.traefik.io/middlewares": "auth, shared/headers,compress",
+			},
+			want: []MiddlewareRef{
+				{Name: "auth"},
+				{Namespace: "shared", Name: "headers"},
+				{Name: "compress"},
+			},
+		},
+		{
+			desc: "empty",
+			annotations: map[string]string{
+				"mesh.traefik.io/middlewares": "",
+			},
+			err: true,
+		},
+		{
+			desc: "trailing comma",
+			annotations: map[string]string{
+				"mesh.traefik.io/middlewares": "auth,",
+			},
+			err: true,
+		},
+		{
+			desc: "invalid name",
+			annotations: map[string]string{
+				"mesh.traefik.io/middlewares": "Auth_Middleware",
+			},
+			err: true,
+		},
+		{
+			desc: "invalid namespace",
+			annotations: map[string]string{
+				"mesh.traefik.io/middlewares": "shared.ns/auth",
+			},
+			err: true,
+		},
+		{
+			desc: "too many parts",
+			annotations: map[string]string{
+				"mesh.traefik.io/middlewares": "shared/auth/other",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			middlewares, err := GetMiddlewares(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, middlewares)
+		})
+	}
+}
+
+func TestGetEntryPoints(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         map[int32][]string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/entrypoints": "8080:internal, 8443:external",
+			},
+			want: map[int32][]string{
+				8080: {"internal"},
+				8443: {"external"},
+			},
+		},
+		{
+			desc: "port with several entrypoints",
+			annotations: map[string]string{
+				"mesh.traefik.io/entrypoints": "8080:internal,8080:external",
+			},
+			want: map[int32][]string{
+				8080: {"internal", "external"},
+			},
+		},
+		{
+			desc: "missing port",
+			annotations: map[string]string{
+				"mesh.traefik.io/entrypoints": "internal",
+			},
+			err: true,
+		},
+		{
+			desc: "invalid port",
+			annotations: map[string]string{
+				"mesh.traefik.io/entrypoints": "http:internal",
+			},
+			err: true,
+		},
+		{
+			desc: "port out of range",
+			annotations: map[string]string{
+				"mesh.traefik.io/entrypoints": "70000:internal",
+			},
+			err: true,
+		},
+		{
+			desc: "empty entrypoint",
+			annotations: map[string]string{
+				"mesh.traefik.io/entrypoints": "8080:",
+			},
+			err: true,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			entryPoints, err := GetEntryPoints(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, entryPoints)
+		})
+	}
+}
+
+func TestIsDefaultMiddlewares(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        bool
+		err         bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/default-middlewares": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/default-middlewares": "true",
+			},
+			want: true,
+		},
+		{
+			desc: "false",
+			annotations: map[string]string{
+				"mesh.traefik.io/default-middlewares": "false",
+			},
+			want: false,
+		},
+		{
+			desc:        "not set",
+			annotations: map[string]string{},
+			want:        true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			defaultMiddlewares, err := IsDefaultMiddlewares(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, defaultMiddlewares)
+		})
+	}
+}
+
+func TestOnlyMiddlewaresChanged(t *testing.T) {
+	tests := []struct {
+		desc           string
+		oldAnnotations map[string]string
+		newAnnotations map[string]string
+		want           bool
+	}{
+		{
+			desc:           "no change",
+			oldAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "2"},
+			newAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "2"},
+			want:           false,
+		},
+		{
+			desc:           "middleware annotation updated",
+			oldAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "2"},
+			newAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "3"},
+			want:           true,
+		},
+		{
+			desc:           "middleware annotation added",
+			oldAnnotations: map[string]string{},
+			newAnnotations: map[string]string{"mesh.traefik.io/ratelimit-average": "100"},
+			want:           true,
+		},
+		{
+			desc:           "default middlewares disabled",
+			oldAnnotations: map[string]string{},
+			newAnnotations: map[string]string{"mesh.traefik.io/default-middlewares": "false"},
+			want:           true,
+		},
+		{
+			desc:           "middleware annotation removed",
+			oldAnnotations: map[string]string{"mesh.traefik.io/middlewares": "auth"},
+			newAnnotations: nil,
+			want:           true,
+		},
+		{
+			desc: "other annotation updated",
+			oldAnnotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "2",
+				"mesh.traefik.io/traffic-type":   "http",
+			},
+			newAnnotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "3",
+				"mesh.traefik.io/traffic-type":   "tcp",
+			},
+			want: false,
+		},
+		{
+			desc:           "non mesh annotation updated",
+			oldAnnotations: map[string]string{"foo": "bar"},
+			newAnnotations: map[string]string{"foo": "baz"},
+			want:           false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, OnlyMiddlewaresChanged(test.oldAnnotations, test.newAnnotations))
+		})
+	}
+}
@@ -7,19 +7,30 @@ import (
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
 )
 
+// AccessLogHeader is the request header set on the requests to the services with access logging enabled.
+const AccessLogHeader = "X-Mesh-Access-Log"
+
 type middlewareBuilder func(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error)
 
+// middlewareBuilders are the builders of the middlewares configured with annotations.
+var middlewareBuilders = []middlewareBuilder{
+	buildRetryMiddleware,
+	buildRateLimitMiddleware,
+	buildCircuitBreakerMiddleware,
+	buildInFlightReqMiddleware,
+	buildBufferingMiddleware,
+	buildAccessLogMiddleware,
+	buildForceHTTPSMiddleware,
+	buildCompressMiddleware,
+	buildStripPrefixMiddleware,
+	buildCORSMiddleware,
+}
+
 // BuildMiddlewares builds middlewares from the given annotations.
 func BuildMiddlewares(annotations map[string]string) (map[string]*dynamic.Middleware, error) {
-	builders := []middlewareBuilder{
-		buildRetryMiddleware,
-		buildRateLimitMiddleware,
-		buildCircuitBreakerMiddleware,
-	}
-
 	middlewares := map[string]*dynamic.Middleware{}
 
-	for _, builder := range builders {
+	for _, builder := range middlewareBuilders {
 		middleware, name, err := builder(annotations)
 		if err != nil {
 			return nil, err
@@ -109,3 +120,168 @@ func buildCircuitBreakerMiddleware(annotations map[string]string) (middleware *d
 
 	return middleware, name, nil
 }
+
+func buildInFlightReqMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
+	var maxConn int
+
+	maxConn, err = GetMaxConn(annotations)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, "", nil
+		}
+
+		return nil, "", fmt.Errorf("unable to build in-flight-req middleware: %w", err)
+	}
+
+	name = "max-conn"
+	middleware = &dynamic.Middleware{
+		InFlightReq: &dynamic.InFlightReq{
+			Amount: int64(maxConn),
+		},
+	}
+
+	return middleware, name, nil
+}
+
+// buildBufferingMiddleware builds a middleware rejecting the requests whose body exceeds the configured size with a
+// 413 Request Entity Too Large status code.
+func buildBufferingMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
+	var maxRequestBodyBytes int64
+
+	maxRequestBodyBytes, err = GetMaxRequestBodyBytes(annotations)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, "", nil
+		}
+
+		return nil, "", fmt.Errorf("unable to build buffering middleware: %w", err)
+	}
+
+	name = "max-request-body"
+	middleware = &dynamic.Middleware{
+		Buffering: &dynamic.Buffering{
+			MaxRequestBodyBytes: maxRequestBodyBytes,
+		},
+	}
+
+	return middleware, name, nil
+}
+
+// buildAccessLogMiddleware builds a middleware marking the requests to the service with the AccessLogHeader header.
+// Traefik access logs are global, keeping this header in the access logs allows to only retain the requests of the
+// annotated services.
+func buildAccessLogMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
+	var enabled bool
+
+	enabled, err = IsAccessLogEnabled(annotations)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to build access-log middleware: %w", err)
+	}
+
+	if !enabled {
+		return nil, "", nil
+	}
+
+	name = "access-log"
+	middleware = &dynamic.Middleware{
+		Headers: &dynamic.Headers{
+			CustomRequestHeaders: map[string]string{AccessLogHeader: "true"},
+		},
+	}
+
+	return middleware, name, nil
+}
+
+func buildForceHTTPSMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
+	var forceHTTPS bool
+
+	forceHTTPS, err = IsForceHTTPS(annotations)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to build force-https middleware: %w", err)
+	}
+
+	if !forceHTTPS {
+		return nil, "", nil
+	}
+
+	name = "force-https"
+	middleware = &dynamic.Middleware{
+		RedirectScheme: &dynamic.RedirectScheme{
+			Scheme:    "https",
+			Permanent: true,
+		},
+	}
+
+	return middleware, name, nil
+}
+
+func buildCompressMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
+	var compress bool
+
+	compress, err = IsCompressEnabled(annotations)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to build compress middleware: %w", err)
+	}
+
+	if !compress {
+		return nil, "", nil
+	}
+
+	name = "compress"
+	middleware = &dynamic.Middleware{
+		Compress: &dynamic.Compress{},
+	}
+
+	return middleware, name, nil
+}
+
+func buildStripPrefixMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
+	var prefixes []string
+
+	prefixes, err = GetStripPrefixes(annotations)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, "", nil
+		}
+
+		return nil, "", fmt.Errorf("unable to build strip-prefix middleware: %w", err)
+	}
+
+	name = "strip-prefix"
+	middleware = &dynamic.Middleware{
+		StripPrefix: &dynamic.StripPrefix{Prefixes: prefixes},
+	}
+
+	return middleware, name, nil
+}
+
+func buildCORSMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
+	origins, err := GetCORSAllowOrigins(annotations)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, "", fmt.Errorf("unable to build cors middleware: %w", err)
+	}
+
+	methods, err := GetCORSAllowMethods(annotations)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, "", fmt.Errorf("unable to build cors middleware: %w", err)
+	}
+
+	if len(origins) == 0 {
+		if len(methods) > 0 {
+			return nil, "", fmt.Errorf("unable to build cors middleware: %q requires %q to be set", annotationCORSAllowMethods, annotationCORSAllowOrigin)
+		}
+
+		return nil, "", nil
+	}
+
+	name = "cors"
+	middleware = &dynamic.Middleware{
+		Headers: &dynamic.Headers{
+			AccessControlAllowOriginList: origins,
+			AccessControlAllowMethods:    methods,
+			AddVaryHeader:                true,
+		},
+	}
+
+	return middleware, name, nil
+}
@@ -97,6 +97,191 @@ func TestBuildMiddleware(t *testing.T) {
 			},
 			want: map[string]*dynamic.Middleware{},
 		},
+		{
+			desc: "max-conn annotation is valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-conn": "100",
+			},
+			want: map[string]*dynamic.Middleware{
+				"max-conn": {
+					InFlightReq: &dynamic.InFlightReq{
+						Amount: 100,
+					},
+				},
+			},
+		},
+		{
+			desc: "max-conn annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-conn": "-1",
+			},
+			err: true,
+		},
+		{
+			desc: "max-request-body-bytes annotation is valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-request-body-bytes": "1048576",
+			},
+			want: map[string]*dynamic.Middleware{
+				"max-request-body": {
+					Buffering: &dynamic.Buffering{
+						MaxRequestBodyBytes: 1048576,
+					},
+				},
+			},
+		},
+		{
+			desc: "max-request-body-bytes annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-request-body-bytes": "0",
+			},
+			err: true,
+		},
+		{
+			desc: "access-log annotation is true",
+			annotations: map[string]string{
+				"mesh.traefik.io/access-log": "true",
+			},
+			want: map[string]*dynamic.Middleware{
+				"access-log": {
+					Headers: &dynamic.Headers{
+						CustomRequestHeaders: map[string]string{"X-Mesh-Access-Log": "true"},
+					},
+				},
+			},
+		},
+		{
+			desc: "access-log annotation is false",
+			annotations: map[string]string{
+				"mesh.traefik.io/access-log": "false",
+			},
+			want: map[string]*dynamic.Middleware{},
+		},
+		{
+			desc: "access-log annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/access-log": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "force-https annotation is true",
+			annotations: map[string]string{
+				"mesh.traefik.io/force-https": "true",
+			},
+			want: map[string]*dynamic.Middleware{
+				"force-https": {
+					RedirectScheme: &dynamic.RedirectScheme{
+						Scheme:    "https",
+						Permanent: true,
+					},
+				},
+			},
+		},
+		{
+			desc: "force-https annotation is false",
+			annotations: map[string]string{
+				"mesh.traefik.io/force-https": "false",
+			},
+			want: map[string]*dynamic.Middleware{},
+		},
+		{
+			desc: "force-https annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/force-https": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "compress annotation is true",
+			annotations: map[string]string{
+				"mesh.traefik.io/compress": "true",
+			},
+			want: map[string]*dynamic.Middleware{
+				"compress": {
+					Compress: &dynamic.Compress{},
+				},
+			},
+		},
+		{
+			desc: "compress annotation is false",
+			annotations: map[string]string{
+				"mesh.traefik.io/compress": "false",
+			},
+			want: map[string]*dynamic.Middleware{},
+		},
+		{
+			desc: "compress annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/compress": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "strip-prefix annotation",
+			annotations: map[string]string{
+				"mesh.traefik.io/strip-prefix": "/api, /v1",
+			},
+			want: map[string]*dynamic.Middleware{
+				"strip-prefix": {
+					StripPrefix: &dynamic.StripPrefix{
+						Prefixes: []string{"/api", "/v1"},
+					},
+				},
+			},
+		},
+		{
+			desc: "strip-prefix annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/strip-prefix": "/api,v1",
+			},
+			err: true,
+		},
+		{
+			desc: "cors annotations",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin":  "https://app.example.com,https://admin.example.com",
+				"mesh.traefik.io/cors-allow-methods": "GET,post",
+			},
+			want: map[string]*dynamic.Middleware{
+				"cors": {
+					Headers: &dynamic.Headers{
+						AccessControlAllowOriginList: []string{"https://app.example.com", "https://admin.example.com"},
+						AccessControlAllowMethods:    []string{"GET", "POST"},
+						AddVaryHeader:                true,
+					},
+				},
+			},
+		},
+		{
+			desc: "cors-allow-origin annotation only",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin": "*",
+			},
+			want: map[string]*dynamic.Middleware{
+				"cors": {
+					Headers: &dynamic.Headers{
+						AccessControlAllowOriginList: []string{"*"},
+						AddVaryHeader:                true,
+					},
+				},
+			},
+		},
+		{
+			desc: "cors-allow-methods annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-origin":  "https://app.example.com",
+				"mesh.traefik.io/cors-allow-methods": "GET,FETCH",
+			},
+			err: true,
+		},
+		{
+			desc: "cors-allow-methods annotation without cors-allow-origin",
+			annotations: map[string]string{
+				"mesh.traefik.io/cors-allow-methods": "GET",
+			},
+			err: true,
+		},
 		{
 			desc: "multiple middlewares",
 			annotations: map[string]string{
@@ -104,8 +289,14 @@ func TestBuildMiddleware(t *testing.T) {
 				"mesh.traefik.io/ratelimit-average":          "200",
 				"mesh.traefik.io/ratelimit-burst":            "100",
 				"mesh.traefik.io/circuit-breaker-expression": "LatencyAtQuantileMS(50.0) > 100",
+				"mesh.traefik.io/max-conn":                   "100",
 			},
 			want: map[string]*dynamic.Middleware{
+				"max-conn": {
+					InFlightReq: &dynamic.InFlightReq{
+						Amount: 100,
+					},
+				},
 				"retry": {
 					Retry: &dynamic.Retry{
 						Attempts: 5,
@@ -0,0 +1,65 @@
+package annotations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type validator func(annotations map[string]string) error
+
+// Validate validates the mesh annotations using the same functions the dynamic configuration is built with, so that
+// annotations accepted by Validate are also accepted at runtime. It returns an error listing every invalid annotation.
+func Validate(annotations map[string]string) error {
+	validators := []validator{
+		func(a map[string]string) error { _, err := GetTrafficType(a); return err },
+		func(a map[string]string) error { _, err := GetScheme(a); return err },
+		func(a map[string]string) error { _, err := GetLBStrategy(a); return err },
+		func(a map[string]string) error { _, err := IsIgnored(a); return err },
+		func(a map[string]string) error { _, err := GetIgnoredPorts(a); return err },
+		func(a map[string]string) error { _, err := GetTrafficSplitBackends(a); return err },
+		func(a map[string]string) error { _, err := GetMirror(a); return err },
+		func(a map[string]string) error { _, err := GetHeaderRoute(a); return err },
+		func(a map[string]string) error { _, err := GetRouterRule(a); return err },
+		func(a map[string]string) error { _, err := GetStickyCookieName(a); return err },
+		func(a map[string]string) error { _, err := IsStickyCookieSecure(a); return err },
+		func(a map[string]string) error { _, err := IsStickyCookieHTTPOnly(a); return err },
+		func(a map[string]string) error { _, err := GetTrafficSplitStickyCookieName(a); return err },
+		func(a map[string]string) error { _, err := GetHealthCheckPath(a); return err },
+		func(a map[string]string) error { _, err := GetHealthCheckInterval(a); return err },
+		func(a map[string]string) error { _, err := IsTLSPassthrough(a); return err },
+		func(a map[string]string) error { _, err := IsTLSInsecure(a); return err },
+		func(a map[string]string) error { _, err := IsZoneAware(a); return err },
+		func(a map[string]string) error { _, err := GetTLSServerName(a); return err },
+		func(a map[string]string) error { _, err := IsPassHostHeader(a); return err },
+		func(a map[string]string) error { _, err := GetResponseForwardingFlushInterval(a); return err },
+		func(a map[string]string) error { _, err := GetDialTimeout(a); return err },
+		func(a map[string]string) error { _, err := GetResponseHeaderTimeout(a); return err },
+		func(a map[string]string) error { _, err := GetIdleConnTimeout(a); return err },
+		func(a map[string]string) error { _, err := GetMaxIdleConnsPerHost(a); return err },
+		func(a map[string]string) error { _, err := IsHTTP2Disabled(a); return err },
+		func(a map[string]string) error { _, err := GetMiddlewares(a); return err },
+		func(a map[string]string) error { _, err := IsDefaultMiddlewares(a); return err },
+		func(a map[string]string) error { _, err := GetBasicAuthSecret(a); return err },
+		func(a map[string]string) error { _, err := GetEntryPoints(a); return err },
+	}
+
+	for _, builder := range middlewareBuilders {
+		builder := builder
+		validators = append(validators, func(a map[string]string) error { _, _, err := builder(a); return err })
+	}
+
+	var messages []string
+
+	for _, validate := range validators {
+		if err := validate(annotations); err != nil && !errors.Is(err, ErrNotFound) {
+			messages = append(messages, err.Error())
+		}
+	}
+
+	if len(messages) > 0 {
+		return fmt.Errorf("invalid annotations: %s", strings.Join(messages, "; "))
+	}
+
+	return nil
+}
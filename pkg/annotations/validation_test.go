@@ -0,0 +1,72 @@
+package annotations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		wantErr     string
+	}{
+		{
+			desc:        "no annotations",
+			annotations: map[string]string{},
+		},
+		{
+			desc: "valid annotations",
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-type":           "http",
+				"mesh.traefik.io/scheme":                 "h2c",
+				"mesh.traefik.io/retry-attempts":         "2",
+				"mesh.traefik.io/ratelimit-average":      "100",
+				"mesh.traefik.io/ratelimit-burst":        "200",
+				"mesh.traefik.io/max-conn":               "10",
+				"mesh.traefik.io/traffic-split-backends": "svc-b:80,svc-c:20",
+				"mesh.traefik.io/middlewares":            "auth,shared/headers",
+				"foo":                                    "bar",
+			},
+		},
+		{
+			desc: "invalid retry-attempts",
+			annotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "two",
+			},
+			wantErr: `invalid annotations: unable to build retry middleware: invalid value "mesh.traefik.io/retry-attempts": strconv.Atoi: parsing "two": invalid syntax`,
+		},
+		{
+			desc: "invalid rate-limit",
+			annotations: map[string]string{
+				"mesh.traefik.io/ratelimit-average": "0",
+				"mesh.traefik.io/ratelimit-burst":   "200",
+			},
+			wantErr: "invalid annotations: unable to build rate-limit middleware: burst and average must be greater than 0",
+		},
+		{
+			desc: "several invalid annotations",
+			annotations: map[string]string{
+				"mesh.traefik.io/scheme":               "ftp",
+				"mesh.traefik.io/sticky-cookie-secure": "maybe",
+			},
+			wantErr: `invalid annotations: unsupported scheme "mesh.traefik.io/scheme": "ftp"; invalid value "mesh.traefik.io/sticky-cookie-secure": strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := Validate(test.annotations)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
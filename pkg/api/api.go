@@ -1,13 +1,23 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/v2/pkg/controller"
 	"github.com/traefik/mesh/v2/pkg/provider"
 	"github.com/traefik/mesh/v2/pkg/safe"
 	"github.com/traefik/mesh/v2/pkg/topology"
@@ -21,18 +31,20 @@ type API struct {
 	readiness     *safe.Safe
 	configuration *safe.Safe
 	topology      *safe.Safe
+	status        *safe.Safe
 
 	namespace string
 	logger    logrus.FieldLogger
 }
 
-// NewAPI creates a new api.
-func NewAPI(logger logrus.FieldLogger, port int32, host, namespace string) *API {
+// NewAPI creates a new api. When debug is true, the debug endpoints are enabled. The host can be an IPv4 or an IPv6
+// address, bracketed or not, and the API listens on all the interfaces when it is empty or `::`.
+func NewAPI(logger logrus.FieldLogger, port int32, host, namespace string, debug bool) *API {
 	router := mux.NewRouter()
 
 	api := &API{
 		Server: http.Server{
-			Addr:         fmt.Sprintf("%s:%d", host, port),
+			Addr:         buildListenAddr(host, port),
 			ReadTimeout:  5 * time.Second,
 			WriteTimeout: 5 * time.Second,
 			Handler:      router,
@@ -40,17 +52,56 @@ func NewAPI(logger logrus.FieldLogger, port int32, host, namespace string) *API
 		configuration: safe.New(provider.NewDefaultDynamicConfig()),
 		topology:      safe.New(topology.NewTopology()),
 		readiness:     safe.New(false),
+		status:        safe.New(controller.Status{}),
 		namespace:     namespace,
 		logger:        logger,
 	}
 
 	router.HandleFunc("/api/configuration", api.getConfiguration)
 	router.HandleFunc("/api/topology", api.getTopology)
+	router.HandleFunc("/api/topology/{namespace}/{service}", api.getServiceTopology)
 	router.HandleFunc("/api/ready", api.getReadiness)
+	router.HandleFunc("/api/status", api.getStatus)
+
+	if debug {
+		router.HandleFunc("/debug/config", api.getDebugConfiguration)
+	}
 
 	return api
 }
 
+// buildListenAddr returns the listen address of the given host and port, bracketing IPv6 addresses.
+func buildListenAddr(host string, port int32) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// ListenAndServeUnix listens on the Unix domain socket at the given path and serves the API on it, in addition to its
+// TCP address. A socket left at this path by a previous run is removed first.
+func (a *API) ListenAndServeUnix(path string) error {
+	info, err := os.Lstat(path)
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case info.Mode()&fs.ModeSocket == 0:
+		return fmt.Errorf("%q exists and is not a socket", path)
+	default:
+		if err = os.Remove(path); err != nil {
+			return fmt.Errorf("unable to remove stale socket %q: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	return a.Serve(listener)
+}
+
 // SetReadiness sets the readiness flag in the API.
 func (a *API) SetReadiness(isReady bool) {
 	a.readiness.Set(isReady)
@@ -67,18 +118,56 @@ func (a *API) SetTopology(topo *topology.Topology) {
 	a.topology.Set(topo)
 }
 
-// getConfiguration returns the current configuration.
-func (a *API) getConfiguration(w http.ResponseWriter, _ *http.Request) {
+// SetStatus sets the current reconciliation status.
+func (a *API) SetStatus(status controller.Status) {
+	a.status.Set(status)
+}
+
+// EnableMetrics serves the metrics of the given gatherer in the Prometheus format on the /metrics path.
+func (a *API) EnableMetrics(gatherer prometheus.Gatherer) {
+	a.Handler.(*mux.Router).Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+}
+
+// getConfiguration returns the current configuration. When the zone query parameter is set, the configuration of the
+// proxies of this zone is returned, see provider.ConfigurationForZone.
+func (a *API) getConfiguration(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if err := json.NewEncoder(w).Encode(a.configuration.Get()); err != nil {
+	cfg := a.configuration.Get()
+
+	if zone := r.URL.Query().Get("zone"); zone != "" {
+		if dynCfg, ok := cfg.(*dynamic.Configuration); ok {
+			cfg = provider.ConfigurationForZone(dynCfg, zone)
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(cfg); err != nil {
 		a.logger.Errorf("Unable to serialize configuration: %v", err)
 		http.Error(w, "", http.StatusInternalServerError)
 	}
 }
 
-// getTopology returns the current topology.
-func (a *API) getTopology(w http.ResponseWriter, _ *http.Request) {
+// getDebugConfiguration returns the current dynamic configuration, indented for troubleshooting.
+func (a *API) getDebugConfiguration(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(a.configuration.Get()); err != nil {
+		a.logger.Errorf("Unable to serialize configuration: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// getTopology returns the current topology. When the format query parameter is dot, the topology is returned as a
+// Graphviz DOT graph.
+func (a *API) getTopology(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "dot" {
+		a.getTopologyDOT(w)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	if err := json.NewEncoder(w).Encode(a.topology.Get()); err != nil {
@@ -87,11 +176,94 @@ func (a *API) getTopology(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-// getReadiness returns the current readiness value, and sets the status code to 500 if not ready.
+// getTopologyDOT returns the current topology as a Graphviz DOT graph.
+func (a *API) getTopologyDOT(w http.ResponseWriter) {
+	topo, ok := a.topology.Get().(*topology.Topology)
+	if !ok {
+		a.logger.Error("Unable to get topology")
+		http.Error(w, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	var buf bytes.Buffer
+
+	if err := topo.WriteDOT(&buf); err != nil {
+		a.logger.Errorf("Unable to serialize topology: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		a.logger.Errorf("Unable to write topology: %v", err)
+	}
+}
+
+// serviceTopology is the view of a single Service of the topology.
+type serviceTopology struct {
+	Service *topology.Service `json:"service"`
+	// Sources holds the sources authorized to access the Service by its TrafficTargets.
+	Sources []topology.ServiceTrafficTargetSource `json:"sources,omitempty"`
+	// Pods holds the Pods exposed by the Service.
+	Pods []*topology.Pod `json:"pods,omitempty"`
+	// Backends holds the backends of the TrafficSplits targeting the Service.
+	Backends []topology.TrafficSplitBackend `json:"backends,omitempty"`
+}
+
+// getServiceTopology returns the view of the requested Service in the current topology.
+func (a *API) getServiceTopology(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	topo, ok := a.topology.Get().(*topology.Topology)
+	if !ok {
+		a.logger.Error("Unable to get topology")
+		http.Error(w, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	svc, ok := topo.Services[topology.Key{Name: vars["service"], Namespace: vars["namespace"]}]
+	if !ok {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	view := serviceTopology{Service: svc}
+
+	for _, ttKey := range svc.TrafficTargets {
+		if tt, exists := topo.ServiceTrafficTargets[ttKey]; exists {
+			view.Sources = append(view.Sources, tt.Sources...)
+		}
+	}
+
+	for _, podKey := range svc.Pods {
+		if pod, exists := topo.Pods[podKey]; exists {
+			view.Pods = append(view.Pods, pod)
+		}
+	}
+
+	for _, tsKey := range svc.TrafficSplits {
+		if ts, exists := topo.TrafficSplits[tsKey]; exists {
+			view.Backends = append(view.Backends, ts.Backends...)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		a.logger.Errorf("Unable to serialize topology of Service %q in namespace %q: %v", svc.Name, svc.Namespace, err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// getReadiness returns the current readiness value, and sets the status code to 503 if not ready.
 func (a *API) getReadiness(w http.ResponseWriter, _ *http.Request) {
 	isReady, _ := a.readiness.Get().(bool)
 	if !isReady {
-		http.Error(w, "", http.StatusInternalServerError)
+		http.Error(w, "", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -102,3 +274,13 @@ func (a *API) getReadiness(w http.ResponseWriter, _ *http.Request) {
 		http.Error(w, "", http.StatusInternalServerError)
 	}
 }
+
+// getStatus returns the current reconciliation status of the controller.
+func (a *API) getStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(a.status.Get()); err != nil {
+		a.logger.Errorf("Unable to serialize status: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
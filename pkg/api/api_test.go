@@ -1,19 +1,29 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/v2/pkg/controller"
+	"github.com/traefik/mesh/v2/pkg/provider"
+	"github.com/traefik/mesh/v2/pkg/topology"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
 )
 
 var localhost = "127.0.0.1"
 
 func TestEnableReadiness(t *testing.T) {
-	api := NewAPI(logrus.New(), 9000, localhost, "foo")
+	api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
 
 	assert.Equal(t, false, api.readiness.Get().(bool))
 
@@ -36,7 +46,7 @@ func TestGetReadiness(t *testing.T) {
 		{
 			desc:               "not ready",
 			readiness:          false,
-			expectedStatusCode: http.StatusInternalServerError,
+			expectedStatusCode: http.StatusServiceUnavailable,
 		},
 	}
 
@@ -45,7 +55,7 @@ func TestGetReadiness(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			api := NewAPI(logrus.New(), 9000, localhost, "foo")
+			api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
 
 			api.readiness.Set(test.readiness)
 
@@ -61,8 +71,90 @@ func TestGetReadiness(t *testing.T) {
 	}
 }
 
+func TestGetReadiness_BeforeAndAfterFirstBuild(t *testing.T) {
+	api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
+
+	req, err := http.NewRequest(http.MethodGet, "/api/ready", nil)
+	require.NoError(t, err)
+
+	res := httptest.NewRecorder()
+	api.getReadiness(res, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+
+	// Simulate the controller signaling that the first topology has been built.
+	api.SetReadiness(true)
+
+	res = httptest.NewRecorder()
+	api.getReadiness(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+}
+
+func TestBuildListenAddr(t *testing.T) {
+	tests := []struct {
+		desc string
+		host string
+		want string
+	}{
+		{
+			desc: "all interfaces",
+			host: "",
+			want: ":9000",
+		},
+		{
+			desc: "IPv4 address",
+			host: "127.0.0.1",
+			want: "127.0.0.1:9000",
+		},
+		{
+			desc: "IPv6 address",
+			host: "::1",
+			want: "[::1]:9000",
+		},
+		{
+			desc: "bracketed IPv6 address",
+			host: "[::1]",
+			want: "[::1]:9000",
+		},
+		{
+			desc: "IPv6 unspecified address",
+			host: "::",
+			want: "[::]:9000",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, buildListenAddr(test.host, 9000))
+		})
+	}
+}
+
+func TestAPI_ServeReadinessOnIPv6Loopback(t *testing.T) {
+	api := NewAPI(logrus.New(), 0, "[::1]", "foo", false)
+	api.SetReadiness(true)
+
+	listener, err := net.Listen("tcp", api.Addr)
+	if err != nil {
+		t.Skipf("IPv6 loopback is not available: %v", err)
+	}
+
+	go func() { _ = api.Serve(listener) }()
+	defer api.Close()
+
+	res, err := http.Get("http://" + listener.Addr().String() + "/api/ready")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
 func TestGetConfiguration(t *testing.T) {
-	api := NewAPI(logrus.New(), 9000, localhost, "foo")
+	api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
 
 	api.configuration.Set("foo")
 
@@ -76,8 +168,112 @@ func TestGetConfiguration(t *testing.T) {
 	assert.Equal(t, "\"foo\"\n", res.Body.String())
 }
 
+func TestGetConfigurationForZone(t *testing.T) {
+	api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
+
+	cfg := provider.NewDefaultDynamicConfig()
+	cfg.HTTP.Services["my-ns-svc-a-8080"] = &dynamic.Service{
+		LoadBalancer: &dynamic.ServersLoadBalancer{
+			Servers: []dynamic.Server{{URL: "http://10.10.2.1:8080"}, {URL: "http://10.10.2.2:8080"}},
+		},
+	}
+	cfg.HTTP.Services["my-ns-svc-a-8080-zone-zone-a"] = &dynamic.Service{
+		LoadBalancer: &dynamic.ServersLoadBalancer{
+			Servers: []dynamic.Server{{URL: "http://10.10.2.1:8080"}},
+		},
+	}
+	api.SetConfiguration(cfg)
+
+	res := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/api/configuration?zone=zone-a", nil)
+	require.NoError(t, err)
+
+	api.Handler.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var got dynamic.Configuration
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+
+	assert.Equal(t, []dynamic.Server{{URL: "http://10.10.2.1:8080"}}, got.HTTP.Services["my-ns-svc-a-8080"].LoadBalancer.Servers)
+
+	// The stored configuration is left unchanged.
+	assert.Len(t, cfg.HTTP.Services["my-ns-svc-a-8080"].LoadBalancer.Servers, 2)
+}
+
+func TestGetStatus(t *testing.T) {
+	api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
+
+	api.SetStatus(controller.Status{
+		LastReconcileTime: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastError:         "unable to build topology: boom",
+		ResourceErrors:    2,
+		Stale:             true,
+	})
+
+	res := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/api/status", nil)
+	require.NoError(t, err)
+
+	api.Handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"lastReconcileTime":"2021-01-01T00:00:00Z","lastError":"unable to build topology: boom","resourceErrors":2,"stale":true}`, res.Body.String())
+}
+
+func TestGetDebugConfiguration(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		debug              bool
+		expectedStatusCode int
+	}{
+		{
+			desc:               "debug enabled",
+			debug:              true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "debug disabled",
+			debug:              false,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(logrus.New(), 9000, localhost, "foo", test.debug)
+
+			cfg := provider.NewDefaultDynamicConfig()
+			api.SetConfiguration(cfg)
+
+			res := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, "/debug/config", nil)
+			require.NoError(t, err)
+
+			api.Handler.ServeHTTP(res, req)
+
+			assert.Equal(t, test.expectedStatusCode, res.Code)
+
+			if !test.debug {
+				return
+			}
+
+			var got dynamic.Configuration
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+
+			assert.Equal(t, cfg, &got)
+		})
+	}
+}
+
 func TestGetTopology(t *testing.T) {
-	api := NewAPI(logrus.New(), 9000, localhost, "foo")
+	api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
 
 	api.topology.Set("foo")
 
@@ -90,3 +286,191 @@ func TestGetTopology(t *testing.T) {
 
 	assert.Equal(t, "\"foo\"\n", res.Body.String())
 }
+
+func TestGetTopologyDOT(t *testing.T) {
+	api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
+
+	svcKey := topology.Key{Name: "svc-a", Namespace: "my-ns"}
+
+	topo := topology.NewTopology()
+	topo.Services[svcKey] = &topology.Service{Name: svcKey.Name, Namespace: svcKey.Namespace}
+
+	api.topology.Set(topo)
+
+	res := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/api/topology?format=dot", nil)
+	require.NoError(t, err)
+
+	api.getTopology(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "text/vnd.graphviz", res.Header().Get("Content-Type"))
+	assert.Equal(t, "digraph mesh {\n  \"svc-a@my-ns\" [label=\"my-ns/svc-a\", shape=box];\n}\n", res.Body.String())
+}
+
+func TestGetServiceTopology(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		path               string
+		expectedStatusCode int
+		expectedService    string
+		expectedSources    []string
+		expectedPods       []string
+		expectedBackends   []string
+	}{
+		{
+			desc:               "service with traffic targets",
+			path:               "/api/topology/my-ns/svc-b",
+			expectedStatusCode: http.StatusOK,
+			expectedService:    "svc-b",
+			expectedSources:    []string{"client@my-ns"},
+			expectedPods:       []string{"pod-b@my-ns"},
+		},
+		{
+			desc:               "service with traffic split",
+			path:               "/api/topology/my-ns/svc-a",
+			expectedStatusCode: http.StatusOK,
+			expectedService:    "svc-a",
+			expectedBackends:   []string{"svc-b@my-ns", "svc-c@my-ns"},
+		},
+		{
+			desc:               "unknown service",
+			path:               "/api/topology/my-ns/svc-unknown",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			desc:               "unknown namespace",
+			path:               "/api/topology/unknown-ns/svc-a",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(logrus.New(), 9000, localhost, "foo", false)
+			api.SetTopology(loadTopology(t, "testdata/topology.json"))
+
+			res := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, test.path, nil)
+			require.NoError(t, err)
+
+			api.Handler.ServeHTTP(res, req)
+
+			require.Equal(t, test.expectedStatusCode, res.Code)
+
+			if test.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var got serviceTopology
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+
+			require.NotNil(t, got.Service)
+			assert.Equal(t, test.expectedService, got.Service.Name)
+
+			var sources []string
+			for _, source := range got.Sources {
+				sources = append(sources, topology.Key{Name: source.ServiceAccount, Namespace: source.Namespace}.String())
+			}
+
+			assert.Equal(t, test.expectedSources, sources)
+
+			var pods []string
+			for _, pod := range got.Pods {
+				pods = append(pods, topology.Key{Name: pod.Name, Namespace: pod.Namespace}.String())
+			}
+
+			assert.Equal(t, test.expectedPods, pods)
+
+			var backends []string
+			for _, backend := range got.Backends {
+				backends = append(backends, backend.Service.String())
+			}
+
+			assert.Equal(t, test.expectedBackends, backends)
+		})
+	}
+}
+
+func loadTopology(t *testing.T, filename string) *topology.Topology {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	var topo topology.Topology
+	require.NoError(t, json.Unmarshal(data, &topo))
+
+	return &topo
+}
+
+func TestAPI_ServeConfigurationOnUnixSocket(t *testing.T) {
+	api := NewAPI(logrus.New(), 0, localhost, "foo", false)
+	api.SetConfiguration(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{"foo": {Service: "bar"}},
+		},
+	})
+
+	path := filepath.Join(t.TempDir(), "api.sock")
+
+	// A stale socket left by a previous run doesn't prevent the API from listening.
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	errCh := make(chan error, 1)
+
+	go func() { errCh <- api.ListenAndServeUnix(path) }()
+	defer api.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	var res *http.Response
+
+	require.Eventually(t, func() bool {
+		res, err = client.Get("http://unix/api/configuration")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var got dynamic.Configuration
+
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+	assert.Equal(t, "bar", got.HTTP.Routers["foo"].Service)
+
+	select {
+	case err = <-errCh:
+		t.Fatalf("API stopped serving on the Unix socket: %v", err)
+	default:
+	}
+}
+
+func TestAPI_ListenAndServeUnixRefusesToRemoveFiles(t *testing.T) {
+	api := NewAPI(logrus.New(), 0, localhost, "foo", false)
+
+	path := filepath.Join(t.TempDir(), "api.sock")
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o600))
+
+	err := api.ListenAndServeUnix(path)
+	require.Error(t, err)
+
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
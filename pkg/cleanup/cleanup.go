@@ -2,6 +2,7 @@ package cleanup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/sirupsen/logrus"
@@ -19,9 +20,10 @@ type Cleanup struct {
 	logger     logrus.FieldLogger
 }
 
-// NewCleanup returns an initialized cleanup object.
-func NewCleanup(logger logrus.FieldLogger, kubeClient kubernetes.Interface, namespace string) *Cleanup {
-	dnsClient := dns.NewClient(logger, kubeClient)
+// NewCleanup returns an initialized cleanup object. The DNS configuration is restored in the given dnsNamespace, with a
+// DNS client configured by the given options.
+func NewCleanup(logger logrus.FieldLogger, kubeClient kubernetes.Interface, namespace, dnsNamespace string, dnsOpts ...dns.ClientOption) *Cleanup {
+	dnsClient := dns.NewClient(logger, kubeClient, append([]dns.ClientOption{dns.SystemNamespace(dnsNamespace)}, dnsOpts...)...)
 
 	return &Cleanup{
 		kubeClient: kubeClient,
@@ -59,7 +61,7 @@ func (c *Cleanup) RestoreDNSConfig(ctx context.Context) error {
 	// Restore configmaps based on DNS provider.
 	switch provider {
 	case dns.CoreDNS:
-		if err := c.dnsClient.RestoreCoreDNS(ctx); err != nil {
+		if err := c.restoreCoreDNS(ctx); err != nil {
 			return fmt.Errorf("unable to restore CoreDNS: %w", err)
 		}
 	case dns.KubeDNS:
@@ -70,3 +72,24 @@ func (c *Cleanup) RestoreDNSConfig(ctx context.Context) error {
 
 	return nil
 }
+
+// restoreCoreDNS restores the CoreDNS configuration from the snapshot taken when it was first configured, or by removing
+// the Traefik Mesh block if there is no snapshot.
+func (c *Cleanup) restoreCoreDNS(ctx context.Context) error {
+	snapshot, err := c.dnsClient.LoadCoreDNSSnapshot(ctx, c.namespace)
+	if errors.Is(err, dns.ErrSnapshotNotFound) {
+		_, err = c.dnsClient.RestoreCoreDNS(ctx)
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = c.dnsClient.RestoreCoreDNSFromSnapshot(ctx, snapshot); err != nil {
+		return err
+	}
+
+	return c.dnsClient.DeleteCoreDNSSnapshot(ctx, c.namespace)
+}
@@ -19,7 +19,7 @@ func TestCleanup_New(t *testing.T) {
 	logger.SetOutput(os.Stdout)
 	logger.SetLevel(logrus.DebugLevel)
 
-	cleanup := NewCleanup(logger, clientMock.KubernetesClient(), metav1.NamespaceDefault)
+	cleanup := NewCleanup(logger, clientMock.KubernetesClient(), metav1.NamespaceDefault, metav1.NamespaceSystem)
 	require.NotNil(t, cleanup)
 }
 
@@ -30,7 +30,7 @@ func TestCleanup_CleanShadowServices(t *testing.T) {
 	logger.SetOutput(os.Stdout)
 	logger.SetLevel(logrus.DebugLevel)
 
-	cleanup := NewCleanup(logger, clientMock.KubernetesClient(), "traefik-mesh")
+	cleanup := NewCleanup(logger, clientMock.KubernetesClient(), "traefik-mesh", metav1.NamespaceSystem)
 	require.NotNil(t, cleanup)
 
 	err := cleanup.CleanShadowServices(context.Background())
@@ -0,0 +1,187 @@
+package configfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/v2/pkg/safe"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported file formats.
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// Writer writes the dynamic configuration to a file, to be loaded by the Traefik file provider.
+type Writer struct {
+	path   string
+	format string
+	logger logrus.FieldLogger
+
+	configuration *safe.Safe
+
+	mu sync.Mutex
+	// written is the content of the last written file.
+	written []byte
+}
+
+// NewWriter creates a new Writer of the file at the given path. The format of the file, YAML or TOML, is deduced from
+// its extension.
+func NewWriter(logger logrus.FieldLogger, path string) (*Writer, error) {
+	format, err := getFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		path:          path,
+		format:        format,
+		logger:        logger,
+		configuration: safe.New((*dynamic.Configuration)(nil)),
+	}, nil
+}
+
+// getFormat returns the format of the file at the given path from its extension.
+func getFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("unsupported extension of file %q, it must be .yaml, .yml or .toml", path)
+	}
+}
+
+// SetConfiguration sets the dynamic configuration written on the next write.
+func (w *Writer) SetConfiguration(cfg *dynamic.Configuration) {
+	w.configuration.Set(cfg)
+}
+
+// Run writes the configuration every interval until the given context is done. The configuration is written one last
+// time before returning.
+func (w *Writer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := w.Write(); err != nil {
+				w.logger.Errorf("Unable to write configuration file %q: %v", w.path, err)
+			}
+
+			return
+
+		case <-ticker.C:
+			if err := w.Write(); err != nil {
+				w.logger.Errorf("Unable to write configuration file %q: %v", w.path, err)
+			}
+		}
+	}
+}
+
+// Write writes the configuration to the file, unless no configuration has been set yet or the file content would be
+// the same. The file is replaced atomically, so that the file provider never loads a partially written file.
+func (w *Writer) Write() error {
+	cfg, ok := w.configuration.Get().(*dynamic.Configuration)
+	if !ok || cfg == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	content, err := marshal(cfg, w.format)
+	if err != nil {
+		return fmt.Errorf("unable to marshal configuration: %w", err)
+	}
+
+	if w.written != nil && bytes.Equal(content, w.written) {
+		return nil
+	}
+
+	if err = writeFileAtomic(w.path, content); err != nil {
+		return err
+	}
+
+	w.written = content
+	w.logger.Debugf("Configuration file %q has been written", w.path)
+
+	return nil
+}
+
+func marshal(cfg *dynamic.Configuration, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(cfg)
+
+	case FormatTOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// writeFileAtomic writes the given content to a temporary file of the directory of the given path, and renames it to
+// the given path.
+func writeFileAtomic(path string, content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	tmpPath := file.Name()
+
+	if _, err = file.Write(content); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to write temporary file %q: %w", tmpPath, err)
+	}
+
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to sync temporary file %q: %w", tmpPath, err)
+	}
+
+	if err = file.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to close temporary file %q: %w", tmpPath, err)
+	}
+
+	// Temporary files are only readable by their owner, while the file provider may run as another user.
+	if err = os.Chmod(tmpPath, 0o644); err != nil {
+		_ = os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to set permissions of temporary file %q: %w", tmpPath, err)
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to rename temporary file %q: %w", tmpPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package configfile
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewWriter(t *testing.T) {
+	tests := []struct {
+		desc       string
+		path       string
+		wantFormat string
+		wantErr    bool
+	}{
+		{
+			desc:       "yaml",
+			path:       "/etc/traefik/mesh.yaml",
+			wantFormat: FormatYAML,
+		},
+		{
+			desc:       "yml",
+			path:       "/etc/traefik/mesh.YML",
+			wantFormat: FormatYAML,
+		},
+		{
+			desc:       "toml",
+			path:       "/etc/traefik/mesh.toml",
+			wantFormat: FormatTOML,
+		},
+		{
+			desc:    "json",
+			path:    "/etc/traefik/mesh.json",
+			wantErr: true,
+		},
+		{
+			desc:    "no extension",
+			path:    "/etc/traefik/mesh",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			w, err := NewWriter(newLogger(), test.path)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantFormat, w.format)
+		})
+	}
+}
+
+func TestWriter_Write(t *testing.T) {
+	tests := []struct {
+		desc      string
+		filename  string
+		unmarshal func(data []byte, v interface{}) error
+	}{
+		{
+			desc:      "yaml",
+			filename:  "mesh.yaml",
+			unmarshal: yaml.Unmarshal,
+		},
+		{
+			desc:      "toml",
+			filename:  "mesh.toml",
+			unmarshal: toml.Unmarshal,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, test.filename)
+
+			w, err := NewWriter(newLogger(), path)
+			require.NoError(t, err)
+
+			// Nothing is written until a configuration is set.
+			require.NoError(t, w.Write())
+			assert.NoFileExists(t, path)
+
+			w.SetConfiguration(newConfiguration("10.10.2.1"))
+			require.NoError(t, w.Write())
+
+			assertConfigurationFile(t, path, test.unmarshal, "10.10.2.1")
+
+			// The file is replaced when the configuration changes.
+			w.SetConfiguration(newConfiguration("10.10.2.2"))
+			require.NoError(t, w.Write())
+
+			assertConfigurationFile(t, path, test.unmarshal, "10.10.2.2")
+
+			// No temporary file is left in the directory.
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, test.filename, entries[0].Name())
+		})
+	}
+}
+
+func TestWriter_WriteUnchangedConfiguration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mesh.yaml")
+
+	w, err := NewWriter(newLogger(), path)
+	require.NoError(t, err)
+
+	w.SetConfiguration(newConfiguration("10.10.2.1"))
+	require.NoError(t, w.Write())
+
+	// Replace the file to detect whether it gets written again.
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o600))
+
+	w.SetConfiguration(newConfiguration("10.10.2.1"))
+	require.NoError(t, w.Write())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+}
+
+func TestWriter_WriteAtomically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mesh.yaml")
+
+	w, err := NewWriter(newLogger(), path)
+	require.NoError(t, err)
+
+	w.SetConfiguration(newConfiguration("10.10.2.1"))
+	require.NoError(t, w.Write())
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	w.SetConfiguration(newConfiguration("10.10.2.2"))
+	require.NoError(t, w.Write())
+
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+
+	// The file is replaced by a new one rather than rewritten in place, so that a reader never sees a partial file.
+	assert.False(t, os.SameFile(before, after))
+	assert.Equal(t, os.FileMode(0o644), after.Mode().Perm())
+}
+
+func TestWriter_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mesh.yaml")
+
+	w, err := NewWriter(newLogger(), path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		w.Run(ctx, 10*time.Millisecond)
+	}()
+
+	w.SetConfiguration(newConfiguration("10.10.2.1"))
+
+	assert.Eventually(t, func() bool {
+		return readServerURL(path) == "http://10.10.2.1:8080"
+	}, time.Second, 10*time.Millisecond)
+
+	w.SetConfiguration(newConfiguration("10.10.2.2"))
+
+	assert.Eventually(t, func() bool {
+		return readServerURL(path) == "http://10.10.2.2:8080"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func assertConfigurationFile(t *testing.T, path string, unmarshal func(data []byte, v interface{}) error, serverIP string) {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var got dynamic.Configuration
+	require.NoError(t, unmarshal(content, &got))
+
+	want := newConfiguration(serverIP)
+
+	require.NotNil(t, got.HTTP)
+	assert.Equal(t, want.HTTP.Routers, got.HTTP.Routers)
+	require.Contains(t, got.HTTP.Services, "my-ns-svc-a-8080")
+	assert.Equal(t, want.HTTP.Services["my-ns-svc-a-8080"].LoadBalancer.Servers, got.HTTP.Services["my-ns-svc-a-8080"].LoadBalancer.Servers)
+
+	require.NotNil(t, got.TCP)
+	assert.Equal(t, want.TCP.Routers, got.TCP.Routers)
+}
+
+func readServerURL(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var cfg dynamic.Configuration
+	if err = yaml.Unmarshal(content, &cfg); err != nil || cfg.HTTP == nil {
+		return ""
+	}
+
+	svc, ok := cfg.HTTP.Services["my-ns-svc-a-8080"]
+	if !ok || svc.LoadBalancer == nil || len(svc.LoadBalancer.Servers) == 0 {
+		return ""
+	}
+
+	return svc.LoadBalancer.Servers[0].URL
+}
+
+func newConfiguration(serverIP string) *dynamic.Configuration {
+	return &dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"my-ns-svc-a-8080": {
+					EntryPoints: []string{"http-5000"},
+					Service:     "my-ns-svc-a-8080",
+					Rule:        "Host(`svc-a.my-ns.traefik.mesh`) || Host(`10.10.14.1`)",
+					Priority:    1000054,
+				},
+			},
+			Services: map[string]*dynamic.Service{
+				"my-ns-svc-a-8080": {
+					LoadBalancer: &dynamic.ServersLoadBalancer{
+						Servers: []dynamic.Server{
+							{URL: "http://" + serverIP + ":8080"},
+						},
+					},
+				},
+			},
+		},
+		TCP: &dynamic.TCPConfiguration{
+			Routers: map[string]*dynamic.TCPRouter{
+				"my-ns-svc-b-8080": {
+					EntryPoints: []string{"tcp-10000"},
+					Service:     "my-ns-svc-b-8080",
+					Rule:        "HostSNI(`*`)",
+				},
+			},
+		},
+	}
+}
+
+func newLogger() logrus.FieldLogger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return logger
+}
@@ -2,10 +2,17 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	split "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/split/v1alpha3"
 	accessinformer "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/access/informers/externalversions"
 	accesslister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/access/listers/access/v1alpha2"
 	specsinformer "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/specs/informers/externalversions"
@@ -20,12 +27,18 @@ import (
 	"github.com/traefik/mesh/v2/pkg/provider"
 	"github.com/traefik/mesh/v2/pkg/topology"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	listers "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -33,17 +46,55 @@ const (
 	// configRefreshKey is the work queue key used to indicate that config has to be refreshed.
 	configRefreshKey = "refresh"
 
+	// configBuildKey is the work queue key used to build and publish the config once the refresh interval is elapsed.
+	configBuildKey = "build"
+
+	// smiAvailableKey is the work queue key used to indicate that some SMI CRDs have been installed.
+	smiAvailableKey = "smi-available"
+
+	// smiDiscoveryInterval is the interval at which the SMI CRDs availability is checked when they are not installed.
+	smiDiscoveryInterval = 30 * time.Second
+
+	// shadowServicesGCKey is the work queue key used to delete the shadow services whose service doesn't exist anymore.
+	shadowServicesGCKey = "shadow-services-gc"
+
+	// settingsKey is the work queue key used to indicate that the settings ConfigMap has changed.
+	settingsKey = "settings"
+
+	// shadowServicesGCInterval is the interval at which the orphan shadow services are deleted.
+	shadowServicesGCInterval = 5 * time.Minute
+
 	// maxRetries is the number of times a work task will be retried before it is dropped out of the queue.
 	// With the current rate-limiter in use (5ms*2^(maxRetries-1)) the following numbers represent the times a
 	// work task is going to be re-queued: 5ms, 10ms, 20ms, 40ms, 80ms, 160ms, 320ms, 640ms, 1.3s, 2.6s, 5.1s, 10.2s.
 	maxRetries = 12
+
+	// basicAuthUsersKey is the key of the secrets data holding the htpasswd users of a basic-auth middleware.
+	basicAuthUsersKey = "users"
 )
 
+// serviceMiddlewaresKey is the work queue key used to indicate that only the middlewares of the service with the
+// given meta namespace key have changed.
+type serviceMiddlewaresKey string
+
 // SharedStore is used to share the controller state.
 type SharedStore interface {
 	SetConfiguration(cfg *dynamic.Configuration)
 	SetTopology(topo *topology.Topology)
 	SetReadiness(isReady bool)
+	SetStatus(status Status)
+}
+
+// Status is the status of the reconciliation of the topology and the configuration.
+type Status struct {
+	// LastReconcileTime is the time of the last attempt to build the topology and the configuration.
+	LastReconcileTime time.Time `json:"lastReconcileTime"`
+	// LastError is the error of the last attempt, empty if it succeeded.
+	LastError string `json:"lastError,omitempty"`
+	// ResourceErrors is the number of resources of the published topology whose configuration couldn't be built.
+	ResourceErrors int `json:"resourceErrors"`
+	// Stale is set when the published configuration doesn't reflect the latest changes, as the last attempt failed.
+	Stale bool `json:"stale"`
 }
 
 // TopologyBuilder builds Topologies.
@@ -54,6 +105,7 @@ type TopologyBuilder interface {
 // Config holds the configuration of the controller.
 type Config struct {
 	ACLEnabled       bool
+	ACLSourceHeader  string
 	DefaultMode      string
 	Namespace        string
 	WatchNamespaces  []string
@@ -64,6 +116,39 @@ type Config struct {
 	MaxTCPPort       int32
 	MinUDPPort       int32
 	MaxUDPPort       int32
+	// ConfigRefreshInterval is the window in which the changes are coalesced into a single configuration build. The
+	// configuration is built on every change when zero.
+	ConfigRefreshInterval time.Duration
+	// ResyncPeriod is the period at which the informers resync their cache, each resync triggers a full topology
+	// rebuild. Resyncs are disabled when zero.
+	ResyncPeriod time.Duration
+	// NoShadowService disables the shadow services. The ports of the services are still mapped to ports on the
+	// proxies, and the TrafficSplit backends and mirror services are reached through their ClusterIP.
+	NoShadowService bool
+	// ShadowTrafficPolicy is the internal traffic policy of the shadow services, the cluster default when nil. It is
+	// only set when the shadow services are created. The shadow services are always ClusterIP services, as the
+	// clients of a headless service would reach the proxies on the service ports instead of the mapped ones.
+	ShadowTrafficPolicy *corev1.ServiceInternalTrafficPolicyType
+	// DefaultMiddlewares are the middlewares referenced by the routers of every HTTP service, unless disabled by its
+	// default-middlewares annotation.
+	DefaultMiddlewares []annotations.MiddlewareRef
+	// ACLDefaultAllow makes the services which are not the destination of any TrafficTarget reachable by all the
+	// clients in ACL mode. Their traffic is forbidden otherwise.
+	ACLDefaultAllow bool
+	// ZoneAware enables the zone-aware routing. The Nodes are watched to assign their zone to the pods of the
+	// topology, which requires to list and watch them.
+	ZoneAware bool
+	// LeaderElection enables the leader election when set. Only the leader manages the shadow services and publishes
+	// the configuration, the other instances keep their informers in sync.
+	LeaderElection *k8s.LeaderElectionConfig
+	// MetricsRegisterer is the registerer of the controller metrics. The metrics are not exposed when nil.
+	MetricsRegisterer prometheus.Registerer
+	// SettingsConfigMap is the name of the ConfigMap, in the controller namespace, holding the settings applied at
+	// runtime. The settings are not watched when empty.
+	SettingsConfigMap string
+	// SMILabelSelector is the label selector the TrafficTargets and TrafficSplits must match to be part of the
+	// topology. All of them are when empty.
+	SMILabelSelector string
 }
 
 // Controller hold controller configuration.
@@ -73,6 +158,7 @@ type Controller struct {
 
 	cfg                  Config
 	workQueue            workqueue.RateLimitingInterface
+	eventBroadcaster     record.EventBroadcaster
 	shadowServiceManager *ShadowServiceManager
 	provider             *provider.Provider
 	resourceFilter       *k8s.ResourceFilter
@@ -81,20 +167,43 @@ type Controller struct {
 	udpStateTable        *portmapping.PortMapping
 	topologyBuilder      TopologyBuilder
 	store                SharedStore
+	metrics              *metrics
 	logger               logrus.FieldLogger
 
+	// ready is set once the first topology has been built and the first configuration has been generated.
+	ready bool
+	// smiGroups holds the SMI API groups whose informers are started. Topologies are built only with the SMI
+	// resources of these groups.
+	smiGroups k8s.SMIGroups
+	// leadershipLost is set when the controller is stopped because it lost the leadership.
+	leadershipLost bool
+	// topology and configuration are the last topology and configuration shared through the store.
+	topology      *topology.Topology
+	configuration *dynamic.Configuration
+	// status is the last reconciliation status shared through the store.
+	status Status
+	// baseSettings are the settings given at startup, restored when they are removed from the settings ConfigMap.
+	// settings are the settings currently applied.
+	baseSettings settings
+	settings     settings
+
 	clients              k8s.Client
 	kubernetesFactory    informers.SharedInformerFactory
 	accessFactory        accessinformer.SharedInformerFactory
 	specsFactory         specsinformer.SharedInformerFactory
 	splitFactory         splitinformer.SharedInformerFactory
 	podLister            listers.PodLister
+	nodeLister           listers.NodeLister
 	serviceLister        listers.ServiceLister
+	secretLister         listers.SecretLister
 	endpointsLister      listers.EndpointsLister
+	endpointSliceLister  discoverylisters.EndpointSliceLister
 	trafficTargetLister  accesslister.TrafficTargetLister
 	httpRouteGroupLister specslister.HTTPRouteGroupLister
 	tcpRouteLister       specslister.TCPRouteLister
 	trafficSplitLister   splitlister.TrafficSplitLister
+	settingsFactory      informers.SharedInformerFactory
+	settingsLister       listers.ConfigMapLister
 }
 
 // NewMeshController builds the informers and other required components of the mesh controller, and returns an
@@ -105,6 +214,7 @@ func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger
 		cfg:     cfg,
 		clients: clients,
 		store:   store,
+		metrics: newMetrics(cfg.MetricsRegisterer),
 		stopCh:  make(chan struct{}),
 	}
 
@@ -125,26 +235,47 @@ func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger
 	}
 
 	// Create SharedInformers, listers and register the event handler to informers that are not ACL related.
-	c.kubernetesFactory = informers.NewSharedInformerFactoryWithOptions(c.clients.KubernetesClient(), k8s.ResyncPeriod)
-	c.splitFactory = splitinformer.NewSharedInformerFactoryWithOptions(c.clients.SplitClient(), k8s.ResyncPeriod)
-	c.specsFactory = specsinformer.NewSharedInformerFactoryWithOptions(c.clients.SpecsClient(), k8s.ResyncPeriod)
+	c.kubernetesFactory = informers.NewSharedInformerFactoryWithOptions(c.clients.KubernetesClient(), c.cfg.ResyncPeriod)
+	c.splitFactory = splitinformer.NewSharedInformerFactoryWithOptions(c.clients.SplitClient(), c.cfg.ResyncPeriod,
+		splitinformer.WithTweakListOptions(c.selectSMIResources),
+	)
+	c.specsFactory = specsinformer.NewSharedInformerFactoryWithOptions(c.clients.SpecsClient(), c.cfg.ResyncPeriod)
 
 	c.podLister = c.kubernetesFactory.Core().V1().Pods().Lister()
-	c.endpointsLister = c.kubernetesFactory.Core().V1().Endpoints().Lister()
 	c.serviceLister = c.kubernetesFactory.Core().V1().Services().Lister()
+	c.secretLister = c.kubernetesFactory.Core().V1().Secrets().Lister()
 	c.trafficSplitLister = c.splitFactory.Split().V1alpha3().TrafficSplits().Lister()
 	c.httpRouteGroupLister = c.specsFactory.Specs().V1alpha3().HTTPRouteGroups().Lister()
 	c.tcpRouteLister = c.specsFactory.Specs().V1alpha3().TCPRoutes().Lister()
 
+	// Node updates are not enqueued: the node zones are read on each build, and zone changes are picked up by the next
+	// one.
+	if c.cfg.ZoneAware {
+		c.nodeLister = c.kubernetesFactory.Core().V1().Nodes().Lister()
+	}
+
 	c.kubernetesFactory.Core().V1().Services().Informer().AddEventHandler(handler)
-	c.kubernetesFactory.Core().V1().Endpoints().Informer().AddEventHandler(handler)
+	// Secrets hold the users of the basic-auth middlewares, which must be updated when they change.
+	c.kubernetesFactory.Core().V1().Secrets().Informer().AddEventHandler(handler)
+
+	// The pods of a service are resolved using EndpointSlices when they are served by the cluster, Endpoints otherwise.
+	if c.isEndpointSliceAvailable() {
+		c.endpointSliceLister = c.kubernetesFactory.Discovery().V1().EndpointSlices().Lister()
+		c.kubernetesFactory.Discovery().V1().EndpointSlices().Informer().AddEventHandler(handler)
+	} else {
+		c.endpointsLister = c.kubernetesFactory.Core().V1().Endpoints().Lister()
+		c.kubernetesFactory.Core().V1().Endpoints().Informer().AddEventHandler(handler)
+	}
+
 	c.splitFactory.Split().V1alpha3().TrafficSplits().Informer().AddEventHandler(handler)
 	c.specsFactory.Specs().V1alpha3().HTTPRouteGroups().Informer().AddEventHandler(handler)
 	c.specsFactory.Specs().V1alpha3().TCPRoutes().Informer().AddEventHandler(handler)
 
 	// Create SharedInformers, listers and register the event handler for ACL related resources.
 	if c.cfg.ACLEnabled {
-		c.accessFactory = accessinformer.NewSharedInformerFactoryWithOptions(c.clients.AccessClient(), k8s.ResyncPeriod)
+		c.accessFactory = accessinformer.NewSharedInformerFactoryWithOptions(c.clients.AccessClient(), c.cfg.ResyncPeriod,
+			accessinformer.WithTweakListOptions(c.selectSMIResources),
+		)
 
 		c.trafficTargetLister = c.accessFactory.Access().V1alpha2().TrafficTargets().Lister()
 
@@ -152,10 +283,28 @@ func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger
 		c.kubernetesFactory.Core().V1().Pods().Informer().AddEventHandler(handler)
 	}
 
+	// The settings ConfigMap is watched on its own, so that the other ConfigMaps of the cluster are not cached.
+	if c.cfg.SettingsConfigMap != "" {
+		c.settingsFactory = informers.NewSharedInformerFactoryWithOptions(c.clients.KubernetesClient(), c.cfg.ResyncPeriod,
+			informers.WithNamespace(c.cfg.Namespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", c.cfg.SettingsConfigMap).String()
+			}),
+		)
+
+		c.settingsLister = c.settingsFactory.Core().V1().ConfigMaps().Lister()
+		c.settingsFactory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+			FilterFunc: c.isSettingsConfigMap,
+			Handler:    &enqueueSettingsHandler{workQueue: c.workQueue},
+		})
+	}
+
 	c.httpStateTable = portmapping.NewMultiplexedPortMapping(c.cfg.MinHTTPPort, c.cfg.MaxHTTPPort)
 	c.tcpStateTable = portmapping.NewPortMapping(c.cfg.MinTCPPort, c.cfg.MaxTCPPort)
 	c.udpStateTable = portmapping.NewPortMapping(c.cfg.MinUDPPort, c.cfg.MaxUDPPort)
 
+	c.eventBroadcaster = record.NewBroadcaster()
+
 	c.shadowServiceManager = &ShadowServiceManager{
 		namespace:          c.cfg.Namespace,
 		serviceLister:      c.serviceLister,
@@ -164,23 +313,28 @@ func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger
 		udpStateTable:      c.udpStateTable,
 		defaultTrafficType: c.cfg.DefaultMode,
 		kubeClient:         c.clients.KubernetesClient(),
+		eventRecorder:      c.eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: k8s.AppName}),
 		logger:             c.logger,
+		noShadowService:    c.cfg.NoShadowService,
+		trafficPolicy:      c.cfg.ShadowTrafficPolicy,
 	}
 
-	c.topologyBuilder = topology.NewBuilder(
-		c.serviceLister,
-		c.endpointsLister,
-		c.podLister,
-		c.trafficTargetLister,
-		c.trafficSplitLister,
-		c.httpRouteGroupLister,
-		c.tcpRouteLister,
-		c.logger,
-	)
+	c.topologyBuilder = c.newTopologyBuilder()
+
+	c.baseSettings = settings{
+		logLevel:              getLogLevel(c.logger),
+		configRefreshInterval: c.cfg.ConfigRefreshInterval,
+		aclDefaultAllow:       c.cfg.ACLDefaultAllow,
+	}
+	c.settings = c.baseSettings
 
 	providerCfg := provider.Config{
 		ACL:                c.cfg.ACLEnabled,
 		DefaultTrafficType: c.cfg.DefaultMode,
+		ACLSourceHeader:    c.cfg.ACLSourceHeader,
+		ACLDefaultAllow:    c.cfg.ACLDefaultAllow,
+		DefaultMiddlewares: c.cfg.DefaultMiddlewares,
+		NoShadowService:    c.cfg.NoShadowService,
 	}
 
 	c.provider = provider.New(
@@ -188,6 +342,7 @@ func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger
 		c.tcpStateTable,
 		c.udpStateTable,
 		annotations.BuildMiddlewares,
+		c.getBasicAuthUsers,
 		providerCfg,
 		c.logger,
 	)
@@ -211,18 +366,64 @@ func (c *Controller) Run() error {
 
 	c.logger.Debug("Initializing mesh controller")
 
+	_, err := c.getSMIGroups()
+	if err != nil {
+		return err
+	}
+
 	// Start the informers.
-	if err := c.startInformers(10 * time.Second); err != nil {
+	if err = c.startInformers(10 * time.Second); err != nil {
 		return fmt.Errorf("could not start informers: %w", err)
 	}
 
+	// Apply the settings before the first build, the next changes are applied by the worker.
+	if c.settingsLister != nil {
+		if err = c.loadSettings(); err != nil {
+			c.logger.Errorf("Unable to load settings, using the startup ones: %v", err)
+		}
+	}
+
+	// Only the SMI resources whose CRDs are installed are used, the missing ones are enabled once they are detected.
+	if err = c.enableSMI(10 * time.Second); err != nil {
+		return fmt.Errorf("could not enable SMI support: %w", err)
+	}
+
+	if len(c.missingSMIGroups(c.smiGroups)) > 0 {
+		go c.watchSMIAvailability(smiDiscoveryInterval)
+	}
+
+	// Only the leader manages the shadow services, its port mappings must be loaded once it is elected.
+	if c.cfg.LeaderElection != nil {
+		var elected bool
+
+		elected, err = c.waitForLeadership()
+		if err != nil {
+			return fmt.Errorf("could not run leader election: %w", err)
+		}
+
+		if !elected {
+			return c.stopErr()
+		}
+	}
+
 	// Load port mappings.
-	if err := c.shadowServiceManager.LoadPortMapping(); err != nil {
+	if err = c.shadowServiceManager.LoadPortMapping(); err != nil {
 		return fmt.Errorf("could not load port mapper states: %w", err)
 	}
 
-	// Enable API readiness endpoint, informers are started and default conf is available.
-	c.store.SetReadiness(true)
+	c.eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: c.clients.KubernetesClient().CoreV1().Events(""),
+	})
+	defer c.eventBroadcaster.Shutdown()
+
+	// Periodically delete the shadow services whose service deletion has been missed.
+	if !c.cfg.NoShadowService {
+		go wait.Until(func() { c.workQueue.Add(shadowServicesGCKey) }, shadowServicesGCInterval, c.stopCh)
+	}
+
+	// Make sure a first topology is built even if no event is received from the informers. The API readiness
+	// endpoint will be enabled once this first build succeeds.
+	c.workQueue.Add(configRefreshKey)
 
 	// Start to poll work from the queue.
 	waitGroup.Add(1)
@@ -236,6 +437,62 @@ func (c *Controller) Run() error {
 
 	<-c.stopCh
 
+	return c.stopErr()
+}
+
+// waitForLeadership runs the leader election in the background and blocks until this instance is elected or the
+// controller is stopped. It returns true if this instance is elected. The controller is stopped when the leadership is
+// lost.
+func (c *Controller) waitForLeadership() (bool, error) {
+	ctx := cmd.ContextWithStopChan(context.Background(), c.stopCh)
+
+	elected := make(chan struct{})
+	errCh := make(chan error, 1)
+
+	go func() {
+		onStartedLeading := func(_ context.Context) { close(elected) }
+
+		if err := k8s.RunLeaderElection(ctx, c.clients.KubernetesClient(), *c.cfg.LeaderElection, c.logger, onStartedLeading, c.stopLeading); err != nil {
+			errCh <- err
+		}
+	}()
+
+	c.logger.Infof("Waiting for the leadership of Lease %s/%s", c.cfg.LeaderElection.Namespace, c.cfg.LeaderElection.LeaseName)
+
+	select {
+	case <-elected:
+		return true, nil
+	case err := <-errCh:
+		return false, err
+	case <-c.stopCh:
+		return false, nil
+	}
+}
+
+// stopLeading stops the controller when the leader election stops while the controller is running, meaning the
+// leadership has been lost.
+func (c *Controller) stopLeading() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.stopCh:
+		// The leader election stopped because the controller is shutting down.
+	default:
+		c.leadershipLost = true
+		close(c.stopCh)
+	}
+}
+
+// stopErr returns the reason why the controller stopped, nil if it has been shut down.
+func (c *Controller) stopErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.leadershipLost {
+		return errors.New("leadership lost")
+	}
+
 	return nil
 }
 
@@ -253,26 +510,182 @@ func (c *Controller) Shutdown() {
 	}
 }
 
-// startInformers starts the controller informers.
+// startInformers starts the controller informers which don't depend on SMI CRDs.
 func (c *Controller) startInformers(syncTimeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(cmd.ContextWithStopChan(context.Background(), c.stopCh), syncTimeout)
 	defer cancel()
 
 	c.logger.Debug("Starting Informers")
 
-	if err := c.startBaseInformers(ctx.Done()); err != nil {
+	return c.startBaseInformers(ctx.Done())
+}
+
+// enableSMI starts the informers of the installed SMI API groups which are not started yet, and switches the topology
+// builder to use their resources. The missing SMI API groups are reported, as the features relying on them are
+// disabled until they are installed.
+func (c *Controller) enableSMI(syncTimeout time.Duration) error {
+	groups, err := c.getSMIGroups()
+	if err != nil {
 		return err
 	}
 
-	if c.cfg.ACLEnabled {
-		if err := c.startACLInformers(ctx.Done()); err != nil {
+	ctx, cancel := context.WithTimeout(cmd.ContextWithStopChan(context.Background(), c.stopCh), syncTimeout)
+	defer cancel()
+
+	enabled := c.smiGroups
+
+	if groups.Split && !enabled.Split {
+		c.logger.Debug("Starting TrafficSplit Informers")
+
+		if err = startInformerFactory(c.splitFactory, ctx.Done(), c.stopCh); err != nil {
 			return err
 		}
+
+		enabled.Split = true
 	}
 
+	if groups.Specs && !enabled.Specs {
+		c.logger.Debug("Starting HTTPRouteGroup and TCPRoute Informers")
+
+		if err = startInformerFactory(c.specsFactory, ctx.Done(), c.stopCh); err != nil {
+			return err
+		}
+
+		enabled.Specs = true
+	}
+
+	if c.cfg.ACLEnabled && groups.Access && !enabled.Access {
+		c.logger.Debug("Starting TrafficTarget Informers")
+
+		if err = startInformerFactory(c.accessFactory, ctx.Done(), c.stopCh); err != nil {
+			return err
+		}
+
+		enabled.Access = true
+	}
+
+	if enabled != c.smiGroups {
+		c.smiGroups = enabled
+		c.topologyBuilder = c.newTopologyBuilder()
+	}
+
+	if missing := c.missingSMIGroups(enabled); len(missing) > 0 {
+		c.logger.Warnf("SMI CRDs are not installed for %s, the related features are disabled", strings.Join(missing, ", "))
+		return nil
+	}
+
+	c.logger.Info("SMI support enabled")
+
 	return nil
 }
 
+// getSMIGroups returns the SMI API groups installed in the cluster. An error is returned if the installed SMI CRDs
+// versions are not supported.
+func (c *Controller) getSMIGroups() (k8s.SMIGroups, error) {
+	groups, err := k8s.GetSMIGroups(c.clients.KubernetesClient())
+	if err != nil {
+		return k8s.SMIGroups{}, fmt.Errorf("unsupported SMI version: %w", err)
+	}
+
+	return groups, nil
+}
+
+// missingSMIGroups returns the names of the SMI API groups required by the controller configuration which are not in
+// the given groups.
+func (c *Controller) missingSMIGroups(groups k8s.SMIGroups) []string {
+	var missing []string
+
+	if !groups.Split {
+		missing = append(missing, split.SchemeGroupVersion.Group)
+	}
+
+	if !groups.Specs {
+		missing = append(missing, specs.SchemeGroupVersion.Group)
+	}
+
+	if c.cfg.ACLEnabled && !groups.Access {
+		missing = append(missing, access.SchemeGroupVersion.Group)
+	}
+
+	return missing
+}
+
+// isEndpointSliceAvailable returns true if the EndpointSlice API is served by the cluster, false otherwise.
+func (c *Controller) isEndpointSliceAvailable() bool {
+	available, err := k8s.IsEndpointSliceAvailable(c.clients.KubernetesClient())
+	if err != nil {
+		c.logger.Errorf("Unable to check EndpointSlice API availability, falling back to Endpoints: %v", err)
+		return false
+	}
+
+	return available
+}
+
+// watchSMIAvailability periodically checks which SMI CRDs are installed, and asks the worker to enable SMI support
+// each time new ones are detected. It stops once all the required SMI CRDs are installed.
+func (c *Controller) watchSMIAvailability(interval time.Duration) {
+	last := c.smiGroups
+
+	_ = wait.PollUntil(interval, func() (bool, error) {
+		groups, err := c.getSMIGroups()
+		if err != nil {
+			c.logger.Errorf("Unable to check SMI CRDs availability: %v", err)
+			return false, nil
+		}
+
+		// Access groups are ignored when ACL is disabled, as they are not used.
+		if !c.cfg.ACLEnabled {
+			groups.Access = false
+		}
+
+		if groups != last {
+			c.logger.Info("New SMI CRDs have been detected")
+			c.workQueue.Add(smiAvailableKey)
+
+			last = groups
+		}
+
+		return len(c.missingSMIGroups(groups)) == 0, nil
+	}, c.stopCh)
+}
+
+// newTopologyBuilder creates a topology builder. SMI listers are given to the builder only when the informers of
+// their API group are started.
+func (c *Controller) newTopologyBuilder() TopologyBuilder {
+	var (
+		trafficTargetLister  accesslister.TrafficTargetLister
+		trafficSplitLister   splitlister.TrafficSplitLister
+		httpRouteGroupLister specslister.HTTPRouteGroupLister
+		tcpRouteLister       specslister.TCPRouteLister
+	)
+
+	if c.smiGroups.Access {
+		trafficTargetLister = c.trafficTargetLister
+	}
+
+	if c.smiGroups.Split {
+		trafficSplitLister = c.trafficSplitLister
+	}
+
+	if c.smiGroups.Specs {
+		httpRouteGroupLister = c.httpRouteGroupLister
+		tcpRouteLister = c.tcpRouteLister
+	}
+
+	return topology.NewBuilder(
+		c.serviceLister,
+		c.endpointsLister,
+		c.endpointSliceLister,
+		c.podLister,
+		c.nodeLister,
+		trafficTargetLister,
+		trafficSplitLister,
+		httpRouteGroupLister,
+		tcpRouteLister,
+		c.logger,
+	)
+}
+
 func (c *Controller) startBaseInformers(stopCh <-chan struct{}) error {
 	c.kubernetesFactory.Start(c.stopCh)
 
@@ -282,17 +695,25 @@ func (c *Controller) startBaseInformers(stopCh <-chan struct{}) error {
 		}
 	}
 
-	c.splitFactory.Start(c.stopCh)
-
-	for t, ok := range c.splitFactory.WaitForCacheSync(stopCh) {
-		if !ok {
-			return fmt.Errorf("timed out waiting for controller caches to sync: %s", t)
-		}
+	if c.settingsFactory != nil {
+		return startInformerFactory(c.settingsFactory, stopCh, c.stopCh)
 	}
 
-	c.specsFactory.Start(c.stopCh)
+	return nil
+}
+
+// informerFactory is implemented by the shared informer factories of the SMI API groups.
+type informerFactory interface {
+	Start(stopCh <-chan struct{})
+	WaitForCacheSync(stopCh <-chan struct{}) map[reflect.Type]bool
+}
+
+// startInformerFactory starts the informers of the given factory until stopCh is closed, and waits for their caches to
+// be synced until syncCh is closed.
+func startInformerFactory(factory informerFactory, syncCh, stopCh <-chan struct{}) error {
+	factory.Start(stopCh)
 
-	for t, ok := range c.specsFactory.WaitForCacheSync(stopCh) {
+	for t, ok := range factory.WaitForCacheSync(syncCh) {
 		if !ok {
 			return fmt.Errorf("timed out waiting for controller caches to sync: %s", t)
 		}
@@ -301,16 +722,43 @@ func (c *Controller) startBaseInformers(stopCh <-chan struct{}) error {
 	return nil
 }
 
-func (c *Controller) startACLInformers(stopCh <-chan struct{}) error {
-	c.accessFactory.Start(c.stopCh)
+// getBasicAuthUsers returns the htpasswd users held by the users key of the secret with the given namespace and name.
+// Empty lines and lines starting with # are ignored.
+func (c *Controller) getBasicAuthUsers(namespace, name string) ([]string, error) {
+	secret, err := c.secretLister.Secrets(namespace).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get secret %s/%s: %w", namespace, name, err)
+	}
 
-	for t, ok := range c.accessFactory.WaitForCacheSync(stopCh) {
-		if !ok {
-			return fmt.Errorf("timed out waiting for controller caches to sync: %s", t)
+	data, ok := secret.Data[basicAuthUsersKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no %q key", namespace, name, basicAuthUsersKey)
+	}
+
+	var users []string
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+
+		users = append(users, line)
 	}
 
-	return nil
+	if len(users) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no users", namespace, name)
+	}
+
+	return users, nil
+}
+
+// selectSMIResources restricts the given list options of the TrafficTargets and TrafficSplits to the ones matching the
+// SMI label selector, if any.
+func (c *Controller) selectSMIResources(opts *metav1.ListOptions) {
+	if c.cfg.SMILabelSelector != "" {
+		opts.LabelSelector = c.cfg.SMILabelSelector
+	}
 }
 
 // isWatchedResource returns true if the given resource is not ignored, false otherwise.
@@ -334,30 +782,241 @@ func (c *Controller) processNextWorkItem() bool {
 
 	defer c.workQueue.Done(key)
 
-	if key != configRefreshKey {
+	switch key {
+	case configBuildKey:
+	case configRefreshKey:
+	case smiAvailableKey:
+		if err := c.enableSMI(10 * time.Second); err != nil {
+			c.handleErr(key, fmt.Errorf("unable to enable SMI support: %w", err))
+			return true
+		}
+	case settingsKey:
+		// Invalid settings are not retried, they are loaded again once the ConfigMap is fixed.
+		if err := c.loadSettings(); err != nil {
+			c.logger.Errorf("Unable to load settings, keeping the current ones: %v", err)
+			c.workQueue.Forget(key)
+
+			return true
+		}
+	case shadowServicesGCKey:
+		if err := c.deleteOrphanShadowServices(); err != nil {
+			c.handleErr(key, fmt.Errorf("unable to delete orphan shadow services: %w", err))
+			return true
+		}
+
+		// Deleted shadow services are not part of the topology, no configuration build is required.
+		c.workQueue.Forget(key)
+
+		return true
+	default:
+		if svcKey, ok := key.(serviceMiddlewaresKey); ok {
+			if c.cfg.ConfigRefreshInterval == 0 && c.updateServiceMiddlewares(svcKey) {
+				c.workQueue.Forget(key)
+				return true
+			}
+
+			// The service configuration couldn't be updated on its own, fallback to a full build.
+			break
+		}
+
 		if err := c.syncShadowService(key.(string)); err != nil {
 			c.handleErr(key, fmt.Errorf("unable to sync shadow service: %w", err))
 			return true
 		}
 	}
 
+	// Once the first configuration is available, coalesce the changes received within the refresh interval. Adding the
+	// build key while it is already waiting doesn't delay it, so a pending change is published at the latest after the
+	// interval.
+	if c.ready && c.cfg.ConfigRefreshInterval > 0 && key != configBuildKey {
+		c.workQueue.AddAfter(configBuildKey, c.cfg.ConfigRefreshInterval)
+		c.workQueue.Forget(key)
+
+		return true
+	}
+
 	// Build and store config.
+	start := time.Now()
+
 	topo, err := c.topologyBuilder.Build(c.resourceFilter)
+
+	c.metrics.observeTopologyBuild(start, topo)
+
 	if err != nil {
-		c.handleErr(key, fmt.Errorf("unable to build topology: %w", err))
+		err = fmt.Errorf("unable to build topology: %w", err)
+		c.setReconcileError(err)
+		c.handleErr(key, err)
+
 		return true
 	}
 
 	conf := c.provider.BuildConfig(topo)
 
-	c.store.SetTopology(topo)
-	c.store.SetConfiguration(conf)
+	c.setTopologyAndConfiguration(topo, conf)
+
+	// Enable API readiness endpoint, the first topology has been built and its configuration is available.
+	if !c.ready {
+		c.ready = true
+		c.store.SetReadiness(true)
+	}
 
 	c.workQueue.Forget(key)
 
 	return true
 }
 
+// updateServiceMiddlewares updates the configuration of the service with the given key, whose middlewares are the only
+// thing that changed, without rebuilding the whole topology and configuration. It returns false if a full build is
+// required instead.
+func (c *Controller) updateServiceMiddlewares(key serviceMiddlewaresKey) bool {
+	if c.topology == nil || c.configuration == nil {
+		return false
+	}
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(string(key))
+	if err != nil {
+		return false
+	}
+
+	svc, err := c.serviceLister.Services(namespace).Get(name)
+	if err != nil {
+		return false
+	}
+
+	svcKey := topology.Key{Name: name, Namespace: namespace}
+
+	topoSvc, ok := c.topology.Services[svcKey]
+	if !ok {
+		return false
+	}
+
+	// The configuration of a Service whose deletion has been missed must not be kept, it is reconciled by a full build.
+	if c.hasStaleServices() {
+		return false
+	}
+
+	// The shared topology and configuration may be read concurrently, the updated ones are copies.
+	updatedSvc := *topoSvc
+	updatedSvc.Annotations = svc.Annotations
+	updatedSvc.Errors = nil
+
+	topo := *c.topology
+	topo.Services = make(map[topology.Key]*topology.Service, len(c.topology.Services))
+
+	for k, s := range c.topology.Services {
+		topo.Services[k] = s
+	}
+
+	topo.Services[svcKey] = &updatedSvc
+
+	conf := c.configuration.DeepCopy()
+
+	if err = c.provider.UpdateServiceConfig(buildScratchTopology(&topo, &updatedSvc), conf, svcKey); err != nil {
+		c.logger.Errorf("Unable to update configuration of service %q: %v", svcKey, err)
+		return false
+	}
+
+	c.setTopologyAndConfiguration(&topo, conf)
+
+	return true
+}
+
+// hasStaleServices returns true if some Services of the last topology do not exist anymore.
+func (c *Controller) hasStaleServices() bool {
+	for key := range c.topology.Services {
+		if _, err := c.serviceLister.Services(key.Namespace).Get(key.Name); err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildScratchTopology returns a copy of the given topology in which the TrafficTargets and TrafficSplits of the given
+// service are copies too. The errors added to them when the configuration of the service is rebuilt are discarded, as
+// they are already part of the given topology.
+func buildScratchTopology(topo *topology.Topology, svc *topology.Service) *topology.Topology {
+	scratch := *topo
+	scratch.ServiceTrafficTargets = make(map[topology.ServiceTrafficTargetKey]*topology.ServiceTrafficTarget, len(topo.ServiceTrafficTargets))
+	scratch.TrafficSplits = make(map[topology.Key]*topology.TrafficSplit, len(topo.TrafficSplits))
+
+	for key, tt := range topo.ServiceTrafficTargets {
+		scratch.ServiceTrafficTargets[key] = tt
+	}
+
+	for key, ts := range topo.TrafficSplits {
+		scratch.TrafficSplits[key] = ts
+	}
+
+	for _, ttKey := range svc.TrafficTargets {
+		if tt, ok := topo.ServiceTrafficTargets[ttKey]; ok {
+			ttCopy := *tt
+			ttCopy.Errors = nil
+			scratch.ServiceTrafficTargets[ttKey] = &ttCopy
+		}
+	}
+
+	for _, tsKey := range svc.TrafficSplits {
+		if ts, ok := topo.TrafficSplits[tsKey]; ok {
+			tsCopy := *ts
+			tsCopy.Errors = nil
+			scratch.TrafficSplits[tsKey] = &tsCopy
+		}
+	}
+
+	return &scratch
+}
+
+// setTopologyAndConfiguration shares the given topology and configuration through the store.
+func (c *Controller) setTopologyAndConfiguration(topo *topology.Topology, conf *dynamic.Configuration) {
+	c.topology = topo
+	c.configuration = conf
+
+	c.store.SetTopology(topo)
+	c.store.SetConfiguration(conf)
+
+	c.status = Status{
+		LastReconcileTime: time.Now(),
+		ResourceErrors:    countResourceErrors(topo),
+	}
+	c.store.SetStatus(c.status)
+}
+
+// setReconcileError shares the error of a failed reconciliation through the store. The published configuration is
+// kept, and flagged as stale until a reconciliation succeeds.
+func (c *Controller) setReconcileError(err error) {
+	c.status.LastReconcileTime = time.Now()
+	c.status.LastError = err.Error()
+	c.status.Stale = true
+
+	c.store.SetStatus(c.status)
+}
+
+// countResourceErrors returns the number of resources of the given topology having errors.
+func countResourceErrors(topo *topology.Topology) int {
+	var count int
+
+	for _, svc := range topo.Services {
+		if len(svc.Errors) > 0 {
+			count++
+		}
+	}
+
+	for _, tt := range topo.ServiceTrafficTargets {
+		if len(tt.Errors) > 0 {
+			count++
+		}
+	}
+
+	for _, ts := range topo.TrafficSplits {
+		if len(ts.Errors) > 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
 // syncShadowService calls the shadow service manager to keep the shadow service state in sync with the service events received.
 func (c *Controller) syncShadowService(key string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -371,8 +1030,24 @@ func (c *Controller) syncShadowService(key string) error {
 	return c.shadowServiceManager.SyncService(ctx, namespace, name)
 }
 
-// handleErr re-queues the given work key only if the maximum number of attempts is not exceeded.
+// deleteOrphanShadowServices calls the shadow service manager to delete the shadow services whose service doesn't exist
+// anymore.
+func (c *Controller) deleteOrphanShadowServices() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return c.shadowServiceManager.DeleteOrphanShadowServices(ctx)
+}
+
+// handleErr re-queues the given work key only if the maximum number of attempts is not exceeded. The SMI available key
+// is always re-queued, as SMI support would otherwise never be enabled until the controller restarts.
 func (c *Controller) handleErr(key interface{}, err error) {
+	if key == smiAvailableKey {
+		c.logger.Errorf("Unable to complete work %q, retrying: %v", key, err)
+		c.workQueue.AddRateLimited(key)
+		return
+	}
+
 	if c.workQueue.NumRequeues(key) < maxRetries {
 		c.workQueue.AddRateLimited(key)
 		return
@@ -1,14 +1,24 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	split "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/split/v1alpha3"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/traefik/mesh/v2/pkg/k8s"
 	"github.com/traefik/mesh/v2/pkg/topology"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 )
 
 const (
@@ -21,11 +31,34 @@ const (
 	maxUDPPort                  = int32(15005)
 )
 
-type storeMock struct{}
+type storeMock struct {
+	ready          bool
+	topology       *topology.Topology
+	configurations int
+	status         Status
+}
+
+func (a *storeMock) SetConfiguration(_ *dynamic.Configuration) { a.configurations++ }
+func (a *storeMock) SetTopology(topo *topology.Topology)       { a.topology = topo }
+func (a *storeMock) SetReadiness(isReady bool)                 { a.ready = isReady }
+func (a *storeMock) SetStatus(status Status)                   { a.status = status }
+
+type topologyBuilderMock struct {
+	err      error
+	topology *topology.Topology
+}
 
-func (a *storeMock) SetConfiguration(_ *dynamic.Configuration) {}
-func (a *storeMock) SetTopology(_ *topology.Topology)          {}
-func (a *storeMock) SetReadiness(_ bool)                       {}
+func (b *topologyBuilderMock) Build(_ *k8s.ResourceFilter) (*topology.Topology, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if b.topology != nil {
+		return b.topology, nil
+	}
+
+	return topology.NewTopology(), nil
+}
 
 func TestController_NewMeshController(t *testing.T) {
 	store := &storeMock{}
@@ -76,3 +109,542 @@ func TestController_NewMeshControllerWithACLEnabled(t *testing.T) {
 
 	assert.NotNil(t, controller)
 }
+
+func TestController_ReadinessAfterFirstBuild(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, logger)
+
+	builder := &topologyBuilderMock{err: errors.New("boom")}
+	controller.topologyBuilder = builder
+
+	// A failed build must not enable readiness.
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	assert.False(t, store.ready)
+
+	builder.err = nil
+
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	assert.True(t, store.ready)
+}
+
+func TestController_TopologyBuildMetrics(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+	registry := prometheus.NewRegistry()
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode:       "http",
+		Namespace:         traefikMeshNamespace,
+		MinHTTPPort:       minHTTPPort,
+		MaxHTTPPort:       maxHTTPPort,
+		MinTCPPort:        minTCPPort,
+		MaxTCPPort:        maxTCPPort,
+		MinUDPPort:        minUDPPort,
+		MaxUDPPort:        maxUDPPort,
+		MetricsRegisterer: registry,
+	}, store, logger)
+
+	topo := topology.NewTopology()
+	topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}] = &topology.Service{Name: "svc-a", Namespace: "my-ns"}
+	topo.Pods[topology.Key{Name: "pod-a", Namespace: "my-ns"}] = &topology.Pod{Name: "pod-a", Namespace: "my-ns"}
+	topo.Pods[topology.Key{Name: "pod-b", Namespace: "my-ns"}] = &topology.Pod{Name: "pod-b", Namespace: "my-ns"}
+
+	controller.topologyBuilder = &topologyBuilderMock{topology: topo}
+
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	gathered := make(map[string]bool)
+
+	for _, family := range families {
+		require.Len(t, family.GetMetric(), 1, family.GetName())
+
+		metric := family.GetMetric()[0]
+
+		switch family.GetName() {
+		case "topology_build_duration_seconds":
+			assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+		case "topology_nodes":
+			assert.Equal(t, float64(3), metric.GetGauge().GetValue())
+		}
+
+		gathered[family.GetName()] = true
+	}
+
+	assert.True(t, gathered["topology_build_duration_seconds"])
+	assert.True(t, gathered["topology_nodes"])
+}
+
+func TestController_StatusReportsReconcileErrors(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, logger)
+	defer controller.workQueue.ShutDown()
+
+	topo := topology.NewTopology()
+	topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}] = &topology.Service{Errors: []string{"boom"}}
+	topo.Services[topology.Key{Name: "svc-b", Namespace: "my-ns"}] = &topology.Service{}
+
+	builder := &topologyBuilderMock{topology: topo}
+	controller.topologyBuilder = builder
+
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	assert.False(t, store.status.LastReconcileTime.IsZero())
+	assert.Empty(t, store.status.LastError)
+	assert.False(t, store.status.Stale)
+	assert.Equal(t, 1, store.status.ResourceErrors)
+
+	// A failed build keeps the published configuration, which is flagged as stale.
+	builder.err = errors.New("boom")
+
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	assert.Equal(t, "unable to build topology: boom", store.status.LastError)
+	assert.True(t, store.status.Stale)
+	assert.Equal(t, 1, store.status.ResourceErrors)
+	assert.Equal(t, 1, store.configurations)
+
+	builder.err = nil
+
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	assert.Empty(t, store.status.LastError)
+	assert.False(t, store.status.Stale)
+}
+
+func TestController_CoalescesChangesWithinRefreshInterval(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode:           "http",
+		Namespace:             traefikMeshNamespace,
+		MinHTTPPort:           minHTTPPort,
+		MaxHTTPPort:           maxHTTPPort,
+		MinTCPPort:            minTCPPort,
+		MaxTCPPort:            maxTCPPort,
+		MinUDPPort:            minUDPPort,
+		MaxUDPPort:            maxUDPPort,
+		ConfigRefreshInterval: 50 * time.Millisecond,
+	}, store, logger)
+	defer controller.workQueue.ShutDown()
+
+	builder := &topologyBuilderMock{}
+	controller.topologyBuilder = builder
+
+	// The first configuration is published right away.
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	assert.True(t, store.ready)
+	assert.Equal(t, 1, store.configurations)
+
+	// Rapid successive changes are not published until the refresh interval is elapsed.
+	var want *topology.Topology
+
+	for i := 0; i < 5; i++ {
+		want = topology.NewTopology()
+		want.Services[topology.Key{Name: fmt.Sprintf("svc-%d", i), Namespace: "my-ns"}] = &topology.Service{}
+		builder.topology = want
+
+		controller.workQueue.Add(configRefreshKey)
+		controller.processNextWorkItem()
+	}
+
+	assert.Equal(t, 1, store.configurations)
+
+	// The coalesced changes are published once, with the final state.
+	controller.processNextWorkItem()
+
+	assert.Equal(t, 2, store.configurations)
+	assert.Same(t, want, store.topology)
+	assert.Equal(t, 0, controller.workQueue.Len())
+}
+
+func TestController_ResyncTriggersConfigRefresh(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode:  "http",
+		Namespace:    traefikMeshNamespace,
+		MinHTTPPort:  minHTTPPort,
+		MaxHTTPPort:  maxHTTPPort,
+		MinTCPPort:   minTCPPort,
+		MaxTCPPort:   maxTCPPort,
+		MinUDPPort:   minUDPPort,
+		MaxUDPPort:   maxUDPPort,
+		ResyncPeriod: time.Second,
+	}, store, logger)
+	defer controller.workQueue.ShutDown()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	controller.kubernetesFactory.Start(stopCh)
+
+	for typ, ok := range controller.kubernetesFactory.WaitForCacheSync(stopCh) {
+		require.True(t, ok, "timed out waiting for controller caches to sync: %s", typ)
+	}
+
+	// Drop the work enqueued by the initial listing, no resource is updated afterwards.
+	time.Sleep(200 * time.Millisecond)
+
+	for controller.workQueue.Len() > 0 {
+		key, _ := controller.workQueue.Get()
+		controller.workQueue.Forget(key)
+		controller.workQueue.Done(key)
+	}
+
+	// The configured resync period is honored by the informers, and the resync requests a full rebuild.
+	require.Eventually(t, func() bool { return controller.workQueue.Len() > 0 }, 5*time.Second, 50*time.Millisecond)
+
+	key, _ := controller.workQueue.Get()
+	assert.Equal(t, configRefreshKey, key)
+}
+
+func TestController_UpdateServiceMiddlewaresWithDeletedService(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, logger)
+	defer controller.workQueue.ShutDown()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	require.NoError(t, controller.startBaseInformers(stopCh))
+
+	// The deletion of foo/deleted has been missed, it is still part of the last topology.
+	topo := topology.NewTopology()
+	topo.Services[topology.Key{Name: "test", Namespace: "foo"}] = &topology.Service{Name: "test", Namespace: "foo"}
+	topo.Services[topology.Key{Name: "deleted", Namespace: "foo"}] = &topology.Service{Name: "deleted", Namespace: "foo"}
+
+	controller.setTopologyAndConfiguration(topo, controller.provider.BuildConfig(topo))
+
+	// A full build is required to remove the configuration of the deleted service.
+	assert.False(t, controller.updateServiceMiddlewares("foo/test"))
+
+	delete(topo.Services, topology.Key{Name: "deleted", Namespace: "foo"})
+	controller.setTopologyAndConfiguration(topo, controller.provider.BuildConfig(topo))
+
+	assert.True(t, controller.updateServiceMiddlewares("foo/test"))
+}
+
+func TestController_GetBasicAuthUsers(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("basic_auth.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, logger)
+	defer controller.workQueue.ShutDown()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	require.NoError(t, controller.startBaseInformers(stopCh))
+
+	tests := []struct {
+		desc      string
+		namespace string
+		name      string
+		want      []string
+		err       bool
+	}{
+		{
+			desc:      "users",
+			namespace: "foo",
+			name:      "users",
+			want: []string{
+				"admin:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+				"ops:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0",
+			},
+		},
+		{
+			desc:      "missing secret",
+			namespace: "foo",
+			name:      "missing",
+			err:       true,
+		},
+		{
+			desc:      "secret in another namespace",
+			namespace: "bar",
+			name:      "users",
+			err:       true,
+		},
+		{
+			desc:      "missing users key",
+			namespace: "foo",
+			name:      "no-users-key",
+			err:       true,
+		},
+		{
+			desc:      "no users",
+			namespace: "foo",
+			name:      "comments-only",
+			err:       true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			users, err := controller.getBasicAuthUsers(test.namespace, test.name)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, users)
+		})
+	}
+}
+
+func TestController_EnableSMIWhenCRDsAreInstalled(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("smi.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, logger)
+	defer controller.Shutdown()
+
+	require.NoError(t, controller.startInformers(time.Second))
+
+	// SMI CRDs are not installed.
+	groups, err := controller.getSMIGroups()
+	require.NoError(t, err)
+	assert.Equal(t, k8s.SMIGroups{}, groups)
+
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	require.NotNil(t, store.topology)
+	assert.Len(t, store.topology.Services, 3)
+	assert.Empty(t, store.topology.TrafficSplits)
+
+	// Install SMI CRDs.
+	discovery, ok := clientMock.KubernetesClient().Discovery().(*fakediscovery.FakeDiscovery)
+	require.True(t, ok)
+
+	discovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: split.SchemeGroupVersion.String()},
+		{GroupVersion: specs.SchemeGroupVersion.String()},
+	}
+
+	groups, err = controller.getSMIGroups()
+	require.NoError(t, err)
+	assert.Equal(t, k8s.SMIGroups{Split: true, Specs: true}, groups)
+
+	controller.workQueue.Add(smiAvailableKey)
+	controller.processNextWorkItem()
+
+	assert.Equal(t, k8s.SMIGroups{Split: true, Specs: true}, controller.smiGroups)
+	assert.Contains(t, store.topology.TrafficSplits, topology.Key{Name: "split", Namespace: "foo"})
+}
+
+func TestController_SMILabelSelector(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("smi-label-selector.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	discovery, ok := clientMock.KubernetesClient().Discovery().(*fakediscovery.FakeDiscovery)
+	require.True(t, ok)
+
+	discovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: split.SchemeGroupVersion.String()},
+		{GroupVersion: specs.SchemeGroupVersion.String()},
+	}
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode:      "http",
+		Namespace:        traefikMeshNamespace,
+		MinHTTPPort:      minHTTPPort,
+		MaxHTTPPort:      maxHTTPPort,
+		MinTCPPort:       minTCPPort,
+		MaxTCPPort:       maxTCPPort,
+		MinUDPPort:       minUDPPort,
+		MaxUDPPort:       maxUDPPort,
+		SMILabelSelector: "rollout=canary",
+	}, store, logger)
+	defer controller.Shutdown()
+
+	require.NoError(t, controller.startInformers(time.Second))
+
+	controller.workQueue.Add(smiAvailableKey)
+	controller.processNextWorkItem()
+
+	// Only the TrafficSplit matching the label selector is part of the topology.
+	require.NotNil(t, store.topology)
+	assert.Contains(t, store.topology.TrafficSplits, topology.Key{Name: "canary-split", Namespace: "foo"})
+	assert.NotContains(t, store.topology.TrafficSplits, topology.Key{Name: "unlabeled-split", Namespace: "foo"})
+}
+
+func TestController_EnableSMIWithPartialCRDs(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("smi.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	// Only the TrafficSplit CRDs are installed.
+	discovery, ok := clientMock.KubernetesClient().Discovery().(*fakediscovery.FakeDiscovery)
+	require.True(t, ok)
+
+	discovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: split.SchemeGroupVersion.String()},
+	}
+
+	controller := NewMeshController(clientMock, Config{
+		ACLEnabled:  true,
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, logger)
+	defer controller.Shutdown()
+
+	require.NoError(t, controller.startInformers(time.Second))
+	require.NoError(t, controller.enableSMI(time.Second))
+
+	assert.Equal(t, k8s.SMIGroups{Split: true}, controller.smiGroups)
+	assert.Equal(t, []string{specs.SchemeGroupVersion.Group, access.SchemeGroupVersion.Group}, controller.missingSMIGroups(controller.smiGroups))
+
+	controller.workQueue.Add(configRefreshKey)
+	controller.processNextWorkItem()
+
+	require.NotNil(t, store.topology)
+	assert.Contains(t, store.topology.TrafficSplits, topology.Key{Name: "split", Namespace: "foo"})
+	assert.Empty(t, store.topology.TrafficTargets)
+
+	for _, ts := range store.topology.TrafficSplits {
+		assert.Empty(t, ts.Errors)
+	}
+}
+
+func TestController_HandleErrKeepsRetryingSMIAvailableKey(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, logger)
+	defer controller.workQueue.ShutDown()
+
+	for i := 0; i <= maxRetries; i++ {
+		controller.handleErr(smiAvailableKey, errors.New("boom"))
+		controller.handleErr("my-ns/svc-a", errors.New("boom"))
+	}
+
+	// The service key is dropped after the maximum number of retries, the SMI available key is not.
+	assert.Equal(t, maxRetries+1, controller.workQueue.NumRequeues(smiAvailableKey))
+	assert.Equal(t, 0, controller.workQueue.NumRequeues("my-ns/svc-a"))
+}
@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"reflect"
+
 	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/v2/pkg/annotations"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
@@ -23,14 +26,40 @@ func (h *enqueueWorkHandler) OnUpdate(oldObj interface{}, newObj interface{}) {
 	oldObjMeta, okOld := oldObj.(metav1.Object)
 	newObjMeta, okNew := newObj.(metav1.Object)
 
-	// This is a resync event, no extra work is needed.
+	// This is a resync event, a full topology rebuild is requested to recover from any missed event. Resync events
+	// of all the resources are coalesced into a single rebuild by the work queue.
 	if okOld && okNew && oldObjMeta.GetResourceVersion() == newObjMeta.GetResourceVersion() {
+		h.workQueue.Add(configRefreshKey)
+		return
+	}
+
+	oldSvc, okOld := oldObj.(*corev1.Service)
+	newSvc, okNew := newObj.(*corev1.Service)
+
+	// Only the configuration of the service has to be updated when its middlewares are the only thing that changed.
+	if okOld && okNew && onlyServiceMiddlewaresChanged(oldSvc, newSvc) {
+		key, err := cache.MetaNamespaceKeyFunc(newSvc)
+		if err != nil {
+			h.logger.Errorf("Unable to create a work key for resource %#v", newSvc)
+			return
+		}
+
+		h.workQueue.Add(serviceMiddlewaresKey(key))
+
 		return
 	}
 
 	h.enqueueWork(newObj)
 }
 
+// onlyServiceMiddlewaresChanged returns true if the given services only differ by the annotations configuring their
+// middlewares.
+func onlyServiceMiddlewaresChanged(oldSvc, newSvc *corev1.Service) bool {
+	return reflect.DeepEqual(oldSvc.Spec, newSvc.Spec) &&
+		reflect.DeepEqual(oldSvc.Labels, newSvc.Labels) &&
+		annotations.OnlyMiddlewaresChanged(oldSvc.Annotations, newSvc.Annotations)
+}
+
 // OnDelete is called when an object is removed from the informers cache.
 func (h *enqueueWorkHandler) OnDelete(obj interface{}) {
 	h.enqueueWork(obj)
@@ -50,3 +79,23 @@ func (h *enqueueWorkHandler) enqueueWork(obj interface{}) {
 
 	h.workQueue.Add(key)
 }
+
+// enqueueSettingsHandler asks the worker to load the settings each time the settings ConfigMap changes.
+type enqueueSettingsHandler struct {
+	workQueue workqueue.RateLimitingInterface
+}
+
+// OnAdd is called when the settings ConfigMap is added to the informers cache.
+func (h *enqueueSettingsHandler) OnAdd(_ interface{}) {
+	h.workQueue.Add(settingsKey)
+}
+
+// OnUpdate is called when the settings ConfigMap is updated in the informers cache.
+func (h *enqueueSettingsHandler) OnUpdate(_, _ interface{}) {
+	h.workQueue.Add(settingsKey)
+}
+
+// OnDelete is called when the settings ConfigMap is removed from the informers cache.
+func (h *enqueueSettingsHandler) OnDelete(_ interface{}) {
+	h.workQueue.Add(settingsKey)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/workqueue"
@@ -50,27 +51,27 @@ func TestEnqueueWorkHandler_OnUpdate(t *testing.T) {
 		desc        string
 		oldObj      interface{}
 		newObj      interface{}
-		expectedLen int
+		expectedKey interface{}
 	}{
 		{
-			desc: "should not enqueue if this is a re-sync event",
+			desc: "should enqueue a config refresh if this is a re-sync event",
 			oldObj: &corev1.Service{
 				ObjectMeta: metav1.ObjectMeta{ResourceVersion: "foo"},
 			},
 			newObj: &corev1.Service{
 				ObjectMeta: metav1.ObjectMeta{ResourceVersion: "foo"},
 			},
-			expectedLen: 0,
+			expectedKey: configRefreshKey,
 		},
 		{
 			desc: "should enqueue if this is not a re-sync event",
 			oldObj: &corev1.Service{
-				ObjectMeta: metav1.ObjectMeta{ResourceVersion: "foo"},
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", ResourceVersion: "foo"},
 			},
 			newObj: &corev1.Service{
-				ObjectMeta: metav1.ObjectMeta{ResourceVersion: "bar"},
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", ResourceVersion: "bar"},
 			},
-			expectedLen: 1,
+			expectedKey: "bar/foo",
 		},
 	}
 
@@ -88,7 +89,84 @@ func TestEnqueueWorkHandler_OnUpdate(t *testing.T) {
 			handler := &enqueueWorkHandler{logger: logger, workQueue: workQueue}
 			handler.OnUpdate(test.oldObj, test.newObj)
 
-			assert.Equal(t, test.expectedLen, workQueue.Len())
+			require.Equal(t, 1, workQueue.Len())
+
+			currentKey, _ := workQueue.Get()
+
+			assert.Equal(t, test.expectedKey, currentKey)
+		})
+	}
+}
+
+func TestEnqueueWorkHandler_OnUpdateServiceMiddlewares(t *testing.T) {
+	tests := []struct {
+		desc           string
+		oldAnnotations map[string]string
+		newAnnotations map[string]string
+		oldSpec        corev1.ServiceSpec
+		newSpec        corev1.ServiceSpec
+		expectedKey    interface{}
+	}{
+		{
+			desc:           "should enqueue a service middlewares key if only middleware annotations changed",
+			oldAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "2"},
+			newAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "3", "mesh.traefik.io/max-conn": "10"},
+			expectedKey:    serviceMiddlewaresKey("bar/foo"),
+		},
+		{
+			desc:           "should enqueue a service key if other annotations changed",
+			oldAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "2"},
+			newAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "3", "mesh.traefik.io/traffic-type": "tcp"},
+			expectedKey:    "bar/foo",
+		},
+		{
+			desc:           "should enqueue a service key if the spec changed",
+			oldAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "2"},
+			newAnnotations: map[string]string{"mesh.traefik.io/retry-attempts": "3"},
+			oldSpec:        corev1.ServiceSpec{ClusterIP: "10.10.10.10"},
+			newSpec:        corev1.ServiceSpec{ClusterIP: "10.10.10.11"},
+			expectedKey:    "bar/foo",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			workQueue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+
+			oldSvc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "foo",
+					Namespace:       "bar",
+					ResourceVersion: "1",
+					Annotations:     test.oldAnnotations,
+				},
+				Spec: test.oldSpec,
+			}
+			newSvc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "foo",
+					Namespace:       "bar",
+					ResourceVersion: "2",
+					Annotations:     test.newAnnotations,
+				},
+				Spec: test.newSpec,
+			}
+
+			handler := &enqueueWorkHandler{logger: logger, workQueue: workQueue}
+			handler.OnUpdate(oldSvc, newSvc)
+
+			assert.Equal(t, 1, workQueue.Len())
+
+			currentKey, _ := workQueue.Get()
+
+			assert.Equal(t, test.expectedKey, currentKey)
 		})
 	}
 }
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/traefik/mesh/v2/pkg/topology"
+)
+
+// metrics holds the metrics reported by the controller.
+type metrics struct {
+	topologyBuildDuration prometheus.Histogram
+	topologyNodes         prometheus.Gauge
+}
+
+// newMetrics creates the controller metrics and registers them with the given registerer. The metrics are still
+// recorded, but not exposed, when the registerer is nil.
+func newMetrics(registerer prometheus.Registerer) *metrics {
+	m := &metrics{
+		topologyBuildDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "topology_build_duration_seconds",
+			Help:    "Duration of the topology builds, in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		topologyNodes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "topology_nodes",
+			Help: "Number of services, pods, service traffic targets and traffic splits in the last built topology.",
+		}),
+	}
+
+	if registerer != nil {
+		registerer.MustRegister(m.topologyBuildDuration, m.topologyNodes)
+	}
+
+	return m
+}
+
+// observeTopologyBuild records the duration of a topology build started at the given time, and the size of the built
+// topology. The size is only updated when the build succeeded.
+func (m *metrics) observeTopologyBuild(start time.Time, topo *topology.Topology) {
+	m.topologyBuildDuration.Observe(time.Since(start).Seconds())
+
+	if topo == nil {
+		return
+	}
+
+	m.topologyNodes.Set(float64(len(topo.Services) + len(topo.Pods) + len(topo.ServiceTrafficTargets) + len(topo.TrafficSplits)))
+}
@@ -9,12 +9,15 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/v2/pkg/annotations"
 	"github.com/traefik/mesh/v2/pkg/k8s"
+	"github.com/traefik/mesh/v2/pkg/logfield"
+	"github.com/traefik/mesh/v2/pkg/topology"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 // PortMapper is capable of storing and retrieving a port mapping for a given service.
@@ -35,10 +38,32 @@ type ShadowServiceManager struct {
 	udpStateTable      PortMapper
 	defaultTrafficType string
 	kubeClient         kubernetes.Interface
+	eventRecorder      record.EventRecorder
+
+	// trafficPolicy is the internal traffic policy of the created shadow services, the cluster default when nil. It
+	// is only set at creation.
+	trafficPolicy *corev1.ServiceInternalTrafficPolicyType
+
+	// noShadowService disables the shadow services: the ports of the services are still mapped to ports on the proxy,
+	// but only in memory.
+	noShadowService bool
+	// mappedServices holds, when shadow services are disabled, the ports and traffic type of the services whose ports
+	// are mapped, indexed by the meta namespace key of the service.
+	mappedServices map[string]mappedService
+}
+
+// mappedService holds the ports and traffic type a service has been mapped with, when shadow services are disabled.
+type mappedService struct {
+	trafficType string
+	ports       []corev1.ServicePort
 }
 
 // LoadPortMapping loads the port mapping of existing shadow services into the different port mappers.
 func (s *ShadowServiceManager) LoadPortMapping() error {
+	if s.noShadowService {
+		return nil
+	}
+
 	shadowSvcs, err := s.getShadowServices()
 	if err != nil {
 		return fmt.Errorf("unable to list shadow services: %w", err)
@@ -64,28 +89,171 @@ func (s *ShadowServiceManager) LoadPortMapping() error {
 }
 
 // SyncService synchronizes the given service and its shadow service. If the shadow service doesn't exist it will be
-// created. If it exists it will be updated and if the service doesn't exist, the shadow service will be removed.
+// created. If it exists it will be updated and if the service doesn't exist or is ignored, the shadow service will be
+// removed.
 func (s *ShadowServiceManager) SyncService(ctx context.Context, namespace, name string) error {
-	s.logger.Debugf("Syncing service %q in namespace %q...", name, namespace)
+	logger := s.logger.WithFields(logrus.Fields{
+		logfield.Namespace: namespace,
+		logfield.Service:   name,
+	})
+
+	logger.Debug("Syncing service...")
 
 	shadowSvcName, err := GetShadowServiceName(namespace, name)
 	if err != nil {
-		s.logger.Errorf("Unable to sync service %q in namespace %q: %v", name, namespace, err)
+		logger.Errorf("Unable to sync service: %v", err)
 		return nil
 	}
 
 	svc, err := s.serviceLister.Services(namespace).Get(name)
 	if kerrors.IsNotFound(err) {
-		return s.deleteShadowService(ctx, namespace, name, shadowSvcName)
+		return s.deleteService(ctx, namespace, name, shadowSvcName)
 	}
 
 	if err != nil {
 		return err
 	}
 
+	// A service with an invalid ignore annotation is ignored, as it is left out of the topology too.
+	ignored, err := annotations.IsIgnored(svc.Annotations)
+	if err != nil {
+		logger.Errorf("Unable to evaluate ignore annotation, the service is ignored: %v", err)
+		ignored = true
+	}
+
+	if ignored {
+		return s.deleteService(ctx, namespace, name, shadowSvcName)
+	}
+
+	// The ports excluded from the mesh are not mapped, and are released if they were mapped before.
+	ports, err := topology.GetMeshedPorts(svc)
+	if err != nil {
+		return fmt.Errorf("unable to evaluate ignore-ports annotation of service %q in namespace %q: %w", name, namespace, err)
+	}
+
+	if len(ports) != len(svc.Spec.Ports) {
+		svc = svc.DeepCopy()
+		svc.Spec.Ports = ports
+	}
+
+	if s.noShadowService {
+		return s.mapServicePorts(svc)
+	}
+
 	return s.upsertShadowService(ctx, svc, shadowSvcName)
 }
 
+// deleteService releases the ports of the given user service, and deletes its shadow service if they are enabled.
+func (s *ShadowServiceManager) deleteService(ctx context.Context, namespace, name, shadowSvcName string) error {
+	if s.noShadowService {
+		s.unmapServicePorts(namespace, name)
+		return nil
+	}
+
+	return s.deleteShadowService(ctx, namespace, name, shadowSvcName)
+}
+
+// mapServicePorts maps the ports of the given user service without creating its shadow service. The ports which have
+// changed since the last time the service has been mapped are released first.
+func (s *ShadowServiceManager) mapServicePorts(svc *corev1.Service) error {
+	trafficType, err := annotations.GetTrafficType(svc.Annotations)
+	if err != nil && !errors.Is(err, annotations.ErrNotFound) {
+		return fmt.Errorf("unable to map ports of service %q in namespace %q: %w", svc.Name, svc.Namespace, err)
+	}
+
+	if errors.Is(err, annotations.ErrNotFound) {
+		trafficType = s.defaultTrafficType
+	}
+
+	key := svc.Namespace + "/" + svc.Name
+
+	if mapped, ok := s.mappedServices[key]; ok {
+		oldPorts := mapped.ports
+		if mapped.trafficType == trafficType {
+			oldPorts = getRemovedOrUpdatedPorts(mapped.ports, svc.Spec.Ports)
+		}
+
+		for _, sp := range oldPorts {
+			if err = s.unmapPort(svc.Namespace, svc.Name, mapped.trafficType, sp.Port); err != nil {
+				s.logger.Errorf("Unable to unmap port %d of service %q in namespace %q: %v", sp.Port, svc.Name, svc.Namespace, err)
+			}
+		}
+	}
+
+	// Mapping the ports of the service is enough, as no shadow service is created.
+	s.getServicePorts(svc, trafficType)
+
+	if s.mappedServices == nil {
+		s.mappedServices = make(map[string]mappedService)
+	}
+
+	s.mappedServices[key] = mappedService{trafficType: trafficType, ports: svc.Spec.Ports}
+
+	return nil
+}
+
+// unmapServicePorts releases the ports of the given user service, mapped while shadow services are disabled.
+func (s *ShadowServiceManager) unmapServicePorts(namespace, name string) {
+	key := namespace + "/" + name
+
+	mapped, ok := s.mappedServices[key]
+	if !ok {
+		return
+	}
+
+	for _, sp := range mapped.ports {
+		if err := s.unmapPort(namespace, name, mapped.trafficType, sp.Port); err != nil {
+			s.logger.Errorf("Unable to unmap port %d of service %q in namespace %q: %v", sp.Port, name, namespace, err)
+		}
+	}
+
+	delete(s.mappedServices, key)
+}
+
+// DeleteOrphanShadowServices deletes the shadow services whose user service doesn't exist anymore. Such shadow services
+// are left behind when the deletion of their user service is missed, e.g. while the controller is not running.
+func (s *ShadowServiceManager) DeleteOrphanShadowServices(ctx context.Context) error {
+	if s.noShadowService {
+		return nil
+	}
+
+	shadowSvcs, err := s.getShadowServices()
+	if err != nil {
+		return fmt.Errorf("unable to list shadow services: %w", err)
+	}
+
+	for _, shadowSvc := range shadowSvcs {
+		namespace := shadowSvc.Labels[k8s.LabelServiceNamespace]
+		name := shadowSvc.Labels[k8s.LabelServiceName]
+
+		if namespace == "" || name == "" {
+			s.logger.Warnf("Unable to find the service of shadow service %q", shadowSvc.Name)
+			continue
+		}
+
+		_, err = s.serviceLister.Services(namespace).Get(name)
+		if err == nil {
+			continue
+		}
+
+		if !kerrors.IsNotFound(err) {
+			s.logger.Errorf("Unable to get service %q in namespace %q: %v", name, namespace, err)
+			continue
+		}
+
+		s.logger.Infof("Deleting orphan shadow service %q of service %q in namespace %q", shadowSvc.Name, name, namespace)
+
+		if err = s.deleteShadowService(ctx, namespace, name, shadowSvc.Name); err != nil {
+			s.logger.Errorf("Unable to delete orphan shadow service %q: %v", shadowSvc.Name, err)
+			continue
+		}
+
+		s.eventRecorder.Eventf(shadowSvc, corev1.EventTypeNormal, "OrphanDeleted", "Deleted shadow service of service %q in namespace %q which doesn't exist anymore", name, namespace)
+	}
+
+	return nil
+}
+
 // deleteShadowService deletes the shadow service associated with the given user service.
 func (s *ShadowServiceManager) deleteShadowService(ctx context.Context, namespace, name, shadowSvcName string) error {
 	shadowSvc, err := s.serviceLister.Services(s.namespace).Get(shadowSvcName)
@@ -177,6 +345,11 @@ func (s *ShadowServiceManager) createShadowService(ctx context.Context, svc *cor
 		},
 	}
 
+	if s.trafficPolicy != nil {
+		policy := *s.trafficPolicy
+		shadowSvc.Spec.InternalTrafficPolicy = &policy
+	}
+
 	annotations.SetTrafficType(trafficType, shadowSvc.Annotations)
 
 	_, err := s.kubeClient.CoreV1().Services(s.namespace).Create(ctx, shadowSvc, metav1.CreateOptions{})
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/traefik/mesh/v2/pkg/annotations"
 	"github.com/traefik/mesh/v2/pkg/k8s"
+	"github.com/traefik/mesh/v2/pkg/portmapping"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,6 +21,7 @@ import (
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
 	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -194,6 +196,126 @@ func TestShadowServiceManager_SyncServiceCreateShadowService(t *testing.T) {
 	assert.Equal(t, 2, httpPortMapper.addCounter)
 }
 
+// TestShadowServiceManager_SyncServiceCreateShadowServiceForHeadlessService tests the case where a headless service
+// is created. It makes sure the shadow service is a regular service, reachable through its ClusterIP.
+func TestShadowServiceManager_SyncServiceCreateShadowServiceForHeadlessService(t *testing.T) {
+	logger := logrus.New()
+
+	svc := newFakeService("svc", map[int]int{9000: 8080}, annotations.ServiceTypeHTTP)
+	svc.Spec.ClusterIP = corev1.ClusterIPNone
+
+	httpPortMapper := &portMappingMock{
+		t: t,
+		addCalledWith: []portMapping{
+			{namespace: svc.Namespace, name: svc.Name, fromPort: 9000, toPort: 5000},
+		},
+	}
+
+	client, svcLister := newFakeK8sClient(t, svc)
+
+	mgr := ShadowServiceManager{
+		namespace:          testNamespace,
+		defaultTrafficType: testDefaultTrafficType,
+		kubeClient:         client,
+		serviceLister:      svcLister,
+		httpStateTable:     httpPortMapper,
+		logger:             logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	shadowSvcName, err := GetShadowServiceName(svc.Namespace, svc.Name)
+	require.NoError(t, err)
+
+	shadowSvc, err := client.CoreV1().Services(testNamespace).Get(ctx, shadowSvcName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	assert.NotEqual(t, corev1.ClusterIPNone, shadowSvc.Spec.ClusterIP)
+	assert.Equal(t, []corev1.ServicePort{
+		{
+			Name:       "port-9000",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       9000,
+			TargetPort: intstr.FromInt(5000),
+		},
+	}, shadowSvc.Spec.Ports)
+
+	assert.Equal(t, 1, httpPortMapper.addCounter)
+}
+
+// TestShadowServiceManager_SyncServiceCreateShadowServiceTrafficPolicy tests the case where an internal traffic policy
+// is configured for the shadow services. It makes sure the shadow service is created with this policy.
+func TestShadowServiceManager_SyncServiceCreateShadowServiceTrafficPolicy(t *testing.T) {
+	local := corev1.ServiceInternalTrafficPolicyLocal
+	cluster := corev1.ServiceInternalTrafficPolicyCluster
+
+	tests := []struct {
+		desc          string
+		trafficPolicy *corev1.ServiceInternalTrafficPolicyType
+	}{
+		{
+			desc: "cluster default",
+		},
+		{
+			desc:          "local",
+			trafficPolicy: &local,
+		},
+		{
+			desc:          "cluster",
+			trafficPolicy: &cluster,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+
+			svc := newFakeService("svc", map[int]int{9000: 8080}, annotations.ServiceTypeHTTP)
+
+			httpPortMapper := &portMappingMock{
+				t: t,
+				addCalledWith: []portMapping{
+					{namespace: svc.Namespace, name: svc.Name, fromPort: 9000, toPort: 5000},
+				},
+			}
+
+			client, svcLister := newFakeK8sClient(t, svc)
+
+			mgr := ShadowServiceManager{
+				namespace:          testNamespace,
+				defaultTrafficType: testDefaultTrafficType,
+				kubeClient:         client,
+				serviceLister:      svcLister,
+				httpStateTable:     httpPortMapper,
+				logger:             logger,
+				trafficPolicy:      test.trafficPolicy,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			require.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+			shadowSvcName, err := GetShadowServiceName(svc.Namespace, svc.Name)
+			require.NoError(t, err)
+
+			shadowSvc, err := client.CoreV1().Services(testNamespace).Get(ctx, shadowSvcName, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			// Shadow services are never headless, the ClusterIP maps the service ports to the proxy ports.
+			assert.NotEqual(t, corev1.ClusterIPNone, shadowSvc.Spec.ClusterIP)
+			assert.Contains(t, []corev1.ServiceType{"", corev1.ServiceTypeClusterIP}, shadowSvc.Spec.Type)
+			assert.Equal(t, test.trafficPolicy, shadowSvc.Spec.InternalTrafficPolicy)
+		})
+	}
+}
+
 // TestShadowServiceManager_SyncServiceUpdateShadowService tests the case where a service has been updated and
 // the shadow service already exist. It makes sure the shadow service is updated accordingly.
 func TestShadowServiceManager_SyncServiceUpdateShadowService(t *testing.T) {
@@ -258,6 +380,58 @@ func TestShadowServiceManager_SyncServiceUpdateShadowService(t *testing.T) {
 	assert.Equal(t, 2, httpPortMapper.addCounter)
 }
 
+// TestShadowServiceManager_SyncServiceIgnoredPorts tests the case where a port of a service is excluded from the mesh.
+// It makes sure the port is released and removed from the shadow service.
+func TestShadowServiceManager_SyncServiceIgnoredPorts(t *testing.T) {
+	logger := logrus.New()
+
+	svc := newFakeService("svc", map[int]int{8000: 80, 9001: 8081}, annotations.ServiceTypeHTTP)
+	svc.Annotations["mesh.traefik.io/ignore-ports"] = "port-9001"
+
+	shadowSvc := newFakeShadowService(t, svc, map[int]int{8000: 5000, 9001: 5001})
+
+	httpPortMapper := &portMappingMock{
+		t: t,
+		removeCalledWith: []portMapping{
+			{namespace: svc.Namespace, name: svc.Name, fromPort: 9001, toPort: 5001},
+		},
+		addCalledWith: []portMapping{
+			{namespace: svc.Namespace, name: svc.Name, fromPort: 8000, toPort: 5000},
+		},
+	}
+
+	client, svcLister := newFakeK8sClient(t, svc, shadowSvc)
+
+	mgr := ShadowServiceManager{
+		namespace:          testNamespace,
+		defaultTrafficType: testDefaultTrafficType,
+		kubeClient:         client,
+		serviceLister:      svcLister,
+		httpStateTable:     httpPortMapper,
+		logger:             logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	updatedShadowSvc, err := client.CoreV1().Services(testNamespace).Get(ctx, shadowSvc.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []corev1.ServicePort{
+		{
+			Name:       "port-8000",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       8000,
+			TargetPort: intstr.FromInt(5000),
+		},
+	}, updatedShadowSvc.Spec.Ports)
+
+	assert.Equal(t, 1, httpPortMapper.removeCounter)
+	assert.Equal(t, 1, httpPortMapper.addCounter)
+}
+
 // TestShadowServiceManager_SyncServiceUpdateShadowServicesAndHandleTrafficTypeChanges tests the case a service has
 // been updated and its traffic type has changed.
 func TestShadowServiceManager_SyncServiceUpdateShadowServicesAndHandleTrafficTypeChanges(t *testing.T) {
@@ -316,6 +490,65 @@ func TestShadowServiceManager_SyncServiceUpdateShadowServicesAndHandleTrafficTyp
 	assert.Equal(t, 1, udpPortMapper.addCounter)
 }
 
+// TestShadowServiceManager_SyncServiceWithoutShadowService tests the case where shadow services are disabled. It makes
+// sure the ports of the service are mapped, and released when they are removed, while no shadow service is created.
+func TestShadowServiceManager_SyncServiceWithoutShadowService(t *testing.T) {
+	logger := logrus.New()
+
+	svc := newFakeService("svc", map[int]int{9000: 8080, 9001: 8081}, annotations.ServiceTypeHTTP)
+
+	client, svcLister := newFakeK8sClient(t, svc)
+	httpStateTable := portmapping.NewPortMapping(5000, 5005)
+
+	mgr := ShadowServiceManager{
+		namespace:          testNamespace,
+		defaultTrafficType: testDefaultTrafficType,
+		kubeClient:         client,
+		serviceLister:      svcLister,
+		httpStateTable:     httpStateTable,
+		logger:             logger,
+		noShadowService:    true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	require.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	// Make sure no shadow service has been created, while the ports are mapped.
+	shadowSvcs, err := client.CoreV1().Services(testNamespace).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, shadowSvcs.Items)
+
+	_, ok := httpStateTable.Find(svc.Namespace, svc.Name, 9000)
+	assert.True(t, ok)
+	_, ok = httpStateTable.Find(svc.Namespace, svc.Name, 9001)
+	assert.True(t, ok)
+
+	// Remove the port 9000 of the service.
+	updatedSvc := newFakeService("svc", map[int]int{9001: 8081}, annotations.ServiceTypeHTTP)
+	_, mgr.serviceLister = newFakeK8sClient(t, updatedSvc)
+
+	require.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	_, ok = httpStateTable.Find(svc.Namespace, svc.Name, 9000)
+	assert.False(t, ok)
+	_, ok = httpStateTable.Find(svc.Namespace, svc.Name, 9001)
+	assert.True(t, ok)
+
+	// Delete the service.
+	_, mgr.serviceLister = newFakeK8sClient(t)
+
+	require.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	_, ok = httpStateTable.Find(svc.Namespace, svc.Name, 9001)
+	assert.False(t, ok)
+
+	shadowSvcs, err = client.CoreV1().Services(testNamespace).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, shadowSvcs.Items)
+}
+
 // TestShadowServiceManager_SyncServiceDeleteShadowServices checks the case where the given service has been removed
 // and there are still some shadow services left.
 func TestShadowServiceManager_SyncServiceDeleteShadowServices(t *testing.T) {
@@ -356,6 +589,178 @@ func TestShadowServiceManager_SyncServiceDeleteShadowServices(t *testing.T) {
 	assert.Equal(t, 1, httpPortMapper.removeCounter)
 }
 
+// TestShadowServiceManager_DeleteOrphanShadowServices tests the case where the deletion of a service has been missed.
+// It makes sure the orphan shadow service is removed while the shadow service of an existing service is retained.
+func TestShadowServiceManager_DeleteOrphanShadowServices(t *testing.T) {
+	logger := logrus.New()
+
+	svc := newFakeService("svc", map[int]int{8000: 80}, annotations.ServiceTypeHTTP)
+	shadowSvc := newFakeShadowService(t, svc, map[int]int{8000: 5000})
+
+	// Simulate a service that have been removed without its shadow service.
+	deletedSvc := newFakeService("deleted-svc", map[int]int{8000: 80}, annotations.ServiceTypeHTTP)
+	orphanShadowSvc := newFakeShadowService(t, deletedSvc, map[int]int{8000: 5001})
+
+	httpPortMapper := &portMappingMock{
+		t: t,
+		removeCalledWith: []portMapping{
+			{namespace: deletedSvc.Namespace, name: deletedSvc.Name, fromPort: 8000, toPort: 5001},
+		},
+	}
+
+	client, svcLister := newFakeK8sClient(t, svc, shadowSvc, orphanShadowSvc)
+	recorder := record.NewFakeRecorder(10)
+
+	mgr := ShadowServiceManager{
+		namespace:          testNamespace,
+		defaultTrafficType: testDefaultTrafficType,
+		kubeClient:         client,
+		serviceLister:      svcLister,
+		httpStateTable:     httpPortMapper,
+		eventRecorder:      recorder,
+		logger:             logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	require.NoError(t, mgr.DeleteOrphanShadowServices(ctx))
+
+	_, err := client.CoreV1().Services(testNamespace).Get(ctx, orphanShadowSvc.Name, metav1.GetOptions{})
+	assert.True(t, kerrors.IsNotFound(err))
+
+	_, err = client.CoreV1().Services(testNamespace).Get(ctx, shadowSvc.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, httpPortMapper.removeCounter)
+
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "OrphanDeleted")
+}
+
+// TestShadowServiceManager_SyncServiceIgnoredService checks that an ignored service doesn't get a shadow service, and
+// that its shadow service is removed when the ignore annotation is added afterwards.
+func TestShadowServiceManager_SyncServiceIgnoredService(t *testing.T) {
+	logger := logrus.New()
+
+	svc := newFakeService("svc", map[int]int{8000: 80}, annotations.ServiceTypeHTTP)
+	svc.Annotations["mesh.traefik.io/ignore"] = "true"
+
+	shadowSvc := newFakeShadowService(t, svc, map[int]int{8000: 5000})
+
+	httpPortMapper := &portMappingMock{
+		t: t,
+		removeCalledWith: []portMapping{
+			{namespace: svc.Namespace, name: svc.Name, fromPort: 8000, toPort: 5000},
+		},
+	}
+
+	client, svcLister := newFakeK8sClient(t, svc, shadowSvc)
+
+	mgr := ShadowServiceManager{
+		namespace:          testNamespace,
+		defaultTrafficType: testDefaultTrafficType,
+		kubeClient:         client,
+		serviceLister:      svcLister,
+		httpStateTable:     httpPortMapper,
+		logger:             logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	// Check if the shadow service have been removed.
+	_, err := client.CoreV1().Services(testNamespace).Get(ctx, shadowSvc.Name, metav1.GetOptions{})
+	assert.True(t, kerrors.IsNotFound(err))
+
+	assert.Equal(t, 1, httpPortMapper.removeCounter)
+}
+
+// TestShadowServiceManager_SyncServiceInvalidIgnoreAnnotation checks that a service with an invalid ignore annotation
+// is ignored, as it is left out of the topology, and that its shadow service is removed.
+func TestShadowServiceManager_SyncServiceInvalidIgnoreAnnotation(t *testing.T) {
+	logger := logrus.New()
+
+	svc := newFakeService("svc", map[int]int{8000: 80}, annotations.ServiceTypeHTTP)
+	svc.Annotations["mesh.traefik.io/ignore"] = "maybe"
+
+	shadowSvc := newFakeShadowService(t, svc, map[int]int{8000: 5000})
+
+	httpPortMapper := &portMappingMock{
+		t: t,
+		removeCalledWith: []portMapping{
+			{namespace: svc.Namespace, name: svc.Name, fromPort: 8000, toPort: 5000},
+		},
+	}
+
+	client, svcLister := newFakeK8sClient(t, svc, shadowSvc)
+
+	mgr := ShadowServiceManager{
+		namespace:          testNamespace,
+		defaultTrafficType: testDefaultTrafficType,
+		kubeClient:         client,
+		serviceLister:      svcLister,
+		httpStateTable:     httpPortMapper,
+		logger:             logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	_, err := client.CoreV1().Services(testNamespace).Get(ctx, shadowSvc.Name, metav1.GetOptions{})
+	assert.True(t, kerrors.IsNotFound(err))
+
+	assert.Equal(t, 1, httpPortMapper.removeCounter)
+}
+
+// TestShadowServiceManager_SyncServiceIgnoreAnnotationRemoved checks that a shadow service is created once the ignore
+// annotation is removed from a service.
+func TestShadowServiceManager_SyncServiceIgnoreAnnotationRemoved(t *testing.T) {
+	logger := logrus.New()
+
+	svc := newFakeService("svc", map[int]int{8000: 80}, annotations.ServiceTypeHTTP)
+	svc.Annotations["mesh.traefik.io/ignore"] = "false"
+
+	httpPortMapper := &portMappingMock{
+		t: t,
+		addCalledWith: []portMapping{
+			{namespace: svc.Namespace, name: svc.Name, fromPort: 8000, toPort: 5000},
+		},
+	}
+
+	client, svcLister := newFakeK8sClient(t, svc)
+
+	mgr := ShadowServiceManager{
+		namespace:          testNamespace,
+		defaultTrafficType: testDefaultTrafficType,
+		kubeClient:         client,
+		serviceLister:      svcLister,
+		httpStateTable:     httpPortMapper,
+		logger:             logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, mgr.SyncService(ctx, svc.Namespace, svc.Name))
+
+	shadowSvcName, err := GetShadowServiceName(svc.Namespace, svc.Name)
+	require.NoError(t, err)
+
+	shadowSvc, err := client.CoreV1().Services(testNamespace).Get(ctx, shadowSvcName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, shadowSvc.Spec.Ports, 1)
+	assert.Equal(t, int32(8000), shadowSvc.Spec.Ports[0].Port)
+	assert.Equal(t, int32(5000), shadowSvc.Spec.Ports[0].TargetPort.IntVal)
+
+	assert.Equal(t, 1, httpPortMapper.addCounter)
+}
+
 func newFakeService(name string, ports map[int]int, trafficType string) *corev1.Service {
 	var svcPorts []corev1.ServicePort
 
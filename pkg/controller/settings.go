@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Keys of the settings ConfigMap data.
+const (
+	settingsLogLevel              = "logLevel"
+	settingsConfigRefreshInterval = "configRefreshInterval"
+	settingsACLDefaultDeny        = "aclDefaultDeny"
+)
+
+// settings are the controller settings which can be changed at runtime through the settings ConfigMap.
+type settings struct {
+	logLevel              logrus.Level
+	configRefreshInterval time.Duration
+	aclDefaultAllow       bool
+}
+
+// parseSettings returns the given base settings overridden by the data of the given settings ConfigMap, which may be
+// nil.
+func parseSettings(base settings, configMap *corev1.ConfigMap) (settings, error) {
+	s := base
+
+	if configMap == nil {
+		return s, nil
+	}
+
+	if value, ok := configMap.Data[settingsLogLevel]; ok {
+		level, err := logrus.ParseLevel(value)
+		if err != nil {
+			return base, fmt.Errorf("invalid %s: %w", settingsLogLevel, err)
+		}
+
+		s.logLevel = level
+	}
+
+	if value, ok := configMap.Data[settingsConfigRefreshInterval]; ok {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return base, fmt.Errorf("invalid %s: %w", settingsConfigRefreshInterval, err)
+		}
+
+		if interval < 0 {
+			return base, fmt.Errorf("invalid %s %q: must not be negative", settingsConfigRefreshInterval, value)
+		}
+
+		s.configRefreshInterval = interval
+	}
+
+	if value, ok := configMap.Data[settingsACLDefaultDeny]; ok {
+		deny, err := strconv.ParseBool(value)
+		if err != nil {
+			return base, fmt.Errorf("invalid %s: %w", settingsACLDefaultDeny, err)
+		}
+
+		s.aclDefaultAllow = !deny
+	}
+
+	return s, nil
+}
+
+// loadSettings reads the settings ConfigMap and applies its settings. The startup settings are restored for the keys
+// missing from the ConfigMap, or if it doesn't exist. The current settings are kept if the ConfigMap is invalid.
+func (c *Controller) loadSettings() error {
+	configMap, err := c.settingsLister.ConfigMaps(c.cfg.Namespace).Get(c.cfg.SettingsConfigMap)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("unable to get settings ConfigMap %s/%s: %w", c.cfg.Namespace, c.cfg.SettingsConfigMap, err)
+	}
+
+	s, err := parseSettings(c.baseSettings, configMap)
+	if err != nil {
+		return fmt.Errorf("invalid settings ConfigMap %s/%s: %w", c.cfg.Namespace, c.cfg.SettingsConfigMap, err)
+	}
+
+	c.applySettings(s)
+
+	return nil
+}
+
+// applySettings applies the given settings. The refresh interval and the ACL default posture are used by the next
+// configuration builds.
+func (c *Controller) applySettings(s settings) {
+	if s.logLevel != c.settings.logLevel {
+		if setLogLevel(c.logger, s.logLevel) {
+			c.logger.Infof("Log level set to %s", s.logLevel)
+		} else {
+			c.logger.Warnf("Unable to set log level to %s, the logger doesn't support it", s.logLevel)
+		}
+	}
+
+	if s.configRefreshInterval != c.settings.configRefreshInterval {
+		c.logger.Infof("Configuration refresh interval set to %s", s.configRefreshInterval)
+	}
+
+	if s.aclDefaultAllow != c.settings.aclDefaultAllow {
+		c.logger.Infof("ACL default allow set to %t", s.aclDefaultAllow)
+	}
+
+	c.cfg.ConfigRefreshInterval = s.configRefreshInterval
+	c.cfg.ACLDefaultAllow = s.aclDefaultAllow
+	c.provider.SetACLDefaultAllow(s.aclDefaultAllow)
+
+	c.settings = s
+}
+
+// isSettingsConfigMap returns true if the given resource is the settings ConfigMap, false otherwise.
+func (c *Controller) isSettingsConfigMap(obj interface{}) bool {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return false
+	}
+
+	return key == c.cfg.Namespace+"/"+c.cfg.SettingsConfigMap
+}
+
+// getLogLevel returns the level of the given logger, the logrus standard logger level if it can't be read.
+func getLogLevel(logger logrus.FieldLogger) logrus.Level {
+	switch l := logger.(type) {
+	case *logrus.Logger:
+		return l.GetLevel()
+	case *logrus.Entry:
+		return l.Logger.GetLevel()
+	default:
+		return logrus.GetLevel()
+	}
+}
+
+// setLogLevel sets the level of the given logger. It returns false if the logger level can't be set.
+func setLogLevel(logger logrus.FieldLogger, level logrus.Level) bool {
+	switch l := logger.(type) {
+	case *logrus.Logger:
+		l.SetLevel(level)
+	case *logrus.Entry:
+		l.Logger.SetLevel(level)
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/v2/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseSettings(t *testing.T) {
+	base := settings{
+		logLevel:              logrus.ErrorLevel,
+		configRefreshInterval: time.Second,
+		aclDefaultAllow:       false,
+	}
+
+	tests := []struct {
+		desc    string
+		data    map[string]string
+		want    settings
+		wantErr bool
+	}{
+		{
+			desc: "no settings",
+			want: base,
+		},
+		{
+			desc: "all settings",
+			data: map[string]string{
+				settingsLogLevel:              "debug",
+				settingsConfigRefreshInterval: "5s",
+				settingsACLDefaultDeny:        "false",
+			},
+			want: settings{
+				logLevel:              logrus.DebugLevel,
+				configRefreshInterval: 5 * time.Second,
+				aclDefaultAllow:       true,
+			},
+		},
+		{
+			desc: "refresh interval disabled",
+			data: map[string]string{settingsConfigRefreshInterval: "0s"},
+			want: settings{
+				logLevel:              logrus.ErrorLevel,
+				configRefreshInterval: 0,
+				aclDefaultAllow:       false,
+			},
+		},
+		{
+			desc:    "invalid log level",
+			data:    map[string]string{settingsLogLevel: "verbose"},
+			wantErr: true,
+		},
+		{
+			desc:    "negative refresh interval",
+			data:    map[string]string{settingsConfigRefreshInterval: "-1s"},
+			wantErr: true,
+		},
+		{
+			desc:    "invalid ACL default deny",
+			data:    map[string]string{settingsACLDefaultDeny: "maybe"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseSettings(base, &corev1.ConfigMap{Data: test.data})
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, base, got)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestController_SettingsConfigMapUpdatesLogLevel(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("mock.yaml")
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.InfoLevel)
+
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode:       "http",
+		Namespace:         traefikMeshNamespace,
+		MinHTTPPort:       minHTTPPort,
+		MaxHTTPPort:       maxHTTPPort,
+		MinTCPPort:        minTCPPort,
+		MaxTCPPort:        maxTCPPort,
+		MinUDPPort:        minUDPPort,
+		MaxUDPPort:        maxUDPPort,
+		SettingsConfigMap: "traefik-mesh-settings",
+	}, store, logger)
+	defer controller.workQueue.ShutDown()
+
+	controller.topologyBuilder = &topologyBuilderMock{}
+
+	configMaps := clientMock.KubernetesClient().CoreV1().ConfigMaps(traefikMeshNamespace)
+
+	configMap, err := configMaps.Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik-mesh-settings", Namespace: traefikMeshNamespace},
+		Data:       map[string]string{settingsLogLevel: "debug"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// Only the settings informer is started, so that the settings work is the only one enqueued.
+	require.NoError(t, startInformerFactory(controller.settingsFactory, stopCh, stopCh))
+
+	// The settings of the existing ConfigMap are applied once its addition is processed.
+	processSettingsWork(t, controller)
+
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+	assert.Equal(t, 1, store.configurations)
+
+	// Updating the ConfigMap changes the log level without restarting the controller.
+	configMap.Data[settingsLogLevel] = "warn"
+
+	_, err = configMaps.Update(context.Background(), configMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	processSettingsWork(t, controller)
+
+	assert.Equal(t, logrus.WarnLevel, logger.GetLevel())
+	assert.Equal(t, 2, store.configurations)
+
+	// An invalid log level is ignored, the current one is kept.
+	configMap.Data[settingsLogLevel] = "verbose"
+
+	_, err = configMaps.Update(context.Background(), configMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	processSettingsWork(t, controller)
+
+	assert.Equal(t, logrus.WarnLevel, logger.GetLevel())
+	assert.Equal(t, 2, store.configurations)
+
+	// Deleting the ConfigMap restores the startup log level.
+	err = configMaps.Delete(context.Background(), configMap.Name, metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	processSettingsWork(t, controller)
+
+	assert.Equal(t, logrus.InfoLevel, logger.GetLevel())
+}
+
+// processSettingsWork waits for the settings work to be enqueued, and processes it.
+func processSettingsWork(t *testing.T, controller *Controller) {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return controller.workQueue.Len() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	for controller.workQueue.Len() > 0 {
+		controller.processNextWorkItem()
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/v2/pkg/annotations"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationsWebhook is an admission webhook handler rejecting Services with invalid mesh annotations.
+type AnnotationsWebhook struct {
+	logger logrus.FieldLogger
+}
+
+// NewAnnotationsWebhook creates a new AnnotationsWebhook.
+func NewAnnotationsWebhook(logger logrus.FieldLogger) *AnnotationsWebhook {
+	return &AnnotationsWebhook{logger: logger}
+}
+
+// ServeHTTP handles the given AdmissionReview request and replies with an AdmissionReview holding the response.
+func (w *AnnotationsWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var review admissionv1.AdmissionReview
+
+	if err := json.NewDecoder(req.Body).Decode(&review); err != nil {
+		w.logger.Errorf("Unable to decode admission review: %v", err)
+		http.Error(rw, "unable to decode admission review", http.StatusBadRequest)
+
+		return
+	}
+
+	if review.Request == nil {
+		http.Error(rw, "admission review has no request", http.StatusBadRequest)
+
+		return
+	}
+
+	review.Response = w.review(review.Request)
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		w.logger.Errorf("Unable to serialize admission review: %v", err)
+	}
+}
+
+// review validates the mesh annotations of the Service held by the given request.
+func (w *AnnotationsWebhook) review(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+
+	if req.Kind.Kind != "Service" || req.Operation == admissionv1.Delete {
+		return resp
+	}
+
+	var svc corev1.Service
+
+	if err := json.Unmarshal(req.Object.Raw, &svc); err != nil {
+		return deny(resp, fmt.Errorf("unable to decode Service: %w", err))
+	}
+
+	if err := annotations.Validate(svc.Annotations); err != nil {
+		w.logger.Debugf("Rejecting Service %s/%s: %v", req.Namespace, svc.Name, err)
+
+		return deny(resp, err)
+	}
+
+	return resp
+}
+
+// deny updates the given response to reject the request with the given error.
+func deny(resp *admissionv1.AdmissionResponse, err error) *admissionv1.AdmissionResponse {
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: err.Error(),
+		Reason:  metav1.StatusReasonInvalid,
+		Code:    http.StatusUnprocessableEntity,
+	}
+
+	return resp
+}
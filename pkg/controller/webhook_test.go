@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAnnotationsWebhook_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc        string
+		kind        string
+		operation   admissionv1.Operation
+		annotations map[string]string
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			desc:      "should allow a Service without annotations",
+			kind:      "Service",
+			operation: admissionv1.Create,
+			annotations: map[string]string{
+				"foo": "bar",
+			},
+			wantAllowed: true,
+		},
+		{
+			desc:      "should allow a Service with valid annotations",
+			kind:      "Service",
+			operation: admissionv1.Update,
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-type":      "http",
+				"mesh.traefik.io/retry-attempts":    "2",
+				"mesh.traefik.io/ratelimit-average": "100",
+				"mesh.traefik.io/ratelimit-burst":   "200",
+			},
+			wantAllowed: true,
+		},
+		{
+			desc:      "should deny a Service with an invalid retry-attempts annotation",
+			kind:      "Service",
+			operation: admissionv1.Create,
+			annotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "two",
+			},
+			wantAllowed: false,
+			wantMessage: `invalid annotations: unable to build retry middleware: invalid value "mesh.traefik.io/retry-attempts": strconv.Atoi: parsing "two": invalid syntax`,
+		},
+		{
+			desc:      "should deny a Service with several invalid annotations",
+			kind:      "Service",
+			operation: admissionv1.Update,
+			annotations: map[string]string{
+				"mesh.traefik.io/traffic-type": "sctp",
+				"mesh.traefik.io/max-conn":     "0",
+			},
+			wantAllowed: false,
+			wantMessage: `invalid annotations: unsupported traffic type "mesh.traefik.io/traffic-type": "sctp"; unable to build in-flight-req middleware: invalid value "mesh.traefik.io/max-conn": 0 must be greater than 0`,
+		},
+		{
+			desc:      "should allow other kinds of resources",
+			kind:      "Pod",
+			operation: admissionv1.Create,
+			annotations: map[string]string{
+				"mesh.traefik.io/retry-attempts": "two",
+			},
+			wantAllowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			svc, err := json.Marshal(&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   "ns",
+					Annotations: test.annotations,
+				},
+			})
+			require.NoError(t, err)
+
+			body, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       "uid",
+					Kind:      metav1.GroupVersionKind{Version: "v1", Kind: test.kind},
+					Namespace: "ns",
+					Operation: test.operation,
+					Object:    runtime.RawExtension{Raw: svc},
+				},
+			})
+			require.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+
+			NewAnnotationsWebhook(logger).ServeHTTP(rw, req)
+
+			assert.Equal(t, http.StatusOK, rw.Code)
+
+			var review admissionv1.AdmissionReview
+
+			err = json.NewDecoder(rw.Body).Decode(&review)
+			require.NoError(t, err)
+			require.NotNil(t, review.Response)
+
+			assert.Equal(t, "uid", string(review.Response.UID))
+			assert.Equal(t, test.wantAllowed, review.Response.Allowed)
+
+			if test.wantAllowed {
+				assert.Nil(t, review.Response.Result)
+				return
+			}
+
+			require.NotNil(t, review.Response.Result)
+			assert.Equal(t, test.wantMessage, review.Response.Result.Message)
+		})
+	}
+}
+
+func TestAnnotationsWebhook_ServeHTTPInvalidReview(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"kind":"AdmissionReview"}`)))
+
+	NewAnnotationsWebhook(logger).ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
@@ -0,0 +1,61 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// HostResolver resolves host names to addresses. It is implemented by net.Resolver.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NewHostResolver creates a HostResolver querying the given DNS server address, or the system resolver, i.e. the
+// cluster DNS provider when running in a pod, when the address is empty.
+func NewHostResolver(server string) HostResolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// CheckResolution resolves the Traefik Mesh name of the given service with the given resolver, and returns the
+// resolved addresses. The returned error tells whether the name doesn't exist, which means the cluster DNS provider
+// doesn't forward the Traefik Mesh domain, or the resolution failed for another reason.
+func CheckResolution(ctx context.Context, resolver HostResolver, namespace, name string) ([]string, error) {
+	if namespace == "" || name == "" {
+		return nil, errors.New("service name and namespace are required")
+	}
+
+	host := fmt.Sprintf("%s.%s.%s", name, namespace, meshDomain)
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, fmt.Errorf("name %q does not exist: the cluster DNS provider may not be configured to forward the %q domain, or the service may not be part of the mesh: %w", host, meshDomain, err)
+		}
+
+		return nil, fmt.Errorf("unable to resolve %q: %w", host, err)
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address resolved for %q", host)
+	}
+
+	return addrs, nil
+}
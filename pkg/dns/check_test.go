@@ -0,0 +1,104 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hostResolverMock struct {
+	addrs map[string][]string
+	err   error
+}
+
+func (r hostResolverMock) LookupHost(_ context.Context, host string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	addrs, ok := r.addrs[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return addrs, nil
+}
+
+func TestCheckResolution(t *testing.T) {
+	tests := []struct {
+		desc         string
+		resolver     hostResolverMock
+		namespace    string
+		name         string
+		wantAddrs    []string
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{
+			desc: "resolved",
+			resolver: hostResolverMock{
+				addrs: map[string][]string{"whoami.default.traefik.mesh": {"10.10.14.1"}},
+			},
+			namespace: "default",
+			name:      "whoami",
+			wantAddrs: []string{"10.10.14.1"},
+		},
+		{
+			desc: "NXDOMAIN",
+			resolver: hostResolverMock{
+				addrs: map[string][]string{},
+			},
+			namespace:    "default",
+			name:         "whoami",
+			wantNotFound: true,
+			wantErr:      true,
+		},
+		{
+			desc: "resolution failure",
+			resolver: hostResolverMock{
+				err: &net.DNSError{Err: "i/o timeout", Name: "whoami.default.traefik.mesh", IsTimeout: true},
+			},
+			namespace: "default",
+			name:      "whoami",
+			wantErr:   true,
+		},
+		{
+			desc: "no address",
+			resolver: hostResolverMock{
+				addrs: map[string][]string{"whoami.default.traefik.mesh": {}},
+			},
+			namespace: "default",
+			name:      "whoami",
+			wantErr:   true,
+		},
+		{
+			desc:      "missing service name",
+			namespace: "default",
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			addrs, err := CheckResolution(context.Background(), test.resolver, test.namespace, test.name)
+			if test.wantErr {
+				require.Error(t, err)
+
+				var dnsErr *net.DNSError
+				assert.Equal(t, test.wantNotFound, errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAddrs, addrs)
+		})
+	}
+}
@@ -5,6 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,11 +17,15 @@ import (
 	"github.com/google/uuid"
 	goversion "github.com/hashicorp/go-version"
 	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/v2/pkg/k8s"
+	"github.com/traefik/mesh/v2/pkg/logfield"
 	"github.com/traefik/mesh/v2/pkg/safe"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -31,28 +40,151 @@ const (
 
 	blockHeader  = "#### Begin Traefik Mesh Block"
 	blockTrailer = "#### End Traefik Mesh Block"
+
+	// healthHeader and healthTrailer enclose the health plugin of the Corefile patched with the health lameduck
+	// option, preceded by the original plugin commented out so that it can be restored.
+	healthHeader  = "#### Begin Traefik Mesh Health"
+	healthTrailer = "#### End Traefik Mesh Health"
+
+	meshDomain = "traefik.mesh"
+
+	// coreDNSSnapshotConfigMapName is the name of the ConfigMap holding the snapshot of the CoreDNS configuration taken
+	// before it was first patched.
+	coreDNSSnapshotConfigMapName = "traefik-mesh-coredns-snapshot"
+	coreDNSSnapshotKey           = "snapshot"
+
+	// addonManagerModeLabel is the label of the resources managed by the Kubernetes addon-manager, which reverts the
+	// changes made to them.
+	addonManagerModeLabel = "addonmanager.kubernetes.io/mode"
+
+	// fieldManager is the manager of the fields set by server-side apply.
+	fieldManager = "traefik-mesh"
+
+	// restartHashAnnotation is the pod template annotation updated to restart the pods of the DNS provider.
+	restartHashAnnotation = "traefik-mesh-hash"
 )
 
+// ErrSnapshotNotFound is returned when no snapshot of the CoreDNS configuration has been stored.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrUnsupportedDNSProvider is returned when neither CoreDNS nor KubeDNS is deployed in the cluster.
+var ErrUnsupportedDNSProvider = errors.New("no supported DNS service available")
+
+// ErrCoreDNSDaemonSet is returned when configuring CoreDNS deployed by a DaemonSet, which is detected but can only be
+// configured manually.
+var ErrCoreDNSDaemonSet = errors.New("CoreDNS deployed by a DaemonSet must be configured manually")
+
 var (
 	versionCoreDNS14 = goversion.Must(goversion.NewVersion("1.4"))
+	versionCoreDNS15 = goversion.Must(goversion.NewVersion("1.5"))
+	versionCoreDNS16 = goversion.Must(goversion.NewVersion("1.6"))
+	versionCoreDNS17 = goversion.Must(goversion.NewVersion("1.7"))
 
 	// Currently supported CoreDNS versions range.
 	versionCoreDNSMin = goversion.Must(goversion.NewVersion("1.3"))
 	versionCoreDNSMax = goversion.Must(goversion.NewVersion("1.9"))
 )
 
+var (
+	// healthPluginRegexp matches the health plugin of a Corefile, with its optional address and options block.
+	healthPluginRegexp = regexp.MustCompile(`(?m)^([ \t]*)health([ \t]+[^\s{}#]+)?[ \t]*(\{[^{}]*\})?[ \t]*$`)
+	// readyPluginRegexp matches the ready plugin of a Corefile, with its optional address.
+	readyPluginRegexp = regexp.MustCompile(`(?m)^[ \t]*ready([ \t]+[^\s{}#]+)?[ \t]*$`)
+)
+
+// String returns the name of the DNS provider.
+func (p Provider) String() string {
+	switch p {
+	case CoreDNS:
+		return "coredns"
+	case KubeDNS:
+		return "kube-dns"
+	default:
+		return "unknown"
+	}
+}
+
+// BlockOptions holds the options of the Traefik Mesh server block added to the CoreDNS configuration.
+type BlockOptions struct {
+	// Ready adds the ready plugin to the block, listening on the address of the ready plugin of the configuration if
+	// any, so that the block reports its readiness to the same endpoint as the main zone.
+	Ready bool
+	// TLSServerName, when set, makes the block forward queries to the Traefik Mesh DNS service over TLS (DoT), using
+	// the given server name to verify the upstream certificate.
+	TLSServerName string
+	// CacheTTL is the maximum TTL, in whole seconds, of the entries cached by the block, 30s when nil. The cache
+	// plugin is omitted from the block when zero, for services whose IPs change too often to be cached.
+	CacheTTL *time.Duration
+	// ServeStale, when positive, makes the cache of the block serve expired entries for the given duration when the
+	// Traefik Mesh DNS service is unreachable.
+	ServeStale time.Duration
+	// ExtraZones are additional zones, e.g. corp.internal, forwarded to the Traefik Mesh DNS service. Each of them is
+	// served by its own server block, added to the Traefik Mesh block.
+	ExtraZones []string
+	// ErrorsConsolidate, when positive, makes the errors plugin of the block consolidate the errors matching
+	// ErrorsConsolidatePattern into a single log entry for the given duration.
+	ErrorsConsolidate time.Duration
+	// ErrorsConsolidatePattern is the regular expression matching the consolidated errors, all of them when empty.
+	ErrorsConsolidatePattern string
+	// ExceptDomains are subdomains of the mesh domain, or of an extra zone, which are not forwarded to the Traefik Mesh
+	// DNS service, e.g. internal.traefik.mesh. They are listed in the except option of the forward plugin.
+	ExceptDomains []string
+	// HealthLameduck, when positive, sets the lameduck duration of the health plugin of the Corefile, so that CoreDNS
+	// keeps serving the mesh domain for this duration when shutting down. The health plugin can only be enabled once
+	// per CoreDNS instance, hence the existing one is patched instead of adding one to the Traefik Mesh block.
+	HealthLameduck time.Duration
+}
+
 // Client holds the client for interacting with the k8s DNS system.
 type Client struct {
 	kubeClient kubernetes.Interface
 	logger     logrus.FieldLogger
+	namespace  string
+	noCreate   bool
+	apply      bool
+}
+
+// ClientOption configures the given Client.
+type ClientOption func(client *Client)
+
+// SystemNamespace sets the namespace in which the DNS provider deployment and its ConfigMaps are installed.
+func SystemNamespace(namespace string) ClientOption {
+	return func(client *Client) {
+		client.namespace = namespace
+	}
+}
+
+// NoConfigMapCreation prevents the Client from creating the optional ConfigMap of the DNS provider when it doesn't
+// exist. Only existing ConfigMaps are patched.
+func NoConfigMapCreation() ClientOption {
+	return func(client *Client) {
+		client.noCreate = true
+	}
 }
 
-// NewClient returns an initialized DNSClient object.
-func NewClient(logger logrus.FieldLogger, kubeClient kubernetes.Interface) *Client {
-	return &Client{
+// ServerSideApply makes the Client update the ConfigMaps and the Deployment of the DNS provider with server-side apply,
+// using the traefik-mesh field manager, instead of replacing them. Only the fields managed by Traefik Mesh are applied,
+// and a conflict with another field manager is returned as an error.
+func ServerSideApply() ClientOption {
+	return func(client *Client) {
+		client.apply = true
+	}
+}
+
+// NewClient returns an initialized DNSClient object. By default, the DNS provider is looked up in the kube-system
+// namespace.
+func NewClient(logger logrus.FieldLogger, kubeClient kubernetes.Interface, opts ...ClientOption) *Client {
+	client := &Client{
 		kubeClient: kubeClient,
 		logger:     logger,
+		namespace:  metav1.NamespaceSystem,
+	}
+
+	for _, opt := range opts {
+		opt(client)
 	}
+
+	return client
 }
 
 // CheckDNSProvider checks that the DNS provider deployed in the cluster is supported and returns it.
@@ -77,115 +209,308 @@ func (c *Client) CheckDNSProvider(ctx context.Context) (Provider, error) {
 		return KubeDNS, nil
 	}
 
-	return UnknownDNS, errors.New("no supported DNS service available")
+	return UnknownDNS, ErrUnsupportedDNSProvider
 }
 
 func (c *Client) coreDNSMatch(ctx context.Context) (bool, error) {
-	c.logger.Debugf("Checking if CoreDNS is installed in namespace %q...", metav1.NamespaceSystem)
+	logger := c.providerLogger(CoreDNS)
+	logger.Debugf("Checking if CoreDNS is installed in namespace %q...", c.namespace)
 
-	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
-	if kerrors.IsNotFound(err) {
-		c.logger.Debug("CoreDNS deployment not found")
-		return false, nil
+	podSpec, found, err := c.getCoreDNSPodSpec(ctx, logger)
+	if err != nil {
+		return false, err
 	}
 
-	if err != nil {
-		return false, fmt.Errorf("unable to get CoreDNS deployment in namespace %q: %w", metav1.NamespaceSystem, err)
+	if !found {
+		logger.Debug("CoreDNS deployment and daemonset not found")
+		return false, nil
 	}
 
-	version, err := getCoreDNSVersion(dnsDeployment)
+	version, err := getCoreDNSVersion(podSpec)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("unable to get CoreDNS version in namespace %q: %w", c.namespace, err)
 	}
 
 	if !(version.Core().GreaterThanOrEqual(versionCoreDNSMin) && version.Core().LessThan(versionCoreDNSMax)) {
-		c.logger.Debugf(`CoreDNS version is not supported, must satisfy ">= %s, < %s", got %q`, versionCoreDNSMin, versionCoreDNSMax, version)
+		logger.Debugf(`CoreDNS version is not supported, must satisfy ">= %s, < %s", got %q`, versionCoreDNSMin, versionCoreDNSMax, version)
 
 		return false, fmt.Errorf("unsupported CoreDNS version %q", version)
 	}
 
-	c.logger.Debugf("CoreDNS %q has been detected", version)
+	logger.Debugf("CoreDNS %q has been detected", version)
+
+	c.checkCoreDNSConfigMap(ctx, logger, podSpec)
 
 	return true, nil
 }
 
+// getCoreDNSPodSpec returns the pod spec of the coredns Deployment or, if there is none, of the coredns DaemonSet. It
+// returns false if CoreDNS is deployed by neither of them.
+func (c *Client) getCoreDNSPodSpec(ctx context.Context, logger logrus.FieldLogger) (corev1.PodSpec, bool, error) {
+	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(c.namespace).Get(ctx, "coredns", metav1.GetOptions{})
+	if err == nil {
+		return dnsDeployment.Spec.Template.Spec, true, nil
+	}
+
+	if !kerrors.IsNotFound(err) {
+		return corev1.PodSpec{}, false, fmt.Errorf("unable to get CoreDNS deployment in namespace %q: %w", c.namespace, err)
+	}
+
+	// The DaemonSets may not be readable, as their permission is only needed by this lookup. CoreDNS is then
+	// considered not deployed by a DaemonSet, for KubeDNS to still be detected.
+	dnsDaemonSet, err := c.kubeClient.AppsV1().DaemonSets(c.namespace).Get(ctx, "coredns", metav1.GetOptions{})
+	if kerrors.IsNotFound(err) || kerrors.IsForbidden(err) {
+		return corev1.PodSpec{}, false, nil
+	}
+
+	if err != nil {
+		return corev1.PodSpec{}, false, fmt.Errorf("unable to get CoreDNS daemonset in namespace %q: %w", c.namespace, err)
+	}
+
+	logger.Warnf("CoreDNS is deployed by daemonset %q in namespace %q, its configuration must be patched manually as only deployments are configured", dnsDaemonSet.Name, dnsDaemonSet.Namespace)
+
+	return dnsDaemonSet.Spec.Template.Spec, true, nil
+}
+
+// getCoreDNSDeployment returns the coredns Deployment. It returns ErrCoreDNSDaemonSet if it doesn't exist, as CoreDNS is
+// then deployed by a DaemonSet when it has been detected.
+func (c *Client) getCoreDNSDeployment(ctx context.Context) (*appsv1.Deployment, error) {
+	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(c.namespace).Get(ctx, "coredns", metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("CoreDNS deployment not found in namespace %q: %w", c.namespace, ErrCoreDNSDaemonSet)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dnsDeployment, nil
+}
+
+// checkCoreDNSConfigMap logs a warning if the CoreDNS configuration is not loaded from the coredns-custom ConfigMap
+// or from the Corefile key of the coredns ConfigMap, which are the ones patched by the client.
+func (c *Client) checkCoreDNSConfigMap(ctx context.Context, logger logrus.FieldLogger, podSpec corev1.PodSpec) {
+	if _, err := getConfigMapVolume(podSpec, "coredns-custom"); err == nil {
+		return
+	}
+
+	volume, err := getConfigMapVolume(podSpec, "coredns")
+	if err != nil {
+		logger.Warn(`CoreDNS configuration is not loaded from the "coredns" ConfigMap, it may need to be patched manually`)
+		return
+	}
+
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).Get(ctx, volume.Name, metav1.GetOptions{})
+	if err != nil {
+		logger.Warnf("Unable to get CoreDNS ConfigMap %q in namespace %q, its configuration may need to be patched manually: %v", volume.Name, c.namespace, err)
+		return
+	}
+
+	if _, exists := configMap.Data["Corefile"]; !exists {
+		logger.Warnf("CoreDNS ConfigMap %q in namespace %q has no Corefile key, its configuration may need to be patched manually", volume.Name, c.namespace)
+	}
+}
+
 func (c *Client) kubeDNSMatch(ctx context.Context) (bool, error) {
-	c.logger.Debugf("Checking if KubeDNS is installed in namespace %q...", metav1.NamespaceSystem)
+	logger := c.providerLogger(KubeDNS)
+	logger.Debugf("Checking if KubeDNS is installed in namespace %q...", c.namespace)
 
-	_, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "kube-dns", metav1.GetOptions{})
+	_, err := c.kubeClient.AppsV1().Deployments(c.namespace).Get(ctx, "kube-dns", metav1.GetOptions{})
 	if kerrors.IsNotFound(err) {
-		c.logger.Debug("KubeDNS deployment not found")
+		logger.Debug("KubeDNS deployment not found")
 		return false, nil
 	}
 
 	if err != nil {
-		return false, fmt.Errorf("unable to get KubeDNS deployment in namespace %q: %w", metav1.NamespaceSystem, err)
+		return false, fmt.Errorf("unable to get KubeDNS deployment in namespace %q: %w", c.namespace, err)
 	}
 
-	c.logger.Debug("KubeDNS has been detected")
+	logger.Debug("KubeDNS has been detected")
 
 	return true, nil
 }
 
 // ConfigureCoreDNS patches the CoreDNS configuration for Traefik Mesh.
-func (c *Client) ConfigureCoreDNS(ctx context.Context, dnsServiceNamespace, dnsServiceName string, dnsServicePort int32) error {
-	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+func (c *Client) ConfigureCoreDNS(ctx context.Context, dnsServiceNamespace, dnsServiceName string, dnsServicePort int32, opts BlockOptions) error {
+	logger := c.providerLogger(CoreDNS)
+
+	dnsDeployment, err := c.getCoreDNSDeployment(ctx)
 	if err != nil {
 		return err
 	}
 
-	dnsServiceIP, err := c.getServiceIP(ctx, dnsServiceNamespace, dnsServiceName)
+	dnsUpstreams, err := c.getServiceUpstreams(ctx, dnsServiceNamespace, dnsServiceName, dnsServicePort)
 	if err != nil {
-		return fmt.Errorf("unable to get ClusterIP of DNS service %q in namespace %q: %w", dnsServiceName, dnsServiceNamespace, err)
+		return fmt.Errorf("unable to get upstreams of DNS service %q in namespace %q: %w", dnsServiceName, dnsServiceNamespace, err)
 	}
 
-	configMap, changed, err := c.patchCoreDNSConfig(ctx, dnsDeployment, dnsServiceIP, dnsServicePort)
+	version, err := getCoreDNSVersion(dnsDeployment.Spec.Template.Spec)
+	if err != nil {
+		return fmt.Errorf("unable to get CoreDNS version of deployment %q in namespace %q: %w", dnsDeployment.Name, dnsDeployment.Namespace, err)
+	}
+
+	if err = validateCoreDNSBlockOptions(version, opts); err != nil {
+		return err
+	}
+
+	if opts.ErrorsConsolidate > 0 && version.Core().LessThan(versionCoreDNS16) {
+		logger.Warnf("CoreDNS %q doesn't support consolidating errors, it won't be enabled in the Traefik Mesh block", version)
+	}
+
+	if opts.Ready && version.Core().LessThan(versionCoreDNS15) {
+		logger.Warnf("CoreDNS %q doesn't support the ready plugin, it won't be added to the Traefik Mesh block", version)
+	}
+
+	if opts.ServeStale > 0 && version.Core().LessThan(versionCoreDNS17) {
+		logger.Warnf("CoreDNS %q doesn't support serving stale cache entries, it won't be enabled in the Traefik Mesh block", version)
+	}
+
+	// Without a snapshot, the cleanup falls back to removing the Traefik Mesh block, which is why a failure to store it
+	// doesn't prevent CoreDNS from being configured.
+	if err = c.storeCoreDNSSnapshot(ctx, logger, dnsServiceNamespace); err != nil {
+		logger.Warnf("Unable to store CoreDNS configuration snapshot in namespace %q, the Traefik Mesh block will be removed on cleanup instead: %v", dnsServiceNamespace, err)
+	}
+
+	configMap, changed, err := c.patchCoreDNSConfig(ctx, dnsDeployment, version, dnsUpstreams, opts, true)
 	if err != nil {
 		return fmt.Errorf("unable to patch coredns config: %w", err)
 	}
 
 	if !changed {
-		c.logger.Infof("CoreDNS ConfigMap %q in namespace %q has already been patched", configMap.Name, configMap.Namespace)
+		logger.Infof("CoreDNS ConfigMap %q in namespace %q has already been patched", configMap.Name, configMap.Namespace)
 
 		return nil
 	}
 
-	if _, err = c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+	if err = c.updateConfigMap(ctx, configMap, getCoreDNSConfigKey(configMap)); err != nil {
 		return err
 	}
 
-	c.logger.Infof("CoreDNS ConfigMap %q in namespace %q has successfully been patched", configMap.Name, configMap.Namespace)
+	logger.Infof("CoreDNS ConfigMap %q in namespace %q has successfully been patched", configMap.Name, configMap.Namespace)
 
-	if err := c.restartPods(ctx, dnsDeployment); err != nil {
+	if err := c.restartPods(ctx, logger, dnsDeployment); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (c *Client) patchCoreDNSConfig(ctx context.Context, deployment *appsv1.Deployment, dnsServiceIP string, dnsServicePort int32) (*corev1.ConfigMap, bool, error) {
-	version, err := getCoreDNSVersion(deployment)
+// NeedsReconfigure returns whether the CoreDNS configuration differs from the one ConfigureCoreDNS would set up with the
+// given options, that is whether calling it would patch the configuration and restart CoreDNS. Nothing is modified.
+func (c *Client) NeedsReconfigure(ctx context.Context, dnsServiceNamespace, dnsServiceName string, dnsServicePort int32, opts BlockOptions) (bool, error) {
+	_, changed, err := c.buildCoreDNSConfig(ctx, dnsServiceNamespace, dnsServiceName, dnsServicePort, opts)
+
+	return changed, err
+}
+
+// BuildCoreDNSConfigMap returns the CoreDNS ConfigMap patched with the Traefik Mesh block built with the given options,
+// as ConfigureCoreDNS would update it, for it to be applied by other means. Nothing is modified. The returned ConfigMap
+// only holds the data and the metadata identifying it, without the fields managed by the API server.
+func (c *Client) BuildCoreDNSConfigMap(ctx context.Context, dnsServiceNamespace, dnsServiceName string, dnsServicePort int32, opts BlockOptions) (*corev1.ConfigMap, error) {
+	configMap, _, err := c.buildCoreDNSConfig(ctx, dnsServiceNamespace, dnsServiceName, dnsServicePort, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        configMap.Name,
+			Namespace:   configMap.Namespace,
+			Labels:      configMap.Labels,
+			Annotations: configMap.Annotations,
+		},
+		Data:       configMap.Data,
+		BinaryData: configMap.BinaryData,
+	}, nil
+}
+
+// buildCoreDNSConfig returns the CoreDNS ConfigMap patched with the Traefik Mesh block built with the given options, and
+// whether it changed. Nothing is modified.
+func (c *Client) buildCoreDNSConfig(ctx context.Context, dnsServiceNamespace, dnsServiceName string, dnsServicePort int32, opts BlockOptions) (*corev1.ConfigMap, bool, error) {
+	dnsDeployment, err := c.getCoreDNSDeployment(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	dnsUpstreams, err := c.getServiceUpstreams(ctx, dnsServiceNamespace, dnsServiceName, dnsServicePort)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to get upstreams of DNS service %q in namespace %q: %w", dnsServiceName, dnsServiceNamespace, err)
+	}
+
+	version, err := getCoreDNSVersion(dnsDeployment.Spec.Template.Spec)
 	if err != nil {
+		return nil, false, fmt.Errorf("unable to get CoreDNS version of deployment %q in namespace %q: %w", dnsDeployment.Name, dnsDeployment.Namespace, err)
+	}
+
+	if err = validateCoreDNSBlockOptions(version, opts); err != nil {
 		return nil, false, err
 	}
 
+	configMap, changed, err := c.patchCoreDNSConfig(ctx, dnsDeployment, version, dnsUpstreams, opts, false)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to compute coredns config: %w", err)
+	}
+
+	return configMap, changed, nil
+}
+
+// validateCoreDNSBlockOptions checks that the given options can be used to build the Traefik Mesh block of the given
+// CoreDNS version.
+func validateCoreDNSBlockOptions(version *goversion.Version, opts BlockOptions) error {
+	if opts.TLSServerName != "" && version.Core().LessThan(versionCoreDNS14) {
+		return fmt.Errorf("CoreDNS %q doesn't support forwarding over TLS", version)
+	}
+
+	for _, zone := range opts.ExtraZones {
+		if zone == "" || zone == meshDomain {
+			return fmt.Errorf("invalid extra zone %q", zone)
+		}
+	}
+
+	if opts.CacheTTL != nil {
+		if *opts.CacheTTL < 0 || *opts.CacheTTL%time.Second != 0 {
+			return fmt.Errorf("invalid cache TTL %q, it must be a non-negative whole number of seconds", *opts.CacheTTL)
+		}
+
+		if *opts.CacheTTL == 0 && opts.ServeStale > 0 {
+			return errors.New("serving stale entries requires the cache")
+		}
+	}
+
+	if opts.HealthLameduck < 0 {
+		return fmt.Errorf("invalid health lameduck duration %q, it must not be negative", opts.HealthLameduck)
+	}
+
+	if strings.ContainsAny(opts.ErrorsConsolidatePattern, "\"\n") {
+		return fmt.Errorf("invalid errors consolidate pattern %q", opts.ErrorsConsolidatePattern)
+	}
+
+	zones := append([]string{meshDomain}, opts.ExtraZones...)
+
+	for _, domain := range opts.ExceptDomains {
+		if strings.ContainsAny(domain, " \t\n{}") || getExceptZone(zones, domain) == "" {
+			return fmt.Errorf("invalid except domain %q, it must be a subdomain of %s", domain, strings.Join(zones, " or "))
+		}
+	}
+
+	return nil
+}
+
+// patchCoreDNSConfig adds the Traefik Mesh block to the CoreDNS configuration and returns the patched ConfigMap, which
+// is not updated, and whether it changed. When create is false, a missing coredns-custom ConfigMap is not created and
+// is considered empty.
+func (c *Client) patchCoreDNSConfig(ctx context.Context, deployment *appsv1.Deployment, version *goversion.Version, dnsUpstreams []string, opts BlockOptions, create bool) (*corev1.ConfigMap, bool, error) {
 	customConfigMap, err := c.getConfigMap(ctx, deployment, "coredns-custom")
 
 	// For AKS the CoreDNS config have to be added to the coredns-custom ConfigMap.
 	// See https://docs.microsoft.com/en-us/azure/aks/coredns-custom
 	if err == nil {
-		corefile, changed := addStubDomain(
-			customConfigMap.Data["traefik.mesh.server"],
-			blockHeader,
-			blockTrailer,
-			dnsServiceIP,
-			dnsServicePort,
-			version,
-		)
-
-		customConfigMap.Data["traefik.mesh.server"] = corefile
+		if opts.HealthLameduck > 0 {
+			return nil, false, errors.New("the health lameduck duration can't be set with the coredns-custom ConfigMap")
+		}
 
-		return customConfigMap, changed, nil
+		return patchCoreDNSCustomConfig(customConfigMap, version, dnsUpstreams, opts)
 	}
 
 	coreDNSConfigMap, err := c.getConfigMap(ctx, deployment, "coredns")
@@ -193,129 +518,404 @@ func (c *Client) patchCoreDNSConfig(ctx context.Context, deployment *appsv1.Depl
 		return nil, false, err
 	}
 
+	// The addon-manager reverts the changes made to the Corefile, the CoreDNS config have to be added to the
+	// coredns-custom ConfigMap instead, which gets created if its volume is optional.
+	if _, managed := coreDNSConfigMap.Labels[addonManagerModeLabel]; managed {
+		if opts.HealthLameduck > 0 {
+			return nil, false, fmt.Errorf("the health lameduck duration can't be set as CoreDNS ConfigMap %q is managed by the addon-manager", coreDNSConfigMap.Name)
+		}
+
+		if create {
+			customConfigMap, err = c.getOrCreateConfigMap(ctx, deployment, "coredns-custom")
+		} else {
+			customConfigMap, err = c.getOptionalConfigMap(ctx, deployment, "coredns-custom")
+		}
+
+		if err != nil {
+			return nil, false, fmt.Errorf("CoreDNS ConfigMap %q is managed by the addon-manager and the coredns-custom ConfigMap is not available: %w", coreDNSConfigMap.Name, err)
+		}
+
+		return patchCoreDNSCustomConfig(customConfigMap, version, dnsUpstreams, opts)
+	}
+
+	existingCorefile := coreDNSConfigMap.Data["Corefile"]
+
 	corefile, changed := addStubDomain(
-		coreDNSConfigMap.Data["Corefile"],
+		removeHealthLameduck(existingCorefile),
 		blockHeader,
 		blockTrailer,
-		dnsServiceIP,
-		dnsServicePort,
+		dnsUpstreams,
 		version,
+		opts,
 	)
 
+	if opts.HealthLameduck > 0 {
+		var found bool
+
+		corefile, found = addHealthLameduck(corefile, opts.HealthLameduck)
+		if !found {
+			c.providerLogger(CoreDNS).Warnf("CoreDNS ConfigMap %q has no health plugin, its lameduck duration won't be set", coreDNSConfigMap.Name)
+		}
+	}
+
+	// The health plugin is compared on its own, as the Traefik Mesh block doesn't contain it.
+	changed = changed || getStubDomain(existingCorefile, healthHeader, healthTrailer) != getStubDomain(corefile, healthHeader, healthTrailer)
+
 	coreDNSConfigMap.Data["Corefile"] = corefile
 
 	return coreDNSConfigMap, changed, nil
 }
 
-// ConfigureKubeDNS patches the KubeDNS configuration for Traefik Mesh.
+// patchCoreDNSCustomConfig adds the Traefik Mesh block to the given coredns-custom ConfigMap.
+func patchCoreDNSCustomConfig(customConfigMap *corev1.ConfigMap, version *goversion.Version, dnsUpstreams []string, opts BlockOptions) (*corev1.ConfigMap, bool, error) {
+	corefile, changed := addStubDomain(
+		customConfigMap.Data["traefik.mesh.server"],
+		blockHeader,
+		blockTrailer,
+		dnsUpstreams,
+		version,
+		opts,
+	)
+
+	customConfigMap.Data["traefik.mesh.server"] = corefile
+
+	return customConfigMap, changed, nil
+}
+
+// ConfigureKubeDNS patches the KubeDNS configuration for Traefik Mesh. The KubeDNS pods are restarted only when the
+// stub domain of Traefik Mesh changed, as some KubeDNS versions don't reload their stub domains.
 func (c *Client) ConfigureKubeDNS(ctx context.Context, dnsServiceNamespace, dnsServiceName string, dnsServicePort int32) error {
-	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "kube-dns", metav1.GetOptions{})
+	logger := c.providerLogger(KubeDNS)
+
+	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(c.namespace).Get(ctx, "kube-dns", metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 
-	dnsServiceIP, err := c.getServiceIP(ctx, dnsServiceNamespace, dnsServiceName)
+	dnsUpstreams, err := c.getServiceUpstreams(ctx, dnsServiceNamespace, dnsServiceName, dnsServicePort)
 	if err != nil {
-		return fmt.Errorf("unable to get ClusterIP of DNS service %q in namespace %q: %w", dnsServiceName, dnsServiceNamespace, err)
+		return fmt.Errorf("unable to get upstreams of DNS service %q in namespace %q: %w", dnsServiceName, dnsServiceNamespace, err)
 	}
 
-	c.logger.Debugf("ClusterIP for Service %q in namespace %q is %q", "coredns", metav1.NamespaceSystem, dnsServiceIP)
+	logger.Debugf("Upstreams for Service %q in namespace %q are %q", dnsServiceName, dnsServiceNamespace, dnsUpstreams)
 
-	if err := c.patchKubeDNSConfig(ctx, dnsDeployment, dnsServiceIP, dnsServicePort); err != nil {
+	changed, err := c.patchKubeDNSConfig(ctx, dnsDeployment, dnsUpstreams)
+	if err != nil {
 		return err
 	}
 
-	if err := c.restartPods(ctx, dnsDeployment); err != nil {
+	if !changed {
+		logger.Infof("KubeDNS ConfigMap of deployment %q in namespace %q has already been patched", dnsDeployment.Name, dnsDeployment.Namespace)
+
+		return nil
+	}
+
+	if err := c.restartPods(ctx, logger, dnsDeployment); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (c *Client) patchKubeDNSConfig(ctx context.Context, deployment *appsv1.Deployment, dnsServiceIP string, dnsServicePort int32) error {
+// patchKubeDNSConfig sets the Traefik Mesh stub domain in the KubeDNS ConfigMap. It returns false, without updating the
+// ConfigMap, when the stub domain already forwards to the given upstreams.
+func (c *Client) patchKubeDNSConfig(ctx context.Context, deployment *appsv1.Deployment, dnsUpstreams []string) (bool, error) {
 	configMap, err := c.getOrCreateConfigMap(ctx, deployment, "kube-dns")
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	stubDomains := make(map[string][]string)
 
 	if stubDomainsStr := configMap.Data["stubDomains"]; stubDomainsStr != "" {
 		if err = json.Unmarshal([]byte(stubDomainsStr), &stubDomains); err != nil {
-			return fmt.Errorf("unable to unmarshal stub domains: %w", err)
+			return false, fmt.Errorf("unable to unmarshal stub domains: %w", err)
 		}
 	}
 
+	if upstreams, exists := stubDomains[meshDomain]; exists && reflect.DeepEqual(upstreams, dnsUpstreams) {
+		return false, nil
+	}
+
 	// Add our stubDomain.
-	stubDomains["traefik.mesh"] = []string{fmt.Sprintf("%s:%d", dnsServiceIP, dnsServicePort)}
+	stubDomains[meshDomain] = dnsUpstreams
 
 	configMapData, err := json.Marshal(stubDomains)
 	if err != nil {
-		return fmt.Errorf("unable to marshal stub domains: %w", err)
+		return false, fmt.Errorf("unable to marshal stub domains: %w", err)
 	}
 
 	configMap.Data["stubDomains"] = string(configMapData)
 
-	if _, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
-		return err
+	if err := c.updateConfigMap(ctx, configMap, "stubDomains"); err != nil {
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
 
-// RestoreCoreDNS restores the CoreDNS configuration to pre-install state.
-func (c *Client) RestoreCoreDNS(ctx context.Context) error {
-	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+// RestoreCoreDNS restores the CoreDNS configuration to pre-install state. It returns false, without updating the
+// ConfigMap nor restarting the CoreDNS pods, when the configuration doesn't contain the Traefik Mesh block.
+func (c *Client) RestoreCoreDNS(ctx context.Context) (bool, error) {
+	logger := c.providerLogger(CoreDNS)
+
+	dnsDeployment, err := c.getCoreDNSDeployment(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	configMap, err := c.unpatchCoreDNSConfig(ctx, dnsDeployment)
+	configMap, changed, err := c.unpatchCoreDNSConfig(ctx, dnsDeployment)
 	if err != nil {
-		return fmt.Errorf("unable to unpatch coredns config: %w", err)
+		return false, fmt.Errorf("unable to unpatch coredns config: %w", err)
 	}
 
-	if _, err = c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
-		return err
+	if !changed {
+		logger.Infof("CoreDNS ConfigMap %q in namespace %q has already been restored", configMap.Name, configMap.Namespace)
+
+		return false, nil
 	}
 
-	if err := c.restartPods(ctx, dnsDeployment); err != nil {
-		return err
+	if err = c.updateConfigMap(ctx, configMap, getCoreDNSConfigKey(configMap)); err != nil {
+		return false, err
 	}
 
-	return nil
+	if err := c.restartPods(ctx, logger, dnsDeployment); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
-func (c *Client) unpatchCoreDNSConfig(ctx context.Context, deployment *appsv1.Deployment) (*corev1.ConfigMap, error) {
+func (c *Client) unpatchCoreDNSConfig(ctx context.Context, deployment *appsv1.Deployment) (*corev1.ConfigMap, bool, error) {
 	coreDNSConfigMap, err := c.getConfigMap(ctx, deployment, "coredns-custom")
 
 	// For AKS the CoreDNS config have to be removed from the coredns-custom ConfigMap.
 	// See https://docs.microsoft.com/en-us/azure/aks/coredns-custom
 	if err == nil {
+		_, changed := coreDNSConfigMap.Data["traefik.mesh.server"]
 		delete(coreDNSConfigMap.Data, "traefik.mesh.server")
 
-		return coreDNSConfigMap, nil
+		return coreDNSConfigMap, changed, nil
 	}
 
 	coreDNSConfigMap, err = c.getConfigMap(ctx, deployment, "coredns")
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	corefile := removeStubDomain(
-		coreDNSConfigMap.Data["Corefile"],
+		removeHealthLameduck(coreDNSConfigMap.Data["Corefile"]),
 		blockHeader,
 		blockTrailer,
 	)
 
+	changed := corefile != coreDNSConfigMap.Data["Corefile"]
 	coreDNSConfigMap.Data["Corefile"] = corefile
 
-	return coreDNSConfigMap, nil
+	return coreDNSConfigMap, changed, nil
+}
+
+// coreDNSSnapshot is a snapshot of the CoreDNS configuration.
+type coreDNSSnapshot struct {
+	// ConfigMap is the name of the snapshotted ConfigMap, the one patched for Traefik Mesh.
+	ConfigMap string            `json:"configMap"`
+	Data      map[string]string `json:"data"`
+	// Missing is true when the snapshotted ConfigMap didn't exist, in which case it is created by Traefik Mesh.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// isPatched returns true if the snapshotted configuration contains the Traefik Mesh block.
+func (s coreDNSSnapshot) isPatched() bool {
+	_, custom := s.Data["traefik.mesh.server"]
+
+	return custom || strings.Contains(s.Data["Corefile"], blockHeader)
+}
+
+// SnapshotCoreDNS captures the current content of the CoreDNS ConfigMap patched for Traefik Mesh.
+func (c *Client) SnapshotCoreDNS(ctx context.Context) ([]byte, error) {
+	snapshot, err := c.snapshotCoreDNS(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(snapshot)
+}
+
+func (c *Client) snapshotCoreDNS(ctx context.Context) (coreDNSSnapshot, error) {
+	dnsDeployment, err := c.getCoreDNSDeployment(ctx)
+	if err != nil {
+		return coreDNSSnapshot{}, err
+	}
+
+	// For AKS the CoreDNS config is patched in the coredns-custom ConfigMap.
+	configMap, err := c.getConfigMap(ctx, dnsDeployment, "coredns-custom")
+	if err != nil {
+		customMissing := kerrors.IsNotFound(err)
+
+		configMap, err = c.getConfigMap(ctx, dnsDeployment, "coredns")
+		if err != nil {
+			return coreDNSSnapshot{}, err
+		}
+
+		// When the Corefile is managed by the addon-manager, the CoreDNS config is patched in the coredns-custom
+		// ConfigMap, which gets created if it is missing. The snapshot records it, so that it is deleted on restore.
+		if _, managed := configMap.Labels[addonManagerModeLabel]; managed && customMissing {
+			return coreDNSSnapshot{
+				ConfigMap: "coredns-custom",
+				Missing:   true,
+			}, nil
+		}
+	}
+
+	return coreDNSSnapshot{
+		ConfigMap: configMap.Name,
+		Data:      configMap.Data,
+	}, nil
+}
+
+// RestoreCoreDNSFromSnapshot writes back the CoreDNS configuration captured by SnapshotCoreDNS and restarts the CoreDNS
+// pods. A coredns-custom ConfigMap missing from the snapshot is deleted instead. Unlike RestoreCoreDNS, it doesn't rely
+// on the Traefik Mesh block markers.
+func (c *Client) RestoreCoreDNSFromSnapshot(ctx context.Context, snapshot []byte) error {
+	logger := c.providerLogger(CoreDNS)
+
+	var snap coreDNSSnapshot
+	if err := json.Unmarshal(snapshot, &snap); err != nil {
+		return fmt.Errorf("unable to decode coredns config snapshot: %w", err)
+	}
+
+	if snap.ConfigMap == "" {
+		return errors.New("coredns config snapshot doesn't reference any ConfigMap")
+	}
+
+	dnsDeployment, err := c.getCoreDNSDeployment(ctx)
+	if err != nil {
+		return err
+	}
+
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).Get(ctx, snap.ConfigMap, metav1.GetOptions{})
+	if snap.Missing && kerrors.IsNotFound(err) {
+		logger.Infof("CoreDNS ConfigMap %q in namespace %q has already been restored", snap.ConfigMap, c.namespace)
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if snap.Missing {
+		err = c.deleteCreatedConfigMap(ctx, configMap)
+	} else {
+		configMap.Data = snap.Data
+		err = c.updateConfigMap(ctx, configMap, getCoreDNSConfigKey(configMap))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("CoreDNS ConfigMap %q in namespace %q has successfully been restored from snapshot", configMap.Name, configMap.Namespace)
+
+	return c.restartPods(ctx, logger, dnsDeployment)
+}
+
+// deleteCreatedConfigMap removes the Traefik Mesh block from the given ConfigMap created when CoreDNS was configured,
+// and deletes the ConfigMap unless other keys have been added to it since.
+func (c *Client) deleteCreatedConfigMap(ctx context.Context, configMap *corev1.ConfigMap) error {
+	key := getCoreDNSConfigKey(configMap)
+	delete(configMap.Data, key)
+
+	if len(configMap.Data) > 0 {
+		return c.updateConfigMap(ctx, configMap, key)
+	}
+
+	err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Delete(ctx, configMap.Name, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// LoadCoreDNSSnapshot returns the snapshot of the CoreDNS configuration stored in the given namespace when CoreDNS was
+// first configured. It returns ErrSnapshotNotFound if there is none.
+func (c *Client) LoadCoreDNSSnapshot(ctx context.Context, namespace string) ([]byte, error) {
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, coreDNSSnapshotConfigMapName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, ErrSnapshotNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, exists := configMap.Data[coreDNSSnapshotKey]
+	if !exists {
+		return nil, ErrSnapshotNotFound
+	}
+
+	return []byte(snapshot), nil
+}
+
+// DeleteCoreDNSSnapshot deletes the snapshot of the CoreDNS configuration stored in the given namespace, if any.
+func (c *Client) DeleteCoreDNSSnapshot(ctx context.Context, namespace string) error {
+	err := c.kubeClient.CoreV1().ConfigMaps(namespace).Delete(ctx, coreDNSSnapshotConfigMapName, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// storeCoreDNSSnapshot stores a snapshot of the current CoreDNS configuration in the given namespace, unless one is
+// already stored. No snapshot is stored if the configuration is already patched, as it wouldn't reflect the pre-install
+// state.
+func (c *Client) storeCoreDNSSnapshot(ctx context.Context, logger logrus.FieldLogger, namespace string) error {
+	_, err := c.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, coreDNSSnapshotConfigMapName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+
+	if !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	snapshot, err := c.snapshotCoreDNS(ctx)
+	if err != nil {
+		return err
+	}
+
+	if snapshot.isPatched() {
+		logger.Warn("CoreDNS configuration is already patched, no snapshot of the pre-install configuration is stored")
+
+		return nil
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      coreDNSSnapshotConfigMapName,
+			Namespace: namespace,
+			Labels:    map[string]string{k8s.LabelPartOf: k8s.AppName},
+		},
+		Data: map[string]string{coreDNSSnapshotKey: string(data)},
+	}
+
+	_, err = c.kubeClient.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
+
+	return err
 }
 
 // RestoreKubeDNS restores the KubeDNS configuration to pre-install state.
 func (c *Client) RestoreKubeDNS(ctx context.Context) error {
-	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "kube-dns", metav1.GetOptions{})
+	logger := c.providerLogger(KubeDNS)
+
+	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(c.namespace).Get(ctx, "kube-dns", metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
@@ -347,21 +947,125 @@ func (c *Client) RestoreKubeDNS(ctx context.Context) error {
 
 	configMap.Data["stubDomains"] = string(configMapData)
 
-	if _, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+	if err := c.updateConfigMap(ctx, configMap, "stubDomains"); err != nil {
 		return err
 	}
 
-	if err := c.restartPods(ctx, dnsDeployment); err != nil {
+	if err := c.restartPods(ctx, logger, dnsDeployment); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// DumpConfig returns the current configuration of the DNS provider deployed in the cluster. For CoreDNS, the Corefile
+// and the custom server blocks are returned as is, the Traefik Mesh block being delimited by its header and trailer. For
+// KubeDNS, the stub domains are listed, the Traefik Mesh one being delimited by the same header and trailer.
+func (c *Client) DumpConfig(ctx context.Context) (string, error) {
+	provider, err := c.CheckDNSProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch provider {
+	case CoreDNS:
+		return c.dumpCoreDNSConfig(ctx)
+	case KubeDNS:
+		return c.dumpKubeDNSConfig(ctx)
+	default:
+		return "", fmt.Errorf("unsupported DNS provider %q", provider)
+	}
+}
+
+func (c *Client) dumpCoreDNSConfig(ctx context.Context) (string, error) {
+	dnsDeployment, err := c.getCoreDNSDeployment(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	coreDNSConfigMap, err := c.getConfigMap(ctx, dnsDeployment, "coredns")
+	if err != nil {
+		return "", err
+	}
+
+	var dump strings.Builder
+
+	writeConfigMapKey(&dump, coreDNSConfigMap, "Corefile")
+
+	// For AKS the Traefik Mesh block lives in the coredns-custom ConfigMap.
+	customConfigMap, err := c.getConfigMap(ctx, dnsDeployment, "coredns-custom")
+	if err != nil {
+		return dump.String(), nil
+	}
+
+	keys := make([]string, 0, len(customConfigMap.Data))
+	for key := range customConfigMap.Data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		dump.WriteString("\n")
+		writeConfigMapKey(&dump, customConfigMap, key)
+	}
+
+	return dump.String(), nil
+}
+
+func (c *Client) dumpKubeDNSConfig(ctx context.Context) (string, error) {
+	dnsDeployment, err := c.kubeClient.AppsV1().Deployments(c.namespace).Get(ctx, "kube-dns", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	configMap, err := c.getConfigMap(ctx, dnsDeployment, "kube-dns")
+	if err != nil {
+		return "", err
+	}
+
+	stubDomains := make(map[string][]string)
+
+	if stubDomainsStr := configMap.Data["stubDomains"]; stubDomainsStr != "" {
+		if err = json.Unmarshal([]byte(stubDomainsStr), &stubDomains); err != nil {
+			return "", fmt.Errorf("unable to unmarshal stub domains: %w", err)
+		}
+	}
+
+	domains := make([]string, 0, len(stubDomains))
+	for domain := range stubDomains {
+		domains = append(domains, domain)
+	}
+
+	sort.Strings(domains)
+
+	var dump strings.Builder
+
+	fmt.Fprintf(&dump, "# ConfigMap %s/%s, key stubDomains\n", configMap.Namespace, configMap.Name)
+
+	for _, domain := range domains {
+		stubDomain := fmt.Sprintf("%s: %s\n", domain, strings.Join(stubDomains[domain], ", "))
+
+		if domain == "traefik.mesh" {
+			stubDomain = blockHeader + "\n" + stubDomain + blockTrailer + "\n"
+		}
+
+		dump.WriteString(stubDomain)
+	}
+
+	return dump.String(), nil
+}
+
+// writeConfigMapKey writes the value of the given ConfigMap key, preceded by a comment referencing it.
+func writeConfigMapKey(dump *strings.Builder, configMap *corev1.ConfigMap, key string) {
+	fmt.Fprintf(dump, "# ConfigMap %s/%s, key %s\n", configMap.Namespace, configMap.Name, key)
+	dump.WriteString(configMap.Data[key])
+}
+
 // getOrCreateConfigMap parses the deployment and returns the ConfigMap with the given name. This method will create the
 // corresponding ConfigMap if the associated volume is marked as optional and the ConfigMap is not found.
 func (c *Client) getOrCreateConfigMap(ctx context.Context, deployment *appsv1.Deployment, name string) (*corev1.ConfigMap, error) {
-	volume, err := getConfigMapVolume(deployment, name)
+	volume, err := getConfigMapVolume(deployment.Spec.Template.Spec, name)
 	if err != nil {
 		return nil, err
 	}
@@ -369,6 +1073,10 @@ func (c *Client) getOrCreateConfigMap(ctx context.Context, deployment *appsv1.De
 	configMap, err := c.kubeClient.CoreV1().ConfigMaps(deployment.Namespace).Get(ctx, volume.Name, metav1.GetOptions{})
 
 	if kerrors.IsNotFound(err) && volume.Optional != nil && *volume.Optional {
+		if c.noCreate {
+			return nil, fmt.Errorf("ConfigMap %q in namespace %q doesn't exist and its creation is disabled", volume.Name, deployment.Namespace)
+		}
+
 		configMap = &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      name,
@@ -390,9 +1098,36 @@ func (c *Client) getOrCreateConfigMap(ctx context.Context, deployment *appsv1.De
 	return configMap, err
 }
 
+// getOptionalConfigMap parses the deployment and returns the ConfigMap with the given name. An empty ConfigMap is
+// returned if the associated volume is marked as optional and the ConfigMap is not found.
+func (c *Client) getOptionalConfigMap(ctx context.Context, deployment *appsv1.Deployment, name string) (*corev1.ConfigMap, error) {
+	volume, err := getConfigMapVolume(deployment.Spec.Template.Spec, name)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(deployment.Namespace).Get(ctx, volume.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) && volume.Optional != nil && *volume.Optional {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: deployment.Namespace,
+			},
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+
+	return configMap, nil
+}
+
 // getConfigMap parses the deployment and returns the ConfigMap with the given name.
 func (c *Client) getConfigMap(ctx context.Context, deployment *appsv1.Deployment, name string) (*corev1.ConfigMap, error) {
-	volume, err := getConfigMapVolume(deployment, name)
+	volume, err := getConfigMapVolume(deployment.Spec.Template.Spec, name)
 	if err != nil {
 		return nil, err
 	}
@@ -410,25 +1145,78 @@ func (c *Client) getConfigMap(ctx context.Context, deployment *appsv1.Deployment
 }
 
 // restartPods restarts the pods in a given deployment.
-func (c *Client) restartPods(ctx context.Context, deployment *appsv1.Deployment) error {
-	c.logger.Infof("Restarting %q pods", deployment.Name)
+func (c *Client) restartPods(ctx context.Context, logger logrus.FieldLogger, deployment *appsv1.Deployment) error {
+	logger.Infof("Restarting %q pods", deployment.Name)
 
 	annotations := deployment.Spec.Template.Annotations
 	if len(annotations) == 0 {
 		annotations = make(map[string]string)
 	}
 
-	annotations["traefik-mesh-hash"] = uuid.New().String()
+	annotations[restartHashAnnotation] = uuid.New().String()
 	deployment.Spec.Template.Annotations = annotations
 
+	if c.apply {
+		deploymentApply := appsv1apply.Deployment(deployment.Name, deployment.Namespace).
+			WithSpec(appsv1apply.DeploymentSpec().
+				WithTemplate(corev1apply.PodTemplateSpec().
+					WithAnnotations(map[string]string{restartHashAnnotation: annotations[restartHashAnnotation]})))
+
+		_, err := c.kubeClient.AppsV1().Deployments(deployment.Namespace).Apply(ctx, deploymentApply, metav1.ApplyOptions{FieldManager: fieldManager})
+		if kerrors.IsConflict(err) {
+			return fmt.Errorf("unable to apply the %s annotation of deployment %q in namespace %q, it is managed by another field manager: %w", restartHashAnnotation, deployment.Name, deployment.Namespace, err)
+		}
+
+		return err
+	}
+
 	_, err := c.kubeClient.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
 
 	return err
 }
 
-// getServiceIP returns the ClusterIP of the given service name in the given namespace.
-func (c *Client) getServiceIP(ctx context.Context, namespace, name string) (string, error) {
-	var clusterIP string
+// updateConfigMap updates the given ConfigMap of the DNS provider, in which only the given key has been modified. With
+// server-side apply, only this key is applied, and it is removed from the ConfigMap when it is missing from the given
+// one, provided no other field manager owns it.
+func (c *Client) updateConfigMap(ctx context.Context, configMap *corev1.ConfigMap, key string) error {
+	if !c.apply {
+		_, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+
+		return err
+	}
+
+	configMapApply := corev1apply.ConfigMap(configMap.Name, configMap.Namespace)
+	if value, exists := configMap.Data[key]; exists {
+		configMapApply = configMapApply.WithData(map[string]string{key: value})
+	}
+
+	_, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Apply(ctx, configMapApply, metav1.ApplyOptions{FieldManager: fieldManager})
+	if kerrors.IsConflict(err) {
+		return fmt.Errorf("unable to apply key %q of ConfigMap %q in namespace %q, it is managed by another field manager: %w", key, configMap.Name, configMap.Namespace, err)
+	}
+
+	return err
+}
+
+// getCoreDNSConfigKey returns the key of the given CoreDNS ConfigMap holding the Traefik Mesh block.
+func getCoreDNSConfigKey(configMap *corev1.ConfigMap) string {
+	if configMap.Name == "coredns-custom" {
+		return "traefik.mesh.server"
+	}
+
+	return "Corefile"
+}
+
+// providerLogger returns a logger carrying the name of the given DNS provider.
+func (c *Client) providerLogger(provider Provider) logrus.FieldLogger {
+	return c.logger.WithField(logfield.Provider, provider.String())
+}
+
+// getServiceUpstreams returns the addresses the DNS queries are forwarded to for the given port of the given service
+// name in the given namespace. This is the ClusterIP of the service, or the addresses of its ready endpoints for
+// headless services, so that the queries are forwarded to all their replicas.
+func (c *Client) getServiceUpstreams(ctx context.Context, namespace, name string, port int32) ([]string, error) {
+	var upstreams []string
 
 	operation := func() error {
 		service, err := c.kubeClient.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
@@ -440,21 +1228,77 @@ func (c *Client) getServiceIP(ctx context.Context, namespace, name string) (stri
 			return fmt.Errorf("service %q in namespace %q has no ClusterIP", name, namespace)
 		}
 
-		clusterIP = service.Spec.ClusterIP
+		if service.Spec.ClusterIP != corev1.ClusterIPNone {
+			upstreams = []string{net.JoinHostPort(service.Spec.ClusterIP, strconv.Itoa(int(port)))}
 
-		return nil
+			return nil
+		}
+
+		upstreams, err = c.getHeadlessServiceUpstreams(ctx, service, port)
+
+		return err
 	}
 
 	if err := backoff.Retry(safe.OperationWithRecover(operation), backoff.WithMaxRetries(backoff.NewConstantBackOff(10*time.Second), 12)); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return upstreams, nil
+}
+
+// getHeadlessServiceUpstreams returns the sorted addresses of the ready endpoints of the given port of the given
+// headless service.
+func (c *Client) getHeadlessServiceUpstreams(ctx context.Context, service *corev1.Service, port int32) ([]string, error) {
+	var (
+		portName string
+		found    bool
+	)
+
+	for _, svcPort := range service.Spec.Ports {
+		if svcPort.Port == port {
+			portName = svcPort.Name
+			found = true
+
+			break
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("headless service %q in namespace %q has no port %d", service.Name, service.Namespace, port)
+	}
+
+	endpoints, err := c.kubeClient.CoreV1().Endpoints(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get endpoints of headless service %q in namespace %q: %w", service.Name, service.Namespace, err)
+	}
+
+	var upstreams []string
+
+	for _, subset := range endpoints.Subsets {
+		for _, endpointPort := range subset.Ports {
+			if endpointPort.Name != portName {
+				continue
+			}
+
+			for _, address := range subset.Addresses {
+				upstreams = append(upstreams, net.JoinHostPort(address.IP, strconv.Itoa(int(endpointPort.Port))))
+			}
+		}
+	}
+
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("headless service %q in namespace %q has no ready endpoint for port %d", service.Name, service.Namespace, port)
 	}
 
-	return clusterIP, nil
+	// Sort the upstreams to generate the same configuration whatever the order of the endpoints.
+	sort.Strings(upstreams)
+
+	return upstreams, nil
 }
 
 // getConfigMapVolume returns the ConfigMapVolumeSource corresponding to the ConfigMap with the given name.
-func getConfigMapVolume(deployment *appsv1.Deployment, name string) (*corev1.ConfigMapVolumeSource, error) {
-	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+func getConfigMapVolume(podSpec corev1.PodSpec, name string) (*corev1.ConfigMapVolumeSource, error) {
+	for _, volume := range podSpec.Volumes {
 		if volume.ConfigMap == nil {
 			continue
 		}
@@ -480,34 +1324,137 @@ func getStubDomain(config, blockHeader, blockTrailer string) string {
 	return config[start : end+len(blockTrailer)]
 }
 
-func addStubDomain(config, blockHeader, blockTrailer, dnsServiceIP string, dnsServicePort int32, coreDNSVersion *goversion.Version) (string, bool) {
+func addStubDomain(config, blockHeader, blockTrailer string, dnsUpstreams []string, coreDNSVersion *goversion.Version, opts BlockOptions) (string, bool) {
 	existingStubDomain := getStubDomain(config, blockHeader, blockTrailer)
 	if existingStubDomain != "" {
 		config = removeStubDomain(config, blockHeader, blockTrailer)
 	}
 
-	stubDomainFormat := `%[4]s
-traefik.mesh:53 {
-    errors
-    cache 30
-    %[1]s . %[2]s:%[3]d
+	serverBlockFormat := `%[1]s:53 {
+    %[4]s
+%[5]s%[2]s    %[3]s
 }
-%[5]s`
+`
+
+	// The ready plugin is available since CoreDNS 1.5. It listens on the address of the ready plugin of the main zone,
+	// as the plugins of all the server blocks enabling it on the same address report to a single readiness endpoint.
+	var ready string
+	if opts.Ready && !coreDNSVersion.Core().LessThan(versionCoreDNS15) {
+		ready = "    ready\n"
+		if match := readyPluginRegexp.FindStringSubmatch(config); match != nil && match[1] != "" {
+			ready = "    ready " + strings.TrimSpace(match[1]) + "\n"
+		}
+	}
 
-	forward := "forward"
-	if coreDNSVersion.LessThan(versionCoreDNS14) {
-		forward = "proxy"
+	upstream := strings.Join(dnsUpstreams, " ")
+	if opts.TLSServerName != "" {
+		upstream = "tls://" + strings.Join(dnsUpstreams, " tls://")
 	}
 
-	stubDomain := fmt.Sprintf(stubDomainFormat,
-		forward,
-		dnsServiceIP,
-		dnsServicePort,
-		blockHeader,
-		blockTrailer,
-	)
+	cacheTTL := 30 * time.Second
+	if opts.CacheTTL != nil {
+		cacheTTL = *opts.CacheTTL
+	}
+
+	// The serve_stale option of the cache plugin is available since CoreDNS 1.7.
+	var cache string
+	if cacheTTL > 0 {
+		cache = fmt.Sprintf("    cache %d\n", int(cacheTTL.Seconds()))
+		if opts.ServeStale > 0 && !coreDNSVersion.Core().LessThan(versionCoreDNS17) {
+			cache = fmt.Sprintf("    cache %d {\n        serve_stale %s\n    }\n", int(cacheTTL.Seconds()), formatDuration(opts.ServeStale))
+		}
+	}
+
+	// The consolidate option of the errors plugin is available since CoreDNS 1.6.
+	errorsPlugin := "errors"
+	if opts.ErrorsConsolidate > 0 && !coreDNSVersion.Core().LessThan(versionCoreDNS16) {
+		pattern := opts.ErrorsConsolidatePattern
+		if pattern == "" {
+			pattern = ".*"
+		}
+
+		errorsPlugin = fmt.Sprintf("errors {\n        consolidate %s \"%s\"\n    }", formatDuration(opts.ErrorsConsolidate), pattern)
+	}
+
+	var stubDomain strings.Builder
 
-	return config + "\n" + stubDomain + "\n", existingStubDomain != stubDomain
+	stubDomain.WriteString(blockHeader + "\n")
+	stubDomain.WriteString(fmt.Sprintf(serverBlockFormat, meshDomain, cache, buildForward(coreDNSVersion, meshDomain, upstream, opts), errorsPlugin, ready))
+
+	// The extra zones are served by their own server blocks, which are enclosed in the Traefik Mesh block to be
+	// removed along with it. The ready plugin is only added once, to the mesh server block.
+	for _, zone := range opts.ExtraZones {
+		stubDomain.WriteString(fmt.Sprintf(serverBlockFormat, zone, cache, buildForward(coreDNSVersion, zone, upstream, opts), errorsPlugin, ""))
+	}
+
+	stubDomain.WriteString(blockTrailer)
+
+	// The block is appended after the server blocks of the configuration rather than inserted in one of them, so that
+	// the plugins of the main zone, like its ready plugin, are left unchanged. The whole block is compared rather than
+	// its presence, so that the forward targets are updated when the Traefik Mesh DNS service is recreated with another
+	// ClusterIP.
+	return config + "\n" + stubDomain.String() + "\n", existingStubDomain != stubDomain.String()
+}
+
+// buildForward builds the directive of the server block of the given zone forwarding the queries to the given
+// upstream, along with the domains of the zone which must not be forwarded.
+func buildForward(coreDNSVersion *goversion.Version, zone, upstream string, opts BlockOptions) string {
+	// The forward plugin replaces the proxy plugin since CoreDNS 1.4, both of them support the except option.
+	plugin := "forward"
+	if coreDNSVersion.Core().LessThan(versionCoreDNS14) {
+		plugin = "proxy"
+	}
+
+	var except []string
+
+	for _, domain := range opts.ExceptDomains {
+		if getExceptZone([]string{zone}, domain) != "" {
+			except = append(except, domain)
+		}
+	}
+
+	var options []string
+
+	if len(except) > 0 {
+		options = append(options, "except "+strings.Join(except, " "))
+	}
+
+	if opts.TLSServerName != "" {
+		options = append(options, "tls_servername "+opts.TLSServerName)
+	}
+
+	if len(options) == 0 {
+		return fmt.Sprintf("%s . %s", plugin, upstream)
+	}
+
+	return fmt.Sprintf("%s . %s {\n        %s\n    }", plugin, upstream, strings.Join(options, "\n        "))
+}
+
+// getExceptZone returns the zone, among the given ones, the given domain is a subdomain of, or an empty string if
+// there is none.
+func getExceptZone(zones []string, domain string) string {
+	for _, zone := range zones {
+		if strings.HasSuffix(domain, "."+zone) && len(domain) > len(zone)+1 {
+			return zone
+		}
+	}
+
+	return ""
+}
+
+// formatDuration formats the given duration without its zero trailing units, e.g. 1h instead of 1h0m0s.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+
+	return s
 }
 
 func removeStubDomain(config, blockHeader, blockTrailer string) string {
@@ -530,16 +1477,90 @@ func removeStubDomain(config, blockHeader, blockTrailer string) string {
 	return preData + postData
 }
 
-func getCoreDNSVersion(deployment *appsv1.Deployment) (*goversion.Version, error) {
-	for _, container := range deployment.Spec.Template.Spec.Containers {
-		if container.Name != "coredns" {
-			continue
+// addHealthLameduck sets the lameduck duration of the first health plugin of the given Corefile, and returns whether
+// there is one. The plugin is enclosed in the health header and trailer, along with its original version commented
+// out.
+func addHealthLameduck(corefile string, lameduck time.Duration) (string, bool) {
+	loc := healthPluginRegexp.FindStringSubmatchIndex(corefile)
+	if loc == nil {
+		return corefile, false
+	}
+
+	indent := corefile[loc[2]:loc[3]]
+
+	var address string
+	if loc[4] != -1 {
+		address = corefile[loc[4]:loc[5]]
+	}
+
+	var health strings.Builder
+
+	health.WriteString(indent + healthHeader + "\n")
+
+	for _, line := range strings.Split(corefile[loc[0]:loc[1]], "\n") {
+		health.WriteString(indent + "# " + strings.TrimPrefix(line, indent) + "\n")
+	}
+
+	health.WriteString(fmt.Sprintf("%[1]shealth%[2]s {\n%[1]s    lameduck %[3]s\n%[1]s}\n", indent, address, formatDuration(lameduck)))
+	health.WriteString(indent + healthTrailer)
+
+	return corefile[:loc[0]] + health.String() + corefile[loc[1]:], true
+}
+
+// removeHealthLameduck restores the health plugin of the given Corefile patched by addHealthLameduck.
+func removeHealthLameduck(corefile string) string {
+	start := strings.Index(corefile, healthHeader)
+	end := strings.Index(corefile, healthTrailer)
+
+	if start == -1 || end < start {
+		return corefile
+	}
+
+	lineStart := strings.LastIndex(corefile[:start], "\n") + 1
+	indent := corefile[lineStart:start]
+
+	var original []string
+
+	for _, line := range strings.Split(corefile[start:end], "\n") {
+		if strings.HasPrefix(line, indent+"# ") {
+			original = append(original, indent+strings.TrimPrefix(line, indent+"# "))
 		}
+	}
+
+	return corefile[:lineStart] + strings.Join(original, "\n") + corefile[end+len(healthTrailer):]
+}
+
+// getCoreDNSVersion returns the CoreDNS version of the given pod spec, read from the image tag of the coredns
+// container or, if there is none, of the first container running a CoreDNS image.
+func getCoreDNSVersion(podSpec corev1.PodSpec) (*goversion.Version, error) {
+	container, ok := findCoreDNSContainer(podSpec)
+	if !ok {
+		return nil, errors.New("unable to find CoreDNS container")
+	}
 
-		parts := strings.Split(container.Image, ":")
+	parts := strings.Split(container.Image, ":")
 
-		return goversion.NewVersion(parts[len(parts)-1])
+	return goversion.NewVersion(parts[len(parts)-1])
+}
+
+func findCoreDNSContainer(podSpec corev1.PodSpec) (corev1.Container, bool) {
+	for _, container := range podSpec.Containers {
+		if container.Name == "coredns" {
+			return container, true
+		}
+	}
+
+	for _, container := range podSpec.Containers {
+		// Strip the tag and the registry path, e.g. "registry:5000/coredns/coredns-amd64:1.6.9" is "coredns-amd64".
+		image := strings.Split(container.Image, "@")[0]
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			image = image[:i]
+		}
+
+		if strings.HasPrefix(image[strings.LastIndex(image, "/")+1:], "coredns") {
+			return container, true
+		}
 	}
 
-	return nil, fmt.Errorf("unable to get CoreDNS container in deployment %q in namespace %q", deployment.Name, deployment.Namespace)
+	return corev1.Container{}, false
 }
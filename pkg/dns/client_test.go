@@ -2,15 +2,27 @@ package dns
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	goversion "github.com/hashicorp/go-version"
 	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/traefik/mesh/v2/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
 )
 
 func TestCheckDNSProvider(t *testing.T) {
@@ -78,74 +90,1096 @@ func TestCheckDNSProvider(t *testing.T) {
 	}
 }
 
+func TestCheckDNSProvider_NonStandardCoreDNS(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mockFile string
+	}{
+		{
+			desc:     "CoreDNS deployment with an unusual ConfigMap",
+			mockFile: "checkdnsprovider_coredns_unusual_configmap.yaml",
+		},
+		{
+			desc:     "CoreDNS daemonset",
+			mockFile: "checkdnsprovider_coredns_daemonset.yaml",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			logger, hook := logrustest.NewNullLogger()
+			logger.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(logger, k8sClient.KubernetesClient())
+
+			provider, err := client.CheckDNSProvider(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, CoreDNS, provider)
+
+			// The configuration may need overrides, which is reported with a warning.
+			var warnings int
+
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == logrus.WarnLevel {
+					warnings++
+				}
+			}
+
+			assert.Equal(t, 1, warnings)
+		})
+	}
+}
+
+func TestCheckDNSProvider_DaemonSetsForbidden(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient, ok := k8s.NewClientMock("checkdnsprovider_kubedns.yaml").KubernetesClient().(*fakekubeclient.Clientset)
+	require.True(t, ok)
+
+	kubeClient.PrependReactor("get", "daemonsets", func(action ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kerrors.NewForbidden(action.GetResource().GroupResource(), "coredns", nil)
+	})
+
+	logger := logrus.New()
+
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	client := NewClient(logger, kubeClient)
+
+	provider, err := client.CheckDNSProvider(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, KubeDNS, provider)
+}
+
+func TestConfigureCoreDNS_DaemonSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8s.NewClientMock("checkdnsprovider_coredns_daemonset.yaml")
+
+	logger := logrus.New()
+
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	client := NewClient(logger, k8sClient.KubernetesClient())
+
+	provider, err := client.CheckDNSProvider(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CoreDNS, provider)
+
+	err = client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+	assert.ErrorIs(t, err, ErrCoreDNSDaemonSet)
+
+	_, err = client.RestoreCoreDNS(ctx)
+	assert.ErrorIs(t, err, ErrCoreDNSDaemonSet)
+}
+
 func TestConfigureCoreDNS(t *testing.T) {
 	tests := []struct {
-		desc        string
-		mockFile    string
-		expCorefile string
-		expCustoms  map[string]string
-		expErr      bool
-		expRestart  bool
+		desc        string
+		mockFile    string
+		namespace   string
+		opts        BlockOptions
+		expCorefile string
+		expCustoms  map[string]string
+		expErr      bool
+		expRestart  bool
+	}{
+		{
+			desc:        "First time config of CoreDNS",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS in a custom namespace",
+			mockFile:    "configurecoredns_custom_namespace.yaml",
+			namespace:   "dns-system",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config forwarding to a stale IP",
+			mockFile:    "configurecoredns_stale_upstream.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS with TLS upstream",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . tls://10.10.10.10:53 {\n        tls_servername dns.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config with TLS upstream",
+			mockFile:    "configurecoredns_tls_already_patched.yaml",
+			opts:        BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . tls://10.10.10.10:53 {\n        tls_servername dns.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  false,
+		},
+		{
+			desc:        "Already patched CoreDNS config without TLS upstream",
+			mockFile:    "configurecoredns_already_patched.yaml",
+			opts:        BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . tls://10.10.10.10:53 {\n        tls_servername dns.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config with TLS upstream disabled",
+			mockFile:    "configurecoredns_tls_already_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config",
+			mockFile:    "configurecoredns_already_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  false,
+		},
+		{
+			desc:        "First time config of CoreDNS with serve stale",
+			mockFile:    "configurecoredns_1_7_not_patched.yaml",
+			opts:        BlockOptions{ServeStale: time.Hour},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30 {\n        serve_stale 1h\n    }\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config with serve stale",
+			mockFile:    "configurecoredns_1_7_serve_stale_already_patched.yaml",
+			opts:        BlockOptions{ServeStale: time.Hour},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30 {\n        serve_stale 1h\n    }\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  false,
+		},
+		{
+			desc:        "Already patched CoreDNS config with serve stale disabled",
+			mockFile:    "configurecoredns_1_7_serve_stale_already_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Config of CoreDNS 1.6 with serve stale",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{ServeStale: time.Hour},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS with a cache TTL",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{CacheTTL: durationPtr(5 * time.Second)},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 5\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS without cache",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{CacheTTL: durationPtr(0)},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config without cache",
+			mockFile:    "configurecoredns_no_cache_already_patched.yaml",
+			opts:        BlockOptions{CacheTTL: durationPtr(0)},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  false,
+		},
+		{
+			desc:     "Serve stale without cache",
+			mockFile: "configurecoredns_1_7_not_patched.yaml",
+			opts:     BlockOptions{CacheTTL: durationPtr(0), ServeStale: time.Hour},
+			expErr:   true,
+		},
+		{
+			desc:     "Cache TTL which is not a whole number of seconds",
+			mockFile: "configurecoredns_not_patched.yaml",
+			opts:     BlockOptions{CacheTTL: durationPtr(1500 * time.Millisecond)},
+			expErr:   true,
+		},
+		{
+			desc:        "First time config of CoreDNS with an extra zone",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{ExtraZones: []string{"corp.internal"}},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\ncorp.internal:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:     "Extra zone conflicting with the mesh domain",
+			mockFile: "configurecoredns_not_patched.yaml",
+			opts:     BlockOptions{ExtraZones: []string{"traefik.mesh"}},
+			expErr:   true,
+		},
+		{
+			desc:        "First time config of CoreDNS with except domains",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{ExtraZones: []string{"corp.internal"}, ExceptDomains: []string{"internal.traefik.mesh", "legacy.traefik.mesh", "db.corp.internal"}},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53 {\n        except internal.traefik.mesh legacy.traefik.mesh\n    }\n}\ncorp.internal:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53 {\n        except db.corp.internal\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS with except domains and TLS upstream",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{TLSServerName: "dns.traefik.mesh", ExceptDomains: []string{"internal.traefik.mesh"}},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . tls://10.10.10.10:53 {\n        except internal.traefik.mesh\n        tls_servername dns.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Config of CoreDNS 1.3 with except domains",
+			mockFile:    "configurecoredns_1_3.yaml",
+			opts:        BlockOptions{ExceptDomains: []string{"internal.traefik.mesh"}},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    proxy . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    proxy . 10.10.10.10:53 {\n        except internal.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config with except domains",
+			mockFile:    "configurecoredns_except_already_patched.yaml",
+			opts:        BlockOptions{ExceptDomains: []string{"internal.traefik.mesh"}},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53 {\n        except internal.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  false,
+		},
+		{
+			desc:        "Already patched CoreDNS config with other except domains",
+			mockFile:    "configurecoredns_except_already_patched.yaml",
+			opts:        BlockOptions{ExceptDomains: []string{"legacy.traefik.mesh"}},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53 {\n        except legacy.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Already patched CoreDNS config with except domains disabled",
+			mockFile:    "configurecoredns_except_already_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:     "Except domain outside of the mesh zones",
+			mockFile: "configurecoredns_not_patched.yaml",
+			opts:     BlockOptions{ExceptDomains: []string{"example.com"}},
+			expErr:   true,
+		},
+		{
+			desc:     "Except domain matching the mesh domain",
+			mockFile: "configurecoredns_not_patched.yaml",
+			opts:     BlockOptions{ExceptDomains: []string{"traefik.mesh"}},
+			expErr:   true,
+		},
+		{
+			desc:        "First time config of CoreDNS with consolidated errors",
+			mockFile:    "configurecoredns_1_7_not_patched.yaml",
+			opts:        BlockOptions{ErrorsConsolidate: 5 * time.Minute, ErrorsConsolidatePattern: ".* error"},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors {\n        consolidate 5m \".* error\"\n    }\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Config of CoreDNS 1.3 with consolidated errors",
+			mockFile:    "configurecoredns_1_3.yaml",
+			opts:        BlockOptions{ErrorsConsolidate: 5 * time.Minute},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    proxy . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    proxy . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:     "Invalid errors consolidate pattern",
+			mockFile: "configurecoredns_1_7_not_patched.yaml",
+			opts:     BlockOptions{ErrorsConsolidate: 5 * time.Minute, ErrorsConsolidatePattern: `"`},
+			expErr:   true,
+		},
+		{
+			desc:       "Missing Corefile configmap",
+			mockFile:   "configurecoredns_missing_configmap.yaml",
+			expErr:     true,
+			expRestart: false,
+		},
+		{
+			desc:        "First time config of CoreDNS custom",
+			mockFile:    "configurecoredns_custom_not_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n",
+			expCustoms: map[string]string{
+				"traefik.mesh.server": "\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			},
+			expRestart: true,
+		},
+		{
+			desc:        "Already patched CoreDNS custom config",
+			mockFile:    "configurecoredns_custom_already_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n",
+			expCustoms: map[string]string{
+				"traefik.mesh.server": "#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			},
+			expRestart: false,
+		},
+		{
+			desc:        "First time config of CoreDNS managed by the addon-manager",
+			mockFile:    "configurecoredns_addon_manager.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n",
+			expCustoms: map[string]string{
+				"traefik.mesh.server": "\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			},
+			expRestart: true,
+		},
+		{
+			desc:       "CoreDNS managed by the addon-manager without custom ConfigMap",
+			mockFile:   "configurecoredns_addon_manager_no_custom.yaml",
+			expErr:     true,
+			expRestart: false,
+		},
+		{
+			desc:        "First time config of CoreDNS with a headless DNS service",
+			mockFile:    "configurecoredns_headless.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.1:5353 10.10.10.2:5353 10.10.10.3:5353\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS with a headless DNS service and TLS upstream",
+			mockFile:    "configurecoredns_headless.yaml",
+			opts:        BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . tls://10.10.10.1:5353 tls://10.10.10.2:5353 tls://10.10.10.3:5353 {\n        tls_servername dns.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Config of CoreDNS 1.3",
+			mockFile:    "configurecoredns_1_3.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    proxy . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    proxy . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:     "Config of CoreDNS 1.3 with TLS upstream",
+			mockFile: "configurecoredns_1_3.yaml",
+			opts:     BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expErr:   true,
+		},
+		{
+			desc:        "Config of CoreDNS 1.4 pre-release with TLS upstream",
+			mockFile:    "configurecoredns_1_4_prerelease.yaml",
+			opts:        BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . tls://10.10.10.10:53 {\n        tls_servername dns.traefik.mesh\n    }\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS with ready",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{Ready: true},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    ready\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "Config of CoreDNS 1.3 with ready",
+			mockFile:    "configurecoredns_1_3.yaml",
+			opts:        BlockOptions{Ready: true},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    proxy . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    proxy . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:        "First time config of CoreDNS with health lameduck",
+			mockFile:    "configurecoredns_not_patched.yaml",
+			opts:        BlockOptions{HealthLameduck: 10 * time.Second},
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    #### Begin Traefik Mesh Health\n    # health {\n    #     lameduck 5s\n    # }\n    health {\n        lameduck 10s\n    }\n    #### End Traefik Mesh Health\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:     "Negative health lameduck",
+			mockFile: "configurecoredns_not_patched.yaml",
+			opts:     BlockOptions{HealthLameduck: -time.Second},
+			expErr:   true,
+		},
+		{
+			desc:     "Health lameduck with the coredns-custom ConfigMap",
+			mockFile: "configurecoredns_custom_not_patched.yaml",
+			opts:     BlockOptions{HealthLameduck: 10 * time.Second},
+			expErr:   true,
+		},
+		{
+			desc:        "CoreDNS 1.4 already patched for an older version of CoreDNS",
+			mockFile:    "configurecoredns_1_4_already_patched.yaml",
+			expErr:      false,
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+			expRestart:  true,
+		},
+		{
+			desc:       "Missing CoreDNS deployment",
+			mockFile:   "configurecoredns_missing_deployment.yaml",
+			expErr:     true,
+			expRestart: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			logger := logrus.New()
+
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			namespace := metav1.NamespaceSystem
+
+			var opts []ClientOption
+			if test.namespace != "" {
+				namespace = test.namespace
+				opts = append(opts, SystemNamespace(namespace))
+			}
+
+			client := NewClient(logger, k8sClient.KubernetesClient(), opts...)
+
+			err := client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, test.opts)
+			if test.expErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			cfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(namespace).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expCorefile, cfgMap.Data["Corefile"])
+
+			if len(test.expCustoms) > 0 {
+				var customCfgMap *corev1.ConfigMap
+
+				customCfgMap, err = k8sClient.KubernetesClient().CoreV1().ConfigMaps(namespace).Get(ctx, "coredns-custom", metav1.GetOptions{})
+				require.NoError(t, err)
+
+				for key, value := range test.expCustoms {
+					assert.Equal(t, value, customCfgMap.Data[key])
+				}
+			}
+
+			coreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(namespace).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			restarted := coreDNSDeployment.Spec.Template.Annotations["traefik-mesh-hash"] != ""
+			assert.Equal(t, test.expRestart, restarted)
+		})
+	}
+}
+
+func TestConfigureCoreDNS_RestoreFromSnapshot(t *testing.T) {
+	tests := []struct {
+		desc      string
+		mockFile  string
+		configMap string
+		key       string
+	}{
+		{
+			desc:      "CoreDNS config",
+			mockFile:  "configurecoredns_not_patched.yaml",
+			configMap: "coredns",
+			key:       "Corefile",
+		},
+		{
+			desc:      "CoreDNS custom config",
+			mockFile:  "configurecoredns_custom_not_patched.yaml",
+			configMap: "coredns-custom",
+			key:       "traefik.mesh.server",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+			configMaps := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem)
+
+			logger := logrus.New()
+
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(logger, k8sClient.KubernetesClient())
+
+			original, err := configMaps.Get(ctx, test.configMap, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			err = client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+			require.NoError(t, err)
+
+			snapshot, err := client.LoadCoreDNSSnapshot(ctx, "traefik-mesh")
+			require.NoError(t, err)
+
+			// Configuring CoreDNS again keeps the snapshot of the pre-install configuration.
+			err = client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{CacheTTL: durationPtr(5 * time.Second)})
+			require.NoError(t, err)
+
+			gotSnapshot, err := client.LoadCoreDNSSnapshot(ctx, "traefik-mesh")
+			require.NoError(t, err)
+			assert.Equal(t, snapshot, gotSnapshot)
+
+			// Alter the markers of the Traefik Mesh block, which can't be removed by RestoreCoreDNS anymore.
+			patched, err := configMaps.Get(ctx, test.configMap, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Contains(t, patched.Data[test.key], blockTrailer)
+
+			patched.Data[test.key] = strings.ReplaceAll(patched.Data[test.key], blockTrailer, "#### End of the mesh block")
+
+			_, err = configMaps.Update(ctx, patched, metav1.UpdateOptions{})
+			require.NoError(t, err)
+
+			err = client.RestoreCoreDNSFromSnapshot(ctx, snapshot)
+			require.NoError(t, err)
+
+			restored, err := configMaps.Get(ctx, test.configMap, metav1.GetOptions{})
+			require.NoError(t, err)
+			originalValue, originalExists := original.Data[test.key]
+			restoredValue, restoredExists := restored.Data[test.key]
+			assert.Equal(t, originalExists, restoredExists)
+			assert.Equal(t, originalValue, restoredValue)
+
+			coreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.NotEmpty(t, coreDNSDeployment.Spec.Template.Annotations["traefik-mesh-hash"])
+
+			err = client.DeleteCoreDNSSnapshot(ctx, "traefik-mesh")
+			require.NoError(t, err)
+
+			_, err = client.LoadCoreDNSSnapshot(ctx, "traefik-mesh")
+			assert.ErrorIs(t, err, ErrSnapshotNotFound)
+		})
+	}
+}
+
+func TestConfigureCoreDNS_RestoreFromSnapshotAddonManager(t *testing.T) {
+	tests := []struct {
+		desc       string
+		otherKey   bool
+		expDeleted bool
+	}{
+		{
+			desc:       "coredns-custom created by Traefik Mesh",
+			expDeleted: true,
+		},
+		{
+			desc:     "coredns-custom created by Traefik Mesh and extended since",
+			otherKey: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock("configurecoredns_addon_manager.yaml")
+			configMaps := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem)
+
+			logger := logrus.New()
+
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(logger, k8sClient.KubernetesClient())
+
+			original, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			_, err = configMaps.Get(ctx, "coredns-custom", metav1.GetOptions{})
+			require.True(t, kerrors.IsNotFound(err))
+
+			err = client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+			require.NoError(t, err)
+
+			custom, err := configMaps.Get(ctx, "coredns-custom", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Contains(t, custom.Data["traefik.mesh.server"], blockHeader)
+
+			if test.otherKey {
+				custom.Data["other.server"] = "other:53 {\n    forward . 10.10.10.20:53\n}\n"
+
+				_, err = configMaps.Update(ctx, custom, metav1.UpdateOptions{})
+				require.NoError(t, err)
+			}
+
+			snapshot, err := client.LoadCoreDNSSnapshot(ctx, "traefik-mesh")
+			require.NoError(t, err)
+
+			err = client.RestoreCoreDNSFromSnapshot(ctx, snapshot)
+			require.NoError(t, err)
+
+			// The Corefile managed by the addon-manager is left untouched.
+			restored, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, original.Data, restored.Data)
+
+			restoredCustom, err := configMaps.Get(ctx, "coredns-custom", metav1.GetOptions{})
+			if test.expDeleted {
+				assert.True(t, kerrors.IsNotFound(err))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, map[string]string{"other.server": custom.Data["other.server"]}, restoredCustom.Data)
+			}
+
+			coreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.NotEmpty(t, coreDNSDeployment.Spec.Template.Annotations["traefik-mesh-hash"])
+
+			// Restoring again finds nothing left to restore.
+			if test.expDeleted {
+				err = client.RestoreCoreDNSFromSnapshot(ctx, snapshot)
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigureCoreDNS_HealthLameduck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8s.NewClientMock("configurecoredns_not_patched.yaml")
+	configMaps := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem)
+	deployments := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem)
+
+	logger := logrus.New()
+
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	client := NewClient(logger, k8sClient.KubernetesClient())
+
+	original, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	opts := BlockOptions{HealthLameduck: 10 * time.Second}
+
+	err = client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, opts)
+	require.NoError(t, err)
+
+	patched, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	wantHealth := "    #### Begin Traefik Mesh Health\n    # health {\n    #     lameduck 5s\n    # }\n    health {\n        lameduck 10s\n    }\n    #### End Traefik Mesh Health\n"
+	assert.Contains(t, patched.Data["Corefile"], wantHealth)
+	assert.Contains(t, patched.Data["Corefile"], blockHeader)
+
+	deployment, err := deployments.Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// Configuring CoreDNS again with the same options neither changes the configuration nor restarts CoreDNS.
+	err = client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, opts)
+	require.NoError(t, err)
+
+	gotPatched, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, patched.Data, gotPatched.Data)
+
+	gotDeployment, err := deployments.Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, deployment.Spec.Template.Annotations, gotDeployment.Spec.Template.Annotations)
+
+	// Restoring CoreDNS removes the whole Traefik Mesh block and restores the original health plugin.
+	restored, err := client.RestoreCoreDNS(ctx)
+	require.NoError(t, err)
+	assert.True(t, restored)
+
+	gotRestored, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	assert.NotContains(t, gotRestored.Data["Corefile"], blockHeader)
+	assert.NotContains(t, gotRestored.Data["Corefile"], healthHeader)
+	assert.NotContains(t, gotRestored.Data["Corefile"], "traefik.mesh")
+	assert.Equal(t, original.Data["Corefile"]+"\n", gotRestored.Data["Corefile"])
+}
+
+func TestAddStubDomain_Ready(t *testing.T) {
+	tests := []struct {
+		desc      string
+		config    string
+		opts      BlockOptions
+		wantReady string
+	}{
+		{
+			desc:   "ready disabled",
+			config: ".:53 {\n    errors\n    ready\n    forward . /etc/resolv.conf\n}\n",
+		},
+		{
+			desc:      "main zone ready on the default address",
+			config:    ".:53 {\n    errors\n    ready\n    forward . /etc/resolv.conf\n}\n",
+			opts:      BlockOptions{Ready: true},
+			wantReady: "    ready\n",
+		},
+		{
+			desc:      "main zone ready on a custom address",
+			config:    ".:53 {\n    errors\n    ready :8182\n    forward . /etc/resolv.conf\n}\n",
+			opts:      BlockOptions{Ready: true},
+			wantReady: "    ready :8182\n",
+		},
+		{
+			desc:      "main zone without ready",
+			config:    ".:53 {\n    errors\n    forward . /etc/resolv.conf\n}\n",
+			opts:      BlockOptions{Ready: true},
+			wantReady: "    ready\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			version := goversion.Must(goversion.NewVersion("1.8.0"))
+
+			got, changed := addStubDomain(test.config, blockHeader, blockTrailer, []string{"10.10.10.10:53"}, version, test.opts)
+			assert.True(t, changed)
+
+			// The main zone, and its ready plugin, are left unchanged.
+			assert.True(t, strings.HasPrefix(got, test.config))
+
+			wantBlock := blockHeader + "\ntraefik.mesh:53 {\n    errors\n" + test.wantReady + "    cache 30\n    forward . 10.10.10.10:53\n}\n" + blockTrailer + "\n"
+			assert.Equal(t, test.config+"\n"+wantBlock, got)
+
+			// Patching the configuration again with the same options doesn't change the block.
+			_, changed = addStubDomain(got, blockHeader, blockTrailer, []string{"10.10.10.10:53"}, version, test.opts)
+			assert.False(t, changed)
+		})
+	}
+}
+
+func TestConfigureCoreDNS_NoSnapshotOfPatchedConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8s.NewClientMock("configurecoredns_already_patched.yaml")
+
+	logger := logrus.New()
+
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	client := NewClient(logger, k8sClient.KubernetesClient())
+
+	err := client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+	require.NoError(t, err)
+
+	_, err = client.LoadCoreDNSSnapshot(ctx, "traefik-mesh")
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
+func TestConfigureCoreDNS_SnapshotForbidden(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient, ok := k8s.NewClientMock("configurecoredns_not_patched.yaml").KubernetesClient().(*fakekubeclient.Clientset)
+	require.True(t, ok)
+
+	// The ConfigMaps of the Traefik Mesh namespace can't be read, as when the dns Role doesn't grant it.
+	kubeClient.PrependReactor("*", "configmaps", func(action ktesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "traefik-mesh" {
+			return false, nil, nil
+		}
+
+		return true, nil, kerrors.NewForbidden(action.GetResource().GroupResource(), coreDNSSnapshotConfigMapName, nil)
+	})
+
+	logger, hook := logrustest.NewNullLogger()
+
+	client := NewClient(logger, kubeClient)
+
+	err := client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+	require.NoError(t, err)
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, configMap.Data["Corefile"], blockHeader)
+
+	var warned bool
+
+	for _, entry := range hook.AllEntries() {
+		warned = warned || entry.Level == logrus.WarnLevel
+	}
+
+	assert.True(t, warned)
+}
+
+func TestConfigureCoreDNS_LogsProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8s.NewClientMock("configurecoredns_not_patched.yaml")
+
+	logger, hook := logrustest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	client := NewClient(logger, k8sClient.KubernetesClient())
+
+	err := client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+	require.NoError(t, err)
+
+	entries := hook.AllEntries()
+	require.NotEmpty(t, entries)
+
+	for _, entry := range entries {
+		assert.Equal(t, "coredns", entry.Data["provider"], entry.Message)
+	}
+}
+
+func TestConfigureCoreDNS_ServerSideApply(t *testing.T) {
+	tests := []struct {
+		desc      string
+		mockFile  string
+		configMap string
+		key       string
+	}{
+		{
+			desc:      "CoreDNS",
+			mockFile:  "configurecoredns_not_patched.yaml",
+			configMap: "coredns",
+			key:       "Corefile",
+		},
+		{
+			desc:      "CoreDNS custom",
+			mockFile:  "configurecoredns_custom_not_patched.yaml",
+			configMap: "coredns-custom",
+			key:       "traefik.mesh.server",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			kubeClient, ok := k8s.NewClientMock(test.mockFile).KubernetesClient().(*fakekubeclient.Clientset)
+			require.True(t, ok)
+
+			var (
+				appliedConfigMap  corev1.ConfigMap
+				appliedDeployment appsv1.Deployment
+				updated           []string
+			)
+
+			// The fake clientset doesn't support server-side apply, the applied fields are merged into the stored
+			// objects instead.
+			kubeClient.PrependReactor("patch", "configmaps", func(action ktesting.Action) (bool, runtime.Object, error) {
+				patch := action.(ktesting.PatchAction)
+				if patch.GetPatchType() != types.ApplyPatchType {
+					return false, nil, nil
+				}
+
+				appliedConfigMap = corev1.ConfigMap{}
+				if err := json.Unmarshal(patch.GetPatch(), &appliedConfigMap); err != nil {
+					return true, nil, err
+				}
+
+				obj, err := kubeClient.Tracker().Get(action.GetResource(), action.GetNamespace(), patch.GetName())
+				if err != nil {
+					return true, nil, err
+				}
+
+				configMap := obj.(*corev1.ConfigMap).DeepCopy()
+				for key, value := range appliedConfigMap.Data {
+					configMap.Data[key] = value
+				}
+
+				return true, configMap, kubeClient.Tracker().Update(action.GetResource(), configMap, action.GetNamespace())
+			})
+
+			kubeClient.PrependReactor("patch", "deployments", func(action ktesting.Action) (bool, runtime.Object, error) {
+				patch := action.(ktesting.PatchAction)
+				if patch.GetPatchType() != types.ApplyPatchType {
+					return false, nil, nil
+				}
+
+				if err := json.Unmarshal(patch.GetPatch(), &appliedDeployment); err != nil {
+					return true, nil, err
+				}
+
+				obj, err := kubeClient.Tracker().Get(action.GetResource(), action.GetNamespace(), patch.GetName())
+				if err != nil {
+					return true, nil, err
+				}
+
+				deployment := obj.(*appsv1.Deployment).DeepCopy()
+				if deployment.Spec.Template.Annotations == nil {
+					deployment.Spec.Template.Annotations = make(map[string]string)
+				}
+
+				for key, value := range appliedDeployment.Spec.Template.Annotations {
+					deployment.Spec.Template.Annotations[key] = value
+				}
+
+				return true, deployment, kubeClient.Tracker().Update(action.GetResource(), deployment, action.GetNamespace())
+			})
+
+			kubeClient.PrependReactor("update", "*", func(action ktesting.Action) (bool, runtime.Object, error) {
+				updated = append(updated, action.GetResource().Resource)
+
+				return false, nil, nil
+			})
+
+			logger := logrus.New()
+
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(logger, kubeClient, ServerSideApply())
+
+			err := client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+			require.NoError(t, err)
+
+			assert.Empty(t, updated)
+
+			// Only the fields managed by Traefik Mesh are applied.
+			assert.Equal(t, test.configMap, appliedConfigMap.Name)
+			assert.Empty(t, appliedConfigMap.Labels)
+			require.Len(t, appliedConfigMap.Data, 1)
+			assert.Contains(t, appliedConfigMap.Data[test.key], blockHeader)
+
+			assert.Equal(t, "coredns", appliedDeployment.Name)
+			assert.Empty(t, appliedDeployment.Spec.Template.Spec.Containers)
+			assert.Len(t, appliedDeployment.Spec.Template.Annotations, 1)
+			assert.NotEmpty(t, appliedDeployment.Spec.Template.Annotations[restartHashAnnotation])
+
+			configMap, err := kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, test.configMap, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, appliedConfigMap.Data[test.key], configMap.Data[test.key])
+
+			// The restoration is applied as well, the key being left out of the applied ConfigMap when it is removed.
+			restored, err := client.RestoreCoreDNS(ctx)
+			require.NoError(t, err)
+			assert.True(t, restored)
+
+			assert.Empty(t, updated)
+			assert.Equal(t, test.configMap, appliedConfigMap.Name)
+			assert.NotContains(t, appliedConfigMap.Data[test.key], blockHeader)
+		})
+	}
+}
+
+func TestConfigureCoreDNS_ServerSideApplyConflict(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient, ok := k8s.NewClientMock("configurecoredns_not_patched.yaml").KubernetesClient().(*fakekubeclient.Clientset)
+	require.True(t, ok)
+
+	// The Corefile is owned by another field manager.
+	kubeClient.PrependReactor("patch", "configmaps", func(action ktesting.Action) (bool, runtime.Object, error) {
+		patch := action.(ktesting.PatchAction)
+
+		return true, nil, kerrors.NewConflict(action.GetResource().GroupResource(), patch.GetName(), errors.New(`conflict with "kubectl": .data.Corefile`))
+	})
+
+	logger := logrus.New()
+
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	client := NewClient(logger, kubeClient, ServerSideApply())
+
+	err := client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+	require.Error(t, err)
+	assert.True(t, kerrors.IsConflict(err))
+
+	// The Corefile is left untouched, and CoreDNS is not restarted.
+	configMap, err := kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, configMap.Data["Corefile"], blockHeader)
+
+	deployment, err := kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, deployment.Spec.Template.Annotations[restartHashAnnotation])
+}
+
+func TestNeedsReconfigure(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mockFile string
+		opts     BlockOptions
+		expNeeds bool
+		expErr   bool
 	}{
 		{
-			desc:        "First time config of CoreDNS",
-			mockFile:    "configurecoredns_not_patched.yaml",
-			expErr:      false,
-			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
-			expRestart:  true,
+			desc:     "Not patched CoreDNS config",
+			mockFile: "configurecoredns_not_patched.yaml",
+			expNeeds: true,
 		},
 		{
-			desc:        "Already patched CoreDNS config",
-			mockFile:    "configurecoredns_already_patched.yaml",
-			expErr:      false,
-			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
-			expRestart:  false,
+			desc:     "Already patched CoreDNS config",
+			mockFile: "configurecoredns_already_patched.yaml",
+			expNeeds: false,
 		},
 		{
-			desc:       "Missing Corefile configmap",
-			mockFile:   "configurecoredns_missing_configmap.yaml",
-			expErr:     true,
-			expRestart: false,
+			desc:     "Already patched CoreDNS config forwarding to a stale IP",
+			mockFile: "configurecoredns_stale_upstream.yaml",
+			expNeeds: true,
 		},
 		{
-			desc:        "First time config of CoreDNS custom",
-			mockFile:    "configurecoredns_custom_not_patched.yaml",
-			expErr:      false,
-			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n",
-			expCustoms: map[string]string{
-				"traefik.mesh.server": "\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
-			},
-			expRestart: true,
+			desc:     "Already patched CoreDNS config with different options",
+			mockFile: "configurecoredns_already_patched.yaml",
+			opts:     BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expNeeds: true,
 		},
 		{
-			desc:        "Already patched CoreDNS custom config",
-			mockFile:    "configurecoredns_custom_already_patched.yaml",
-			expErr:      false,
-			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n",
-			expCustoms: map[string]string{
-				"traefik.mesh.server": "#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
-			},
-			expRestart: false,
+			desc:     "Not patched CoreDNS custom config",
+			mockFile: "configurecoredns_custom_not_patched.yaml",
+			expNeeds: true,
 		},
 		{
-			desc:        "Config of CoreDNS 1.3",
-			mockFile:    "configurecoredns_1_3.yaml",
-			expErr:      false,
-			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    proxy . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    proxy . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
-			expRestart:  true,
+			desc:     "Already patched CoreDNS custom config",
+			mockFile: "configurecoredns_custom_already_patched.yaml",
+			expNeeds: false,
 		},
 		{
-			desc:        "CoreDNS 1.4 already patched for an older version of CoreDNS",
-			mockFile:    "configurecoredns_1_4_already_patched.yaml",
-			expErr:      false,
-			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
-			expRestart:  true,
+			desc:     "CoreDNS managed by the addon-manager without coredns-custom ConfigMap",
+			mockFile: "configurecoredns_addon_manager.yaml",
+			expNeeds: true,
 		},
 		{
-			desc:       "Missing CoreDNS deployment",
-			mockFile:   "configurecoredns_missing_deployment.yaml",
-			expErr:     true,
-			expRestart: false,
+			desc:     "Unsupported options",
+			mockFile: "configurecoredns_1_3.yaml",
+			opts:     BlockOptions{TLSServerName: "dns.traefik.mesh"},
+			expErr:   true,
+		},
+		{
+			desc:     "Missing CoreDNS deployment",
+			mockFile: "configurecoredns_missing_deployment.yaml",
+			expErr:   true,
 		},
 	}
 
@@ -163,35 +1197,93 @@ func TestConfigureCoreDNS(t *testing.T) {
 
 			client := NewClient(logger, k8sClient.KubernetesClient())
 
-			err := client.ConfigureCoreDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53)
+			before, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+
+			needs, err := client.NeedsReconfigure(ctx, "traefik-mesh", "traefik-mesh-dns", 53, test.opts)
 			if test.expErr {
 				require.Error(t, err)
 				return
 			}
 
 			require.NoError(t, err)
+			assert.Equal(t, test.expNeeds, needs)
 
-			cfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+			// Nothing must have been modified.
+			after, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem).List(ctx, metav1.ListOptions{})
 			require.NoError(t, err)
+			assert.Equal(t, before.Items, after.Items)
 
-			assert.Equal(t, test.expCorefile, cfgMap.Data["Corefile"])
+			coreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Empty(t, coreDNSDeployment.Spec.Template.Annotations["traefik-mesh-hash"])
 
-			if len(test.expCustoms) > 0 {
-				var customCfgMap *corev1.ConfigMap
+			_, err = client.LoadCoreDNSSnapshot(ctx, "traefik-mesh")
+			assert.ErrorIs(t, err, ErrSnapshotNotFound)
+		})
+	}
+}
 
-				customCfgMap, err = k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns-custom", metav1.GetOptions{})
-				require.NoError(t, err)
+func TestBuildCoreDNSConfigMap(t *testing.T) {
+	tests := []struct {
+		desc      string
+		mockFile  string
+		configMap string
+		key       string
+		expValue  string
+	}{
+		{
+			desc:      "CoreDNS config",
+			mockFile:  "configurecoredns_not_patched.yaml",
+			configMap: "coredns",
+			key:       "Corefile",
+			expValue:  ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+		},
+		{
+			desc:      "CoreDNS custom config",
+			mockFile:  "configurecoredns_custom_not_patched.yaml",
+			configMap: "coredns-custom",
+			key:       "traefik.mesh.server",
+			expValue:  "\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+		},
+	}
 
-				for key, value := range test.expCustoms {
-					assert.Equal(t, value, customCfgMap.Data[key])
-				}
-			}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+			configMaps := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem)
+
+			logger := logrus.New()
+
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
 
-			coreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+			client := NewClient(logger, k8sClient.KubernetesClient())
+
+			before, err := configMaps.List(ctx, metav1.ListOptions{})
 			require.NoError(t, err)
 
-			restarted := coreDNSDeployment.Spec.Template.Annotations["traefik-mesh-hash"] != ""
-			assert.Equal(t, test.expRestart, restarted)
+			configMap, err := client.BuildCoreDNSConfigMap(ctx, "traefik-mesh", "traefik-mesh-dns", 53, BlockOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}, configMap.TypeMeta)
+			assert.Equal(t, test.configMap, configMap.Name)
+			assert.Equal(t, metav1.NamespaceSystem, configMap.Namespace)
+			assert.Empty(t, configMap.ResourceVersion)
+			assert.Empty(t, configMap.UID)
+			assert.Equal(t, test.expValue, configMap.Data[test.key])
+
+			// Nothing must have been modified.
+			after, err := configMaps.List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, before.Items, after.Items)
+
+			coreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Empty(t, coreDNSDeployment.Spec.Template.Annotations["traefik-mesh-hash"])
 		})
 	}
 }
@@ -200,8 +1292,10 @@ func TestConfigureKubeDNS(t *testing.T) {
 	tests := []struct {
 		desc           string
 		mockFile       string
+		noCreate       bool
 		expStubDomains string
 		expErr         bool
+		expRestart     bool
 	}{
 		{
 			desc:     "should return an error if kube-dns deployment does not exist",
@@ -212,16 +1306,31 @@ func TestConfigureKubeDNS(t *testing.T) {
 			desc:           "should add stubdomains config in kube-dns configmap",
 			mockFile:       "configurekubedns_not_patched.yaml",
 			expStubDomains: `{"traefik.mesh":["10.10.10.10:53"]}`,
+			expRestart:     true,
 		},
 		{
 			desc:           "should replace stubdomains config in kube-dns configmap",
 			mockFile:       "configurekubedns_already_patched.yaml",
 			expStubDomains: `{"traefik.mesh":["10.10.10.10:53"]}`,
+			expRestart:     true,
+		},
+		{
+			desc:           "should not update nor restart kube-dns when stubdomains config is up to date",
+			mockFile:       "configurekubedns_up_to_date.yaml",
+			expStubDomains: "{\"traefik.mesh\":[\"10.10.10.10:53\"]}\n",
+			expRestart:     false,
 		},
 		{
 			desc:           "should create optional kube-dns configmap and add stubdomains config",
 			mockFile:       "configurekubedns_optional_configmap.yaml",
 			expStubDomains: `{"traefik.mesh":["10.10.10.10:53"]}`,
+			expRestart:     true,
+		},
+		{
+			desc:     "should return an error if optional kube-dns configmap does not exist and creation is disabled",
+			mockFile: "configurekubedns_optional_configmap.yaml",
+			noCreate: true,
+			expErr:   true,
 		},
 	}
 
@@ -237,11 +1346,22 @@ func TestConfigureKubeDNS(t *testing.T) {
 			logger.SetOutput(os.Stdout)
 			logger.SetLevel(logrus.DebugLevel)
 
-			client := NewClient(logger, k8sClient.KubernetesClient())
+			var opts []ClientOption
+			if test.noCreate {
+				opts = append(opts, NoConfigMapCreation())
+			}
+
+			client := NewClient(logger, k8sClient.KubernetesClient(), opts...)
 
 			err := client.ConfigureKubeDNS(ctx, "traefik-mesh", "traefik-mesh-dns", 53)
 			if test.expErr {
 				require.Error(t, err)
+
+				if test.noCreate {
+					_, err = k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system").Get(ctx, "kube-dns", metav1.GetOptions{})
+					assert.True(t, kerrors.IsNotFound(err))
+				}
+
 				return
 			}
 
@@ -251,6 +1371,12 @@ func TestConfigureKubeDNS(t *testing.T) {
 			require.NoError(t, err)
 
 			assert.Equal(t, test.expStubDomains, cfgMap.Data["stubDomains"])
+
+			kubeDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments("kube-system").Get(ctx, "kube-dns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			restarted := kubeDNSDeployment.Spec.Template.Annotations["traefik-mesh-hash"] != ""
+			assert.Equal(t, test.expRestart, restarted)
 		})
 	}
 }
@@ -259,13 +1385,59 @@ func TestRestoreCoreDNS(t *testing.T) {
 	tests := []struct {
 		desc        string
 		mockFile    string
+		namespace   string
 		hasCustom   bool
 		expCorefile string
+		expRestored bool
 	}{
 		{
 			desc:        "CoreDNS config patched",
 			mockFile:    "restorecoredns_patched.yaml",
 			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
+		},
+		{
+			desc:        "CoreDNS config patched in a custom namespace",
+			mockFile:    "restorecoredns_custom_namespace.yaml",
+			namespace:   "dns-system",
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
+		},
+		{
+			desc:        "CoreDNS config patched with TLS upstream",
+			mockFile:    "restorecoredns_tls_patched.yaml",
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
+		},
+		{
+			desc:        "CoreDNS config patched with serve stale",
+			mockFile:    "restorecoredns_serve_stale_patched.yaml",
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
+		},
+		{
+			desc:        "CoreDNS config patched without cache",
+			mockFile:    "restorecoredns_no_cache_patched.yaml",
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
+		},
+		{
+			desc:        "CoreDNS config patched with an extra zone",
+			mockFile:    "restorecoredns_extra_zones_patched.yaml",
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
+		},
+		{
+			desc:        "CoreDNS config patched with except domains",
+			mockFile:    "restorecoredns_except_patched.yaml",
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
+		},
+		{
+			desc:        "CoreDNS config patched with consolidated errors",
+			mockFile:    "restorecoredns_errors_consolidate_patched.yaml",
+			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# This is test data that must be present\n",
+			expRestored: true,
 		},
 		{
 			desc:        "CoreDNS config not patched",
@@ -277,6 +1449,7 @@ func TestRestoreCoreDNS(t *testing.T) {
 			mockFile:    "restorecoredns_custom_patched.yaml",
 			hasCustom:   true,
 			expCorefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n",
+			expRestored: true,
 		},
 		{
 			desc:        "CoreDNS custom config not patched",
@@ -298,18 +1471,28 @@ func TestRestoreCoreDNS(t *testing.T) {
 			logger.SetOutput(os.Stdout)
 			logger.SetLevel(logrus.DebugLevel)
 
-			client := NewClient(logger, k8sClient.KubernetesClient())
+			namespace := metav1.NamespaceSystem
+
+			var opts []ClientOption
+			if test.namespace != "" {
+				namespace = test.namespace
+				opts = append(opts, SystemNamespace(namespace))
+			}
+
+			client := NewClient(logger, k8sClient.KubernetesClient(), opts...)
 
-			err := client.RestoreCoreDNS(ctx)
+			restored, err := client.RestoreCoreDNS(ctx)
 			require.NoError(t, err)
 
-			cfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+			assert.Equal(t, test.expRestored, restored)
+
+			cfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(namespace).Get(ctx, "coredns", metav1.GetOptions{})
 			require.NoError(t, err)
 
 			assert.Equal(t, test.expCorefile, cfgMap.Data["Corefile"])
 
 			if test.hasCustom {
-				customCfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns-custom", metav1.GetOptions{})
+				customCfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(namespace).Get(ctx, "coredns-custom", metav1.GetOptions{})
 				require.NoError(t, err)
 
 				_, exists := customCfgMap.Data["traefik.mesh.server"]
@@ -322,6 +1505,46 @@ func TestRestoreCoreDNS(t *testing.T) {
 	}
 }
 
+func TestRestoreCoreDNS_Idempotent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8s.NewClientMock("restorecoredns_patched.yaml")
+
+	logger := logrus.New()
+
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	client := NewClient(logger, k8sClient.KubernetesClient())
+
+	restored, err := client.RestoreCoreDNS(ctx)
+	require.NoError(t, err)
+	assert.True(t, restored)
+
+	cfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	coreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	restored, err = client.RestoreCoreDNS(ctx)
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	gotCfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, cfgMap, gotCfgMap)
+	assert.Equal(t, cfgMap.ResourceVersion, gotCfgMap.ResourceVersion)
+
+	gotCoreDNSDeployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// The CoreDNS pods must not be restarted again.
+	assert.Equal(t, coreDNSDeployment.Spec.Template.Annotations, gotCoreDNSDeployment.Spec.Template.Annotations)
+}
+
 func TestRestoreKubeDNS(t *testing.T) {
 	tests := []struct {
 		desc           string
@@ -364,3 +1587,62 @@ func TestRestoreKubeDNS(t *testing.T) {
 		})
 	}
 }
+
+func TestDumpConfig(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mockFile string
+		expDump  string
+		expErr   bool
+	}{
+		{
+			desc:     "CoreDNS config patched",
+			mockFile: "configurecoredns_already_patched.yaml",
+			expDump:  "# ConfigMap kube-system/coredns, key Corefile\n.:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+		},
+		{
+			desc:     "CoreDNS custom config patched",
+			mockFile: "configurecoredns_custom_already_patched.yaml",
+			expDump:  "# ConfigMap kube-system/coredns, key Corefile\n.:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    ready\n    kubernetes {{ pillar['dns_domain'] }} in-addr.arpa ip6.arpa {\n        pods insecure\n        fallthrough in-addr.arpa ip6.arpa\n        ttl 30\n    }\n    prometheus :9153\n    forward . /etc/resolv.conf\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n\n# ConfigMap kube-system/coredns-custom, key traefik.mesh.server\n#### Begin Traefik Mesh Block\ntraefik.mesh:53 {\n    errors\n    cache 30\n    forward . 10.10.10.10:53\n}\n#### End Traefik Mesh Block\n",
+		},
+		{
+			desc:     "KubeDNS config patched",
+			mockFile: "configurekubedns_already_patched.yaml",
+			expDump:  "# ConfigMap kube-system/kube-dns, key stubDomains\n#### Begin Traefik Mesh Block\ntraefik.mesh: 1.2.3.4\n#### End Traefik Mesh Block\n",
+		},
+		{
+			desc:     "No known DNS provider",
+			mockFile: "checkdnsprovider_no_provider.yaml",
+			expErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			logger := logrus.New()
+
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(logger, k8sClient.KubernetesClient())
+
+			dump, err := client.DumpConfig(ctx)
+			if test.expErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expDump, dump)
+		})
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
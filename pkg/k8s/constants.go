@@ -18,7 +18,7 @@ const (
 	TCPRouteObjectKind = "TCPRoute"
 
 	// CoreObjectKinds is a filter for objects to process by the core client.
-	CoreObjectKinds = "Deployment|Endpoints|Service|Ingress|Secret|Namespace|Pod|ConfigMap"
+	CoreObjectKinds = "Deployment|DaemonSet|Endpoints|EndpointSlice|Service|Ingress|Secret|Namespace|Pod|ConfigMap"
 	// AccessObjectKinds is a filter for objects to process by the access client.
 	AccessObjectKinds = TrafficTargetObjectKind
 	// SpecsObjectKinds is a filter for objects to process by the specs client.
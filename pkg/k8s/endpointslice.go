@@ -0,0 +1,31 @@
+package k8s
+
+import (
+	"fmt"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IsEndpointSliceAvailable returns true if the EndpointSlice API supported by Traefik Mesh is served by the cluster,
+// false otherwise.
+func IsEndpointSliceAvailable(client kubernetes.Interface) (bool, error) {
+	serverGroups, err := client.Discovery().ServerGroups()
+	if err != nil {
+		return false, fmt.Errorf("unable to list kubernetes server groups: %w", err)
+	}
+
+	for _, group := range serverGroups.Groups {
+		if group.Name != discoveryv1.GroupName {
+			continue
+		}
+
+		for _, version := range group.Versions {
+			if version.Version == discoveryv1.SchemeGroupVersion.Version {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,52 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsEndpointSliceAvailable(t *testing.T) {
+	tests := []struct {
+		desc          string
+		groupVersions []string
+		want          bool
+	}{
+		{
+			desc:          "discovery v1 served",
+			groupVersions: []string{"discovery.k8s.io/v1beta1", "discovery.k8s.io/v1"},
+			want:          true,
+		},
+		{
+			desc:          "only discovery v1beta1 served",
+			groupVersions: []string{"discovery.k8s.io/v1beta1"},
+		},
+		{
+			desc: "discovery group not served",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := fakekubeclient.NewSimpleClientset()
+
+			discovery, ok := client.Discovery().(*fakediscovery.FakeDiscovery)
+			require.True(t, ok)
+
+			for _, groupVersion := range test.groupVersions {
+				discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{GroupVersion: groupVersion})
+			}
+
+			got, err := IsEndpointSliceAvailable(client)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -96,11 +96,6 @@ func (f *ResourceFilter) IsIgnored(obj interface{}) bool {
 		if containsNamespaceName(f.ignoredServices, namespaceName{Namespace: svc.Namespace, Name: svc.Name}) {
 			return true
 		}
-
-		// Ignore ExternalName services as they are not currently supported.
-		if svc.Spec.Type == corev1.ServiceTypeExternalName {
-			return true
-		}
 	}
 
 	return false
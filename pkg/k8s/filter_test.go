@@ -174,7 +174,7 @@ func TestResourceFilter_IsIgnoredWithIgnoredServices(t *testing.T) {
 	assert.False(t, got)
 }
 
-func TestResourceFilter_IsIgnoredIgnoresExternalNameServices(t *testing.T) {
+func TestResourceFilter_IsIgnoredKeepsExternalNameServices(t *testing.T) {
 	filter := NewResourceFilter()
 
 	got := filter.IsIgnored(&v1.Service{
@@ -187,7 +187,7 @@ func TestResourceFilter_IsIgnoredIgnoresExternalNameServices(t *testing.T) {
 		},
 	})
 
-	assert.True(t, got)
+	assert.False(t, got)
 }
 
 func TestResourceFilter_WatchNamespaces(t *testing.T) {
@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElectionConfig holds the configuration of a lease based leader election.
+type LeaderElectionConfig struct {
+	// Namespace and LeaseName identify the Lease used as lock.
+	Namespace string
+	LeaseName string
+	// Identity is the unique identity of the candidate, usually the Pod name.
+	Identity string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// NewLeaderElectionConfig creates a LeaderElectionConfig with the default durations.
+func NewLeaderElectionConfig(namespace, leaseName, identity string) LeaderElectionConfig {
+	return LeaderElectionConfig{
+		Namespace:     namespace,
+		LeaseName:     leaseName,
+		Identity:      identity,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+}
+
+// RunLeaderElection runs the leader election until the given context is canceled or the leadership is lost.
+// onStartedLeading is called once the leadership is acquired, and onStoppedLeading once the election stops, whether
+// the leadership has been acquired or not.
+func RunLeaderElection(ctx context.Context, kubeClient kubernetes.Interface, cfg LeaderElectionConfig, logger logrus.FieldLogger, onStartedLeading func(ctx context.Context), onStoppedLeading func()) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: cfg.Namespace,
+			Name:      cfg.LeaseName,
+		},
+		Client: kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: cfg.Identity,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logger.Infof("Acquired leadership of Lease %s/%s as %q", cfg.Namespace, cfg.LeaseName, cfg.Identity)
+				onStartedLeading(ctx)
+			},
+			OnStoppedLeading: func() {
+				logger.Infof("Stopped leader election of Lease %s/%s as %q", cfg.Namespace, cfg.LeaseName, cfg.Identity)
+				onStoppedLeading()
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create leader elector: %w", err)
+	}
+
+	elector.Run(ctx)
+
+	return nil
+}
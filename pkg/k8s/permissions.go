@@ -0,0 +1,144 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is an operation on a Kubernetes resource performed by Traefik Mesh.
+type Permission struct {
+	Verb     string
+	Group    string
+	Resource string
+	// Namespace of the resource. Empty for an operation on all the namespaces.
+	Namespace string
+}
+
+// String returns a human readable representation of the permission.
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s %s in all namespaces", p.Verb, resource)
+	}
+
+	return fmt.Sprintf("%s %s in namespace %q", p.Verb, resource, p.Namespace)
+}
+
+// ControllerPermissions returns the permissions needed by the controller. The shadow services are managed, and their
+// events recorded, in the given namespace, and the SMI access resources and the pods are only watched when the ACL mode
+// is enabled.
+func ControllerPermissions(namespace string, acl bool) []Permission {
+	var permissions []Permission
+
+	for _, verb := range []string{"list", "watch"} {
+		permissions = append(permissions,
+			Permission{Verb: verb, Resource: "services"},
+			Permission{Verb: verb, Resource: "secrets"},
+			Permission{Verb: verb, Resource: "endpoints"},
+			Permission{Verb: verb, Group: "discovery.k8s.io", Resource: "endpointslices"},
+			Permission{Verb: verb, Group: "split.smi-spec.io", Resource: "trafficsplits"},
+			Permission{Verb: verb, Group: "specs.smi-spec.io", Resource: "httproutegroups"},
+			Permission{Verb: verb, Group: "specs.smi-spec.io", Resource: "tcproutes"},
+		)
+
+		if acl {
+			permissions = append(permissions,
+				Permission{Verb: verb, Resource: "pods"},
+				Permission{Verb: verb, Group: "access.smi-spec.io", Resource: "traffictargets"},
+			)
+		}
+	}
+
+	for _, verb := range []string{"create", "update", "delete"} {
+		permissions = append(permissions, Permission{Verb: verb, Resource: "services", Namespace: namespace})
+	}
+
+	return append(permissions, EventsPermissions(namespace)...)
+}
+
+// DNSPermissions returns the permissions needed by the DNS client to configure the cluster DNS provider installed in
+// the given DNS namespace. The snapshot of the CoreDNS configuration is stored in the given namespace. With server-side
+// apply, the Deployment and the ConfigMaps of the DNS provider are patched instead of being updated.
+func DNSPermissions(namespace, dnsNamespace string, serverSideApply bool) []Permission {
+	updateVerb := "update"
+	if serverSideApply {
+		updateVerb = "patch"
+	}
+
+	return []Permission{
+		{Verb: "get", Group: "apps", Resource: "deployments", Namespace: dnsNamespace},
+		{Verb: updateVerb, Group: "apps", Resource: "deployments", Namespace: dnsNamespace},
+		{Verb: "get", Group: "apps", Resource: "daemonsets", Namespace: dnsNamespace},
+		{Verb: "get", Resource: "configmaps", Namespace: dnsNamespace},
+		{Verb: "create", Resource: "configmaps", Namespace: dnsNamespace},
+		{Verb: updateVerb, Resource: "configmaps", Namespace: dnsNamespace},
+		{Verb: "get", Resource: "configmaps", Namespace: namespace},
+		{Verb: "create", Resource: "configmaps", Namespace: namespace},
+		{Verb: "delete", Resource: "configmaps", Namespace: namespace},
+	}
+}
+
+// EventsPermissions returns the permissions needed to record the events on the Traefik Mesh resources of the given
+// namespace.
+func EventsPermissions(namespace string) []Permission {
+	return []Permission{
+		{Verb: "create", Resource: "events", Namespace: namespace},
+	}
+}
+
+// LeaderElectionPermissions returns the permissions needed to take part in a leader election based on the Leases of
+// the given namespace.
+func LeaderElectionPermissions(namespace string) []Permission {
+	return []Permission{
+		{Verb: "get", Group: "coordination.k8s.io", Resource: "leases", Namespace: namespace},
+		{Verb: "create", Group: "coordination.k8s.io", Resource: "leases", Namespace: namespace},
+		{Verb: "update", Group: "coordination.k8s.io", Resource: "leases", Namespace: namespace},
+	}
+}
+
+// SettingsPermissions returns the permissions needed by the controller to watch its settings ConfigMap in the given
+// namespace.
+func SettingsPermissions(namespace string) []Permission {
+	return []Permission{
+		{Verb: "list", Resource: "configmaps", Namespace: namespace},
+		{Verb: "watch", Resource: "configmaps", Namespace: namespace},
+	}
+}
+
+// CheckPermissions checks with a SelfSubjectAccessReview whether each of the given permissions is granted to the
+// current user, and returns the denied ones.
+func CheckPermissions(ctx context.Context, kubeClient kubernetes.Interface, permissions []Permission) ([]Permission, error) {
+	var denied []Permission
+
+	for _, permission := range permissions {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: permission.Namespace,
+					Verb:      permission.Verb,
+					Group:     permission.Group,
+					Resource:  permission.Resource,
+				},
+			},
+		}
+
+		result, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to review permission to %s: %w", permission, err)
+		}
+
+		if !result.Status.Allowed {
+			denied = append(denied, permission)
+		}
+	}
+
+	return denied, nil
+}
@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+
+	// The fake authorizer denies the update of the ConfigMaps, and allows everything else.
+	var reviewed []authorizationv1.ResourceAttributes
+
+	kubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action ktesting.Action) (bool, runtime.Object, error) {
+		review := action.(ktesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+
+		reviewed = append(reviewed, *attrs)
+		review.Status.Allowed = attrs.Resource != "configmaps" || attrs.Verb != "update"
+
+		return true, review, nil
+	})
+
+	permissions := append(ControllerPermissions("traefik-mesh", false), DNSPermissions("traefik-mesh", "kube-system", false)...)
+
+	denied, err := CheckPermissions(context.Background(), kubeClient, permissions)
+	require.NoError(t, err)
+
+	assert.Len(t, reviewed, len(permissions))
+	assert.Equal(t, []Permission{{Verb: "update", Resource: "configmaps", Namespace: "kube-system"}}, denied)
+	assert.Equal(t, `update configmaps in namespace "kube-system"`, denied[0].String())
+}
+
+func TestDNSPermissions(t *testing.T) {
+	tests := []struct {
+		desc            string
+		serverSideApply bool
+		want            []string
+		notWant         []string
+	}{
+		{
+			desc:    "update",
+			want:    []string{`update deployments.apps in namespace "kube-system"`, `update configmaps in namespace "kube-system"`},
+			notWant: []string{`patch deployments.apps in namespace "kube-system"`, `patch configmaps in namespace "kube-system"`},
+		},
+		{
+			desc:            "server-side apply",
+			serverSideApply: true,
+			want:            []string{`patch deployments.apps in namespace "kube-system"`, `patch configmaps in namespace "kube-system"`},
+			notWant:         []string{`update deployments.apps in namespace "kube-system"`, `update configmaps in namespace "kube-system"`},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, permission := range DNSPermissions("traefik-mesh", "kube-system", test.serverSideApply) {
+				got = append(got, permission.String())
+			}
+
+			// The DaemonSet lookup and the CoreDNS snapshot are always needed.
+			assert.Contains(t, got, `get daemonsets.apps in namespace "kube-system"`)
+			assert.Contains(t, got, `create configmaps in namespace "traefik-mesh"`)
+			assert.Contains(t, got, `delete configmaps in namespace "traefik-mesh"`)
+
+			for _, permission := range test.want {
+				assert.Contains(t, got, permission)
+			}
+
+			for _, permission := range test.notWant {
+				assert.NotContains(t, got, permission)
+			}
+		})
+	}
+}
+
+func TestPermission_String(t *testing.T) {
+	permission := Permission{Verb: "list", Group: "split.smi-spec.io", Resource: "trafficsplits"}
+
+	assert.Equal(t, "list trafficsplits.split.smi-spec.io in all namespaces", permission.String())
+}
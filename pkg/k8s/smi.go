@@ -12,45 +12,53 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// CheckSMIVersion checks if the SMI CRDs versions installed match the supported versions.
-func CheckSMIVersion(client kubernetes.Interface, aclEnabled bool) error {
+// SMIGroups tells which SMI API groups are installed with a supported version.
+type SMIGroups struct {
+	Access bool
+	Specs  bool
+	Split  bool
+}
+
+// GetSMIGroups returns the SMI API groups installed with a supported version. An error is returned if an SMI API group
+// is installed with an unsupported version.
+func GetSMIGroups(client kubernetes.Interface) (SMIGroups, error) {
 	serverGroups, err := client.Discovery().ServerGroups()
 	if err != nil {
-		return fmt.Errorf("unable to list kubernetes server groups: %w", err)
-	}
-
-	requiredGroups := []schema.GroupVersion{
-		split.SchemeGroupVersion,
-		specs.SchemeGroupVersion,
-	}
-
-	if aclEnabled {
-		requiredGroups = append(requiredGroups, access.SchemeGroupVersion)
+		return SMIGroups{}, fmt.Errorf("unable to list kubernetes server groups: %w", err)
 	}
 
-	var errs []string
-
-	for _, requiredGroup := range requiredGroups {
-		var version string
+	var (
+		groups SMIGroups
+		errs   []string
+	)
 
+	for _, supported := range []struct {
+		groupVersion schema.GroupVersion
+		installed    *bool
+	}{
+		{groupVersion: access.SchemeGroupVersion, installed: &groups.Access},
+		{groupVersion: specs.SchemeGroupVersion, installed: &groups.Specs},
+		{groupVersion: split.SchemeGroupVersion, installed: &groups.Split},
+	} {
 		for _, group := range serverGroups.Groups {
-			if requiredGroup.Group == group.Name {
-				version = group.PreferredVersion.Version
+			if group.Name != supported.groupVersion.Group {
+				continue
+			}
 
+			if group.PreferredVersion.Version != supported.groupVersion.Version {
+				errs = append(errs, fmt.Sprintf("unable to find group %q version %q, got %q", supported.groupVersion.Group, supported.groupVersion.Version, group.PreferredVersion.Version))
 				break
 			}
-		}
 
-		if version == "" {
-			errs = append(errs, fmt.Sprintf("unable to find group %q version %q", requiredGroup.Group, requiredGroup.Version))
-		} else if version != requiredGroup.Version {
-			errs = append(errs, fmt.Sprintf("unable to find group %q version %q, got %q", requiredGroup.Group, requiredGroup.Version, version))
+			*supported.installed = true
+
+			break
 		}
 	}
 
 	if len(errs) > 0 {
-		return errors.New(strings.Join(errs, "; "))
+		return SMIGroups{}, errors.New(strings.Join(errs, "; "))
 	}
 
-	return nil
+	return groups, nil
 }
@@ -0,0 +1,67 @@
+package k8s
+
+import (
+	"testing"
+
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	split "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/split/v1alpha3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetSMIGroups(t *testing.T) {
+	tests := []struct {
+		desc          string
+		groupVersions []string
+		want          SMIGroups
+		wantErr       bool
+	}{
+		{
+			desc: "no CRDs installed",
+		},
+		{
+			desc:          "only TrafficSplit CRDs installed",
+			groupVersions: []string{split.SchemeGroupVersion.String()},
+			want:          SMIGroups{Split: true},
+		},
+		{
+			desc:          "all CRDs installed",
+			groupVersions: []string{split.SchemeGroupVersion.String(), specs.SchemeGroupVersion.String(), access.SchemeGroupVersion.String()},
+			want:          SMIGroups{Access: true, Specs: true, Split: true},
+		},
+		{
+			desc:          "unsupported version",
+			groupVersions: []string{"split.smi-spec.io/v1alpha1", specs.SchemeGroupVersion.String()},
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := fakekubeclient.NewSimpleClientset()
+
+			discovery, ok := client.Discovery().(*fakediscovery.FakeDiscovery)
+			require.True(t, ok)
+
+			for _, groupVersion := range test.groupVersions {
+				discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{GroupVersion: groupVersion})
+			}
+
+			got, err := GetSMIGroups(client)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+// Package logfield holds the field keys used in structured logs.
+package logfield
+
+// Field keys used in structured logs.
+const (
+	Namespace = "namespace"
+	Service   = "service"
+	Provider  = "provider"
+)
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+)
+
+// configKeys holds the keys of the routers, services, middlewares and servers transports built for a Service.
+type configKeys struct {
+	httpRouters           []string
+	httpServices          []string
+	httpMiddlewares       []string
+	httpServersTransports []string
+	tcpRouters            []string
+	tcpServices           []string
+	udpRouters            []string
+	udpServices           []string
+}
+
+// mergeServiceConfig adds the routers, services, middlewares and servers transports of the given Service configuration
+// to the given configuration, and returns their keys.
+func mergeServiceConfig(cfg, svcCfg *dynamic.Configuration) configKeys {
+	var keys configKeys
+
+	for key, router := range svcCfg.HTTP.Routers {
+		cfg.HTTP.Routers[key] = router
+		keys.httpRouters = append(keys.httpRouters, key)
+	}
+
+	for key, service := range svcCfg.HTTP.Services {
+		cfg.HTTP.Services[key] = service
+		keys.httpServices = append(keys.httpServices, key)
+	}
+
+	for key, middleware := range svcCfg.HTTP.Middlewares {
+		cfg.HTTP.Middlewares[key] = middleware
+		keys.httpMiddlewares = append(keys.httpMiddlewares, key)
+	}
+
+	for key, serversTransport := range svcCfg.HTTP.ServersTransports {
+		if cfg.HTTP.ServersTransports == nil {
+			cfg.HTTP.ServersTransports = map[string]*dynamic.ServersTransport{}
+		}
+
+		cfg.HTTP.ServersTransports[key] = serversTransport
+		keys.httpServersTransports = append(keys.httpServersTransports, key)
+	}
+
+	if svcCfg.TCP != nil {
+		for key, router := range svcCfg.TCP.Routers {
+			addTCPRouter(cfg, key, router)
+			keys.tcpRouters = append(keys.tcpRouters, key)
+		}
+
+		for key, service := range svcCfg.TCP.Services {
+			addTCPService(cfg, key, service)
+			keys.tcpServices = append(keys.tcpServices, key)
+		}
+	}
+
+	if svcCfg.UDP != nil {
+		for key, router := range svcCfg.UDP.Routers {
+			addUDPRouter(cfg, key, router)
+			keys.udpRouters = append(keys.udpRouters, key)
+		}
+
+		for key, service := range svcCfg.UDP.Services {
+			addUDPService(cfg, key, service)
+			keys.udpServices = append(keys.udpServices, key)
+		}
+	}
+
+	return keys
+}
+
+// removeServiceConfig removes the routers, services, middlewares and servers transports with the given keys from the
+// given configuration.
+func removeServiceConfig(cfg *dynamic.Configuration, keys configKeys) {
+	for _, key := range keys.httpRouters {
+		delete(cfg.HTTP.Routers, key)
+	}
+
+	for _, key := range keys.httpServices {
+		delete(cfg.HTTP.Services, key)
+	}
+
+	for _, key := range keys.httpMiddlewares {
+		delete(cfg.HTTP.Middlewares, key)
+	}
+
+	for _, key := range keys.httpServersTransports {
+		delete(cfg.HTTP.ServersTransports, key)
+	}
+
+	if cfg.TCP != nil {
+		for _, key := range keys.tcpRouters {
+			delete(cfg.TCP.Routers, key)
+		}
+
+		for _, key := range keys.tcpServices {
+			delete(cfg.TCP.Services, key)
+		}
+	}
+
+	if cfg.UDP != nil {
+		for _, key := range keys.udpRouters {
+			delete(cfg.UDP.Routers, key)
+		}
+
+		for _, key := range keys.udpServices {
+			delete(cfg.UDP.Services, key)
+		}
+	}
+}
@@ -3,22 +3,64 @@ package provider
 import (
 	"fmt"
 
+	"github.com/traefik/mesh/v2/pkg/annotations"
 	"github.com/traefik/mesh/v2/pkg/topology"
 )
 
 const (
 	blockAllMiddlewareKey = "block-all-middleware"
 	blockAllServiceKey    = "block-all-service"
+
+	basicAuthMiddlewareName = "basic-auth"
+	rateLimitMiddlewareName = "rate-limit"
+
+	// rateLimitSourceHeader is the internal header holding the source identity used by the rate-limit middlewares of
+	// the sources, when the source header is disabled.
+	rateLimitSourceHeader                     = "X-Traefik-Mesh-Rate-Limit-Source"
+	rateLimitSourceHeaderCleanupMiddlewareKey = "rate-limit-source-header-cleanup-middleware"
 )
 
 func getMiddlewareKey(svc *topology.Service, name string) string {
 	return fmt.Sprintf("%s-%s-%s", svc.Namespace, svc.Name, name)
 }
 
+// getMiddlewareRefKey returns the key of a middleware defined with the Kubernetes CRD provider. Middlewares referenced
+// without a namespace are looked up in the namespace of the service.
+func getMiddlewareRefKey(svc *topology.Service, ref annotations.MiddlewareRef) string {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = svc.Namespace
+	}
+
+	return fmt.Sprintf("%s-%s@kubernetescrd", namespace, ref.Name)
+}
+
+func getServersTransportKey(svc *topology.Service) string {
+	return fmt.Sprintf("%s-%s", svc.Namespace, svc.Name)
+}
+
 func getServiceRouterKeyFromService(svc *topology.Service, port int32) string {
 	return fmt.Sprintf("%s-%s-%d", svc.Namespace, svc.Name, port)
 }
 
+func getMirroringServiceKeyFromService(svc *topology.Service, port int32) string {
+	return fmt.Sprintf("%s-%s-%d-mirroring", svc.Namespace, svc.Name, port)
+}
+
+func getMirrorServiceKeyFromService(svc *topology.Service, port int32) string {
+	return fmt.Sprintf("%s-%s-%d-mirror", svc.Namespace, svc.Name, port)
+}
+
+func getHeaderRouteServiceRouterKeyFromService(svc *topology.Service, port int32) string {
+	return fmt.Sprintf("%s-%s-%d-header-route", svc.Namespace, svc.Name, port)
+}
+
+// getZoneServiceKey returns the key of the variant of the service with the given key load-balancing between the pods
+// of the given zone only.
+func getZoneServiceKey(svcKey, zone string) string {
+	return fmt.Sprintf("%s-zone-%s", svcKey, zone)
+}
+
 func getWhitelistMiddlewareKeyFromTrafficTargetDirect(tt *topology.ServiceTrafficTarget) string {
 	return fmt.Sprintf("%s-%s-%s-whitelist-traffic-target-direct", tt.Service.Namespace, tt.Service.Name, tt.Name)
 }
@@ -35,6 +77,22 @@ func getWhitelistMiddlewareKeyFromTrafficSplitIndirect(ts *topology.TrafficSplit
 	return fmt.Sprintf("%s-%s-%s-whitelist-traffic-split-indirect", ts.Service.Namespace, ts.Service.Name, ts.Name)
 }
 
+func getWhitelistMiddlewareKeyFromTrafficTargetSourceDirect(tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource) string {
+	return fmt.Sprintf("%s-%s-%s-%s.%s-whitelist-traffic-target-source-direct", tt.Service.Namespace, tt.Service.Name, tt.Name, source.ServiceAccount, source.Namespace)
+}
+
+func getWhitelistMiddlewareKeyFromTrafficTargetSourceIndirect(tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource) string {
+	return fmt.Sprintf("%s-%s-%s-%s.%s-whitelist-traffic-target-source-indirect", tt.Service.Namespace, tt.Service.Name, tt.Name, source.ServiceAccount, source.Namespace)
+}
+
+func getSourceHeaderMiddlewareKeyFromTrafficTargetSource(tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource) string {
+	return fmt.Sprintf("%s-%s-%s-%s.%s-source-header-traffic-target-source", tt.Service.Namespace, tt.Service.Name, tt.Name, source.ServiceAccount, source.Namespace)
+}
+
+func getRateLimitMiddlewareKeyFromTrafficTargetSource(tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource) string {
+	return fmt.Sprintf("%s-%s-%s-%s.%s-rate-limit-traffic-target-source", tt.Service.Namespace, tt.Service.Name, tt.Name, source.ServiceAccount, source.Namespace)
+}
+
 func getServiceKeyFromTrafficTarget(tt *topology.ServiceTrafficTarget, port int32) string {
 	return fmt.Sprintf("%s-%s-%s-%d-traffic-target", tt.Service.Namespace, tt.Service.Name, tt.Name, port)
 }
@@ -47,6 +105,14 @@ func getRouterKeyFromTrafficTargetIndirect(tt *topology.ServiceTrafficTarget, po
 	return fmt.Sprintf("%s-%s-%s-%d-traffic-target-indirect", tt.Service.Namespace, tt.Service.Name, tt.Name, port)
 }
 
+func getRouterKeyFromTrafficTargetSourceDirect(tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource, port int32) string {
+	return fmt.Sprintf("%s-%s-%s-%d-%s.%s-traffic-target-source-direct", tt.Service.Namespace, tt.Service.Name, tt.Name, port, source.ServiceAccount, source.Namespace)
+}
+
+func getRouterKeyFromTrafficTargetSourceIndirect(tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource, port int32) string {
+	return fmt.Sprintf("%s-%s-%s-%d-%s.%s-traffic-target-source-indirect", tt.Service.Namespace, tt.Service.Name, tt.Name, port, source.ServiceAccount, source.Namespace)
+}
+
 func getServiceKeyFromTrafficSplit(ts *topology.TrafficSplit, port int32) string {
 	return fmt.Sprintf("%s-%s-%s-%d-traffic-split", ts.Service.Namespace, ts.Service.Name, ts.Name, port)
 }
@@ -60,5 +126,12 @@ func getRouterKeyFromTrafficSplitIndirect(ts *topology.TrafficSplit, port int32)
 }
 
 func getServiceKeyFromTrafficSplitBackend(ts *topology.TrafficSplit, port int32, backend topology.TrafficSplitBackend) string {
+	// Backends living in another namespace than the TrafficSplit may have the same name as a local one, so their key is
+	// qualified with their namespace. The "@" separator is reserved by Traefik for provider names, hence the
+	// "name.namespace" form which can't be ambiguous as neither part may contain a dot.
+	if backend.Service.Namespace != ts.Namespace {
+		return fmt.Sprintf("%s-%s-%s-%d-%s.%s-traffic-split-backend", ts.Service.Namespace, ts.Service.Name, ts.Name, port, backend.Service.Name, backend.Service.Namespace)
+	}
+
 	return fmt.Sprintf("%s-%s-%s-%d-%s-traffic-split-backend", ts.Service.Namespace, ts.Service.Name, ts.Name, port, backend.Service.Name)
 }
@@ -4,11 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/v2/pkg/annotations"
+	"github.com/traefik/mesh/v2/pkg/logfield"
 	"github.com/traefik/mesh/v2/pkg/topology"
+	ptypes "github.com/traefik/paerser/types"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
 	corev1 "k8s.io/api/core/v1"
 )
@@ -16,6 +20,9 @@ import (
 // MiddlewareBuilder is capable of building a middleware from service annotations.
 type MiddlewareBuilder func(annotations map[string]string) (map[string]*dynamic.Middleware, error)
 
+// BasicAuthUsersGetter returns the htpasswd users held by the secret with the given namespace and name.
+type BasicAuthUsersGetter func(namespace, name string) ([]string, error)
+
 // PortFinder finds service port mappings.
 type PortFinder interface {
 	Find(namespace, name string, port int32) (int32, bool)
@@ -29,17 +36,36 @@ type PortFinder interface {
 // - When a TrafficTarget Destination targets pods of a k8s service and a TrafficSplit is set on this service. This
 //   creates 2 Traefik Routers. One for the TrafficSplit and one for the TrafficTarget. We should always prioritize
 //   TrafficSplits Routers and TrafficSplit Routers should always have a higher priority than TrafficTarget Routers.
+// - When a header-route annotation is set on a k8s service, the requests carrying the header must reach the service
+//   defined by the annotation, whatever the other routers of the service. Therefore, header-route Routers have the
+//   highest priority.
+//
+// These priorities are priority classes: the priority of a router is computed from its class and the specificity of
+// its rule by getRulePriority, so that the most specific router of a class wins, whatever the build order.
 const (
 	priorityService = iota + 1
 	priorityTrafficTargetDirect
 	priorityTrafficTargetIndirect
 	priorityTrafficSplit
+	priorityHeaderRoute
 )
 
 // Config holds the Provider configuration.
 type Config struct {
 	ACL                bool
 	DefaultTrafficType string
+	// ACLSourceHeader is the name of the request header set with the identity of the TrafficTarget source which made
+	// the request, when ACL mode is enabled. No header is set if empty.
+	ACLSourceHeader string
+	// ACLDefaultAllow makes the Services which are not the destination of any TrafficTarget reachable by all the
+	// clients when ACL mode is enabled. Otherwise, their traffic is forbidden.
+	ACLDefaultAllow bool
+	// NoShadowService disables the shadow services: the TrafficSplit backends and mirror services are reached through
+	// their ClusterIP instead of their shadow service.
+	NoShadowService bool
+	// DefaultMiddlewares are the middlewares referenced by the routers of every HTTP service, unless disabled by its
+	// default-middlewares annotation. References without a namespace are resolved in the namespace of the service.
+	DefaultMiddlewares []annotations.MiddlewareRef
 }
 
 // Provider holds the configuration for generating dynamic configuration from a kubernetes cluster state.
@@ -50,12 +76,17 @@ type Provider struct {
 	tcpStateTable          PortFinder
 	udpStateTable          PortFinder
 	buildServiceMiddleware MiddlewareBuilder
+	getBasicAuthUsers      BasicAuthUsersGetter
+
+	// serviceConfigKeys indexes the keys of the routers, services and middlewares built for each Service by the last
+	// configuration build, so that the configuration of a single Service can be updated afterwards.
+	serviceConfigKeys map[topology.Key]configKeys
 
 	logger logrus.FieldLogger
 }
 
 // New creates a new Provider.
-func New(httpStateTable, tcpStateTable, udpStateTable PortFinder, middlewareBuilder MiddlewareBuilder, cfg Config, logger logrus.FieldLogger) *Provider {
+func New(httpStateTable, tcpStateTable, udpStateTable PortFinder, middlewareBuilder MiddlewareBuilder, basicAuthUsersGetter BasicAuthUsersGetter, cfg Config, logger logrus.FieldLogger) *Provider {
 	return &Provider{
 		config:                 cfg,
 		httpStateTable:         httpStateTable,
@@ -63,9 +94,16 @@ func New(httpStateTable, tcpStateTable, udpStateTable PortFinder, middlewareBuil
 		udpStateTable:          udpStateTable,
 		logger:                 logger,
 		buildServiceMiddleware: middlewareBuilder,
+		getBasicAuthUsers:      basicAuthUsersGetter,
 	}
 }
 
+// SetACLDefaultAllow sets whether the Services which are not the destination of any TrafficTarget are reachable by all
+// the clients in ACL mode. It applies to the next configuration builds.
+func (p *Provider) SetACLDefaultAllow(allow bool) {
+	p.config.ACLDefaultAllow = allow
+}
+
 // NewDefaultDynamicConfig creates and returns the minimal working dynamic configuration which should be propagated
 // to proxy nodes.
 func NewDefaultDynamicConfig() *dynamic.Configuration {
@@ -106,21 +144,79 @@ func NewDefaultDynamicConfig() *dynamic.Configuration {
 	}
 }
 
-// BuildConfig builds a dynamic configuration.
+// BuildConfig builds a dynamic configuration from scratch, nothing is kept from the previous builds. The routers,
+// services and middlewares of the Services which are not part of the given topology are therefore never published.
 func (p *Provider) BuildConfig(t *topology.Topology) *dynamic.Configuration {
 	cfg := NewDefaultDynamicConfig()
 
+	p.serviceConfigKeys = make(map[topology.Key]configKeys, len(t.Services))
+
 	for svcKey, svc := range t.Services {
-		if err := p.buildConfigForService(t, cfg, svc); err != nil {
-			err = fmt.Errorf("unable to build configuration: %w", err)
-			svc.AddError(err)
-			p.logger.Errorf("Error building dynamic configuration for Service %q: %v", svcKey, err)
-		}
+		p.serviceConfigKeys[svcKey] = mergeServiceConfig(cfg, p.buildServiceConfig(t, svc))
 	}
 
 	return cfg
 }
 
+// UpdateServiceConfig rebuilds, in the given dynamic configuration, the routers, services and middlewares of the
+// Service with the given key. The configuration must have been returned by the last BuildConfig call, possibly updated
+// by UpdateServiceConfig since. The configuration of the other Services is left untouched, except for the Services
+// which are not part of the given topology anymore whose configuration is removed.
+func (p *Provider) UpdateServiceConfig(t *topology.Topology, cfg *dynamic.Configuration, svcKey topology.Key) error {
+	keys, ok := p.serviceConfigKeys[svcKey]
+	if !ok {
+		return fmt.Errorf("no configuration has been built for Service %q", svcKey)
+	}
+
+	svc, ok := t.Services[svcKey]
+	if !ok {
+		return fmt.Errorf("unable to find Service %q", svcKey)
+	}
+
+	p.pruneServiceConfigs(t, cfg)
+
+	removeServiceConfig(cfg, keys)
+
+	p.serviceConfigKeys[svcKey] = mergeServiceConfig(cfg, p.buildServiceConfig(t, svc))
+
+	return nil
+}
+
+// pruneServiceConfigs removes, from the given dynamic configuration, the routers, services and middlewares of the
+// Services which are not part of the given topology anymore, so that they don't keep routing to dead backends.
+func (p *Provider) pruneServiceConfigs(t *topology.Topology, cfg *dynamic.Configuration) {
+	for svcKey, keys := range p.serviceConfigKeys {
+		if _, ok := t.Services[svcKey]; ok {
+			continue
+		}
+
+		removeServiceConfig(cfg, keys)
+		delete(p.serviceConfigKeys, svcKey)
+	}
+}
+
+// buildServiceConfig builds the dynamic configuration of the given service only.
+func (p *Provider) buildServiceConfig(t *topology.Topology, svc *topology.Service) *dynamic.Configuration {
+	svcCfg := &dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers:     map[string]*dynamic.Router{},
+			Services:    map[string]*dynamic.Service{},
+			Middlewares: map[string]*dynamic.Middleware{},
+		},
+	}
+
+	if err := p.buildConfigForService(t, svcCfg, svc); err != nil {
+		err = fmt.Errorf("unable to build configuration: %w", err)
+		svc.AddError(err)
+		p.logger.WithFields(logrus.Fields{
+			logfield.Namespace: svc.Namespace,
+			logfield.Service:   svc.Name,
+		}).Errorf("Error building dynamic configuration for Service: %v", err)
+	}
+
+	return svcCfg
+}
+
 // buildConfigForService builds the dynamic configuration for the given service.
 func (p *Provider) buildConfigForService(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service) error {
 	trafficType, err := annotations.GetTrafficType(svc.Annotations)
@@ -139,8 +235,12 @@ func (p *Provider) buildConfigForService(t *topology.Topology, cfg *dynamic.Conf
 
 	var middlewareKeys []string
 
-	// Middlewares are currently supported only for HTTP services.
+	// Middlewares and router rules are currently supported only for HTTP services.
 	if trafficType == annotations.ServiceTypeHTTP {
+		if _, err = annotations.GetRouterRule(svc.Annotations); err != nil && !errors.Is(err, annotations.ErrNotFound) {
+			return fmt.Errorf("unable to evaluate router-rule annotation: %w", err)
+		}
+
 		middlewareKeys, err = p.buildMiddlewaresForConfigFromService(cfg, svc)
 		if err != nil {
 			return err
@@ -164,6 +264,10 @@ func (p *Provider) buildConfigForService(t *topology.Topology, cfg *dynamic.Conf
 		}
 	}
 
+	if err = p.buildServiceAndRoutersForAnnotationTrafficSplit(t, cfg, svc, scheme, trafficType, middlewareKeys); err != nil {
+		return fmt.Errorf("unable to build traffic split from annotations: %w", err)
+	}
+
 	return nil
 }
 
@@ -175,6 +279,19 @@ func (p *Provider) buildMiddlewaresForConfigFromService(cfg *dynamic.Configurati
 		return middlewareKeys, fmt.Errorf("unable to build middlewares: %w", err)
 	}
 
+	basicAuth, err := p.buildBasicAuthMiddleware(svc)
+	if err != nil {
+		return middlewareKeys, fmt.Errorf("unable to build middlewares: %w", err)
+	}
+
+	if basicAuth != nil {
+		if middlewares == nil {
+			middlewares = map[string]*dynamic.Middleware{}
+		}
+
+		middlewares[basicAuthMiddlewareName] = basicAuth
+	}
+
 	for name, middleware := range middlewares {
 		middlewareKey := getMiddlewareKey(svc, name)
 		cfg.HTTP.Middlewares[middlewareKey] = middleware
@@ -182,9 +299,64 @@ func (p *Provider) buildMiddlewaresForConfigFromService(cfg *dynamic.Configurati
 		middlewareKeys = append(middlewareKeys, middlewareKey)
 	}
 
+	// Middlewares are applied in the order of the router middleware list, which must be the same on every build.
+	sort.Strings(middlewareKeys)
+
+	// Referenced middlewares are applied after the ones built from annotations, in the order they are defined.
+	refs, err := annotations.GetMiddlewares(svc.Annotations)
+	if err != nil && !errors.Is(err, annotations.ErrNotFound) {
+		return middlewareKeys, fmt.Errorf("unable to build middlewares: %w", err)
+	}
+
+	for _, ref := range refs {
+		middlewareKeys = append(middlewareKeys, getMiddlewareRefKey(svc, ref))
+	}
+
+	// Default middlewares are applied last, unless the service already references them.
+	defaultMiddlewares, err := annotations.IsDefaultMiddlewares(svc.Annotations)
+	if err != nil {
+		return middlewareKeys, fmt.Errorf("unable to build middlewares: %w", err)
+	}
+
+	if !defaultMiddlewares {
+		return middlewareKeys, nil
+	}
+
+	for _, ref := range p.config.DefaultMiddlewares {
+		if key := getMiddlewareRefKey(svc, ref); !containsString(middlewareKeys, key) {
+			middlewareKeys = append(middlewareKeys, key)
+		}
+	}
+
 	return middlewareKeys, nil
 }
 
+// buildBasicAuthMiddleware builds the basic-auth middleware of the given service with the users of the secret
+// referenced by its basic-auth-secret annotation. It returns nil if the annotation is not set.
+func (p *Provider) buildBasicAuthMiddleware(svc *topology.Service) (*dynamic.Middleware, error) {
+	secret, err := annotations.GetBasicAuthSecret(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to build basic-auth middleware: %w", err)
+	}
+
+	if p.getBasicAuthUsers == nil {
+		return nil, errors.New("unable to build basic-auth middleware: secrets are not available")
+	}
+
+	users, err := p.getBasicAuthUsers(svc.Namespace, secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build basic-auth middleware: %w", err)
+	}
+
+	return &dynamic.Middleware{
+		BasicAuth: &dynamic.BasicAuth{Users: users},
+	}, nil
+}
+
 func (p *Provider) buildConfigRoutersAndServices(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, scheme, trafficType string, middlewareKeys []string) error {
 	err := p.buildServicesAndRoutersForService(t, cfg, svc, scheme, trafficType, middlewareKeys)
 	if err != nil {
@@ -199,6 +371,29 @@ func (p *Provider) buildACLConfigRoutersAndServices(t *topology.Topology, cfg *d
 		p.buildBlockAllRouters(cfg, svc)
 	}
 
+	// Mirrored requests would have to be authorized by a TrafficTarget of the mirror service, whose sources are the
+	// proxies rather than the original clients.
+	if _, err := annotations.GetMirror(svc.Annotations); !errors.Is(err, annotations.ErrNotFound) {
+		svc.AddError(errors.New("mirror-service annotation is not supported in ACL mode"))
+		p.logger.Errorf("Error building dynamic configuration for Service %q: mirror-service annotation is not supported in ACL mode", topology.Key{Name: svc.Name, Namespace: svc.Namespace})
+	}
+
+	// Routing requests to another service based on a header would bypass the TrafficTargets of the annotated service.
+	if _, err := annotations.GetHeaderRoute(svc.Annotations); !errors.Is(err, annotations.ErrNotFound) {
+		svc.AddError(errors.New("header-route annotation is not supported in ACL mode"))
+		p.logger.Errorf("Error building dynamic configuration for Service %q: header-route annotation is not supported in ACL mode", topology.Key{Name: svc.Name, Namespace: svc.Namespace})
+	}
+
+	// Services no TrafficTarget applies to are not subject to access control in default-allow mode.
+	if p.config.ACLDefaultAllow && len(svc.TrafficTargets) == 0 {
+		if err := p.buildConfigRoutersAndServices(t, cfg, svc, scheme, trafficType, middlewareKeys); err != nil {
+			svc.AddError(err)
+			p.logger.Errorf("Error building dynamic configuration for Service %q: %v", topology.Key{Name: svc.Name, Namespace: svc.Namespace}, err)
+		}
+
+		return
+	}
+
 	for _, ttKey := range svc.TrafficTargets {
 		if err := p.buildServicesAndRoutersForTrafficTarget(t, cfg, ttKey, scheme, trafficType, middlewareKeys); err != nil {
 			err = fmt.Errorf("unable to build routers and services: %w", err)
@@ -231,6 +426,51 @@ func (p *Provider) buildServicesAndRoutersForService(t *topology.Topology, cfg *
 }
 
 func (p *Provider) buildServicesAndRoutersForHTTPService(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, scheme string, middlewares []string, svcKey topology.Key) {
+	lbOpts, err := buildLoadBalancerOptionsFromService(svc)
+	if err != nil {
+		err = fmt.Errorf("unable to build load-balancer options: %w", err)
+		svc.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for Service %q: %v", svcKey, err)
+
+		return
+	}
+
+	mirror, err := buildMirrorFromService(t, svc)
+	if err != nil {
+		err = fmt.Errorf("unable to build mirror: %w", err)
+		svc.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for Service %q: %v", svcKey, err)
+
+		return
+	}
+
+	headerRoute, err := buildHeaderRouteFromService(t, svc)
+	if err != nil {
+		err = fmt.Errorf("unable to build header route: %w", err)
+		svc.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for Service %q: %v", svcKey, err)
+
+		return
+	}
+
+	extraEntryPoints, err := buildEntryPointsFromService(svc)
+	if err != nil {
+		err = fmt.Errorf("unable to build entrypoints: %w", err)
+		svc.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for Service %q: %v", svcKey, err)
+
+		return
+	}
+
+	zoneAware, err := annotations.IsZoneAware(svc.Annotations)
+	if err != nil {
+		err = fmt.Errorf("unable to evaluate zone-aware annotation: %w", err)
+		svc.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for Service %q: %v", svcKey, err)
+
+		return
+	}
+
 	httpRule := buildHTTPRuleFromService(svc)
 
 	for _, svcPort := range svc.Ports {
@@ -245,13 +485,178 @@ func (p *Provider) buildServicesAndRoutersForHTTPService(t *topology.Topology, c
 
 		key := getServiceRouterKeyFromService(svc, svcPort.Port)
 
-		cfg.HTTP.Services[key] = p.buildHTTPServiceFromService(t, svc, scheme, svcPort)
-		cfg.HTTP.Routers[key] = buildHTTPRouter(httpRule, entrypoint, middlewares, key, priorityService)
+		httpSvc := p.buildHTTPServiceFromService(t, svc, scheme, svcPort)
+		lbOpts.apply(cfg, httpSvc.LoadBalancer)
+
+		cfg.HTTP.Services[key] = httpSvc
+
+		if zoneAware {
+			for zone, zoneSvc := range p.buildHTTPZoneServicesFromService(t, svc, scheme, svcPort) {
+				lbOpts.apply(cfg, zoneSvc.LoadBalancer)
+
+				cfg.HTTP.Services[getZoneServiceKey(key, zone)] = zoneSvc
+			}
+		}
+
+		rtrSvcKey := key
+
+		if mirror != nil {
+			mirrorSvcKey := getMirrorServiceKeyFromService(svc, svcPort.Port)
+			cfg.HTTP.Services[mirrorSvcKey] = buildHTTPMirrorService(p.getServiceHost(t, mirror.service), scheme, svcPort.Port)
+
+			rtrSvcKey = getMirroringServiceKeyFromService(svc, svcPort.Port)
+			cfg.HTTP.Services[rtrSvcKey] = buildHTTPMirroringService(key, mirrorSvcKey, mirror.percent)
+		}
+
+		router := buildHTTPRouter(httpRule, entrypoint, middlewares, rtrSvcKey, priorityService)
+		router.EntryPoints = append(router.EntryPoints, extraEntryPoints[svcPort.Port]...)
+
+		cfg.HTTP.Routers[key] = router
+
+		if headerRoute != nil {
+			headerRouteKey := getHeaderRouteServiceRouterKeyFromService(svc, svcPort.Port)
+			cfg.HTTP.Services[headerRouteKey] = buildHTTPHeaderRouteService(p.getServiceHost(t, headerRoute.service), scheme, svcPort.Port)
+
+			headerRouteRule := buildHTTPRuleFromHeaderRoute(svc, headerRoute.header, headerRoute.value)
+
+			headerRouter := buildHTTPRouter(headerRouteRule, entrypoint, middlewares, headerRouteKey, priorityHeaderRoute)
+			headerRouter.EntryPoints = append(headerRouter.EntryPoints, extraEntryPoints[svcPort.Port]...)
+
+			cfg.HTTP.Routers[headerRouteKey] = headerRouter
+		}
+	}
+}
+
+// buildEntryPointsFromService returns the entrypoints the routers of the given service are attached to in addition to
+// the mesh entrypoint of their port, indexed by service port.
+func buildEntryPointsFromService(svc *topology.Service) (map[int32][]string, error) {
+	entryPoints, err := annotations.GetEntryPoints(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for port := range entryPoints {
+		if !hasPort(svc, port) {
+			return nil, fmt.Errorf("port %d is not exposed by the service", port)
+		}
+	}
+
+	return entryPoints, nil
+}
+
+// mirror is the service the requests of an HTTP service are mirrored to.
+type mirror struct {
+	service topology.Key
+	percent int
+}
+
+// buildMirrorFromService returns the mirror defined with the mirror-service annotation of the given service, nil if
+// the annotation is not set. The mirror service must exist and expose all the ports of the given service.
+func buildMirrorFromService(t *topology.Topology, svc *topology.Service) (*mirror, error) {
+	m, err := annotations.GetMirror(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	mirrorSvcKey := topology.Key{Name: m.Service, Namespace: m.Namespace}
+	if mirrorSvcKey.Namespace == "" {
+		mirrorSvcKey.Namespace = svc.Namespace
+	}
+
+	if mirrorSvcKey.Name == svc.Name && mirrorSvcKey.Namespace == svc.Namespace {
+		return nil, errors.New("a service can't be mirrored to itself")
+	}
+
+	mirrorSvc, ok := t.Services[mirrorSvcKey]
+	if !ok {
+		return nil, fmt.Errorf("unable to find mirror Service %q", mirrorSvcKey)
+	}
+
+	for _, svcPort := range svc.Ports {
+		if !hasPort(mirrorSvc, svcPort.Port) {
+			return nil, fmt.Errorf("mirror Service %q has no port %d", mirrorSvcKey, svcPort.Port)
+		}
+	}
+
+	return &mirror{
+		service: mirrorSvcKey,
+		percent: m.Percent,
+	}, nil
+}
+
+// headerRoute is the service the requests of an HTTP service carrying a header value are routed to.
+type headerRoute struct {
+	header  string
+	value   string
+	service topology.Key
+}
+
+// buildHeaderRouteFromService returns the header route defined with the header-route annotation of the given service,
+// nil if the annotation is not set. The routed service must exist and expose all the ports of the given service.
+func buildHeaderRouteFromService(t *topology.Topology, svc *topology.Service) (*headerRoute, error) {
+	r, err := annotations.GetHeaderRoute(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	routeSvcKey := topology.Key{Name: r.Service, Namespace: r.Namespace}
+	if routeSvcKey.Namespace == "" {
+		routeSvcKey.Namespace = svc.Namespace
+	}
+
+	if routeSvcKey.Name == svc.Name && routeSvcKey.Namespace == svc.Namespace {
+		return nil, errors.New("a service can't route requests to itself")
 	}
+
+	routeSvc, ok := t.Services[routeSvcKey]
+	if !ok {
+		return nil, fmt.Errorf("unable to find header route Service %q", routeSvcKey)
+	}
+
+	for _, svcPort := range svc.Ports {
+		if !hasPort(routeSvc, svcPort.Port) {
+			return nil, fmt.Errorf("header route Service %q has no port %d", routeSvcKey, svcPort.Port)
+		}
+	}
+
+	return &headerRoute{
+		header:  r.Header,
+		value:   r.Value,
+		service: routeSvcKey,
+	}, nil
+}
+
+func hasPort(svc *topology.Service, port int32) bool {
+	for _, svcPort := range svc.Ports {
+		if svcPort.Port == port {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (p *Provider) buildServicesAndRoutersForTCPService(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, svcKey topology.Key) {
-	rule := buildTCPRouterRule()
+	rule, tls, err := buildTCPRouterRuleAndTLSFromService(svc)
+	if err != nil {
+		err = fmt.Errorf("unable to build TCP router rule: %w", err)
+		svc.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for Service %q: %v", svcKey, err)
+
+		return
+	}
 
 	for _, svcPort := range svc.Ports {
 		entrypoint, err := p.buildTCPEntrypoint(svc, svcPort.Port)
@@ -266,7 +671,7 @@ func (p *Provider) buildServicesAndRoutersForTCPService(t *topology.Topology, cf
 		key := getServiceRouterKeyFromService(svc, svcPort.Port)
 
 		addTCPService(cfg, key, p.buildTCPServiceFromService(t, svc, svcPort))
-		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, key))
+		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, key, tls))
 	}
 }
 
@@ -317,11 +722,23 @@ func (p *Provider) buildHTTPServicesAndRoutersForTrafficTarget(t *topology.Topol
 		return
 	}
 
+	lbOpts, err := buildLoadBalancerOptionsFromService(ttSvc)
+	if err != nil {
+		err = fmt.Errorf("unable to build load-balancer options: %w", err)
+		tt.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for TrafficTarget %q: %v", ttKey, err)
+
+		return
+	}
+
 	whitelistDirect := p.buildWhitelistMiddlewareFromTrafficTargetDirect(t, tt)
 	whitelistDirectKey := getWhitelistMiddlewareKeyFromTrafficTargetDirect(tt)
 	cfg.HTTP.Middlewares[whitelistDirectKey] = whitelistDirect
 
 	rule := buildHTTPRuleFromTrafficTarget(tt, ttSvc)
+	indirectRule := buildHTTPRuleFromTrafficTargetIndirect(tt, ttSvc)
+
+	sourceRoutings := p.buildSourceRoutingsFromTrafficTarget(t, tt, cfg, ttSvc, rule, indirectRule, middlewares)
 
 	for _, svcPort := range tt.Destination.Ports {
 		entrypoint, err := p.buildHTTPEntrypoint(ttSvc, svcPort.Port)
@@ -334,13 +751,21 @@ func (p *Provider) buildHTTPServicesAndRoutersForTrafficTarget(t *topology.Topol
 		}
 
 		svcKey := getServiceKeyFromTrafficTarget(tt, svcPort.Port)
-		cfg.HTTP.Services[svcKey] = p.buildHTTPServiceFromTrafficTarget(t, tt, scheme, svcPort)
+		httpSvc := p.buildHTTPServiceFromTrafficTarget(t, tt, scheme, svcPort)
+		lbOpts.apply(cfg, httpSvc.LoadBalancer)
+
+		cfg.HTTP.Services[svcKey] = httpSvc
 
 		rtrMiddlewares := addToSliceCopy(middlewares, whitelistDirectKey)
 
 		directRtrKey := getRouterKeyFromTrafficTargetDirect(tt, svcPort.Port)
 		cfg.HTTP.Routers[directRtrKey] = buildHTTPRouter(rule, entrypoint, rtrMiddlewares, svcKey, priorityTrafficTargetDirect)
 
+		for _, routing := range sourceRoutings {
+			rtrKey := getRouterKeyFromTrafficTargetSourceDirect(tt, routing.source, svcPort.Port)
+			cfg.HTTP.Routers[rtrKey] = buildHTTPRouter(routing.directRule, entrypoint, routing.directMiddlewares, svcKey, priorityTrafficTargetDirect)
+		}
+
 		// If the ServiceTrafficTarget is the backend of at least one TrafficSplit we need an additional router with
 		// a whitelist middleware which whitelists based on the X-Forwarded-For header instead of on the RemoteAddr value.
 		if len(ttSvc.BackendOf) > 0 {
@@ -348,21 +773,144 @@ func (p *Provider) buildHTTPServicesAndRoutersForTrafficTarget(t *topology.Topol
 			whitelistIndirectKey := getWhitelistMiddlewareKeyFromTrafficTargetIndirect(tt)
 			cfg.HTTP.Middlewares[whitelistIndirectKey] = whitelistIndirect
 
-			rule = buildHTTPRuleFromTrafficTargetIndirect(tt, ttSvc)
 			rtrMiddlewares = addToSliceCopy(middlewares, whitelistIndirectKey)
 
 			indirectRtrKey := getRouterKeyFromTrafficTargetIndirect(tt, svcPort.Port)
-			cfg.HTTP.Routers[indirectRtrKey] = buildHTTPRouter(rule, entrypoint, rtrMiddlewares, svcKey, priorityTrafficTargetIndirect)
+			cfg.HTTP.Routers[indirectRtrKey] = buildHTTPRouter(indirectRule, entrypoint, rtrMiddlewares, svcKey, priorityTrafficTargetIndirect)
+
+			for _, routing := range sourceRoutings {
+				rtrKey := getRouterKeyFromTrafficTargetSourceIndirect(tt, routing.source, svcPort.Port)
+				cfg.HTTP.Routers[rtrKey] = buildHTTPRouter(routing.indirectRule, entrypoint, routing.indirectMiddlewares, svcKey, priorityTrafficTargetIndirect)
+			}
 		}
 	}
 }
 
+// sourceRouting holds the rules and the middlewares of the routers dedicated to a source of a ServiceTrafficTarget.
+type sourceRouting struct {
+	source              *topology.ServiceTrafficTargetSource
+	directRule          string
+	directMiddlewares   []string
+	indirectRule        string
+	indirectMiddlewares []string
+}
+
+// buildSourceRoutingsFromTrafficTarget builds the routing of each source of the given ServiceTrafficTarget when the
+// source header is enabled or the Service is rate-limited. The routers of a source only match the requests of its
+// Pods, and have a higher priority than the routers of the ServiceTrafficTarget as their rule has more conditions.
+// Once authorized by the whitelist of the source, a request gets the source header set to the identity of this source
+// and is rate-limited with the other requests of this source only. Sources without Pods are skipped.
+func (p *Provider) buildSourceRoutingsFromTrafficTarget(t *topology.Topology, tt *topology.ServiceTrafficTarget, cfg *dynamic.Configuration, ttSvc *topology.Service, rule, indirectRule string, middlewares []string) []sourceRouting {
+	// The per-IP rate-limit middleware of the Service, if any, is replaced by one per source limiting its identity as
+	// a whole, so that the sources don't consume each other's quota whatever the TrafficTarget authorizing them.
+	var svcRateLimit *dynamic.RateLimit
+
+	svcRateLimitKey := getMiddlewareKey(ttSvc, rateLimitMiddlewareName)
+	if middleware, ok := cfg.HTTP.Middlewares[svcRateLimitKey]; ok && middleware.RateLimit != nil && containsString(middlewares, svcRateLimitKey) {
+		svcRateLimit = middleware.RateLimit
+		middlewares = removeFromSliceCopy(middlewares, svcRateLimitKey)
+	}
+
+	if p.config.ACLSourceHeader == "" && svcRateLimit == nil {
+		return nil
+	}
+
+	var routings []sourceRouting
+
+	for i := range tt.Sources {
+		source := &tt.Sources[i]
+
+		IPs := p.getTrafficTargetSourceIPs(t, tt, source)
+		if len(IPs) == 0 {
+			continue
+		}
+
+		sourceMiddlewares := p.buildSourceMiddlewaresFromTrafficTargetSource(tt, source, cfg, svcRateLimit)
+
+		whitelistDirectKey := getWhitelistMiddlewareKeyFromTrafficTargetSourceDirect(tt, source)
+		cfg.HTTP.Middlewares[whitelistDirectKey] = &dynamic.Middleware{
+			IPWhiteList: &dynamic.IPWhiteList{
+				SourceRange: IPs,
+			},
+		}
+
+		routing := sourceRouting{
+			source:            source,
+			directRule:        buildHTTPRuleFromTrafficTargetSourceDirect(rule, IPs),
+			directMiddlewares: append(addToSliceCopy(middlewares, whitelistDirectKey), sourceMiddlewares...),
+		}
+
+		if len(ttSvc.BackendOf) > 0 {
+			whitelistIndirectKey := getWhitelistMiddlewareKeyFromTrafficTargetSourceIndirect(tt, source)
+			cfg.HTTP.Middlewares[whitelistIndirectKey] = &dynamic.Middleware{
+				IPWhiteList: &dynamic.IPWhiteList{
+					SourceRange: IPs,
+					IPStrategy: &dynamic.IPStrategy{
+						Depth: 1,
+					},
+				},
+			}
+
+			routing.indirectRule = buildHTTPRuleFromTrafficTargetSourceIndirect(indirectRule, IPs)
+			routing.indirectMiddlewares = append(addToSliceCopy(middlewares, whitelistIndirectKey), sourceMiddlewares...)
+		}
+
+		routings = append(routings, routing)
+	}
+
+	return routings
+}
+
+// buildSourceMiddlewaresFromTrafficTargetSource builds the middlewares applied to the requests of the given source
+// once they are authorized, and returns their keys in order. The source header holds the identity of the source, and
+// the rate-limit of the source, if any, groups its requests by this header. When the source header is disabled, an
+// internal header is set for the rate-limit only and removed right after it.
+func (p *Provider) buildSourceMiddlewaresFromTrafficTargetSource(tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource, cfg *dynamic.Configuration, svcRateLimit *dynamic.RateLimit) []string {
+	header := p.config.ACLSourceHeader
+	if header == "" {
+		header = rateLimitSourceHeader
+	}
+
+	sourceHeaderKey := getSourceHeaderMiddlewareKeyFromTrafficTargetSource(tt, source)
+	cfg.HTTP.Middlewares[sourceHeaderKey] = buildSourceHeaderMiddlewareFromTrafficTargetSource(header, source)
+
+	keys := []string{sourceHeaderKey}
+
+	if svcRateLimit != nil {
+		rateLimitKey := getRateLimitMiddlewareKeyFromTrafficTargetSource(tt, source)
+		cfg.HTTP.Middlewares[rateLimitKey] = buildRateLimitMiddlewareFromTrafficTargetSource(svcRateLimit, header)
+
+		keys = append(keys, rateLimitKey)
+	}
+
+	if header == rateLimitSourceHeader {
+		cfg.HTTP.Middlewares[rateLimitSourceHeaderCleanupMiddlewareKey] = &dynamic.Middleware{
+			Headers: &dynamic.Headers{
+				CustomRequestHeaders: map[string]string{
+					rateLimitSourceHeader: "",
+				},
+			},
+		}
+
+		keys = append(keys, rateLimitSourceHeaderCleanupMiddlewareKey)
+	}
+
+	return keys
+}
+
 func (p *Provider) buildTCPServicesAndRoutersForTrafficTarget(t *topology.Topology, tt *topology.ServiceTrafficTarget, cfg *dynamic.Configuration, ttSvc *topology.Service, ttKey topology.ServiceTrafficTargetKey) {
 	if !hasTrafficTargetRuleTCPRoute(tt) {
 		return
 	}
 
-	rule := buildTCPRouterRule()
+	rule, tls, err := buildTCPRouterRuleAndTLSFromService(ttSvc)
+	if err != nil {
+		err = fmt.Errorf("unable to build TCP router rule: %w", err)
+		tt.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for TrafficTarget %q: %v", ttKey, err)
+
+		return
+	}
 
 	for _, svcPort := range tt.Destination.Ports {
 		entrypoint, err := p.buildTCPEntrypoint(ttSvc, svcPort.Port)
@@ -377,7 +925,7 @@ func (p *Provider) buildTCPServicesAndRoutersForTrafficTarget(t *topology.Topolo
 		key := getServiceRouterKeyFromService(ttSvc, svcPort.Port)
 
 		addTCPService(cfg, key, p.buildTCPServiceFromTrafficTarget(t, tt, svcPort))
-		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, key))
+		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, key, tls))
 	}
 }
 
@@ -387,20 +935,82 @@ func (p *Provider) buildServiceAndRoutersForTrafficSplit(t *topology.Topology, c
 		return fmt.Errorf("unable to find TrafficSplit %q", tsKey)
 	}
 
+	return p.buildServiceAndRoutersFromTrafficSplit(t, cfg, tsKey, ts, scheme, trafficType, middlewares)
+}
+
+// buildServiceAndRoutersForAnnotationTrafficSplit builds the services and routers of the traffic split defined with
+// the traffic-split-backends annotation of the given service, if any. The resulting configuration is the same as the
+// one of an SMI TrafficSplit named after the service.
+func (p *Provider) buildServiceAndRoutersForAnnotationTrafficSplit(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, scheme, trafficType string, middlewares []string) error {
+	backends, err := annotations.GetTrafficSplitBackends(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to evaluate traffic-split-backends annotation: %w", err)
+	}
+
+	if len(svc.TrafficSplits) > 0 {
+		return errors.New("traffic-split-backends annotation can't be used on a service targeted by a TrafficSplit")
+	}
+
+	// Authorized sources of a TrafficSplit are computed from TrafficTargets by the topology builder, which is not
+	// aware of annotation based traffic splits.
+	if p.config.ACL {
+		return errors.New("traffic-split-backends annotation is not supported in ACL mode")
+	}
+
+	ts := &topology.TrafficSplit{
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+		Service:   topology.Key{Name: svc.Name, Namespace: svc.Namespace},
+	}
+
+	for _, backend := range backends {
+		backendSvcKey := topology.Key{Name: backend.Service, Namespace: backend.Namespace}
+		if backendSvcKey.Namespace == "" {
+			backendSvcKey.Namespace = svc.Namespace
+		}
+		if _, ok := t.Services[backendSvcKey]; !ok {
+			return fmt.Errorf("unable to find backend Service %q", backendSvcKey)
+		}
+
+		ts.Backends = append(ts.Backends, topology.TrafficSplitBackend{
+			Weight:  backend.Weight,
+			Service: backendSvcKey,
+		})
+	}
+
+	tsKey := topology.Key{Name: ts.Name, Namespace: ts.Namespace}
+	err = p.buildServiceAndRoutersFromTrafficSplit(t, cfg, tsKey, ts, scheme, trafficType, middlewares)
+
+	for _, tsErr := range ts.Errors {
+		svc.AddError(errors.New(tsErr))
+	}
+
+	return err
+}
+
+func (p *Provider) buildServiceAndRoutersFromTrafficSplit(t *topology.Topology, cfg *dynamic.Configuration, tsKey topology.Key, ts *topology.TrafficSplit, scheme, trafficType string, middlewares []string) error {
 	tsSvc, ok := t.Services[ts.Service]
 	if !ok {
 		return fmt.Errorf("unable to find Service %q", ts.Service)
 	}
 
+	if !normalizeTrafficSplitWeights(ts) {
+		p.logger.Warnf("All the backends of TrafficSplit %q have a zero weight, the traffic is evenly split between them", tsKey)
+	}
+
 	switch trafficType {
 	case annotations.ServiceTypeHTTP:
 		p.buildHTTPServiceAndRoutersForTrafficSplit(t, cfg, tsKey, scheme, ts, tsSvc, middlewares)
 
 	case annotations.ServiceTypeTCP:
-		p.buildTCPServiceAndRoutersForTrafficSplit(cfg, tsKey, ts, tsSvc)
+		p.buildTCPServiceAndRoutersForTrafficSplit(t, cfg, tsKey, ts, tsSvc)
 
 	case annotations.ServiceTypeUDP:
-		p.buildUDPServiceAndRoutersForTrafficSplit(cfg, tsKey, ts, tsSvc)
+		p.buildUDPServiceAndRoutersForTrafficSplit(t, cfg, tsKey, ts, tsSvc)
 
 	default:
 		return fmt.Errorf("unknown traffic-type %q", trafficType)
@@ -409,7 +1019,52 @@ func (p *Provider) buildServiceAndRoutersForTrafficSplit(t *topology.Topology, c
 	return nil
 }
 
+// normalizeTrafficSplitWeights sets the normalized weights of the backends of the given TrafficSplit, which are the
+// weights of the Traefik weighted services: the backend weights divided by their greatest common divisor, so that the
+// ratios are preserved with the smallest integers. Negative weights are considered as zero. When all the weights are
+// zero, the traffic is evenly split between the backends and false is returned.
+func normalizeTrafficSplitWeights(ts *topology.TrafficSplit) bool {
+	var divisor int
+
+	for _, backend := range ts.Backends {
+		if backend.Weight > 0 {
+			divisor = gcd(divisor, backend.Weight)
+		}
+	}
+
+	for i, backend := range ts.Backends {
+		switch {
+		case divisor == 0:
+			ts.Backends[i].NormalizedWeight = 1
+		case backend.Weight <= 0:
+			ts.Backends[i].NormalizedWeight = 0
+		default:
+			ts.Backends[i].NormalizedWeight = backend.Weight / divisor
+		}
+	}
+
+	return divisor != 0 || len(ts.Backends) == 0
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
 func (p *Provider) buildHTTPServiceAndRoutersForTrafficSplit(t *topology.Topology, cfg *dynamic.Configuration, tsKey topology.Key, scheme string, ts *topology.TrafficSplit, tsSvc *topology.Service, middlewares []string) {
+	// Sessions are pinned to a backend by the weighted service, the backend services having a single server each.
+	sticky, err := buildTrafficSplitStickyFromService(tsSvc)
+	if err != nil {
+		err = fmt.Errorf("unable to build sticky sessions: %w", err)
+		ts.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for TrafficSplit %q: %v", tsKey, err)
+
+		return
+	}
+
 	rule := buildHTTPRuleFromTrafficSplit(ts, tsSvc)
 
 	rtrMiddlewares := middlewares
@@ -442,7 +1097,7 @@ func (p *Provider) buildHTTPServiceAndRoutersForTrafficSplit(t *topology.Topolog
 		}
 
 		svcKey := getServiceKeyFromTrafficSplit(ts, svcPort.Port)
-		cfg.HTTP.Services[svcKey] = buildHTTPServiceFromTrafficSplit(backendSvcs)
+		cfg.HTTP.Services[svcKey] = buildHTTPServiceFromTrafficSplit(backendSvcs, sticky)
 
 		directRtrKey := getRouterKeyFromTrafficSplitDirect(ts, svcPort.Port)
 		cfg.HTTP.Routers[directRtrKey] = buildHTTPRouter(rule, entrypoint, rtrMiddlewares, svcKey, priorityTrafficSplit)
@@ -463,8 +1118,15 @@ func (p *Provider) buildHTTPServiceAndRoutersForTrafficSplit(t *topology.Topolog
 	}
 }
 
-func (p *Provider) buildTCPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configuration, tsKey topology.Key, ts *topology.TrafficSplit, tsSvc *topology.Service) {
-	tcpRule := buildTCPRouterRule()
+func (p *Provider) buildTCPServiceAndRoutersForTrafficSplit(t *topology.Topology, cfg *dynamic.Configuration, tsKey topology.Key, ts *topology.TrafficSplit, tsSvc *topology.Service) {
+	tcpRule, tls, err := buildTCPRouterRuleAndTLSFromService(tsSvc)
+	if err != nil {
+		err = fmt.Errorf("unable to build TCP router rule: %w", err)
+		ts.AddError(err)
+		p.logger.Errorf("Error building dynamic configuration for TrafficSplit %q: %v", tsKey, err)
+
+		return
+	}
 
 	for _, svcPort := range tsSvc.Ports {
 		entrypoint, err := p.buildTCPEntrypoint(tsSvc, svcPort.Port)
@@ -481,22 +1143,22 @@ func (p *Provider) buildTCPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configu
 		for i, backend := range ts.Backends {
 			backendSvcKey := getServiceKeyFromTrafficSplitBackend(ts, svcPort.Port, backend)
 
-			addTCPService(cfg, backendSvcKey, buildTCPSplitTrafficBackendService(backend, svcPort.TargetPort.IntVal))
+			addTCPService(cfg, backendSvcKey, buildTCPSplitTrafficBackendService(p.getServiceHost(t, backend.Service), svcPort.Port))
 
 			backendSvcs[i] = dynamic.TCPWRRService{
 				Name:   backendSvcKey,
-				Weight: getIntRef(backend.Weight),
+				Weight: getIntRef(backend.NormalizedWeight),
 			}
 		}
 
 		key := getServiceRouterKeyFromService(tsSvc, svcPort.Port)
 
 		addTCPService(cfg, key, buildTCPServiceFromTrafficSplit(backendSvcs))
-		addTCPRouter(cfg, key, buildTCPRouter(tcpRule, entrypoint, key))
+		addTCPRouter(cfg, key, buildTCPRouter(tcpRule, entrypoint, key, tls))
 	}
 }
 
-func (p *Provider) buildUDPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configuration, tsKey topology.Key, ts *topology.TrafficSplit, tsSvc *topology.Service) {
+func (p *Provider) buildUDPServiceAndRoutersForTrafficSplit(t *topology.Topology, cfg *dynamic.Configuration, tsKey topology.Key, ts *topology.TrafficSplit, tsSvc *topology.Service) {
 	for _, svcPort := range tsSvc.Ports {
 		entrypoint, err := p.buildUDPEntrypoint(tsSvc, svcPort.Port)
 		if err != nil {
@@ -512,11 +1174,11 @@ func (p *Provider) buildUDPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configu
 		for i, backend := range ts.Backends {
 			backendSvcKey := getServiceKeyFromTrafficSplitBackend(ts, svcPort.Port, backend)
 
-			addUDPService(cfg, backendSvcKey, buildUDPSplitTrafficBackendService(backend, svcPort.TargetPort.IntVal))
+			addUDPService(cfg, backendSvcKey, buildUDPSplitTrafficBackendService(p.getServiceHost(t, backend.Service), svcPort.Port))
 
 			backendSvcs[i] = dynamic.UDPWRRService{
 				Name:   backendSvcKey,
-				Weight: getIntRef(backend.Weight),
+				Weight: getIntRef(backend.NormalizedWeight),
 			}
 		}
 
@@ -543,10 +1205,10 @@ func (p *Provider) buildServicesForTrafficSplitBackends(t *topology.Topology, cf
 
 		backendSvcKey := getServiceKeyFromTrafficSplitBackend(ts, svcPort.Port, backend)
 
-		cfg.HTTP.Services[backendSvcKey] = buildHTTPSplitTrafficBackendService(backend, scheme, svcPort.Port)
+		cfg.HTTP.Services[backendSvcKey] = buildHTTPSplitTrafficBackendService(p.getServiceHost(t, backend.Service), scheme, svcPort.Port)
 		backendSvcs[i] = dynamic.WRRService{
 			Name:   backendSvcKey,
-			Weight: getIntRef(backend.Weight),
+			Weight: getIntRef(backend.NormalizedWeight),
 		}
 	}
 
@@ -573,7 +1235,7 @@ func (p *Provider) buildBlockAllRouters(cfg *dynamic.Configuration, svc *topolog
 			Middlewares: []string{blockAllMiddlewareKey},
 			Service:     blockAllServiceKey,
 			Rule:        rule,
-			Priority:    priorityService,
+			Priority:    getRulePriority(rule, priorityService),
 		}
 	}
 }
@@ -593,28 +1255,87 @@ func (p Provider) buildTCPEntrypoint(svc *topology.Service, port int32) (string,
 		return "", errors.New("port not found")
 	}
 
-	return fmt.Sprintf("tcp-%d", targetPort), nil
-}
+	return fmt.Sprintf("tcp-%d", targetPort), nil
+}
+
+func (p Provider) buildUDPEntrypoint(svc *topology.Service, port int32) (string, error) {
+	targetPort, ok := p.udpStateTable.Find(svc.Namespace, svc.Name, port)
+	if !ok {
+		return "", errors.New("port not found")
+	}
+
+	return fmt.Sprintf("udp-%d", targetPort), nil
+}
+
+func (p *Provider) buildHTTPServiceFromService(t *topology.Topology, svc *topology.Service, scheme string, svcPort corev1.ServicePort) *dynamic.Service {
+	// ExternalName services are forwarded to the external host, which expects its own name in the Host header.
+	if svc.ExternalName != "" {
+		return &dynamic.Service{
+			LoadBalancer: &dynamic.ServersLoadBalancer{
+				Servers: []dynamic.Server{
+					{URL: fmt.Sprintf("%s://%s", scheme, getExternalNameAddress(svc, svcPort))},
+				},
+				PassHostHeader: getBoolRef(false),
+			},
+		}
+	}
+
+	return &dynamic.Service{
+		LoadBalancer: &dynamic.ServersLoadBalancer{
+			Servers:        p.buildHTTPServersFromPods(t, svc, svc.Pods, scheme, svcPort),
+			PassHostHeader: getBoolRef(true),
+		},
+	}
+}
+
+// buildHTTPZoneServicesFromService builds, for each zone the available pods of the given service run in, an HTTP
+// service load-balancing between the pods of this zone only. Pods of an unknown zone are left out.
+func (p *Provider) buildHTTPZoneServicesFromService(t *topology.Topology, svc *topology.Service, scheme string, svcPort corev1.ServicePort) map[string]*dynamic.Service {
+	podsByZone := make(map[string][]topology.Key)
+
+	for _, podKey := range svc.Pods {
+		pod, ok := t.Pods[podKey]
+		if !ok || pod.Zone == "" || !isPodAvailable(pod) {
+			continue
+		}
+
+		podsByZone[pod.Zone] = append(podsByZone[pod.Zone], podKey)
+	}
+
+	zoneSvcs := make(map[string]*dynamic.Service)
 
-func (p Provider) buildUDPEntrypoint(svc *topology.Service, port int32) (string, error) {
-	targetPort, ok := p.udpStateTable.Find(svc.Namespace, svc.Name, port)
-	if !ok {
-		return "", errors.New("port not found")
+	for zone, pods := range podsByZone {
+		servers := p.buildHTTPServersFromPods(t, svc, pods, scheme, svcPort)
+		if len(servers) == 0 {
+			continue
+		}
+
+		zoneSvcs[zone] = &dynamic.Service{
+			LoadBalancer: &dynamic.ServersLoadBalancer{
+				Servers:        servers,
+				PassHostHeader: getBoolRef(true),
+			},
+		}
 	}
 
-	return fmt.Sprintf("udp-%d", targetPort), nil
+	return zoneSvcs
 }
 
-func (p *Provider) buildHTTPServiceFromService(t *topology.Topology, svc *topology.Service, scheme string, svcPort corev1.ServicePort) *dynamic.Service {
+// buildHTTPServersFromPods builds the servers of the given pods of the given service, skipping the unavailable ones.
+func (p *Provider) buildHTTPServersFromPods(t *topology.Topology, svc *topology.Service, pods []topology.Key, scheme string, svcPort corev1.ServicePort) []dynamic.Server {
 	var servers []dynamic.Server
 
-	for _, podKey := range svc.Pods {
+	for _, podKey := range pods {
 		pod, ok := t.Pods[podKey]
 		if !ok {
 			p.logger.Errorf("Unable to find Pod %q for HTTP service from Service %s@%s", podKey, topology.Key{Name: svc.Name, Namespace: svc.Namespace})
 			continue
 		}
 
+		if !isPodAvailable(pod) {
+			continue
+		}
+
 		hostPort, ok := topology.ResolveServicePort(svcPort, pod.ContainerPorts)
 		if !ok {
 			p.logger.Warnf("Unable to resolve HTTP service port %q for Pod %q", svcPort.Name, podKey)
@@ -628,12 +1349,7 @@ func (p *Provider) buildHTTPServiceFromService(t *topology.Topology, svc *topolo
 		})
 	}
 
-	return &dynamic.Service{
-		LoadBalancer: &dynamic.ServersLoadBalancer{
-			Servers:        servers,
-			PassHostHeader: getBoolRef(true),
-		},
-	}
+	return servers
 }
 
 func (p *Provider) buildHTTPServiceFromTrafficTarget(t *topology.Topology, tt *topology.ServiceTrafficTarget, scheme string, svcPort corev1.ServicePort) *dynamic.Service {
@@ -650,6 +1366,10 @@ func (p *Provider) buildHTTPServiceFromTrafficTarget(t *topology.Topology, tt *t
 			continue
 		}
 
+		if !isPodAvailable(pod) {
+			continue
+		}
+
 		hostPort, ok := topology.ResolveServicePort(svcPort, pod.ContainerPorts)
 		if !ok {
 			p.logger.Warnf("Unable to resolve HTTP service port %q for Pod %q", svcPort.TargetPort, podKey)
@@ -672,6 +1392,16 @@ func (p *Provider) buildHTTPServiceFromTrafficTarget(t *topology.Topology, tt *t
 }
 
 func (p *Provider) buildTCPServiceFromService(t *topology.Topology, svc *topology.Service, svcPort corev1.ServicePort) *dynamic.TCPService {
+	if svc.ExternalName != "" {
+		return &dynamic.TCPService{
+			LoadBalancer: &dynamic.TCPServersLoadBalancer{
+				Servers: []dynamic.TCPServer{
+					{Address: getExternalNameAddress(svc, svcPort)},
+				},
+			},
+		}
+	}
+
 	var servers []dynamic.TCPServer
 
 	for _, podKey := range svc.Pods {
@@ -681,6 +1411,10 @@ func (p *Provider) buildTCPServiceFromService(t *topology.Topology, svc *topolog
 			continue
 		}
 
+		if !isPodAvailable(pod) {
+			continue
+		}
+
 		hostPort, ok := topology.ResolveServicePort(svcPort, pod.ContainerPorts)
 		if !ok {
 			p.logger.Warnf("Unable to resolve TCP service port %q for Pod %q", svcPort.Name, podKey)
@@ -711,6 +1445,10 @@ func (p *Provider) buildTCPServiceFromTrafficTarget(t *topology.Topology, tt *to
 			continue
 		}
 
+		if !isPodAvailable(pod) {
+			continue
+		}
+
 		hostPort, ok := topology.ResolveServicePort(svcPort, pod.ContainerPorts)
 		if !ok {
 			p.logger.Warnf("Unable to resolve TCP service port %q for Pod %q", svcPort.Name, podKey)
@@ -732,6 +1470,16 @@ func (p *Provider) buildTCPServiceFromTrafficTarget(t *topology.Topology, tt *to
 }
 
 func (p *Provider) buildUDPServiceFromService(t *topology.Topology, svc *topology.Service, svcPort corev1.ServicePort) *dynamic.UDPService {
+	if svc.ExternalName != "" {
+		return &dynamic.UDPService{
+			LoadBalancer: &dynamic.UDPServersLoadBalancer{
+				Servers: []dynamic.UDPServer{
+					{Address: getExternalNameAddress(svc, svcPort)},
+				},
+			},
+		}
+	}
+
 	var servers []dynamic.UDPServer
 
 	for _, podKey := range svc.Pods {
@@ -741,6 +1489,10 @@ func (p *Provider) buildUDPServiceFromService(t *topology.Topology, svc *topolog
 			continue
 		}
 
+		if !isPodAvailable(pod) {
+			continue
+		}
+
 		hostPort, ok := topology.ResolveServicePort(svcPort, pod.ContainerPorts)
 		if !ok {
 			p.logger.Warnf("Unable to resolve UDP service port %q for Pod %q", svcPort.Name, podKey)
@@ -761,22 +1513,20 @@ func (p *Provider) buildUDPServiceFromService(t *topology.Topology, svc *topolog
 	}
 }
 
+// getExternalNameAddress returns the address of the external host targeted by the given ExternalName service port.
+// The port of an ExternalName service is the one exposed by the external host.
+func getExternalNameAddress(svc *topology.Service, svcPort corev1.ServicePort) string {
+	return net.JoinHostPort(svc.ExternalName, strconv.Itoa(int(svcPort.Port)))
+}
+
 // buildWhitelistMiddlewareFromTrafficTargetDirect builds an IPWhiteList middleware which blocks requests from
 // unauthorized Pods. Authorized Pods are those listed in the ServiceTrafficTarget.Sources.
 // This middleware doesn't work if used behind a proxy.
 func (p *Provider) buildWhitelistMiddlewareFromTrafficTargetDirect(t *topology.Topology, tt *topology.ServiceTrafficTarget) *dynamic.Middleware {
 	var IPs []string
 
-	for _, source := range tt.Sources {
-		for _, podKey := range source.Pods {
-			pod, ok := t.Pods[podKey]
-			if !ok {
-				p.logger.Errorf("Unable to find Pod %q for WhitelistMiddleware from Traffic Target %s@%s", podKey, topology.Key{Name: tt.Name, Namespace: tt.Namespace})
-				continue
-			}
-
-			IPs = append(IPs, pod.IP)
-		}
+	for i := range tt.Sources {
+		IPs = append(IPs, p.getTrafficTargetSourceIPs(t, tt, &tt.Sources[i])...)
 	}
 
 	return &dynamic.Middleware{
@@ -786,6 +1536,23 @@ func (p *Provider) buildWhitelistMiddlewareFromTrafficTargetDirect(t *topology.T
 	}
 }
 
+// getTrafficTargetSourceIPs returns the IPs of the Pods of the given source of a ServiceTrafficTarget.
+func (p *Provider) getTrafficTargetSourceIPs(t *topology.Topology, tt *topology.ServiceTrafficTarget, source *topology.ServiceTrafficTargetSource) []string {
+	var IPs []string
+
+	for _, podKey := range source.Pods {
+		pod, ok := t.Pods[podKey]
+		if !ok {
+			p.logger.Errorf("Unable to find Pod %q for WhitelistMiddleware from Traffic Target %s@%s", podKey, topology.Key{Name: tt.Name, Namespace: tt.Namespace})
+			continue
+		}
+
+		IPs = append(IPs, pod.IP)
+	}
+
+	return IPs
+}
+
 // buildWhitelistMiddlewareFromTrafficSplitDirect builds an IPWhiteList middleware which blocks requests from
 // unauthorized Pods. Authorized Pods are those that can access all the leaves of the TrafficSplit.
 // This middleware doesn't work if used behind a proxy.
@@ -833,10 +1600,39 @@ func (p *Provider) buildWhitelistMiddlewareFromTrafficSplitIndirect(t *topology.
 	return whitelist
 }
 
-func buildHTTPServiceFromTrafficSplit(backendSvc []dynamic.WRRService) *dynamic.Service {
+// buildSourceHeaderMiddlewareFromTrafficTargetSource builds a Headers middleware which sets the given header to the
+// identity of the given source of a ServiceTrafficTarget, formatted as "<service-account>@<namespace>".
+func buildSourceHeaderMiddlewareFromTrafficTargetSource(header string, source *topology.ServiceTrafficTargetSource) *dynamic.Middleware {
+	return &dynamic.Middleware{
+		Headers: &dynamic.Headers{
+			CustomRequestHeaders: map[string]string{
+				header: topology.Key{Name: source.ServiceAccount, Namespace: source.Namespace}.String(),
+			},
+		},
+	}
+}
+
+// buildRateLimitMiddlewareFromTrafficTargetSource builds a RateLimit middleware with the limits of the given
+// rate-limit, whose requests are grouped by the value of the given header. It must only be used by the routers of a
+// single source, which set this header to the identity of the source beforehand.
+func buildRateLimitMiddlewareFromTrafficTargetSource(rateLimit *dynamic.RateLimit, header string) *dynamic.Middleware {
+	return &dynamic.Middleware{
+		RateLimit: &dynamic.RateLimit{
+			Average: rateLimit.Average,
+			Period:  rateLimit.Period,
+			Burst:   rateLimit.Burst,
+			SourceCriterion: &dynamic.SourceCriterion{
+				RequestHeaderName: header,
+			},
+		},
+	}
+}
+
+func buildHTTPServiceFromTrafficSplit(backendSvc []dynamic.WRRService, sticky *dynamic.Sticky) *dynamic.Service {
 	return &dynamic.Service{
 		Weighted: &dynamic.WeightedRoundRobin{
 			Services: backendSvc,
+			Sticky:   sticky,
 		},
 	}
 }
@@ -857,9 +1653,56 @@ func buildUDPServiceFromTrafficSplit(backendSvc []dynamic.UDPWRRService) *dynami
 	}
 }
 
-func buildHTTPSplitTrafficBackendService(backend topology.TrafficSplitBackend, scheme string, port int32) *dynamic.Service {
+// getServiceHost returns the host through which the proxies reach the given Service: its Traefik Mesh domain, resolved
+// to its shadow service, or its ClusterIP when shadow services are disabled. Services without a ClusterIP are then
+// reached through their Kubernetes domain.
+func (p *Provider) getServiceHost(t *topology.Topology, svcKey topology.Key) string {
+	if !p.config.NoShadowService {
+		return fmt.Sprintf("%s.%s.traefik.mesh", svcKey.Name, svcKey.Namespace)
+	}
+
+	if svc, ok := t.Services[svcKey]; ok && svc.ClusterIP != "" && svc.ClusterIP != corev1.ClusterIPNone {
+		return svc.ClusterIP
+	}
+
+	return fmt.Sprintf("%s.%s.svc", svcKey.Name, svcKey.Namespace)
+}
+
+func buildHTTPSplitTrafficBackendService(host, scheme string, port int32) *dynamic.Service {
+	server := dynamic.Server{
+		URL: fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(port)))),
+	}
+
+	return &dynamic.Service{
+		LoadBalancer: &dynamic.ServersLoadBalancer{
+			Servers:        []dynamic.Server{server},
+			PassHostHeader: getBoolRef(false),
+		},
+	}
+}
+
+// buildHTTPMirrorService builds a service which sends the mirrored requests to the mirror service with the given host.
+// Unless shadow services are disabled, the host is the one of the mirror service in the mesh, so that the configuration
+// of the mirror service applies to them.
+func buildHTTPMirrorService(host, scheme string, port int32) *dynamic.Service {
+	server := dynamic.Server{
+		URL: fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(port)))),
+	}
+
+	return &dynamic.Service{
+		LoadBalancer: &dynamic.ServersLoadBalancer{
+			Servers:        []dynamic.Server{server},
+			PassHostHeader: getBoolRef(false),
+		},
+	}
+}
+
+// buildHTTPHeaderRouteService builds a service which sends the requests matching a header route to the service with
+// the given host. As for mirrors, the host is the one of the routed service in the mesh unless shadow services are
+// disabled.
+func buildHTTPHeaderRouteService(host, scheme string, port int32) *dynamic.Service {
 	server := dynamic.Server{
-		URL: fmt.Sprintf("%s://%s.%s.traefik.mesh:%d", scheme, backend.Service.Name, backend.Service.Namespace, port),
+		URL: fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(port)))),
 	}
 
 	return &dynamic.Service{
@@ -870,9 +1713,20 @@ func buildHTTPSplitTrafficBackendService(backend topology.TrafficSplitBackend, s
 	}
 }
 
-func buildTCPSplitTrafficBackendService(backend topology.TrafficSplitBackend, port int32) *dynamic.TCPService {
+func buildHTTPMirroringService(svcKey, mirrorSvcKey string, percent int) *dynamic.Service {
+	return &dynamic.Service{
+		Mirroring: &dynamic.Mirroring{
+			Service: svcKey,
+			Mirrors: []dynamic.MirrorService{
+				{Name: mirrorSvcKey, Percent: percent},
+			},
+		},
+	}
+}
+
+func buildTCPSplitTrafficBackendService(host string, port int32) *dynamic.TCPService {
 	server := dynamic.TCPServer{
-		Address: fmt.Sprintf("%s.%s.traefik.mesh:%d", backend.Service.Name, backend.Service.Namespace, port),
+		Address: net.JoinHostPort(host, strconv.Itoa(int(port))),
 	}
 
 	return &dynamic.TCPService{
@@ -882,9 +1736,9 @@ func buildTCPSplitTrafficBackendService(backend topology.TrafficSplitBackend, po
 	}
 }
 
-func buildUDPSplitTrafficBackendService(backend topology.TrafficSplitBackend, port int32) *dynamic.UDPService {
+func buildUDPSplitTrafficBackendService(host string, port int32) *dynamic.UDPService {
 	server := dynamic.UDPServer{
-		Address: fmt.Sprintf("%s.%s.traefik.mesh:%d", backend.Service.Name, backend.Service.Namespace, port),
+		Address: net.JoinHostPort(host, strconv.Itoa(int(port))),
 	}
 
 	return &dynamic.UDPService{
@@ -894,22 +1748,311 @@ func buildUDPSplitTrafficBackendService(backend topology.TrafficSplitBackend, po
 	}
 }
 
-func buildHTTPRouter(routerRule string, entrypoint string, middlewares []string, svcKey string, priority int) *dynamic.Router {
+// loadBalancerOptions holds the load-balancer settings of an HTTP service configured through annotations.
+type loadBalancerOptions struct {
+	sticky             *dynamic.Sticky
+	healthCheck        *dynamic.ServerHealthCheck
+	passHostHeader     bool
+	responseForwarding *dynamic.ResponseForwarding
+	// serversTransport is the key of the servers transport holding the forwarding timeouts and TLS settings, empty if
+	// none is set.
+	serversTransport       string
+	serversTransportConfig *dynamic.ServersTransport
+}
+
+// apply sets the options on the given load-balancer, and adds the servers transport it refers to, if any, to the given
+// configuration.
+func (o loadBalancerOptions) apply(cfg *dynamic.Configuration, lb *dynamic.ServersLoadBalancer) {
+	lb.Sticky = o.sticky
+	lb.HealthCheck = o.healthCheck
+	lb.PassHostHeader = getBoolRef(o.passHostHeader)
+	lb.ResponseForwarding = o.responseForwarding
+
+	if o.serversTransport == "" {
+		return
+	}
+
+	lb.ServersTransport = o.serversTransport
+
+	if cfg.HTTP.ServersTransports == nil {
+		cfg.HTTP.ServersTransports = map[string]*dynamic.ServersTransport{}
+	}
+
+	cfg.HTTP.ServersTransports[o.serversTransport] = o.serversTransportConfig
+}
+
+// buildLoadBalancerOptionsFromService builds the load-balancer options of the given service from its annotations.
+func buildLoadBalancerOptionsFromService(svc *topology.Service) (loadBalancerOptions, error) {
+	// The lb-strategy annotation is only validated: round-robin, its only accepted value, is the default and only
+	// strategy of the Traefik v2 servers load-balancer, so there is nothing to configure.
+	if _, err := annotations.GetLBStrategy(svc.Annotations); err != nil {
+		return loadBalancerOptions{}, err
+	}
+
+	sticky, err := buildStickyFromService(svc)
+	if err != nil {
+		return loadBalancerOptions{}, err
+	}
+
+	healthCheck, err := buildHealthCheckFromService(svc)
+	if err != nil {
+		return loadBalancerOptions{}, err
+	}
+
+	passHostHeader, err := annotations.IsPassHostHeader(svc.Annotations)
+	if err != nil {
+		return loadBalancerOptions{}, err
+	}
+
+	// ExternalName services are forwarded to the external host, which expects its own name in the Host header.
+	if svc.ExternalName != "" {
+		passHostHeader = false
+	}
+
+	responseForwarding, err := buildResponseForwardingFromService(svc)
+	if err != nil {
+		return loadBalancerOptions{}, err
+	}
+
+	serversTransport, err := buildServersTransportFromService(svc)
+	if err != nil {
+		return loadBalancerOptions{}, err
+	}
+
+	opts := loadBalancerOptions{
+		sticky:                 sticky,
+		healthCheck:            healthCheck,
+		passHostHeader:         passHostHeader,
+		responseForwarding:     responseForwarding,
+		serversTransportConfig: serversTransport,
+	}
+
+	if serversTransport != nil {
+		opts.serversTransport = getServersTransportKey(svc)
+	}
+
+	return opts, nil
+}
+
+// buildResponseForwardingFromService builds the response forwarding configuration of the given service from its
+// annotations. It returns nil if no flush interval is configured.
+func buildResponseForwardingFromService(svc *topology.Service) (*dynamic.ResponseForwarding, error) {
+	flushInterval, err := annotations.GetResponseForwardingFlushInterval(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamic.ResponseForwarding{FlushInterval: flushInterval.String()}, nil
+}
+
+// buildServersTransportFromService builds the servers transport of the given service from its forwarding timeouts,
+// connection pool and TLS annotations. It returns nil if none of them is configured. HTTP/2 can't be disabled for a
+// service using the h2c scheme, as its requests are forwarded over cleartext HTTP/2.
+func buildServersTransportFromService(svc *topology.Service) (*dynamic.ServersTransport, error) {
+	forwardingTimeouts, err := buildForwardingTimeoutsFromService(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	insecureSkipVerify, err := annotations.IsTLSInsecure(svc.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	serverName, err := annotations.GetTLSServerName(svc.Annotations)
+	if err != nil && !errors.Is(err, annotations.ErrNotFound) {
+		return nil, err
+	}
+
+	maxIdleConnsPerHost, err := annotations.GetMaxIdleConnsPerHost(svc.Annotations)
+	if err != nil && !errors.Is(err, annotations.ErrNotFound) {
+		return nil, err
+	}
+
+	disableHTTP2, err := annotations.IsHTTP2Disabled(svc.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme, err := annotations.GetScheme(svc.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme == annotations.SchemeH2C && disableHTTP2 {
+		return nil, errors.New("HTTP/2 can't be disabled for a service using the h2c scheme")
+	}
+
+	if forwardingTimeouts == nil && !insecureSkipVerify && serverName == "" && maxIdleConnsPerHost == 0 && !disableHTTP2 {
+		return nil, nil
+	}
+
+	return &dynamic.ServersTransport{
+		ServerName:          serverName,
+		InsecureSkipVerify:  insecureSkipVerify,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		ForwardingTimeouts:  forwardingTimeouts,
+		DisableHTTP2:        disableHTTP2,
+	}, nil
+}
+
+// buildForwardingTimeoutsFromService builds the forwarding timeouts of the given service from its annotations. The
+// timeouts which are not configured keep the Traefik default values. It returns nil if no timeout is configured.
+func buildForwardingTimeoutsFromService(svc *topology.Service) (*dynamic.ForwardingTimeouts, error) {
+	var timeouts dynamic.ForwardingTimeouts
+
+	timeouts.SetDefaults()
+
+	var configured bool
+
+	for _, timeout := range []struct {
+		get   func(map[string]string) (time.Duration, error)
+		value *ptypes.Duration
+	}{
+		{get: annotations.GetDialTimeout, value: &timeouts.DialTimeout},
+		{get: annotations.GetResponseHeaderTimeout, value: &timeouts.ResponseHeaderTimeout},
+		{get: annotations.GetIdleConnTimeout, value: &timeouts.IdleConnTimeout},
+	} {
+		value, err := timeout.get(svc.Annotations)
+		if errors.Is(err, annotations.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		*timeout.value = ptypes.Duration(value)
+		configured = true
+	}
+
+	if !configured {
+		return nil, nil
+	}
+
+	return &timeouts, nil
+}
+
+// buildStickyFromService builds the sticky sessions configuration of the given service from its annotations. It returns
+// nil if no sticky cookie name is configured.
+func buildStickyFromService(svc *topology.Service) (*dynamic.Sticky, error) {
+	name, err := annotations.GetStickyCookieName(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buildStickyCookie(svc, name)
+}
+
+// buildTrafficSplitStickyFromService builds the sticky sessions configuration of the weighted services of the traffic
+// splits targeting the given service from its annotations. The cookie is the traffic split sticky cookie, or the sticky
+// cookie of the service when it is not set, so that the sessions of a sticky service are not moved to another backend.
+// It returns nil if none of them is configured.
+func buildTrafficSplitStickyFromService(svc *topology.Service) (*dynamic.Sticky, error) {
+	name, err := annotations.GetTrafficSplitStickyCookieName(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		name, err = annotations.GetStickyCookieName(svc.Annotations)
+	}
+
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buildStickyCookie(svc, name)
+}
+
+// buildStickyCookie builds a sticky sessions configuration setting the cookie with the given name, secured according to
+// the annotations of the given service.
+func buildStickyCookie(svc *topology.Service, name string) (*dynamic.Sticky, error) {
+	secure, err := annotations.IsStickyCookieSecure(svc.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	httpOnly, err := annotations.IsStickyCookieHTTPOnly(svc.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamic.Sticky{
+		Cookie: &dynamic.Cookie{
+			Name:     name,
+			Secure:   secure,
+			HTTPOnly: httpOnly,
+		},
+	}, nil
+}
+
+// buildHealthCheckFromService builds the health-check configuration of the given service from its annotations. It
+// returns nil if no health-check path is configured.
+func buildHealthCheckFromService(svc *topology.Service) (*dynamic.ServerHealthCheck, error) {
+	path, err := annotations.GetHealthCheckPath(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	healthCheck := &dynamic.ServerHealthCheck{Path: path}
+
+	interval, err := annotations.GetHealthCheckInterval(svc.Annotations)
+	if err != nil && !errors.Is(err, annotations.ErrNotFound) {
+		return nil, err
+	}
+
+	if err == nil {
+		healthCheck.Interval = interval.String()
+	}
+
+	return healthCheck, nil
+}
+
+func buildHTTPRouter(routerRule string, entrypoint string, middlewares []string, svcKey string, priorityClass int) *dynamic.Router {
 	return &dynamic.Router{
 		EntryPoints: []string{entrypoint},
 		Middlewares: middlewares,
 		Service:     svcKey,
 		Rule:        routerRule,
-		Priority:    getRulePriority(routerRule, priority),
+		Priority:    getRulePriority(routerRule, priorityClass),
 	}
 }
 
-func buildTCPRouter(routerRule string, entrypoint string, svcKey string) *dynamic.TCPRouter {
+func buildTCPRouter(routerRule string, entrypoint string, svcKey string, tls *dynamic.RouterTCPTLSConfig) *dynamic.TCPRouter {
 	return &dynamic.TCPRouter{
 		EntryPoints: []string{entrypoint},
 		Service:     svcKey,
 		Rule:        routerRule,
+		TLS:         tls,
+	}
+}
+
+// buildTCPRouterRuleAndTLSFromService builds the rule and the TLS configuration of the TCP routers of the given service.
+// Services annotated for TLS passthrough are routed using the SNI of their mesh DNS name, without terminating TLS.
+func buildTCPRouterRuleAndTLSFromService(svc *topology.Service) (string, *dynamic.RouterTCPTLSConfig, error) {
+	passthrough, err := annotations.IsTLSPassthrough(svc.Annotations)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !passthrough {
+		return buildTCPRouterRule(), nil, nil
 	}
+
+	return buildTCPRouterRuleFromService(svc), &dynamic.RouterTCPTLSConfig{Passthrough: true}, nil
 }
 
 func buildUDPRouter(entrypoint string, svcKey string) *dynamic.UDPRouter {
@@ -947,6 +2090,19 @@ func addToSliceCopy(items []string, item string) []string {
 	return cpy
 }
 
+// removeFromSliceCopy returns a copy of the given slice without the given item.
+func removeFromSliceCopy(items []string, item string) []string {
+	newItems := make([]string, 0, len(items))
+
+	for _, i := range items {
+		if i != item {
+			newItems = append(newItems, i)
+		}
+	}
+
+	return newItems
+}
+
 func addTCPService(config *dynamic.Configuration, key string, service *dynamic.TCPService) {
 	if config.TCP == nil {
 		config.TCP = &dynamic.TCPConfiguration{}
@@ -999,6 +2155,22 @@ func getBoolRef(v bool) *bool {
 	return &v
 }
 
+// containsString returns true if the given slice contains the given value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func getIntRef(v int) *int {
 	return &v
 }
+
+// isPodAvailable returns true if the given Pod is ready and not terminating, and can therefore receive traffic.
+func isPodAvailable(pod *topology.Pod) bool {
+	return !pod.NotReady && !pod.Terminating
+}
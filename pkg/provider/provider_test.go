@@ -2,15 +2,21 @@ package provider
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/v2/pkg/annotations"
 	"github.com/traefik/mesh/v2/pkg/topology"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type stateTableMock struct {
@@ -37,7 +43,10 @@ func TestProvider_BuildConfig(t *testing.T) {
 	tests := []struct {
 		desc               string
 		acl                bool
+		noShadowService    bool
 		defaultTrafficType string
+		middlewareBuilder  MiddlewareBuilder
+		basicAuthUsers     BasicAuthUsersGetter
 		httpStateTable     map[servicePort]int32
 		tcpStateTable      map[servicePort]int32
 		udpStateTable      map[servicePort]int32
@@ -63,10 +72,224 @@ func TestProvider_BuildConfig(t *testing.T) {
 			defaultTrafficType: "http",
 			httpStateTable: map[servicePort]int32{
 				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
 			},
 			topology:   "testdata/annotations-scheme-topology.json",
 			wantConfig: "testdata/annotations-scheme-config.json",
 		},
+		{
+			desc:               "Pods: not ready and terminating pods are excluded",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+			},
+			topology:   "testdata/pods-conditions-topology.json",
+			wantConfig: "testdata/pods-conditions-config.json",
+		},
+		{
+			desc:               "Annotations: sticky cookie",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-sticky-topology.json",
+			wantConfig: "testdata/annotations-sticky-config.json",
+		},
+		{
+			desc:               "Annotations: health check",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-healthcheck-topology.json",
+			wantConfig: "testdata/annotations-healthcheck-config.json",
+		},
+		{
+			desc:               "Annotations: pass host header",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-pass-host-header-topology.json",
+			wantConfig: "testdata/annotations-pass-host-header-config.json",
+		},
+		{
+			desc:               "Annotations: load-balancing strategy",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-lb-strategy-topology.json",
+			wantConfig: "testdata/annotations-lb-strategy-config.json",
+		},
+		{
+			desc:               "Annotations: timeouts",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-timeouts-topology.json",
+			wantConfig: "testdata/annotations-timeouts-config.json",
+		},
+		{
+			desc:               "Annotations: connection pool",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-connection-pool-topology.json",
+			wantConfig: "testdata/annotations-connection-pool-config.json",
+		},
+		{
+			desc:               "Annotations: TLS servers transport",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-tls-transport-topology.json",
+			wantConfig: "testdata/annotations-tls-transport-config.json",
+		},
+		{
+			desc:               "Annotations: mirror",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-mirror-topology.json",
+			wantConfig: "testdata/annotations-mirror-config.json",
+		},
+		{
+			desc:               "Annotations: header route",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			},
+			topology:   "testdata/annotations-header-route-topology.json",
+			wantConfig: "testdata/annotations-header-route-config.json",
+		},
+		{
+			desc:               "Annotations: zone-aware",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+			},
+			topology:   "testdata/annotations-zone-aware-topology.json",
+			wantConfig: "testdata/annotations-zone-aware-config.json",
+		},
+		{
+			desc:               "Annotations: entrypoints",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-entrypoints-topology.json",
+			wantConfig: "testdata/annotations-entrypoints-config.json",
+		},
+		{
+			desc:               "Annotations: TLS passthrough",
+			acl:                false,
+			defaultTrafficType: "http",
+			tcpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 5000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 5001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 5002,
+			},
+			topology:   "testdata/annotations-tls-passthrough-topology.json",
+			wantConfig: "testdata/annotations-tls-passthrough-config.json",
+		},
+		{
+			desc:               "Services: external name",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+			},
+			tcpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 5000,
+			},
+			topology:   "testdata/services-external-name-topology.json",
+			wantConfig: "testdata/services-external-name-config.json",
+		},
+		{
+			desc:               "Services: headless",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+			},
+			topology:   "testdata/services-headless-topology.json",
+			wantConfig: "testdata/services-headless-config.json",
+		},
+		{
+			desc:               "Annotations: middlewares",
+			acl:                false,
+			defaultTrafficType: "http",
+			middlewareBuilder:  annotations.BuildMiddlewares,
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			},
+			topology:   "testdata/annotations-middlewares-topology.json",
+			wantConfig: "testdata/annotations-middlewares-config.json",
+		},
+		{
+			desc:               "Annotations: basic-auth-secret",
+			acl:                false,
+			defaultTrafficType: "http",
+			middlewareBuilder:  annotations.BuildMiddlewares,
+			basicAuthUsers:     basicAuthUsersGetterMock,
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+			},
+			topology:   "testdata/annotations-basic-auth-topology.json",
+			wantConfig: "testdata/annotations-basic-auth-config.json",
+		},
 		{
 			desc:               "ACL disabled: basic HTTP service",
 			acl:                false,
@@ -78,6 +301,17 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:   "testdata/acl-disabled-http-basic-topology.json",
 			wantConfig: "testdata/acl-disabled-http-basic-config.json",
 		},
+		{
+			desc:               "ACL disabled: HTTP service with multiple named ports",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-a", Port: 9090}: 10001,
+			},
+			topology:   "testdata/acl-disabled-http-multiple-ports-topology.json",
+			wantConfig: "testdata/acl-disabled-http-multiple-ports-config.json",
+		},
 		{
 			desc:               "ACL disabled: basic TCP service",
 			acl:                false,
@@ -112,6 +346,30 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:   "testdata/acl-disabled-http-traffic-split-topology.json",
 			wantConfig: "testdata/acl-disabled-http-traffic-split-config.json",
 		},
+		{
+			desc:               "ACL disabled: HTTP service with traffic-split scoped to an HTTPRouteGroup",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			},
+			topology:   "testdata/acl-disabled-http-traffic-split-http-route-group-topology.json",
+			wantConfig: "testdata/acl-disabled-http-traffic-split-http-route-group-config.json",
+		},
+		{
+			desc:               "ACL disabled: HTTP service with traffic-split on a backend in another namespace",
+			acl:                false,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}:     10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}:     10001,
+				{Namespace: "canary-ns", Name: "svc-b", Port: 8080}: 10002,
+			},
+			topology:   "testdata/acl-disabled-http-traffic-split-cross-namespace-topology.json",
+			wantConfig: "testdata/acl-disabled-http-traffic-split-cross-namespace-config.json",
+		},
 		{
 			desc:               "ACL enabled: basic HTTP service",
 			acl:                true,
@@ -123,6 +381,16 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:   "testdata/acl-enabled-http-basic-topology.json",
 			wantConfig: "testdata/acl-enabled-http-basic-config.json",
 		},
+		{
+			desc:               "ACL enabled: HTTP service with a traffic target with multiple sources",
+			acl:                true,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10000,
+			},
+			topology:   "testdata/acl-enabled-http-multiple-sources-topology.json",
+			wantConfig: "testdata/acl-enabled-http-multiple-sources-config.json",
+		},
 		{
 			desc:               "ACL enabled: basic TCP service",
 			acl:                true,
@@ -168,6 +436,46 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:   "testdata/acl-enabled-http-traffic-split-http-route-group-topology.json",
 			wantConfig: "testdata/acl-enabled-http-traffic-split-http-route-group-config.json",
 		},
+		{
+			desc:               "No shadow service: annotations mirror",
+			acl:                false,
+			noShadowService:    true,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+			},
+			topology:   "testdata/annotations-mirror-topology.json",
+			wantConfig: "testdata/no-shadow-service-annotations-mirror-config.json",
+		},
+		{
+			desc:               "No shadow service: ACL disabled HTTP service with traffic-split",
+			acl:                false,
+			noShadowService:    true,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			},
+			topology:   "testdata/acl-disabled-http-traffic-split-topology.json",
+			wantConfig: "testdata/no-shadow-service-acl-disabled-http-traffic-split-config.json",
+		},
+		{
+			desc:               "No shadow service: ACL enabled HTTP service with traffic-split",
+			acl:                true,
+			noShadowService:    true,
+			defaultTrafficType: "http",
+			httpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			},
+			topology:   "testdata/acl-enabled-http-traffic-split-topology.json",
+			wantConfig: "testdata/no-shadow-service-acl-enabled-http-traffic-split-config.json",
+		},
 	}
 
 	for _, test := range tests {
@@ -186,10 +494,12 @@ func TestProvider_BuildConfig(t *testing.T) {
 			cfg := Config{
 				ACL:                test.acl,
 				DefaultTrafficType: defaultTrafficType,
+				NoShadowService:    test.noShadowService,
 			}
 
-			middlewareBuilder := func(a map[string]string) (map[string]*dynamic.Middleware, error) {
-				return nil, nil
+			middlewareBuilder := test.middlewareBuilder
+			if middlewareBuilder == nil {
+				middlewareBuilder = noopMiddlewareBuilder
 			}
 
 			p := New(
@@ -197,6 +507,7 @@ func TestProvider_BuildConfig(t *testing.T) {
 				&stateTableMock{test.tcpStateTable},
 				&stateTableMock{test.udpStateTable},
 				middlewareBuilder,
+				test.basicAuthUsers,
 				cfg,
 				logger,
 			)
@@ -245,3 +556,1365 @@ func assertConfig(t *testing.T, filename string, got *dynamic.Configuration) {
 
 	assert.Equal(t, string(wantMarshaled), string(gotMarshaled))
 }
+
+func TestProvider_BuildConfigWithACLSourceHeader(t *testing.T) {
+	tests := []struct {
+		desc       string
+		acl        bool
+		topology   string
+		wantHeader bool
+	}{
+		{
+			desc:       "ACL enabled",
+			acl:        true,
+			topology:   "testdata/acl-enabled-http-basic-topology.json",
+			wantHeader: true,
+		},
+		{
+			desc:     "ACL disabled",
+			acl:      false,
+			topology: "testdata/acl-enabled-http-basic-topology.json",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{
+				ACL:                test.acl,
+				DefaultTrafficType: "http",
+				ACLSourceHeader:    "X-Mesh-Source",
+			}
+
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8081}: 10001,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology(test.topology)
+			require.NoError(t, err)
+
+			// Authorize a second source with the same TrafficTarget.
+			ttKey := topology.ServiceTrafficTargetKey{
+				Service:       topology.Key{Name: "svc-b", Namespace: "my-ns"},
+				TrafficTarget: topology.Key{Name: "tt", Namespace: "my-ns"},
+			}
+			tt := topo.ServiceTrafficTargets[ttKey]
+			require.NotNil(t, tt)
+
+			podCKey := topology.Key{Name: "pod-c", Namespace: "other-ns"}
+			topo.Pods[podCKey] = &topology.Pod{Name: podCKey.Name, Namespace: podCKey.Namespace, ServiceAccount: "worker", IP: "10.10.2.2"}
+
+			tt.Sources = append(tt.Sources, topology.ServiceTrafficTargetSource{
+				ServiceAccount: "worker",
+				Namespace:      "other-ns",
+				Pods:           []topology.Key{podCKey},
+			})
+
+			got := p.BuildConfig(topo)
+
+			if !test.wantHeader {
+				for key := range got.HTTP.Middlewares {
+					assert.NotContains(t, key, "source-header")
+				}
+
+				for key := range got.HTTP.Routers {
+					assert.NotContains(t, key, "traffic-target-source")
+				}
+
+				return
+			}
+
+			ttRouter, ok := got.HTTP.Routers["my-ns-svc-b-tt-8080-traffic-target-direct"]
+			require.True(t, ok)
+
+			sources := []struct {
+				identity      string
+				IP            string
+				rule          string
+				routerKey     string
+				whitelistKey  string
+				middlewareKey string
+			}{
+				{
+					identity:      "client@my-ns",
+					IP:            "10.10.2.1",
+					rule:          "HeadersRegexp(`X-Real-Ip`, `^(10\\.10\\.2\\.1)$`)",
+					routerKey:     "my-ns-svc-b-tt-8080-client.my-ns-traffic-target-source-direct",
+					whitelistKey:  "my-ns-svc-b-tt-client.my-ns-whitelist-traffic-target-source-direct",
+					middlewareKey: "my-ns-svc-b-tt-client.my-ns-source-header-traffic-target-source",
+				},
+				{
+					identity:      "worker@other-ns",
+					IP:            "10.10.2.2",
+					rule:          "HeadersRegexp(`X-Real-Ip`, `^(10\\.10\\.2\\.2)$`)",
+					routerKey:     "my-ns-svc-b-tt-8080-worker.other-ns-traffic-target-source-direct",
+					whitelistKey:  "my-ns-svc-b-tt-worker.other-ns-whitelist-traffic-target-source-direct",
+					middlewareKey: "my-ns-svc-b-tt-worker.other-ns-source-header-traffic-target-source",
+				},
+			}
+
+			for _, source := range sources {
+				middleware, ok := got.HTTP.Middlewares[source.middlewareKey]
+				require.True(t, ok, source.middlewareKey)
+				require.NotNil(t, middleware.Headers)
+				assert.Equal(t, map[string]string{"X-Mesh-Source": source.identity}, middleware.Headers.CustomRequestHeaders)
+
+				whitelist, ok := got.HTTP.Middlewares[source.whitelistKey]
+				require.True(t, ok, source.whitelistKey)
+				require.NotNil(t, whitelist.IPWhiteList)
+				assert.Equal(t, []string{source.IP}, whitelist.IPWhiteList.SourceRange)
+
+				router, ok := got.HTTP.Routers[source.routerKey]
+				require.True(t, ok, source.routerKey)
+				assert.Contains(t, router.Rule, source.rule)
+				assert.Equal(t, []string{source.whitelistKey, source.middlewareKey}, router.Middlewares)
+				assert.Greater(t, router.Priority, ttRouter.Priority)
+
+				// The router of the TrafficTarget doesn't tell its sources apart.
+				assert.NotContains(t, ttRouter.Middlewares, source.middlewareKey)
+			}
+		})
+	}
+}
+
+func TestProvider_BuildConfigWithACLRateLimitBySource(t *testing.T) {
+	tests := []struct {
+		desc         string
+		sourceHeader string
+		wantHeader   string
+		wantCleanup  bool
+	}{
+		{
+			desc:        "without source header",
+			wantHeader:  "X-Traefik-Mesh-Rate-Limit-Source",
+			wantCleanup: true,
+		},
+		{
+			desc:         "with source header",
+			sourceHeader: "X-Mesh-Source",
+			wantHeader:   "X-Mesh-Source",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{
+				ACL:                true,
+				DefaultTrafficType: "http",
+				ACLSourceHeader:    test.sourceHeader,
+			}
+
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8081}: 10001,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, annotations.BuildMiddlewares, nil, cfg, logger)
+
+			topo, err := loadTopology("testdata/acl-enabled-http-basic-topology.json")
+			require.NoError(t, err)
+
+			svcBKey := topology.Key{Name: "svc-b", Namespace: "my-ns"}
+			svcB := topo.Services[svcBKey]
+			require.NotNil(t, svcB)
+
+			svcB.Annotations = map[string]string{
+				"mesh.traefik.io/ratelimit-average": "100",
+				"mesh.traefik.io/ratelimit-burst":   "200",
+			}
+
+			// Authorize a second source with the same TrafficTarget.
+			ttKey := topology.ServiceTrafficTargetKey{Service: svcBKey, TrafficTarget: topology.Key{Name: "tt", Namespace: "my-ns"}}
+			tt := topo.ServiceTrafficTargets[ttKey]
+			require.NotNil(t, tt)
+
+			podCKey := topology.Key{Name: "pod-c", Namespace: "other-ns"}
+			topo.Pods[podCKey] = &topology.Pod{Name: podCKey.Name, Namespace: podCKey.Namespace, ServiceAccount: "worker", IP: "10.10.2.2"}
+
+			tt.Sources = append(tt.Sources, topology.ServiceTrafficTargetSource{
+				ServiceAccount: "worker",
+				Namespace:      "other-ns",
+				Pods:           []topology.Key{podCKey},
+			})
+
+			got := p.BuildConfig(topo)
+
+			assert.Empty(t, svcB.Errors)
+
+			sources := []struct {
+				identity        string
+				routerKey       string
+				whitelistKey    string
+				sourceHeaderKey string
+				rateLimitKey    string
+			}{
+				{
+					identity:        "client@my-ns",
+					routerKey:       "my-ns-svc-b-tt-8080-client.my-ns-traffic-target-source-direct",
+					whitelistKey:    "my-ns-svc-b-tt-client.my-ns-whitelist-traffic-target-source-direct",
+					sourceHeaderKey: "my-ns-svc-b-tt-client.my-ns-source-header-traffic-target-source",
+					rateLimitKey:    "my-ns-svc-b-tt-client.my-ns-rate-limit-traffic-target-source",
+				},
+				{
+					identity:        "worker@other-ns",
+					routerKey:       "my-ns-svc-b-tt-8080-worker.other-ns-traffic-target-source-direct",
+					whitelistKey:    "my-ns-svc-b-tt-worker.other-ns-whitelist-traffic-target-source-direct",
+					sourceHeaderKey: "my-ns-svc-b-tt-worker.other-ns-source-header-traffic-target-source",
+					rateLimitKey:    "my-ns-svc-b-tt-worker.other-ns-rate-limit-traffic-target-source",
+				},
+			}
+
+			rateLimits := make(map[*dynamic.RateLimit]struct{})
+
+			for _, source := range sources {
+				middleware, ok := got.HTTP.Middlewares[source.rateLimitKey]
+				require.True(t, ok, source.rateLimitKey)
+				require.NotNil(t, middleware.RateLimit)
+
+				assert.Equal(t, int64(100), middleware.RateLimit.Average)
+				assert.Equal(t, int64(200), middleware.RateLimit.Burst)
+				assert.Equal(t, &dynamic.SourceCriterion{RequestHeaderName: test.wantHeader}, middleware.RateLimit.SourceCriterion)
+
+				rateLimits[middleware.RateLimit] = struct{}{}
+
+				require.Contains(t, got.HTTP.Middlewares, source.sourceHeaderKey)
+				assert.Equal(t, map[string]string{test.wantHeader: source.identity}, got.HTTP.Middlewares[source.sourceHeaderKey].Headers.CustomRequestHeaders)
+
+				router, ok := got.HTTP.Routers[source.routerKey]
+				require.True(t, ok, source.routerKey)
+
+				// The rate-limit of the source replaces the per-IP one of the service, and is applied once the source
+				// is authorized and identified.
+				wantMiddlewares := []string{source.whitelistKey, source.sourceHeaderKey, source.rateLimitKey}
+				if test.wantCleanup {
+					wantMiddlewares = append(wantMiddlewares, "rate-limit-source-header-cleanup-middleware")
+				}
+
+				assert.Equal(t, wantMiddlewares, router.Middlewares)
+			}
+
+			assert.Len(t, rateLimits, len(sources))
+
+			if test.wantCleanup {
+				require.Contains(t, got.HTTP.Middlewares, "rate-limit-source-header-cleanup-middleware")
+				assert.Equal(t, map[string]string{"X-Traefik-Mesh-Rate-Limit-Source": ""}, got.HTTP.Middlewares["rate-limit-source-header-cleanup-middleware"].Headers.CustomRequestHeaders)
+			}
+
+			// The router of the TrafficTarget keeps the per-IP rate-limit of the service.
+			router, ok := got.HTTP.Routers["my-ns-svc-b-tt-8080-traffic-target-direct"]
+			require.True(t, ok)
+			assert.Contains(t, router.Middlewares, "my-ns-svc-b-rate-limit")
+		})
+	}
+}
+
+// TestProvider_BuildConfigWithAnnotationTrafficSplit makes sure a traffic split defined with annotations produces the
+// same configuration as the equivalent SMI TrafficSplit.
+func TestProvider_BuildConfigWithAnnotationTrafficSplit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	// SMI TrafficSplit named after the service it targets.
+	smiTopo, err := loadTopology("testdata/acl-disabled-http-traffic-split-topology.json")
+	require.NoError(t, err)
+
+	ts := smiTopo.TrafficSplits[topology.Key{Name: "split", Namespace: "my-ns"}]
+	delete(smiTopo.TrafficSplits, topology.Key{Name: "split", Namespace: "my-ns"})
+
+	tsKey := topology.Key{Name: "svc-a", Namespace: "my-ns"}
+	ts.Name = tsKey.Name
+	smiTopo.TrafficSplits[tsKey] = ts
+	smiTopo.Services[ts.Service].TrafficSplits = []topology.Key{tsKey}
+
+	// Equivalent annotation based traffic split.
+	annotationTopo, err := loadTopology("testdata/acl-disabled-http-traffic-split-topology.json")
+	require.NoError(t, err)
+
+	annotationTopo.TrafficSplits = map[topology.Key]*topology.TrafficSplit{}
+	for _, svc := range annotationTopo.Services {
+		svc.TrafficSplits = nil
+		svc.BackendOf = nil
+	}
+
+	svcA := annotationTopo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}]
+	svcA.Annotations = map[string]string{"mesh.traefik.io/traffic-split-backends": "svc-b:80,svc-c:20"}
+
+	want := p.BuildConfig(smiTopo)
+	got := p.BuildConfig(annotationTopo)
+
+	assert.Empty(t, svcA.Errors)
+
+	require.Contains(t, got.HTTP.Services, "my-ns-svc-a-svc-a-8080-traffic-split")
+	assert.Equal(t, want.HTTP.Services, got.HTTP.Services)
+	assert.Equal(t, want.HTTP.Routers, got.HTTP.Routers)
+}
+
+func TestProvider_UpdateServiceConfig(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+		{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, annotations.BuildMiddlewares, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/annotations-middlewares-topology.json")
+	require.NoError(t, err)
+
+	got := p.BuildConfig(topo)
+
+	svcKey := topology.Key{Name: "svc-a", Namespace: "my-ns"}
+	topo.Services[svcKey].Annotations = map[string]string{"mesh.traefik.io/retry-attempts": "5"}
+
+	err = p.UpdateServiceConfig(topo, got, svcKey)
+	require.NoError(t, err)
+
+	// The updated configuration must be the same as a full build of the updated topology.
+	wantTopo, err := loadTopology("testdata/annotations-middlewares-topology.json")
+	require.NoError(t, err)
+
+	wantTopo.Services[svcKey].Annotations = map[string]string{"mesh.traefik.io/retry-attempts": "5"}
+
+	want := p.BuildConfig(wantTopo)
+
+	assert.Equal(t, want, got)
+
+	err = p.UpdateServiceConfig(wantTopo, want, topology.Key{Name: "unknown", Namespace: "my-ns"})
+	assert.Error(t, err)
+}
+
+func TestProvider_BuildConfigRemovesDeletedService(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+		{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, annotations.BuildMiddlewares, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/annotations-middlewares-topology.json")
+	require.NoError(t, err)
+
+	got := p.BuildConfig(topo)
+	require.Contains(t, got.HTTP.Routers, "my-ns-svc-b-8080")
+
+	delete(topo.Services, topology.Key{Name: "svc-b", Namespace: "my-ns"})
+
+	got = p.BuildConfig(topo)
+
+	assertNoServiceConfig(t, got, "my-ns-svc-b-")
+	assert.Contains(t, got.HTTP.Routers, "my-ns-svc-a-8080")
+	assert.Contains(t, got.HTTP.Services, "my-ns-svc-a-8080")
+}
+
+func TestProvider_UpdateServiceConfigPrunesDeletedServices(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+		{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, annotations.BuildMiddlewares, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/annotations-middlewares-topology.json")
+	require.NoError(t, err)
+
+	got := p.BuildConfig(topo)
+	require.Contains(t, got.HTTP.Routers, "my-ns-svc-b-8080")
+
+	// The deletion of svc-b has been missed, and only the middlewares of svc-a are updated afterwards.
+	delete(topo.Services, topology.Key{Name: "svc-b", Namespace: "my-ns"})
+
+	svcKey := topology.Key{Name: "svc-a", Namespace: "my-ns"}
+	topo.Services[svcKey].Annotations = map[string]string{"mesh.traefik.io/retry-attempts": "5"}
+
+	err = p.UpdateServiceConfig(topo, got, svcKey)
+	require.NoError(t, err)
+
+	assertNoServiceConfig(t, got, "my-ns-svc-b-")
+
+	// The updated configuration must be the same as a full build of the updated topology.
+	wantTopo, err := loadTopology("testdata/annotations-middlewares-topology.json")
+	require.NoError(t, err)
+
+	delete(wantTopo.Services, topology.Key{Name: "svc-b", Namespace: "my-ns"})
+	wantTopo.Services[svcKey].Annotations = map[string]string{"mesh.traefik.io/retry-attempts": "5"}
+
+	assert.Equal(t, p.BuildConfig(wantTopo), got)
+}
+
+// assertNoServiceConfig asserts that the given configuration has no router, service or middleware whose key starts with
+// the given prefix.
+func assertNoServiceConfig(t *testing.T, cfg *dynamic.Configuration, prefix string) {
+	t.Helper()
+
+	for key := range cfg.HTTP.Routers {
+		assert.False(t, strings.HasPrefix(key, prefix), "unexpected router %q", key)
+	}
+
+	for key := range cfg.HTTP.Services {
+		assert.False(t, strings.HasPrefix(key, prefix), "unexpected service %q", key)
+	}
+
+	for key := range cfg.HTTP.Middlewares {
+		assert.False(t, strings.HasPrefix(key, prefix), "unexpected middleware %q", key)
+	}
+}
+
+func TestProvider_BuildConfigWithAnnotationTrafficSplitUnknownBackend(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-a", Port: 8081}: 10001,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/acl-disabled-http-basic-topology.json")
+	require.NoError(t, err)
+
+	svcA := topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}]
+	require.NotNil(t, svcA)
+
+	svcA.Annotations = map[string]string{"mesh.traefik.io/traffic-split-backends": "svc-unknown:100"}
+
+	got := p.BuildConfig(topo)
+
+	assert.Len(t, svcA.Errors, 1)
+
+	for key := range got.HTTP.Services {
+		assert.NotContains(t, key, "traffic-split")
+	}
+}
+
+func TestProvider_BuildConfigWithMirrorUnknownService(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-a", Port: 8081}: 10001,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/acl-disabled-http-basic-topology.json")
+	require.NoError(t, err)
+
+	svcA := topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}]
+	require.NotNil(t, svcA)
+
+	svcA.Annotations = map[string]string{"mesh.traefik.io/mirror-service": "svc-unknown:20"}
+
+	got := p.BuildConfig(topo)
+
+	assert.Len(t, svcA.Errors, 1)
+
+	for key := range got.HTTP.Services {
+		assert.NotContains(t, key, "mirror")
+	}
+}
+
+func TestProvider_BuildConfigWithHeaderRoute(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+		{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/annotations-header-route-topology.json")
+	require.NoError(t, err)
+
+	got := p.BuildConfig(topo)
+
+	defaultRouter, ok := got.HTTP.Routers["my-ns-svc-a-8080"]
+	require.True(t, ok)
+
+	headerRouter, ok := got.HTTP.Routers["my-ns-svc-a-8080-header-route"]
+	require.True(t, ok)
+
+	assert.Equal(t, "my-ns-svc-a-8080-header-route", headerRouter.Service)
+	assert.Contains(t, headerRouter.Rule, "Headers(`X-Canary`, `true`)")
+	assert.Greater(t, headerRouter.Priority, defaultRouter.Priority)
+
+	svcC := topo.Services[topology.Key{Name: "svc-c", Namespace: "my-ns"}]
+	require.NotNil(t, svcC)
+	assert.Len(t, svcC.Errors, 1)
+}
+
+// TestProvider_BuildConfigWebSocket makes sure the generated configuration doesn't prevent the protocol upgrades of
+// WebSocket connections, and honors the flush interval of streaming responses.
+func TestProvider_BuildConfigWebSocket(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-a", Port: 8081}: 10001,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/annotations-websocket-topology.json")
+	require.NoError(t, err)
+
+	got := p.BuildConfig(topo)
+
+	for _, key := range []string{"my-ns-svc-a-8080", "my-ns-svc-a-8081"} {
+		svc, ok := got.HTTP.Services[key]
+		require.True(t, ok, key)
+		require.NotNil(t, svc.LoadBalancer, key)
+
+		// The Host header is passed, and the Connection and Upgrade headers are forwarded by Traefik.
+		require.NotNil(t, svc.LoadBalancer.PassHostHeader, key)
+		assert.True(t, *svc.LoadBalancer.PassHostHeader, key)
+
+		require.NotNil(t, svc.LoadBalancer.ResponseForwarding, key)
+		assert.Equal(t, "-1ms", svc.LoadBalancer.ResponseForwarding.FlushInterval, key)
+
+		router, ok := got.HTTP.Routers[key]
+		require.True(t, ok, key)
+		assert.Empty(t, router.Middlewares, key)
+	}
+
+	// Buffering the requests or the responses breaks the protocol upgrades.
+	for key, middleware := range got.HTTP.Middlewares {
+		assert.Nil(t, middleware.Buffering, key)
+	}
+}
+
+func TestProvider_BuildConfigACLDefaultPosture(t *testing.T) {
+	tests := []struct {
+		desc              string
+		defaultAllow      bool
+		wantSvcAReachable bool
+	}{
+		{
+			desc:              "default-deny",
+			defaultAllow:      false,
+			wantSvcAReachable: false,
+		},
+		{
+			desc:              "default-allow",
+			defaultAllow:      true,
+			wantSvcAReachable: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{
+				ACL:                true,
+				ACLDefaultAllow:    test.defaultAllow,
+				DefaultTrafficType: "http",
+			}
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology("testdata/acl-enabled-http-default-posture-topology.json")
+			require.NoError(t, err)
+
+			got := p.BuildConfig(topo)
+
+			// svc-a is not the destination of any TrafficTarget.
+			routerA, ok := got.HTTP.Routers["my-ns-svc-a-8080"]
+			require.True(t, ok)
+
+			if test.wantSvcAReachable {
+				assert.Equal(t, "my-ns-svc-a-8080", routerA.Service)
+				assert.Empty(t, routerA.Middlewares)
+				assert.Equal(t, []string{"http://10.10.4.1:8080"}, getServerURLs(t, got, "my-ns-svc-a-8080"))
+			} else {
+				assert.Equal(t, blockAllServiceKey, routerA.Service)
+				assert.Equal(t, []string{blockAllMiddlewareKey}, routerA.Middlewares)
+				assert.NotContains(t, got.HTTP.Services, "my-ns-svc-a-8080")
+			}
+
+			// svc-b remains restricted to the sources of its TrafficTarget whatever the default posture.
+			routerB, ok := got.HTTP.Routers["my-ns-svc-b-8080"]
+			require.True(t, ok)
+			assert.Equal(t, blockAllServiceKey, routerB.Service)
+
+			ttRouter, ok := got.HTTP.Routers["my-ns-svc-b-tt-8080-traffic-target-direct"]
+			require.True(t, ok)
+			assert.Equal(t, []string{"my-ns-svc-b-tt-whitelist-traffic-target-direct"}, ttRouter.Middlewares)
+		})
+	}
+}
+
+func TestProvider_BuildConfigWithDefaultMiddlewares(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{
+		DefaultTrafficType: "http",
+		DefaultMiddlewares: []annotations.MiddlewareRef{
+			{Namespace: "shared", Name: "headers"},
+			{Name: "auth"},
+		},
+	}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+		{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+		{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 10003,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/annotations-default-middlewares-topology.json")
+	require.NoError(t, err)
+
+	got := p.BuildConfig(topo)
+
+	// The default middlewares apply to services without annotations, the ones without a namespace being resolved in
+	// the namespace of the service.
+	router, ok := got.HTTP.Routers["my-ns-svc-a-8080"]
+	require.True(t, ok)
+	assert.Equal(t, []string{"shared-headers@kubernetescrd", "my-ns-auth@kubernetescrd"}, router.Middlewares)
+
+	// The default-middlewares annotation disables them.
+	router, ok = got.HTTP.Routers["my-ns-svc-b-8080"]
+	require.True(t, ok)
+	assert.Empty(t, router.Middlewares)
+
+	// Default middlewares already referenced by the service are not referenced twice, and keep the service order.
+	router, ok = got.HTTP.Routers["my-ns-svc-c-8080"]
+	require.True(t, ok)
+	assert.Equal(t, []string{"my-ns-auth@kubernetescrd", "shared-headers@kubernetescrd"}, router.Middlewares)
+
+	// An invalid default-middlewares annotation is reported on the service.
+	assert.NotContains(t, got.HTTP.Routers, "my-ns-svc-d-8080")
+	assert.NotEmpty(t, topo.Services[topology.Key{Name: "svc-d", Namespace: "my-ns"}].Errors)
+}
+
+func TestProvider_BuildConfigRouterPriorities(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+		{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/annotations-header-route-topology.json")
+	require.NoError(t, err)
+
+	svcA := topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}]
+	require.NotNil(t, svcA)
+
+	svcA.Annotations = map[string]string{
+		"mesh.traefik.io/traffic-split-backends": "svc-b:80,svc-c:20",
+		"mesh.traefik.io/header-route":           "X-Canary=true:svc-b",
+	}
+
+	// Build the configuration several times, as the priorities must not depend on the build order.
+	for i := 0; i < 5; i++ {
+		got := p.BuildConfig(topo)
+
+		require.Empty(t, svcA.Errors)
+
+		var routerKeys []string
+		for key, router := range got.HTTP.Routers {
+			if len(router.EntryPoints) > 0 && router.EntryPoints[0] == "http-10000" {
+				routerKeys = append(routerKeys, key)
+			}
+		}
+
+		sort.Slice(routerKeys, func(i, j int) bool {
+			return got.HTTP.Routers[routerKeys[i]].Priority > got.HTTP.Routers[routerKeys[j]].Priority
+		})
+
+		assert.Equal(t, []string{
+			"my-ns-svc-a-8080-header-route",
+			"my-ns-svc-a-svc-a-8080-traffic-split-direct",
+			"my-ns-svc-a-8080",
+		}, routerKeys)
+
+		for j := 1; j < len(routerKeys); j++ {
+			assert.NotEqual(t, got.HTTP.Routers[routerKeys[j-1]].Priority, got.HTTP.Routers[routerKeys[j]].Priority)
+		}
+	}
+}
+
+func TestProvider_BuildConfigTrafficSplitWeights(t *testing.T) {
+	tests := []struct {
+		desc        string
+		weights     []int
+		wantWeights []int
+	}{
+		{
+			desc:        "weights summing to 100",
+			weights:     []int{30, 70},
+			wantWeights: []int{3, 7},
+		},
+		{
+			desc:        "weights not summing to 100",
+			weights:     []int{1, 2},
+			wantWeights: []int{1, 2},
+		},
+		{
+			desc:        "zero weight",
+			weights:     []int{0, 50},
+			wantWeights: []int{0, 1},
+		},
+		{
+			desc:        "negative weight",
+			weights:     []int{-10, 20},
+			wantWeights: []int{0, 1},
+		},
+		{
+			desc:        "all weights zero",
+			weights:     []int{0, 0},
+			wantWeights: []int{1, 1},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{DefaultTrafficType: "http"}
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology("testdata/acl-disabled-http-traffic-split-topology.json")
+			require.NoError(t, err)
+
+			ts := topo.TrafficSplits[topology.Key{Name: "split", Namespace: "my-ns"}]
+			require.NotNil(t, ts)
+			require.Len(t, ts.Backends, len(test.weights))
+
+			for i, weight := range test.weights {
+				ts.Backends[i].Weight = weight
+			}
+
+			got := p.BuildConfig(topo)
+
+			svc, ok := got.HTTP.Services["my-ns-svc-a-split-8080-traffic-split"]
+			require.True(t, ok)
+			require.NotNil(t, svc.Weighted)
+			require.Len(t, svc.Weighted.Services, len(test.wantWeights))
+
+			for i, wantWeight := range test.wantWeights {
+				require.NotNil(t, svc.Weighted.Services[i].Weight)
+				assert.Equal(t, wantWeight, *svc.Weighted.Services[i].Weight)
+
+				// Normalized weights are exposed in the topology for debugging.
+				assert.Equal(t, wantWeight, ts.Backends[i].NormalizedWeight)
+			}
+		})
+	}
+}
+
+func TestProvider_BuildConfigTrafficSplitSticky(t *testing.T) {
+	tests := []struct {
+		desc            string
+		annotationSplit bool
+	}{
+		{
+			desc: "SMI TrafficSplit",
+		},
+		{
+			desc:            "traffic-split-backends annotation",
+			annotationSplit: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{DefaultTrafficType: "http"}
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology("testdata/acl-disabled-http-traffic-split-topology.json")
+			require.NoError(t, err)
+
+			svcA := topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}]
+			require.NotNil(t, svcA)
+
+			svcA.Annotations = map[string]string{
+				"mesh.traefik.io/traffic-split-sticky-cookie-name": "canary",
+				"mesh.traefik.io/sticky-cookie-secure":             "true",
+			}
+
+			wantSvcKey := "my-ns-svc-a-split-8080-traffic-split"
+
+			if test.annotationSplit {
+				topo.TrafficSplits = map[topology.Key]*topology.TrafficSplit{}
+				for _, svc := range topo.Services {
+					svc.TrafficSplits = nil
+					svc.BackendOf = nil
+				}
+
+				svcA.Annotations["mesh.traefik.io/traffic-split-backends"] = "svc-b:80,svc-c:20"
+				wantSvcKey = "my-ns-svc-a-svc-a-8080-traffic-split"
+			}
+
+			got := p.BuildConfig(topo)
+
+			assert.Empty(t, svcA.Errors)
+
+			svc, ok := got.HTTP.Services[wantSvcKey]
+			require.True(t, ok)
+			require.NotNil(t, svc.Weighted)
+
+			wantSticky := &dynamic.Sticky{Cookie: &dynamic.Cookie{Name: "canary", Secure: true}}
+			assert.Equal(t, wantSticky, svc.Weighted.Sticky)
+
+			require.Len(t, svc.Weighted.Services, 2)
+
+			for _, backend := range svc.Weighted.Services {
+				backendSvc, exists := got.HTTP.Services[backend.Name]
+				require.True(t, exists, backend.Name)
+				require.NotNil(t, backendSvc.LoadBalancer)
+
+				assert.Nil(t, backendSvc.LoadBalancer.Sticky)
+			}
+
+			// The traffic split sticky cookie doesn't make the service itself sticky.
+			ownSvc, ok := got.HTTP.Services["my-ns-svc-a-8080"]
+			require.True(t, ok)
+			assert.Nil(t, ownSvc.LoadBalancer.Sticky)
+		})
+	}
+}
+
+func TestProvider_BuildConfigTrafficSplitStickyWeights(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+		{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/acl-disabled-http-traffic-split-topology.json")
+	require.NoError(t, err)
+
+	svcA := topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}]
+	require.NotNil(t, svcA)
+
+	// The sticky cookie of the service pins its sessions to a backend when no traffic split sticky cookie is set.
+	svcA.Annotations = map[string]string{"mesh.traefik.io/sticky-cookie-name": "session"}
+
+	got := p.BuildConfig(topo)
+
+	assert.Empty(t, svcA.Errors)
+
+	svc, ok := got.HTTP.Services["my-ns-svc-a-split-8080-traffic-split"]
+	require.True(t, ok)
+	require.NotNil(t, svc.Weighted)
+
+	assert.Equal(t, &dynamic.Sticky{Cookie: &dynamic.Cookie{Name: "session"}}, svc.Weighted.Sticky)
+	assert.Equal(t, []dynamic.WRRService{
+		{Name: "my-ns-svc-a-split-8080-svc-b-traffic-split-backend", Weight: getIntRef(4)},
+		{Name: "my-ns-svc-a-split-8080-svc-c-traffic-split-backend", Weight: getIntRef(1)},
+	}, svc.Weighted.Services)
+
+	// The weights only affect the new sessions: the backend services, which are the values of the cookie, are kept.
+	ts := topo.TrafficSplits[topology.Key{Name: "split", Namespace: "my-ns"}]
+	ts.Backends[0].Weight = 50
+	ts.Backends[1].Weight = 50
+
+	got = p.BuildConfig(topo)
+
+	svc, ok = got.HTTP.Services["my-ns-svc-a-split-8080-traffic-split"]
+	require.True(t, ok)
+	require.NotNil(t, svc.Weighted)
+
+	assert.Equal(t, &dynamic.Sticky{Cookie: &dynamic.Cookie{Name: "session"}}, svc.Weighted.Sticky)
+	assert.Equal(t, []dynamic.WRRService{
+		{Name: "my-ns-svc-a-split-8080-svc-b-traffic-split-backend", Weight: getIntRef(1)},
+		{Name: "my-ns-svc-a-split-8080-svc-c-traffic-split-backend", Weight: getIntRef(1)},
+	}, svc.Weighted.Services)
+
+	// The traffic split sticky cookie overrides the cookie of the service.
+	svcA.Annotations["mesh.traefik.io/traffic-split-sticky-cookie-name"] = "canary"
+
+	got = p.BuildConfig(topo)
+
+	svc, ok = got.HTTP.Services["my-ns-svc-a-split-8080-traffic-split"]
+	require.True(t, ok)
+	require.NotNil(t, svc.Weighted)
+
+	assert.Equal(t, &dynamic.Sticky{Cookie: &dynamic.Cookie{Name: "canary"}}, svc.Weighted.Sticky)
+}
+
+func TestProvider_BuildConfigWithRouterRule(t *testing.T) {
+	const customRule = "Host(`svc-b.example.com`) && PathPrefix(`/api`)"
+
+	tests := []struct {
+		desc           string
+		acl            bool
+		topology       string
+		annotations    map[string]string
+		wantRouterKey  string
+		wantRule       string
+		wantSvcErrors  int
+		wantNoRouterOf string
+	}{
+		{
+			desc:          "custom rule replaces the default rule",
+			topology:      "testdata/acl-disabled-http-basic-topology.json",
+			annotations:   map[string]string{"mesh.traefik.io/router-rule": customRule},
+			wantRouterKey: "my-ns-svc-a-8080",
+			wantRule:      customRule,
+		},
+		{
+			desc:          "custom rule composes with the TrafficTarget conditions",
+			acl:           true,
+			topology:      "testdata/acl-enabled-http-route-group-topology.json",
+			annotations:   map[string]string{"mesh.traefik.io/router-rule": customRule},
+			wantRouterKey: "my-ns-svc-b-tt-8080-traffic-target-direct",
+			wantRule:      "(" + customRule + ") && (PathPrefix(`/{path:app}`) || (PathPrefix(`/{path:api/notifications}`) && Method(`GET`)) || HeadersRegexp(`User-Agent`, `Mozilla/.*`))",
+		},
+		{
+			desc:           "empty custom rule",
+			topology:       "testdata/acl-disabled-http-basic-topology.json",
+			annotations:    map[string]string{"mesh.traefik.io/router-rule": ""},
+			wantSvcErrors:  1,
+			wantNoRouterOf: "my-ns-svc-a-8080",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{ACL: test.acl, DefaultTrafficType: "http"}
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-a", Port: 8081}: 10001,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10002,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology(test.topology)
+			require.NoError(t, err)
+
+			for _, svc := range topo.Services {
+				svc.Annotations = test.annotations
+			}
+
+			got := p.BuildConfig(topo)
+
+			for _, svc := range topo.Services {
+				assert.Len(t, svc.Errors, test.wantSvcErrors)
+			}
+
+			if test.wantNoRouterOf != "" {
+				assert.NotContains(t, got.HTTP.Routers, test.wantNoRouterOf)
+				return
+			}
+
+			router, ok := got.HTTP.Routers[test.wantRouterKey]
+			require.True(t, ok)
+
+			assert.Equal(t, test.wantRule, router.Rule)
+		})
+	}
+}
+
+func TestProvider_BuildConfigIgnoredPorts(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := Config{DefaultTrafficType: "http"}
+	httpStateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+		{Namespace: "my-ns", Name: "svc-a", Port: 9090}: 10001,
+	}
+
+	p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+	topo, err := loadTopology("testdata/acl-disabled-http-multiple-ports-topology.json")
+	require.NoError(t, err)
+
+	svcA := topo.Services[topology.Key{Name: "svc-a", Namespace: "my-ns"}]
+	require.NotNil(t, svcA)
+
+	// The metrics port is excluded from the mesh, the topology builder only keeps the meshed ports.
+	svcA.Annotations = map[string]string{"mesh.traefik.io/ignore-ports": "metrics"}
+
+	svcA.Ports, err = topology.GetMeshedPorts(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: svcA.Name, Namespace: svcA.Namespace, Annotations: svcA.Annotations},
+		Spec:       corev1.ServiceSpec{Ports: svcA.Ports},
+	})
+	require.NoError(t, err)
+
+	got := p.BuildConfig(topo)
+
+	assert.Empty(t, svcA.Errors)
+	assert.Contains(t, got.HTTP.Routers, "my-ns-svc-a-8080")
+	assert.Contains(t, got.HTTP.Services, "my-ns-svc-a-8080")
+	assert.NotContains(t, got.HTTP.Routers, "my-ns-svc-a-9090")
+	assert.NotContains(t, got.HTTP.Services, "my-ns-svc-a-9090")
+}
+
+func TestProvider_BuildConfigH2CScheme(t *testing.T) {
+	tests := []struct {
+		desc            string
+		svcBAnnotations map[string]string
+		wantSvcErrors   int
+	}{
+		{
+			desc:            "h2c service",
+			svcBAnnotations: map[string]string{"mesh.traefik.io/scheme": "h2c"},
+		},
+		{
+			desc: "h2c service with HTTP/2 disabled",
+			svcBAnnotations: map[string]string{
+				"mesh.traefik.io/scheme":        "h2c",
+				"mesh.traefik.io/disable-http2": "true",
+			},
+			wantSvcErrors: 1,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{DefaultTrafficType: "http"}
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 10002,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology("testdata/annotations-scheme-topology.json")
+			require.NoError(t, err)
+
+			svcB := topo.Services[topology.Key{Name: "svc-b", Namespace: "my-ns"}]
+			require.NotNil(t, svcB)
+
+			svcB.Annotations = test.svcBAnnotations
+
+			got := p.BuildConfig(topo)
+
+			// The plain HTTP service keeps the default transport of the proxies.
+			svcC, ok := got.HTTP.Services["my-ns-svc-c-8080"]
+			require.True(t, ok)
+			require.NotNil(t, svcC.LoadBalancer)
+			assert.Empty(t, svcC.LoadBalancer.ServersTransport)
+			assert.NotContains(t, got.HTTP.ServersTransports, "my-ns-svc-c")
+
+			assert.Len(t, svcB.Errors, test.wantSvcErrors)
+
+			if test.wantSvcErrors > 0 {
+				assert.NotContains(t, got.HTTP.Services, "my-ns-svc-b-8080")
+				assert.NotContains(t, got.HTTP.ServersTransports, "my-ns-svc-b")
+
+				return
+			}
+
+			// The h2c service is forwarded over cleartext HTTP/2 by the default transport of the proxies.
+			svc, ok := got.HTTP.Services["my-ns-svc-b-8080"]
+			require.True(t, ok)
+			require.NotNil(t, svc.LoadBalancer)
+			require.Len(t, svc.LoadBalancer.Servers, 1)
+			assert.Equal(t, "h2c://10.10.2.3:8080", svc.LoadBalancer.Servers[0].URL)
+			assert.Empty(t, svc.LoadBalancer.ServersTransport)
+			assert.NotContains(t, got.HTTP.ServersTransports, "my-ns-svc-b")
+		})
+	}
+}
+
+func TestProvider_BuildConfigTrafficSplitCrossNamespaceBackends(t *testing.T) {
+	wantBackendKeys := []string{
+		"my-ns-svc-a-split-8080-svc-b-traffic-split-backend",
+		"my-ns-svc-a-split-8080-svc-b.canary-ns-traffic-split-backend",
+	}
+
+	tests := []struct {
+		desc        string
+		trafficType string
+	}{
+		{
+			desc:        "HTTP",
+			trafficType: "http",
+		},
+		{
+			desc:        "TCP",
+			trafficType: "tcp",
+		},
+		{
+			desc:        "UDP",
+			trafficType: "udp",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{DefaultTrafficType: test.trafficType}
+			stateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}:     10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}:     10001,
+				{Namespace: "canary-ns", Name: "svc-b", Port: 8080}: 10002,
+			}
+
+			p := New(&stateTableMock{stateTable}, &stateTableMock{stateTable}, &stateTableMock{stateTable}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology("testdata/acl-disabled-http-traffic-split-cross-namespace-topology.json")
+			require.NoError(t, err)
+
+			got := p.BuildConfig(topo)
+
+			// Each weighted service reference must point to a service targeting the backend of the right namespace.
+			hosts := make(map[string]string)
+
+			switch test.trafficType {
+			case "http":
+				svc, ok := got.HTTP.Services["my-ns-svc-a-split-8080-traffic-split"]
+				require.True(t, ok)
+				require.NotNil(t, svc.Weighted)
+
+				for _, ref := range svc.Weighted.Services {
+					backendSvc, ok := got.HTTP.Services[ref.Name]
+					require.True(t, ok, ref.Name)
+					require.Len(t, backendSvc.LoadBalancer.Servers, 1)
+
+					hosts[ref.Name] = backendSvc.LoadBalancer.Servers[0].URL
+				}
+			case "tcp":
+				svc, ok := got.TCP.Services["my-ns-svc-a-8080"]
+				require.True(t, ok)
+				require.NotNil(t, svc.Weighted)
+
+				for _, ref := range svc.Weighted.Services {
+					backendSvc, ok := got.TCP.Services[ref.Name]
+					require.True(t, ok, ref.Name)
+					require.Len(t, backendSvc.LoadBalancer.Servers, 1)
+
+					hosts[ref.Name] = backendSvc.LoadBalancer.Servers[0].Address
+				}
+			case "udp":
+				svc, ok := got.UDP.Services["my-ns-svc-a-8080"]
+				require.True(t, ok)
+				require.NotNil(t, svc.Weighted)
+
+				for _, ref := range svc.Weighted.Services {
+					backendSvc, ok := got.UDP.Services[ref.Name]
+					require.True(t, ok, ref.Name)
+					require.Len(t, backendSvc.LoadBalancer.Servers, 1)
+
+					hosts[ref.Name] = backendSvc.LoadBalancer.Servers[0].Address
+				}
+			}
+
+			require.Len(t, hosts, len(wantBackendKeys))
+			assert.Contains(t, hosts[wantBackendKeys[0]], "svc-b.my-ns.traefik.mesh:8080")
+			assert.Contains(t, hosts[wantBackendKeys[1]], "svc-b.canary-ns.traefik.mesh:8080")
+		})
+	}
+}
+
+func TestProvider_BuildConfigWithTrafficSplitNamedTargetPort(t *testing.T) {
+	stateTable := map[servicePort]int32{
+		{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 5000,
+		{Namespace: "my-ns", Name: "svc-a", Port: 8081}: 5001,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 5002,
+		{Namespace: "my-ns", Name: "svc-b", Port: 8081}: 5003,
+	}
+
+	tests := []struct {
+		desc        string
+		trafficType string
+		topology    string
+	}{
+		{
+			desc:        "TCP",
+			trafficType: "tcp",
+			topology:    "testdata/acl-disabled-tcp-basic-topology.json",
+		},
+		{
+			desc:        "UDP",
+			trafficType: "udp",
+			topology:    "testdata/acl-disabled-udp-basic-topology.json",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{DefaultTrafficType: test.trafficType}
+
+			p := New(&stateTableMock{}, &stateTableMock{stateTable}, &stateTableMock{stateTable}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology(test.topology)
+			require.NoError(t, err)
+
+			svcAKey := topology.Key{Name: "svc-a", Namespace: "my-ns"}
+			svcA := topo.Services[svcAKey]
+			require.NotNil(t, svcA)
+
+			// The 8081 port of svc-b targets a named port, which resolves to a different port on each pod.
+			svcBKey := topology.Key{Name: "svc-b", Namespace: "my-ns"}
+			svcB := *svcA
+			svcB.Name = svcBKey.Name
+			svcB.TrafficSplits = nil
+			topo.Services[svcBKey] = &svcB
+
+			svcA.Annotations = map[string]string{"mesh.traefik.io/traffic-split-backends": "svc-b:100"}
+
+			got := p.BuildConfig(topo)
+
+			assert.Empty(t, svcA.Errors)
+
+			// Addresses of the servers of each service, by service key.
+			addresses := make(map[string][]string)
+
+			switch test.trafficType {
+			case "tcp":
+				for key, svc := range got.TCP.Services {
+					if svc.LoadBalancer == nil {
+						continue
+					}
+
+					for _, server := range svc.LoadBalancer.Servers {
+						addresses[key] = append(addresses[key], server.Address)
+					}
+				}
+			case "udp":
+				for key, svc := range got.UDP.Services {
+					if svc.LoadBalancer == nil {
+						continue
+					}
+
+					for _, server := range svc.LoadBalancer.Servers {
+						addresses[key] = append(addresses[key], server.Address)
+					}
+				}
+			}
+
+			assert.ElementsMatch(t, []string{"10.10.2.1:8080", "10.10.2.2:8081"}, addresses["my-ns-svc-b-8081"])
+
+			// Traffic split backends target the shadow service of the backend on the service port.
+			var backendAddresses []string
+			for key, svcAddresses := range addresses {
+				if strings.HasSuffix(key, "traffic-split-backend") {
+					backendAddresses = append(backendAddresses, svcAddresses...)
+				}
+			}
+
+			assert.ElementsMatch(t, []string{"svc-b.my-ns.traefik.mesh:8080", "svc-b.my-ns.traefik.mesh:8081"}, backendAddresses)
+		})
+	}
+}
+
+func noopMiddlewareBuilder(_ map[string]string) (map[string]*dynamic.Middleware, error) {
+	return nil, nil
+}
+
+func basicAuthUsersGetterMock(namespace, name string) ([]string, error) {
+	if namespace == "my-ns" && name == "admin-users" {
+		return []string{"admin:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}, nil
+	}
+
+	return nil, fmt.Errorf("secret %s/%s not found", namespace, name)
+}
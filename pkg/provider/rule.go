@@ -2,10 +2,14 @@ package provider
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	"github.com/traefik/mesh/v2/pkg/annotations"
 	"github.com/traefik/mesh/v2/pkg/topology"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func buildHTTPRuleFromTrafficSpecs(specs []topology.TrafficSpec) string {
@@ -80,23 +84,41 @@ func appendMethodFilter(matchParts []string, match specs.HTTPMatch) []string {
 }
 
 func appendHeaderFilter(matchParts []string, match specs.HTTPMatch) []string {
-	rules := make([]string, 0, len(match.Headers))
-
-	for name, value := range match.Headers {
-		rules = append(rules, fmt.Sprintf("HeadersRegexp(`%s`, `%s`)", name, value))
+	names := make([]string, 0, len(match.Headers))
+	for name := range match.Headers {
+		names = append(names, name)
 	}
 
-	if len(rules) > 0 {
-		matchParts = append(matchParts, strings.Join(rules, " && "))
+	// Sort header names to generate the same rule for a given match on every build.
+	sort.Strings(names)
+
+	// Each header is a distinct condition of the match, so that it gets properly grouped with the other conditions.
+	for _, name := range names {
+		matchParts = append(matchParts, fmt.Sprintf("HeadersRegexp(`%s`, `%s`)", name, match.Headers[name]))
 	}
 
 	return matchParts
 }
 
+// buildHTTPRuleFromService builds the rule matching the requests to the given service. It is the router-rule
+// annotation of the service when set, which is validated before the routers of the service are built.
 func buildHTTPRuleFromService(svc *topology.Service) string {
+	if rule, err := annotations.GetRouterRule(svc.Annotations); err == nil {
+		return rule
+	}
+
+	// ExternalName services don't have a ClusterIP, and headless services are not reachable through it.
+	if svc.ClusterIP == "" || svc.ClusterIP == corev1.ClusterIPNone {
+		return fmt.Sprintf("Host(`%s.%s.traefik.mesh`)", svc.Name, svc.Namespace)
+	}
+
 	return fmt.Sprintf("Host(`%[1]s.%[2]s.traefik.mesh`) || Host(`%[3]s`)", svc.Name, svc.Namespace, svc.ClusterIP)
 }
 
+func buildHTTPRuleFromHeaderRoute(svc *topology.Service, header, value string) string {
+	return fmt.Sprintf("(%s) && Headers(`%s`, `%s`)", buildHTTPRuleFromService(svc), header, value)
+}
+
 func buildHTTPRuleFromTrafficTarget(tt *topology.ServiceTrafficTarget, ttSvc *topology.Service) string {
 	ttRule := buildHTTPRuleFromTrafficSpecs(tt.Rules)
 	svcRule := buildHTTPRuleFromService(ttSvc)
@@ -131,6 +153,28 @@ func buildHTTPRuleFromTrafficTargetIndirect(tt *topology.ServiceTrafficTarget, t
 	return fmt.Sprintf("(%s) && %s", svcRule, indirectRule)
 }
 
+// buildHTTPRuleFromTrafficTargetSourceDirect restricts the given direct rule of a ServiceTrafficTarget to the requests
+// sent by the given source IPs. The proxy entrypoints set the X-Real-Ip header to the address of the client, unless
+// the client already provided it, so a spoofed header must still be rejected by a whitelist on the remote address.
+func buildHTTPRuleFromTrafficTargetSourceDirect(ttRule string, IPs []string) string {
+	return fmt.Sprintf("(%s) && HeadersRegexp(`X-Real-Ip`, `^(%s)$`)", ttRule, buildIPsRegexp(IPs))
+}
+
+// buildHTTPRuleFromTrafficTargetSourceIndirect restricts the given indirect rule of a ServiceTrafficTarget to the
+// requests forwarded on behalf of the given source IPs, which are the last entry of the X-Forwarded-For header.
+func buildHTTPRuleFromTrafficTargetSourceIndirect(ttRule string, IPs []string) string {
+	return fmt.Sprintf("(%s) && HeadersRegexp(`X-Forwarded-For`, `(^|[ ,])(%s)$`)", ttRule, buildIPsRegexp(IPs))
+}
+
+func buildIPsRegexp(IPs []string) string {
+	quoted := make([]string, len(IPs))
+	for i, IP := range IPs {
+		quoted[i] = regexp.QuoteMeta(IP)
+	}
+
+	return strings.Join(quoted, "|")
+}
+
 func buildHTTPRuleFromTrafficSplitIndirect(ts *topology.TrafficSplit, tsSvc *topology.Service) string {
 	tsRule := buildHTTPRuleFromTrafficSpecs(ts.Rules)
 	svcRule := buildHTTPRuleFromService(tsSvc)
@@ -147,9 +191,54 @@ func buildTCPRouterRule() string {
 	return "HostSNI(`*`)"
 }
 
-func getRulePriority(rule string, priority int) int {
-	andOps := strings.Count(rule, "&&")
-	orOps := strings.Count(rule, "||")
+func buildTCPRouterRuleFromService(svc *topology.Service) string {
+	return fmt.Sprintf("HostSNI(`%s.%s.traefik.mesh`)", svc.Name, svc.Namespace)
+}
+
+const (
+	// priorityClassRange is the range of priorities of a priority class.
+	priorityClassRange = 1000000
+	// priorityConditionRange is the range of priorities of the rules of a priority class with the same number of
+	// conditions.
+	priorityConditionRange = 1000
+)
+
+// getRulePriority returns the priority of a router with the given rule and priority class. Routers of a higher class
+// always win. Within a class, rules with more conditions are more specific and win, and ties are broken by the rule
+// length, as Traefik does for routers without priority. This makes the routing deterministic: Traefik doesn't
+// guarantee the order of routers with the same priority.
+func getRulePriority(rule string, priorityClass int) int {
+	conditions := countRuleConditions(rule)
+	if maxConditions := priorityClassRange/priorityConditionRange - 1; conditions > maxConditions {
+		conditions = maxConditions
+	}
+
+	length := len(rule)
+	if length >= priorityConditionRange {
+		length = priorityConditionRange - 1
+	}
+
+	return priorityClass*priorityClassRange + conditions*priorityConditionRange + length
+}
+
+// countRuleConditions returns the number of conditions which must all be fulfilled by the requests matching the given
+// rule, that is the number of && operators outside of the matcher values. Alternatives (||) don't make a rule more
+// specific and are not counted.
+func countRuleConditions(rule string) int {
+	var (
+		conditions int
+		quoted     bool
+	)
+
+	for i := 0; i < len(rule); i++ {
+		switch {
+		case rule[i] == '`':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(rule[i:], "&&"):
+			conditions++
+			i++
+		}
+	}
 
-	return priority*1000 + (andOps + orOps)
+	return conditions
 }
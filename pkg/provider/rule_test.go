@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	"github.com/stretchr/testify/assert"
+	"github.com/traefik/mesh/v2/pkg/topology"
+)
+
+func TestBuildHTTPRuleFromTrafficSpecs(t *testing.T) {
+	tests := []struct {
+		desc     string
+		matches  []specs.HTTPMatch
+		expected string
+	}{
+		{
+			desc: "path prefix and header",
+			matches: []specs.HTTPMatch{
+				{
+					Name:      "v2",
+					PathRegex: "/v2",
+					Headers:   map[string]string{"X-Canary": "true"},
+				},
+			},
+			expected: "(PathPrefix(`/{path:v2}`) && HeadersRegexp(`X-Canary`, `true`))",
+		},
+		{
+			desc: "multiple headers are sorted",
+			matches: []specs.HTTPMatch{
+				{
+					Name: "headers",
+					Headers: map[string]string{
+						"X-Version":  "v2",
+						"User-Agent": "Mozilla/.*",
+					},
+				},
+			},
+			expected: "(HeadersRegexp(`User-Agent`, `Mozilla/.*`) && HeadersRegexp(`X-Version`, `v2`))",
+		},
+		{
+			desc: "multiple matches",
+			matches: []specs.HTTPMatch{
+				{
+					Name:      "api",
+					PathRegex: "/api",
+					Methods:   []string{"GET"},
+				},
+				{
+					Name:    "firefox",
+					Headers: map[string]string{"User-Agent": "Mozilla/.*"},
+				},
+			},
+			expected: "(PathPrefix(`/{path:api}`) && Method(`GET`)) || HeadersRegexp(`User-Agent`, `Mozilla/.*`)",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			trafficSpecs := []topology.TrafficSpec{
+				{
+					HTTPRouteGroup: &specs.HTTPRouteGroup{
+						Spec: specs.HTTPRouteGroupSpec{Matches: test.matches},
+					},
+				},
+			}
+
+			assert.Equal(t, test.expected, buildHTTPRuleFromTrafficSpecs(trafficSpecs))
+		})
+	}
+}
+
+func TestGetRulePriority(t *testing.T) {
+	tests := []struct {
+		desc          string
+		rule          string
+		priorityClass int
+		higherRule    string
+		higherClass   int
+	}{
+		{
+			desc:          "higher priority class wins",
+			rule:          "(Host(`svc-a.my-ns.traefik.mesh`) || Host(`10.10.14.1`)) && (PathPrefix(`/{path:api}`) && Method(`GET`))",
+			priorityClass: priorityTrafficTargetDirect,
+			higherRule:    "Host(`svc-a.my-ns.traefik.mesh`) || Host(`10.10.14.1`)",
+			higherClass:   priorityTrafficSplit,
+		},
+		{
+			desc:          "more conditions win",
+			rule:          "(Host(`svc-a.my-ns.traefik.mesh`) || Host(`10.10.14.1`)) && (PathPrefix(`/{path:api/v1/long/prefix}`))",
+			priorityClass: priorityTrafficTargetDirect,
+			higherRule:    "(Host(`svc-a.my-ns.traefik.mesh`) || Host(`10.10.14.1`)) && (PathPrefix(`/{path:api}`) && Method(`GET`))",
+			higherClass:   priorityTrafficTargetDirect,
+		},
+		{
+			desc:          "alternatives don't make a rule more specific",
+			rule:          "(Host(`svc-a.my-ns.traefik.mesh`) || Host(`10.10.14.1`)) && (PathPrefix(`/{path:a}`) || PathPrefix(`/{path:b}`) || PathPrefix(`/{path:c}`))",
+			priorityClass: priorityTrafficTargetDirect,
+			higherRule:    "(Host(`svc-a.my-ns.traefik.mesh`) || Host(`10.10.14.1`)) && (PathPrefix(`/{path:a}`) && Method(`GET`))",
+			higherClass:   priorityTrafficTargetDirect,
+		},
+		{
+			desc:          "operators in matcher values are ignored",
+			rule:          "(Host(`svc-a.my-ns.traefik.mesh`)) && HeadersRegexp(`X-Test`, `a&&b&&c`)",
+			priorityClass: priorityTrafficTargetDirect,
+			higherRule:    "(Host(`svc-a.my-ns.traefik.mesh`)) && (PathPrefix(`/{path:a}`) && Method(`GET`))",
+			higherClass:   priorityTrafficTargetDirect,
+		},
+		{
+			desc:          "longer rule wins on ties",
+			rule:          "(Host(`svc-a.my-ns.traefik.mesh`)) && PathPrefix(`/{path:a}`)",
+			priorityClass: priorityTrafficTargetDirect,
+			higherRule:    "(Host(`svc-a.my-ns.traefik.mesh`)) && PathPrefix(`/{path:api}`)",
+			higherClass:   priorityTrafficTargetDirect,
+		},
+		{
+			desc:          "conditions never overflow the priority class",
+			rule:          "Host(`a`)" + strings.Repeat(" && Host(`a`)", 2000),
+			priorityClass: priorityService,
+			higherRule:    "Host(`a`)",
+			higherClass:   priorityTrafficTargetDirect,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Greater(t, getRulePriority(test.higherRule, test.higherClass), getRulePriority(test.rule, test.priorityClass))
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+)
+
+// ConfigurationForZone returns the dynamic configuration of the proxies running in the given zone. The HTTP services
+// of the zone-aware services are replaced by their variant load-balancing between the pods of this zone only, when
+// such pods are available. Otherwise, the services keep load-balancing between all the pods. The given configuration
+// is not modified.
+func ConfigurationForZone(cfg *dynamic.Configuration, zone string) *dynamic.Configuration {
+	if zone == "" || cfg == nil || cfg.HTTP == nil {
+		return cfg
+	}
+
+	services := make(map[string]*dynamic.Service, len(cfg.HTTP.Services))
+
+	for key, svc := range cfg.HTTP.Services {
+		services[key] = svc
+
+		if zoneSvc, ok := cfg.HTTP.Services[getZoneServiceKey(key, zone)]; ok {
+			services[key] = zoneSvc
+		}
+	}
+
+	httpCfg := *cfg.HTTP
+	httpCfg.Services = services
+
+	zoneCfg := *cfg
+	zoneCfg.HTTP = &httpCfg
+
+	return &zoneCfg
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+)
+
+func TestConfigurationForZone(t *testing.T) {
+	tests := []struct {
+		desc         string
+		zone         string
+		wantServersA []string
+	}{
+		{
+			desc:         "same-zone pods are preferred",
+			zone:         "zone-a",
+			wantServersA: []string{"http://10.10.2.1:8080", "http://10.10.2.3:8080"},
+		},
+		{
+			desc:         "single same-zone pod",
+			zone:         "zone-b",
+			wantServersA: []string{"http://10.10.2.2:8080"},
+		},
+		{
+			desc:         "no same-zone pod falls back to all pods",
+			zone:         "zone-c",
+			wantServersA: []string{"http://10.10.2.1:8080", "http://10.10.2.2:8080", "http://10.10.2.3:8080", "http://10.10.2.4:8080"},
+		},
+		{
+			desc:         "no zone",
+			wantServersA: []string{"http://10.10.2.1:8080", "http://10.10.2.2:8080", "http://10.10.2.3:8080", "http://10.10.2.4:8080"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			cfg := Config{DefaultTrafficType: "http"}
+			httpStateTable := map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 10000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 10001,
+			}
+
+			p := New(&stateTableMock{httpStateTable}, &stateTableMock{}, &stateTableMock{}, noopMiddlewareBuilder, nil, cfg, logger)
+
+			topo, err := loadTopology("testdata/annotations-zone-aware-topology.json")
+			require.NoError(t, err)
+
+			built := p.BuildConfig(topo)
+
+			got := ConfigurationForZone(built, test.zone)
+
+			assert.Equal(t, test.wantServersA, getServerURLs(t, got, "my-ns-svc-a-8080"))
+
+			// Services without the zone-aware annotation always load-balance between all their pods.
+			assert.Equal(t, []string{"http://10.10.2.5:8080"}, getServerURLs(t, got, "my-ns-svc-b-8080"))
+
+			// The built configuration is left unchanged.
+			assert.Len(t, getServerURLs(t, built, "my-ns-svc-a-8080"), 4)
+		})
+	}
+}
+
+func getServerURLs(t *testing.T, cfg *dynamic.Configuration, svcKey string) []string {
+	t.Helper()
+
+	svc, ok := cfg.HTTP.Services[svcKey]
+	require.True(t, ok, svcKey)
+	require.NotNil(t, svc.LoadBalancer, svcKey)
+
+	var urls []string
+	for _, server := range svc.LoadBalancer.Servers {
+		urls = append(urls, server.URL)
+	}
+
+	return urls
+}
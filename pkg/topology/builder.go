@@ -2,6 +2,10 @@ package topology
 
 import (
 	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 
 	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
 	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
@@ -10,29 +14,43 @@ import (
 	speclister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/specs/listers/specs/v1alpha3"
 	splitlister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/split/listers/split/v1alpha3"
 	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/v2/pkg/annotations"
 	mk8s "github.com/traefik/mesh/v2/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	listers "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
 )
 
 // Builder builds Topology objects based on the current state of a kubernetes cluster.
 type Builder struct {
 	serviceLister        listers.ServiceLister
 	endpointsLister      listers.EndpointsLister
+	endpointSliceLister  discoverylisters.EndpointSliceLister
 	podLister            listers.PodLister
+	nodeLister           listers.NodeLister
 	trafficTargetLister  accesslister.TrafficTargetLister
 	trafficSplitLister   splitlister.TrafficSplitLister
 	httpRouteGroupLister speclister.HTTPRouteGroupLister
 	tcpRoutesLister      speclister.TCPRouteLister
 	logger               logrus.FieldLogger
+	// workers is the number of endpoints, services, traffic-splits and traffic-targets resolved concurrently, they are
+	// resolved one at a time when lower than 2.
+	workers int
 }
 
-// NewBuilder creates and returns a new topology Builder instance.
+// NewBuilder creates and returns a new topology Builder instance. SMI listers can be nil, in which case the
+// corresponding SMI resources are not part of the built topologies. When an EndpointSlice lister is given, service pods
+// are resolved using EndpointSlices instead of Endpoints. When a Node lister is given, pods are assigned the zone of
+// their node.
 func NewBuilder(
 	serviceLister listers.ServiceLister,
 	endpointLister listers.EndpointsLister,
+	endpointSliceLister discoverylisters.EndpointSliceLister,
 	podLister listers.PodLister,
+	nodeLister listers.NodeLister,
 	trafficTargetLister accesslister.TrafficTargetLister,
 	trafficSplitLister splitlister.TrafficSplitLister,
 	httpRouteGroupLister speclister.HTTPRouteGroupLister,
@@ -42,12 +60,15 @@ func NewBuilder(
 	return &Builder{
 		serviceLister:        serviceLister,
 		endpointsLister:      endpointLister,
+		endpointSliceLister:  endpointSliceLister,
 		podLister:            podLister,
+		nodeLister:           nodeLister,
 		trafficTargetLister:  trafficTargetLister,
 		trafficSplitLister:   trafficSplitLister,
 		httpRouteGroupLister: httpRouteGroupLister,
 		tcpRoutesLister:      tcpRoutesLister,
 		logger:               logger,
+		workers:              runtime.GOMAXPROCS(0),
 	}
 }
 
@@ -62,57 +83,240 @@ func (b *Builder) Build(resourceFilter *mk8s.ResourceFilter) (*Topology, error)
 	}
 
 	// Populate services.
-	for _, svc := range res.Services {
-		b.evaluateService(res, topology, svc)
-	}
+	b.evaluateServices(res, topology)
 
 	// Populate services with traffic-split definitions.
-	for _, ts := range res.TrafficSplits {
-		b.evaluateTrafficSplit(res, topology, ts)
-	}
+	b.evaluateTrafficSplits(res, topology)
 
 	// Populate services with traffic-target definitions.
-	for _, tt := range res.TrafficTargets {
-		b.evaluateTrafficTarget(res, topology, tt)
-	}
+	b.evaluateTrafficTargets(res, topology)
 
 	b.populateTrafficSplitsAuthorizedIncomingTraffic(topology)
 
+	// Report the endpoint conditions of the pods selected by services.
+	for podKey, conditions := range res.PodConditions {
+		if pod, ok := topology.Pods[podKey]; ok {
+			pod.NotReady = !conditions.Ready
+			pod.Terminating = conditions.Terminating
+		}
+	}
+
+	for podKey, zone := range res.PodZones {
+		if pod, ok := topology.Pods[podKey]; ok {
+			pod.Zone = zone
+		}
+	}
+
 	return topology, nil
 }
 
-// evaluateService evaluates the given service. It adds the Service to the topology and its selected Pods.
-func (b *Builder) evaluateService(res *resources, topology *Topology, svc *corev1.Service) {
+// forEachConcurrently calls fn with each index lower than count, using a bounded pool of workers. The indexes are
+// processed one at a time when there are less than 2 workers.
+func forEachConcurrently(workers, count int, fn func(index int)) {
+	if workers > count {
+		workers = count
+	}
+
+	if workers < 2 {
+		for i := 0; i < count; i++ {
+			fn(i)
+		}
+
+		return
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				fn(index)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+}
+
+// sortKeys sorts the given keys by namespace, then by name.
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+
+		return keys[i].Name < keys[j].Name
+	})
+}
+
+// serviceEvaluation holds a Service resolved from a Kubernetes service, along with its selected Pods.
+type serviceEvaluation struct {
+	key     Key
+	service *Service
+	pods    []*Pod
+}
+
+// evaluateServices evaluates the services of the given resources concurrently. The evaluations are merged into the
+// topology in the order of the service keys, which keeps the built topology deterministic.
+func (b *Builder) evaluateServices(res *resources, topology *Topology) {
+	svcKeys := make([]Key, 0, len(res.Services))
+	for svcKey := range res.Services {
+		svcKeys = append(svcKeys, svcKey)
+	}
+
+	sortKeys(svcKeys)
+
+	evaluations := make([]serviceEvaluation, len(svcKeys))
+
+	forEachConcurrently(b.workers, len(svcKeys), func(index int) {
+		evaluations[index] = evaluateService(res, res.Services[svcKeys[index]])
+	})
+
+	for _, evaluation := range evaluations {
+		for _, pod := range evaluation.pods {
+			podKey := Key{pod.Name, pod.Namespace}
+
+			if _, ok := topology.Pods[podKey]; !ok {
+				topology.Pods[podKey] = pod
+			}
+		}
+
+		topology.Services[evaluation.key] = evaluation.service
+	}
+}
+
+// evaluateService evaluates the given service. It resolves the Service and its selected Pods without modifying the
+// topology, so that services can be evaluated concurrently.
+func evaluateService(res *resources, svc *corev1.Service) serviceEvaluation {
 	svcKey := Key{svc.Name, svc.Namespace}
 
 	svcPods := res.PodsBySvc[svcKey]
-	pods := make([]Key, len(svcPods))
+	podKeys := make([]Key, len(svcPods))
+	pods := make([]*Pod, len(svcPods))
 
 	for i, pod := range svcPods {
-		pods[i] = getOrCreatePod(topology, pod)
+		podKeys[i] = Key{pod.Name, pod.Namespace}
+		pods[i] = newPod(pod)
 	}
 
-	topology.Services[svcKey] = &Service{
+	service := &Service{
 		Name:        svc.Name,
 		Namespace:   svc.Namespace,
 		Selector:    svc.Spec.Selector,
 		Annotations: svc.Annotations,
-		Ports:       svc.Spec.Ports,
 		ClusterIP:   svc.Spec.ClusterIP,
-		Pods:        pods,
+		Pods:        podKeys,
+	}
+
+	// The ports excluded from the mesh are not part of the topology, so that no configuration is built for them. None
+	// of the ports are meshed when the ignore-ports annotation is invalid, as no shadow service is synced either.
+	ports, err := GetMeshedPorts(svc)
+	if err != nil {
+		service.AddError(fmt.Errorf("unable to evaluate ignore-ports annotation: %w", err))
+	}
+
+	service.Ports = ports
+
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		service.ExternalName = svc.Spec.ExternalName
 	}
+
+	return serviceEvaluation{key: svcKey, service: service, pods: pods}
+}
+
+// serviceTrafficTargetEvaluation holds a ServiceTrafficTarget resolved from a TrafficTarget, along with the destination
+// pods it selects and whether it applies to its Service.
+type serviceTrafficTargetEvaluation struct {
+	key                  ServiceTrafficTargetKey
+	serviceTrafficTarget *ServiceTrafficTarget
+	pods                 []*corev1.Pod
+	attached             bool
 }
 
-// evaluateTrafficTarget evaluates the given traffic-target. It adds a ServiceTrafficTargets on every Service which
-// has pods with a service-account being the one defined in the traffic-target destination.
-// When a ServiceTrafficTarget gets added to a Service, each source and destination pod will be added to the topology
-// and linked to it.
-func (b *Builder) evaluateTrafficTarget(res *resources, topology *Topology, tt *access.TrafficTarget) {
+// trafficTargetEvaluation holds the ServiceTrafficTargets resolved from a TrafficTarget, along with its sources and the
+// source pods they select.
+type trafficTargetEvaluation struct {
+	sources               []ServiceTrafficTargetSource
+	pods                  []*corev1.Pod
+	serviceTrafficTargets []*serviceTrafficTargetEvaluation
+}
+
+// evaluateTrafficTargets evaluates the traffic-targets of the given resources concurrently. The evaluations are merged
+// into the topology in the order of the traffic-target keys, which keeps the built topology deterministic.
+func (b *Builder) evaluateTrafficTargets(res *resources, topology *Topology) {
+	ttKeys := make([]Key, 0, len(res.TrafficTargets))
+	for ttKey := range res.TrafficTargets {
+		ttKeys = append(ttKeys, ttKey)
+	}
+
+	sortKeys(ttKeys)
+
+	evaluations := make([]trafficTargetEvaluation, len(ttKeys))
+
+	forEachConcurrently(b.workers, len(ttKeys), func(index int) {
+		evaluations[index] = b.evaluateTrafficTarget(res, topology, res.TrafficTargets[ttKeys[index]])
+	})
+
+	for _, evaluation := range evaluations {
+		for _, pod := range evaluation.pods {
+			addPod(topology, pod)
+		}
+
+		for _, svcTT := range evaluation.serviceTrafficTargets {
+			topology.ServiceTrafficTargets[svcTT.key] = svcTT.serviceTrafficTarget
+
+			for _, pod := range svcTT.pods {
+				addPod(topology, pod)
+			}
+
+			if !svcTT.attached {
+				continue
+			}
+
+			svc := topology.Services[svcTT.key.Service]
+			svc.TrafficTargets = append(svc.TrafficTargets, svcTT.key)
+
+			// Add the ServiceTrafficTarget to the source and destination pods.
+			addSourceAndDestinationToPods(topology, evaluation.sources, svcTT.key)
+		}
+	}
+}
+
+// evaluateTrafficTarget evaluates the given traffic-target. It resolves a ServiceTrafficTarget for every Service which
+// has pods with a service-account being the one defined in the traffic-target destination. The topology is not
+// modified, so that traffic-targets can be evaluated concurrently: once merged, each source and destination pod of a
+// ServiceTrafficTarget is added to the topology and linked to it.
+func (b *Builder) evaluateTrafficTarget(res *resources, topology *Topology, tt *access.TrafficTarget) trafficTargetEvaluation {
 	destSaKey := Key{tt.Spec.Destination.Name, tt.Spec.Destination.Namespace}
 
-	sources := b.buildTrafficTargetSources(res, topology, tt)
+	sources, srcPods := b.buildTrafficTargetSources(res, tt)
+
+	evaluation := trafficTargetEvaluation{
+		sources: sources,
+		pods:    srcPods,
+	}
+
+	podsBySvc := res.PodsBySvcBySa[destSaKey]
+
+	svcKeys := make([]Key, 0, len(podsBySvc))
+	for svcKey := range podsBySvc {
+		svcKeys = append(svcKeys, svcKey)
+	}
+
+	sortKeys(svcKeys)
 
-	for svcKey, pods := range res.PodsBySvcBySa[destSaKey] {
+	for _, svcKey := range svcKeys {
 		stt := &ServiceTrafficTarget{
 			Name:      tt.Name,
 			Namespace: tt.Namespace,
@@ -124,7 +328,9 @@ func (b *Builder) evaluateTrafficTarget(res *resources, topology *Topology, tt *
 			Service:       svcKey,
 			TrafficTarget: Key{tt.Name, tt.Namespace},
 		}
-		topology.ServiceTrafficTargets[svcTTKey] = stt
+
+		svcTT := &serviceTrafficTargetEvaluation{key: svcTTKey, serviceTrafficTarget: stt}
+		evaluation.serviceTrafficTargets = append(evaluation.serviceTrafficTargets, svcTT)
 
 		svc, ok := topology.Services[svcKey]
 		if !ok {
@@ -146,7 +352,7 @@ func (b *Builder) evaluateTrafficTarget(res *resources, topology *Topology, tt *
 			continue
 		}
 
-		stt.Destination, err = b.buildTrafficTargetDestination(topology, tt, pods, svc)
+		stt.Destination, svcTT.pods, err = b.buildTrafficTargetDestination(tt, podsBySvc[svcKey], svc)
 		if err != nil {
 			stt.AddError(err)
 			b.logger.Errorf("Error building topology for TrafficTarget %q: %v", Key{tt.Name, tt.Namespace}, err)
@@ -154,37 +360,42 @@ func (b *Builder) evaluateTrafficTarget(res *resources, topology *Topology, tt *
 			continue
 		}
 
-		svc.TrafficTargets = append(svc.TrafficTargets, svcTTKey)
-
-		// Add the ServiceTrafficTarget to the source and destination pods.
-		addSourceAndDestinationToPods(topology, sources, svcTTKey)
+		svcTT.attached = true
 	}
+
+	return evaluation
 }
 
-func (b *Builder) buildTrafficTargetDestination(topology *Topology, tt *access.TrafficTarget, pods []*corev1.Pod, svc *Service) (ServiceTrafficTargetDestination, error) {
+// buildTrafficTargetDestination builds the destination of the given TrafficTarget on the given Service, along with the
+// destination pods having an IP.
+func (b *Builder) buildTrafficTargetDestination(tt *access.TrafficTarget, pods []*corev1.Pod, svc *Service) (ServiceTrafficTargetDestination, []*corev1.Pod, error) {
 	dest := ServiceTrafficTargetDestination{
 		ServiceAccount: tt.Spec.Destination.Name,
 		Namespace:      tt.Spec.Destination.Namespace,
 	}
 
 	// Find out which are the destination pods.
+	var destPods []*corev1.Pod
+
 	for _, pod := range pods {
 		if pod.Status.PodIP == "" {
 			continue
 		}
 
-		dest.Pods = append(dest.Pods, getOrCreatePod(topology, pod))
+		destPods = append(destPods, pod)
 	}
 
-	var err error
+	// Find out which ports can be used on the destination service, and which destination pods expose them.
+	ports, portPods, err := b.getTrafficTargetDestinationPorts(svc, tt, destPods)
+
+	dest.Ports = ports
+	dest.Pods = getPodKeys(portPods)
 
-	// Find out which ports can be used on the destination service.
-	dest.Ports, err = b.getTrafficTargetDestinationPorts(svc, tt)
 	if err != nil {
-		return dest, fmt.Errorf("unable to find destination ports on Service %q: %w", Key{Namespace: svc.Namespace, Name: svc.Name}, err)
+		return dest, destPods, fmt.Errorf("unable to find destination ports on Service %q: %w", Key{Namespace: svc.Namespace, Name: svc.Name}, err)
 	}
 
-	return dest, nil
+	return dest, destPods, nil
 }
 
 func addSourceAndDestinationToPods(topology *Topology, sources []ServiceTrafficTargetSource, svcTTKey ServiceTrafficTargetKey) {
@@ -209,9 +420,51 @@ func addSourceAndDestinationToPods(topology *Topology, sources []ServiceTrafficT
 	}
 }
 
-// evaluateTrafficSplit evaluates the given traffic-split. If the traffic-split targets a known Service, a new TrafficSplit
-// will be added to it. The TrafficSplit will be added only if all its backends expose the ports required by the Service.
-func (b *Builder) evaluateTrafficSplit(res *resources, topology *Topology, trafficSplit *split.TrafficSplit) {
+// trafficSplitEvaluation holds a TrafficSplit resolved from an SMI TrafficSplit, and whether it applies to its Service.
+type trafficSplitEvaluation struct {
+	key          Key
+	trafficSplit *TrafficSplit
+	attached     bool
+}
+
+// evaluateTrafficSplits evaluates the traffic-splits of the given resources concurrently. The evaluations are merged
+// into the topology in the order of the traffic-split keys, which keeps the built topology deterministic.
+func (b *Builder) evaluateTrafficSplits(res *resources, topology *Topology) {
+	tsKeys := make([]Key, 0, len(res.TrafficSplits))
+	for tsKey := range res.TrafficSplits {
+		tsKeys = append(tsKeys, tsKey)
+	}
+
+	sortKeys(tsKeys)
+
+	evaluations := make([]trafficSplitEvaluation, len(tsKeys))
+
+	forEachConcurrently(b.workers, len(tsKeys), func(index int) {
+		evaluations[index] = b.evaluateTrafficSplit(res, topology, res.TrafficSplits[tsKeys[index]])
+	})
+
+	for _, evaluation := range evaluations {
+		ts := evaluation.trafficSplit
+		topology.TrafficSplits[evaluation.key] = ts
+
+		if !evaluation.attached {
+			continue
+		}
+
+		for _, backend := range ts.Backends {
+			backendSvc := topology.Services[backend.Service]
+			backendSvc.BackendOf = append(backendSvc.BackendOf, evaluation.key)
+		}
+
+		svc := topology.Services[ts.Service]
+		svc.TrafficSplits = append(svc.TrafficSplits, evaluation.key)
+	}
+}
+
+// evaluateTrafficSplit evaluates the given traffic-split. If the traffic-split targets a known Service, the resolved
+// TrafficSplit applies to it, with the backends exposing the ports required by the Service. The topology is not
+// modified, so that traffic-splits can be evaluated concurrently.
+func (b *Builder) evaluateTrafficSplit(res *resources, topology *Topology, trafficSplit *split.TrafficSplit) trafficSplitEvaluation {
 	svcKey := Key{trafficSplit.Spec.Service, trafficSplit.Namespace}
 	ts := &TrafficSplit{
 		Name:      trafficSplit.Name,
@@ -220,7 +473,7 @@ func (b *Builder) evaluateTrafficSplit(res *resources, topology *Topology, traff
 	}
 
 	tsKey := Key{trafficSplit.Name, trafficSplit.Namespace}
-	topology.TrafficSplits[tsKey] = ts
+	evaluation := trafficSplitEvaluation{key: tsKey, trafficSplit: ts}
 
 	var err error
 
@@ -230,7 +483,7 @@ func (b *Builder) evaluateTrafficSplit(res *resources, topology *Topology, traff
 		ts.AddError(err)
 		b.logger.Errorf("Error building topology for TrafficTarget %q: %v", tsKey, err)
 
-		return
+		return evaluation
 	}
 
 	svc, ok := topology.Services[svcKey]
@@ -239,11 +492,11 @@ func (b *Builder) evaluateTrafficSplit(res *resources, topology *Topology, traff
 		ts.AddError(err)
 		b.logger.Errorf("Error building topology for TrafficSplit %q: %v", tsKey, err)
 
-		return
+		return evaluation
 	}
 
 	for _, backend := range trafficSplit.Spec.Backends {
-		backendSvcKey := Key{backend.Service, trafficSplit.Namespace}
+		backendSvcKey := getTrafficSplitBackendServiceKey(backend.Service, trafficSplit.Namespace)
 
 		backendSvc, ok := topology.Services[backendSvcKey]
 		if !ok {
@@ -267,11 +520,22 @@ func (b *Builder) evaluateTrafficSplit(res *resources, topology *Topology, traff
 			Weight:  backend.Weight,
 			Service: backendSvcKey,
 		})
+	}
+
+	evaluation.attached = true
+
+	return evaluation
+}
 
-		backendSvc.BackendOf = append(backendSvc.BackendOf, tsKey)
+// getTrafficSplitBackendServiceKey returns the key of the Service referenced by a TrafficSplit backend. The backend
+// service is either a name, in which case it lives in the namespace of the TrafficSplit, or in the form name.namespace.
+func getTrafficSplitBackendServiceKey(backendSvc, namespace string) Key {
+	parts := strings.SplitN(backendSvc, ".", 2)
+	if len(parts) == 2 {
+		return Key{parts[0], parts[1]}
 	}
 
-	svc.TrafficSplits = append(svc.TrafficSplits, tsKey)
+	return Key{backendSvc, namespace}
 }
 
 func (b *Builder) validateServiceAndBackendPorts(svcPorts []corev1.ServicePort, backendPorts []corev1.ServicePort) error {
@@ -429,34 +693,36 @@ func unionPod(pods1, pods2 []Key) []Key {
 	return union
 }
 
-// buildTrafficTargetSources retrieves the Pod IPs for each Pod mentioned in a source of the given TrafficTarget.
-// If a Pod IP is not yet available, the pod will be skipped.
-func (b *Builder) buildTrafficTargetSources(res *resources, t *Topology, tt *access.TrafficTarget) []ServiceTrafficTargetSource {
+// buildTrafficTargetSources retrieves the Pod IPs for each Pod mentioned in a source of the given TrafficTarget, along
+// with the source pods. If a Pod IP is not yet available, the pod will be skipped.
+func (b *Builder) buildTrafficTargetSources(res *resources, tt *access.TrafficTarget) ([]ServiceTrafficTargetSource, []*corev1.Pod) {
 	sources := make([]ServiceTrafficTargetSource, len(tt.Spec.Sources))
 
+	var srcPods []*corev1.Pod
+
 	for i, source := range tt.Spec.Sources {
 		srcSaKey := Key{source.Name, source.Namespace}
 
-		pods := res.PodsByServiceAccounts[srcSaKey]
+		var pods []*corev1.Pod
 
-		var srcPods []Key
-
-		for _, pod := range pods {
+		for _, pod := range res.PodsByServiceAccounts[srcSaKey] {
 			if pod.Status.PodIP == "" {
 				continue
 			}
 
-			srcPods = append(srcPods, getOrCreatePod(t, pod))
+			pods = append(pods, pod)
 		}
 
 		sources[i] = ServiceTrafficTargetSource{
 			ServiceAccount: source.Name,
 			Namespace:      source.Namespace,
-			Pods:           srcPods,
+			Pods:           getPodKeys(pods),
 		}
+
+		srcPods = append(srcPods, pods...)
 	}
 
-	return sources
+	return sources, srcPods
 }
 
 func (b *Builder) buildTrafficTargetRules(res *resources, tt *access.TrafficTarget) ([]TrafficSpec, error) {
@@ -565,48 +831,86 @@ func (b *Builder) buildTCPRoute(tcpRts map[Key]*specs.TCPRoute, ns, name string)
 	}, nil
 }
 
-// getTrafficTargetDestinationPorts gets the ports mentioned in the TrafficTarget.Destination.Port. If the destination
-// port is defined but not on the service itself an error will be returned. If the destination port is not defined, the
-// traffic allowed on all the service's ports.
-func (b *Builder) getTrafficTargetDestinationPorts(svc *Service, tt *access.TrafficTarget) ([]corev1.ServicePort, error) {
+// getTrafficTargetDestinationPorts gets the ports mentioned in the TrafficTarget.Destination.Port, along with the
+// destination pods exposing them. If the destination port is defined but not on the service itself an error will be
+// returned. If the destination port is not defined, the traffic allowed on all the service's ports. As a named target
+// port can resolve to a different port number on each destination pod, only the pods on which it resolves to the
+// destination port are kept.
+func (b *Builder) getTrafficTargetDestinationPorts(svc *Service, tt *access.TrafficTarget, destPods []*corev1.Pod) ([]corev1.ServicePort, []*corev1.Pod, error) {
 	port := tt.Spec.Destination.Port
 
 	if port == nil {
-		return svc.Ports, nil
+		return svc.Ports, destPods, nil
 	}
 
 	key := Key{tt.Name, tt.Namespace}
 
 	for _, svcPort := range svc.Ports {
-		if svcPort.TargetPort.IntVal == int32(*port) {
-			return []corev1.ServicePort{svcPort}, nil
+		if svcPort.TargetPort.Type == intstr.Int {
+			if svcPort.TargetPort.IntVal == int32(*port) {
+				return []corev1.ServicePort{svcPort}, destPods, nil
+			}
+
+			continue
+		}
+
+		var pods []*corev1.Pod
+
+		for _, pod := range destPods {
+			if targetPort, ok := ResolveServicePort(svcPort, getContainerPorts(pod)); ok && targetPort == int32(*port) {
+				pods = append(pods, pod)
+			}
+		}
+
+		if len(pods) > 0 {
+			return []corev1.ServicePort{svcPort}, pods, nil
 		}
 	}
 
-	return nil, fmt.Errorf("destination port %d of TrafficTarget %q is not exposed by the service", *port, key)
+	return nil, destPods, fmt.Errorf("destination port %d of TrafficTarget %q is not exposed by the service", *port, key)
 }
 
-func getOrCreatePod(topology *Topology, pod *corev1.Pod) Key {
+// addPod adds the given pod to the topology, unless it is already part of it.
+func addPod(topology *Topology, pod *corev1.Pod) {
 	podKey := Key{pod.Name, pod.Namespace}
 
 	if _, ok := topology.Pods[podKey]; !ok {
-		var containerPorts []corev1.ContainerPort
+		topology.Pods[podKey] = newPod(pod)
+	}
+}
 
-		for _, container := range pod.Spec.Containers {
-			containerPorts = append(containerPorts, container.Ports...)
-		}
+// getPodKeys returns the keys of the given pods.
+func getPodKeys(pods []*corev1.Pod) []Key {
+	var podKeys []Key
 
-		topology.Pods[podKey] = &Pod{
-			Name:            pod.Name,
-			Namespace:       pod.Namespace,
-			ServiceAccount:  pod.Spec.ServiceAccountName,
-			OwnerReferences: pod.OwnerReferences,
-			ContainerPorts:  containerPorts,
-			IP:              pod.Status.PodIP,
-		}
+	for _, pod := range pods {
+		podKeys = append(podKeys, Key{pod.Name, pod.Namespace})
 	}
 
-	return podKey
+	return podKeys
+}
+
+// newPod creates a topology Pod from the given Kubernetes pod.
+func newPod(pod *corev1.Pod) *Pod {
+	return &Pod{
+		Name:            pod.Name,
+		Namespace:       pod.Namespace,
+		ServiceAccount:  pod.Spec.ServiceAccountName,
+		OwnerReferences: pod.OwnerReferences,
+		ContainerPorts:  getContainerPorts(pod),
+		IP:              pod.Status.PodIP,
+	}
+}
+
+// getContainerPorts returns the ports of all the containers of the given pod.
+func getContainerPorts(pod *corev1.Pod) []corev1.ContainerPort {
+	var containerPorts []corev1.ContainerPort
+
+	for _, container := range pod.Spec.Containers {
+		containerPorts = append(containerPorts, container.Ports...)
+	}
+
+	return containerPorts
 }
 
 func (b *Builder) loadResources(resourceFilter *mk8s.ResourceFilter) (*resources, error) {
@@ -619,6 +923,8 @@ func (b *Builder) loadResources(resourceFilter *mk8s.ResourceFilter) (*resources
 		PodsBySvc:             make(map[Key][]*corev1.Pod),
 		PodsByServiceAccounts: make(map[Key][]*corev1.Pod),
 		PodsBySvcBySa:         make(map[Key]map[Key][]*corev1.Pod),
+		PodConditions:         make(map[Key]podConditions),
+		PodZones:              make(map[Key]string),
 	}
 
 	err := b.loadServices(resourceFilter, res)
@@ -631,14 +937,29 @@ func (b *Builder) loadResources(resourceFilter *mk8s.ResourceFilter) (*resources
 		return nil, fmt.Errorf("unable to list Pods: %w", err)
 	}
 
-	eps, err := b.endpointsLister.List(labels.Everything())
-	if err != nil {
-		return nil, fmt.Errorf("unable to list Endpoints: %w", err)
+	var (
+		eps            []*corev1.Endpoints
+		endpointSlices []*discoveryv1.EndpointSlice
+	)
+
+	if b.endpointSliceLister != nil {
+		endpointSlices, err = b.endpointSliceLister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list EndpointSlices: %w", err)
+		}
+	} else {
+		eps, err = b.endpointsLister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list Endpoints: %w", err)
+		}
 	}
 
-	tss, err := b.trafficSplitLister.List(labels.Everything())
-	if err != nil {
-		return nil, fmt.Errorf("unable to list TrafficSplits: %w", err)
+	var tss []*split.TrafficSplit
+	if b.trafficSplitLister != nil {
+		tss, err = b.trafficSplitLister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list TrafficSplits: %w", err)
+		}
 	}
 
 	var httpRtGrps []*specs.HTTPRouteGroup
@@ -665,8 +986,17 @@ func (b *Builder) loadResources(resourceFilter *mk8s.ResourceFilter) (*resources
 		}
 	}
 
+	var nodes []*corev1.Node
+	if b.nodeLister != nil {
+		nodes, err = b.nodeLister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list Nodes: %w", err)
+		}
+	}
+
 	res.indexSMIResources(resourceFilter, tts, tss, tcpRts, httpRtGrps)
-	res.indexPods(resourceFilter, pods, eps)
+	res.indexPods(resourceFilter, pods, eps, endpointSlices, b.workers)
+	res.indexPodZones(resourceFilter, pods, nodes)
 
 	return res, nil
 }
@@ -682,12 +1012,25 @@ func (b *Builder) loadServices(resourceFilter *mk8s.ResourceFilter, res *resourc
 			continue
 		}
 
+		// Services with an invalid ignore annotation are skipped, as no shadow service is created for them.
+		ignored, err := annotations.IsIgnored(svc.Annotations)
+		if err != nil {
+			b.logger.Errorf("Unable to evaluate ignore annotation of Service %q in namespace %q: %v", svc.Name, svc.Namespace, err)
+			continue
+		}
+
+		if ignored {
+			continue
+		}
+
 		res.Services[Key{svc.Name, svc.Namespace}] = svc
 	}
 
 	return nil
 }
 
+// resources is the snapshot of the resources a topology is built from, indexed by namespace/name. It is loaded once at
+// the beginning of each build, so that every resource is listed once per build and never reused by the next one.
 type resources struct {
 	Services        map[Key]*corev1.Service
 	TrafficTargets  map[Key]*access.TrafficTarget
@@ -699,17 +1042,63 @@ type resources struct {
 	PodsBySvc             map[Key][]*corev1.Pod
 	PodsByServiceAccounts map[Key][]*corev1.Pod
 	PodsBySvcBySa         map[Key]map[Key][]*corev1.Pod
+
+	// Endpoint conditions of the pods selected by services.
+	PodConditions map[Key]podConditions
+
+	// Zones of the nodes the pods run on.
+	PodZones map[Key]string
+}
+
+// podConditions holds the endpoint conditions of a pod. A pod is considered ready if it is reported as ready by at
+// least one of the endpoints referencing it.
+type podConditions struct {
+	Ready       bool
+	Terminating bool
 }
 
 // indexPods populates the different pod indexes in the given resources object. It builds 3 indexes:
 // - pods indexed by service-account
 // - pods indexed by service
 // - pods indexed by service indexed by service-account.
-func (r *resources) indexPods(resourceFilter *mk8s.ResourceFilter, pods []*corev1.Pod, eps []*corev1.Endpoints) {
+// The pods referenced by the endpoints are resolved using the given number of workers.
+func (r *resources) indexPods(resourceFilter *mk8s.ResourceFilter, pods []*corev1.Pod, eps []*corev1.Endpoints, endpointSlices []*discoveryv1.EndpointSlice, workers int) {
 	podsByName := make(map[Key]*corev1.Pod)
 
 	r.indexPodsByServiceAccount(resourceFilter, pods, podsByName)
-	r.indexPodsByService(resourceFilter, eps, podsByName)
+	r.indexPodsByService(resourceFilter, eps, podsByName, workers)
+	r.indexPodsByServiceFromEndpointSlices(resourceFilter, endpointSlices, podsByName, workers)
+}
+
+// indexPodZones indexes the zone of the pods, read from the labels of the node they run on. The well-known
+// topology.kubernetes.io/zone label is preferred over its deprecated failure-domain.beta.kubernetes.io/zone variant.
+func (r *resources) indexPodZones(resourceFilter *mk8s.ResourceFilter, pods []*corev1.Pod, nodes []*corev1.Node) {
+	if len(nodes) == 0 {
+		return
+	}
+
+	nodeZones := make(map[string]string)
+
+	for _, node := range nodes {
+		zone := node.Labels[corev1.LabelTopologyZone]
+		if zone == "" {
+			zone = node.Labels[corev1.LabelFailureDomainBetaZone]
+		}
+
+		if zone != "" {
+			nodeZones[node.Name] = zone
+		}
+	}
+
+	for _, pod := range pods {
+		if resourceFilter.IsIgnored(pod) {
+			continue
+		}
+
+		if zone, ok := nodeZones[pod.Spec.NodeName]; ok {
+			r.PodZones[Key{Name: pod.Name, Namespace: pod.Namespace}] = zone
+		}
+	}
 }
 
 func (r *resources) indexPodsByServiceAccount(resourceFilter *mk8s.ResourceFilter, pods []*corev1.Pod, podsByName map[Key]*corev1.Pod) {
@@ -726,49 +1115,159 @@ func (r *resources) indexPodsByServiceAccount(resourceFilter *mk8s.ResourceFilte
 	}
 }
 
-func (r *resources) indexPodsByService(resourceFilter *mk8s.ResourceFilter, eps []*corev1.Endpoints, podsByName map[Key]*corev1.Pod) {
+func (r *resources) indexPodsByService(resourceFilter *mk8s.ResourceFilter, eps []*corev1.Endpoints, podsByName map[Key]*corev1.Pod, workers int) {
+	var svcEps []*corev1.Endpoints
+
 	for _, ep := range eps {
-		if resourceFilter.IsIgnored(ep) {
-			continue
+		if !resourceFilter.IsIgnored(ep) {
+			svcEps = append(svcEps, ep)
 		}
+	}
+
+	endpointPods := make([][]endpointPod, len(svcEps))
 
+	forEachConcurrently(workers, len(svcEps), func(index int) {
+		endpointPods[index] = resolveEndpointsPods(svcEps[index], podsByName)
+	})
+
+	for i, ep := range svcEps {
 		// This map keeps track of service pods already indexed. A service pod can be listed in multiple endpoint
 		// subset in function of the matched service ports.
 		indexedServicePods := make(map[Key]struct{})
 
-		for _, subset := range ep.Subsets {
-			for _, address := range subset.Addresses {
-				r.indexPodByService(ep, address, podsByName, indexedServicePods)
+		keySvc := Key{Name: ep.Name, Namespace: ep.Namespace}
+
+		for _, epPod := range endpointPods[i] {
+			r.indexPodByService(keySvc, epPod, indexedServicePods)
+		}
+	}
+}
+
+func (r *resources) indexPodsByServiceFromEndpointSlices(resourceFilter *mk8s.ResourceFilter, endpointSlices []*discoveryv1.EndpointSlice, podsByName map[Key]*corev1.Pod, workers int) {
+	var svcEndpointSlices []*discoveryv1.EndpointSlice
+
+	for _, endpointSlice := range endpointSlices {
+		if resourceFilter.IsIgnored(endpointSlice) || endpointSlice.Labels[discoveryv1.LabelServiceName] == "" {
+			continue
+		}
+
+		svcEndpointSlices = append(svcEndpointSlices, endpointSlice)
+	}
+
+	endpointPods := make([][]endpointPod, len(svcEndpointSlices))
+
+	forEachConcurrently(workers, len(svcEndpointSlices), func(index int) {
+		endpointPods[index] = resolveEndpointSlicePods(svcEndpointSlices[index], podsByName)
+	})
+
+	// The pods of a service can be spread across multiple EndpointSlices, and a pod can be listed by more than one of
+	// them while they are being updated. This map keeps track of service pods already indexed, by service.
+	indexedServicePods := make(map[Key]map[Key]struct{})
+
+	for i, endpointSlice := range svcEndpointSlices {
+		keySvc := Key{Name: endpointSlice.Labels[discoveryv1.LabelServiceName], Namespace: endpointSlice.Namespace}
+
+		if _, exists := indexedServicePods[keySvc]; !exists {
+			indexedServicePods[keySvc] = make(map[Key]struct{})
+		}
+
+		for _, epPod := range endpointPods[i] {
+			r.indexPodByService(keySvc, epPod, indexedServicePods[keySvc])
+		}
+	}
+}
+
+// endpointPod holds a pod referenced by an endpoint, along with the conditions reported by this endpoint.
+type endpointPod struct {
+	pod        *corev1.Pod
+	conditions podConditions
+}
+
+// resolveEndpointsPods resolves the pods referenced by the addresses of the given Endpoints. It doesn't modify the
+// indexes, so that endpoints can be resolved concurrently.
+func resolveEndpointsPods(ep *corev1.Endpoints, podsByName map[Key]*corev1.Pod) []endpointPod {
+	var epPods []endpointPod
+
+	for _, subset := range ep.Subsets {
+		for _, address := range subset.Addresses {
+			if epPod, ok := resolveEndpointPod(address.TargetRef, podsByName, podConditions{Ready: true}); ok {
+				epPods = append(epPods, epPod)
+			}
+		}
+
+		for _, address := range subset.NotReadyAddresses {
+			if epPod, ok := resolveEndpointPod(address.TargetRef, podsByName, podConditions{}); ok {
+				epPods = append(epPods, epPod)
 			}
 		}
 	}
+
+	return epPods
 }
 
-func (r *resources) indexPodByService(ep *corev1.Endpoints, address corev1.EndpointAddress, podsByName map[Key]*corev1.Pod, indexedServicePods map[Key]struct{}) {
-	if address.TargetRef == nil {
-		return
+// resolveEndpointSlicePods resolves the pods referenced by the endpoints of the given EndpointSlice. It doesn't modify
+// the indexes, so that endpoint slices can be resolved concurrently.
+func resolveEndpointSlicePods(endpointSlice *discoveryv1.EndpointSlice, podsByName map[Key]*corev1.Pod) []endpointPod {
+	var epPods []endpointPod
+
+	for _, endpoint := range endpointSlice.Endpoints {
+		// A nil ready condition must be interpreted as ready, and a nil terminating condition as not terminating.
+		conditions := podConditions{
+			Ready:       endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready,
+			Terminating: endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating,
+		}
+
+		if epPod, ok := resolveEndpointPod(endpoint.TargetRef, podsByName, conditions); ok {
+			epPods = append(epPods, epPod)
+		}
 	}
 
-	keyPod := Key{Name: address.TargetRef.Name, Namespace: address.TargetRef.Namespace}
+	return epPods
+}
 
-	if _, exists := indexedServicePods[keyPod]; exists {
-		return
+func resolveEndpointPod(targetRef *corev1.ObjectReference, podsByName map[Key]*corev1.Pod, conditions podConditions) (endpointPod, bool) {
+	if targetRef == nil {
+		return endpointPod{}, false
 	}
 
-	pod, ok := podsByName[keyPod]
+	pod, ok := podsByName[Key{Name: targetRef.Name, Namespace: targetRef.Namespace}]
 	if !ok {
+		return endpointPod{}, false
+	}
+
+	// Endpoints don't report the terminating condition, rely on the pod deletion timestamp instead.
+	if pod.DeletionTimestamp != nil {
+		conditions.Terminating = true
+	}
+
+	return endpointPod{pod: pod, conditions: conditions}, true
+}
+
+func (r *resources) indexPodByService(keySvc Key, epPod endpointPod, indexedServicePods map[Key]struct{}) {
+	pod := epPod.pod
+	conditions := epPod.conditions
+
+	keyPod := Key{Name: pod.Name, Namespace: pod.Namespace}
+
+	if existing, exists := r.PodConditions[keyPod]; exists {
+		conditions.Ready = conditions.Ready || existing.Ready
+		conditions.Terminating = conditions.Terminating || existing.Terminating
+	}
+
+	r.PodConditions[keyPod] = conditions
+
+	if _, exists := indexedServicePods[keyPod]; exists {
 		return
 	}
 
 	keySA := Key{Name: pod.Spec.ServiceAccountName, Namespace: pod.Namespace}
-	keyEP := Key{Name: ep.Name, Namespace: ep.Namespace}
 
 	if _, exists := r.PodsBySvcBySa[keySA]; !exists {
 		r.PodsBySvcBySa[keySA] = make(map[Key][]*corev1.Pod)
 	}
 
-	r.PodsBySvcBySa[keySA][keyEP] = append(r.PodsBySvcBySa[keySA][keyEP], pod)
-	r.PodsBySvc[keyEP] = append(r.PodsBySvc[keyEP], pod)
+	r.PodsBySvcBySa[keySA][keySvc] = append(r.PodsBySvcBySa[keySA][keySvc], pod)
+	r.PodsBySvc[keySvc] = append(r.PodsBySvc[keySvc], pod)
 
 	indexedServicePods[keyPod] = struct{}{}
 }
@@ -28,11 +28,16 @@ import (
 	"github.com/stretchr/testify/require"
 	mk8s "github.com/traefik/mesh/v2/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/informers"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	listers "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
 )
 
 // TestTopologyBuilder_BuildIgnoresNamespaces makes sure namespace to ignore are ignored by the TopologyBuilder.
@@ -288,6 +293,61 @@ func TestTopologyBuilder_EvaluatesIncomingTrafficSplit(t *testing.T) {
 	assertTopology(t, "testdata/topology-traffic-split-traffic-target.json", got)
 }
 
+// TestTopologyBuilder_EvaluatesCrossNamespaceTrafficSplit makes sure a TrafficSplit can reference a backend living in
+// another namespace, and that its authorized incoming traffic takes the TrafficTargets of this namespace into account.
+func TestTopologyBuilder_EvaluatesCrossNamespaceTrafficSplit(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	selectorAppB := map[string]string{"app": "app-b"}
+	selectorAppC := map[string]string{"app": "app-c"}
+	annotations := map[string]string{}
+	svcPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	podA := createPod("my-ns", "app-a", saA, selectorAppA, "10.10.1.1")
+
+	svcB := createService("my-ns", "svc-b", annotations, svcPorts, selectorAppB, "10.10.1.16")
+
+	saC := createServiceAccount("my-ns", "service-account-c")
+	svcC := createService("my-ns", "svc-c", annotations, svcPorts, selectorAppC, "10.10.1.17")
+	podC := createPod("my-ns", "app-c", saC, svcC.Spec.Selector, "10.10.2.2")
+
+	saCanary := createServiceAccount("canary-ns", "service-account-c")
+	svcCanary := createService("canary-ns", "svc-c", annotations, svcPorts, selectorAppC, "10.10.1.18")
+	podCanary := createPod("canary-ns", "app-c", saCanary, svcCanary.Spec.Selector, "10.10.2.3")
+
+	epC := createEndpoints(svcC, createEndpointSubset(svcPorts, podC))
+	epCanary := createEndpoints(svcCanary, createEndpointSubset(svcPorts, podCanary))
+
+	ttc := createTrafficTarget("my-ns", "tt-c", saC, intPtr(8080), []*corev1.ServiceAccount{saA}, nil, nil)
+	ttCanary := createTrafficTarget("canary-ns", "tt-c", saCanary, intPtr(8080), []*corev1.ServiceAccount{saA}, nil, nil)
+	ts := createTrafficSplit("my-ns", "ts", svcB, svcC, svcCanary, nil)
+
+	k8sClient := fake.NewSimpleClientset(saA, saC, saCanary,
+		podA, podC, podCanary,
+		svcB, svcC, svcCanary,
+		epC, epCanary)
+	smiAccessClient := accessfake.NewSimpleClientset(ttc, ttCanary)
+	smiSplitClient := splitfake.NewSimpleClientset(ts)
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	gotTs, ok := got.TrafficSplits[nn("ts", "my-ns")]
+	require.True(t, ok)
+
+	assert.Empty(t, gotTs.Errors)
+	assert.Equal(t, []TrafficSplitBackend{
+		{Weight: 80, Service: nn("svc-c", "my-ns")},
+		{Weight: 20, Service: nn("svc-c", "canary-ns")},
+	}, gotTs.Backends)
+	assert.Equal(t, []Key{nn("app-a", "my-ns")}, gotTs.Incoming)
+	assert.Equal(t, []Key{nn("ts", "my-ns")}, got.Services[nn("svc-c", "canary-ns")].BackendOf)
+}
+
 // TestTopologyBuilder_EvaluatesTrafficSplitSpecs makes sure a topology can be built with TrafficSplits containing
 // HTTPRouteGroups.
 func TestTopologyBuilder_EvaluatesTrafficSplitSpecs(t *testing.T) {
@@ -342,6 +402,115 @@ func TestTopologyBuilder_EvaluatesTrafficSplitSpecs(t *testing.T) {
 	assertTopology(t, "testdata/topology-traffic-split-specs.json", got)
 }
 
+// TestTopologyBuilder_BuildIgnoresAnnotatedServices makes sure services annotated with the ignore annotation are not
+// part of the topology, as well as services with an invalid ignore annotation, and are back in it once the annotation is
+// removed.
+func TestTopologyBuilder_BuildIgnoresAnnotatedServices(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	svcPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	svcA := createService("my-ns", "svc-a", map[string]string{}, svcPorts, selectorAppA, "10.10.1.15")
+	svcB := createService("my-ns", "svc-b", map[string]string{"mesh.traefik.io/ignore": "true"}, svcPorts, selectorAppA, "10.10.1.16")
+	svcC := createService("my-ns", "svc-c", map[string]string{"mesh.traefik.io/ignore": "hello"}, svcPorts, selectorAppA, "10.10.1.17")
+	podA := createPod("my-ns", "app-a", saA, selectorAppA, "10.10.1.1")
+
+	k8sClient := fake.NewSimpleClientset(saA, svcA, svcB, svcC, podA)
+	smiAccessClient := accessfake.NewSimpleClientset()
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	assert.Contains(t, got.Services, nn("svc-a", "my-ns"))
+	assert.NotContains(t, got.Services, nn("svc-b", "my-ns"))
+	assert.NotContains(t, got.Services, nn("svc-c", "my-ns"))
+
+	// Remove the annotation and make sure the service is back in the topology.
+	svcB.Annotations = map[string]string{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = k8sClient.CoreV1().Services("my-ns").Update(ctx, svcB, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		got, err = builder.Build(mk8s.NewResourceFilter())
+		if err != nil {
+			return false
+		}
+
+		_, ok := got.Services[nn("svc-b", "my-ns")]
+
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTopologyBuilder_BuildIgnoresAnnotatedPorts(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	svcPorts := []corev1.ServicePort{
+		svcPort("http", 8080, 8080),
+		svcPort("debug", 6060, 6060),
+		svcPort("metrics", 9090, 9090),
+	}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	svcA := createService("my-ns", "svc-a", map[string]string{"mesh.traefik.io/ignore-ports": "debug,9090"}, svcPorts, selectorAppA, "10.10.1.15")
+	svcB := createService("my-ns", "svc-b", map[string]string{"mesh.traefik.io/ignore-ports": "not_a_port"}, svcPorts, selectorAppA, "10.10.1.16")
+	podA := createPod("my-ns", "app-a", saA, selectorAppA, "10.10.1.1")
+
+	k8sClient := fake.NewSimpleClientset(saA, svcA, svcB, podA)
+	smiAccessClient := accessfake.NewSimpleClientset()
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	require.Contains(t, got.Services, nn("svc-a", "my-ns"))
+	assert.Equal(t, []corev1.ServicePort{svcPort("http", 8080, 8080)}, got.Services[nn("svc-a", "my-ns")].Ports)
+	assert.Empty(t, got.Services[nn("svc-a", "my-ns")].Errors)
+
+	// None of the ports are meshed when the annotation is invalid.
+	require.Contains(t, got.Services, nn("svc-b", "my-ns"))
+	assert.Empty(t, got.Services[nn("svc-b", "my-ns")].Ports)
+	assert.Len(t, got.Services[nn("svc-b", "my-ns")].Errors, 1)
+}
+
+func TestTopologyBuilder_BuildWithExternalNameService(t *testing.T) {
+	svcPorts := []corev1.ServicePort{svcPort("port-443", 443, 443)}
+
+	svcA := createService("my-ns", "svc-a", map[string]string{}, svcPorts, nil, "")
+	svcA.Spec.Type = corev1.ServiceTypeExternalName
+	svcA.Spec.ExternalName = "api.example.com"
+
+	k8sClient := fake.NewSimpleClientset(svcA)
+	smiAccessClient := accessfake.NewSimpleClientset()
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	require.Contains(t, got.Services, nn("svc-a", "my-ns"))
+
+	svc := got.Services[nn("svc-a", "my-ns")]
+	assert.Equal(t, "api.example.com", svc.ExternalName)
+	assert.Equal(t, svcPorts, svc.Ports)
+	assert.Empty(t, svc.Pods)
+}
+
 // TestTopologyBuilder_BuildWithTrafficTarget makes sure a topology can be built using TrafficTargets.
 func TestTopologyBuilder_BuildWithTrafficTarget(t *testing.T) {
 	selectorAppA := map[string]string{"app": "app-a"}
@@ -382,6 +551,59 @@ func TestTopologyBuilder_BuildWithTrafficTarget(t *testing.T) {
 	assertTopology(t, "testdata/topology-traffic-target.json", got)
 }
 
+// TestTopologyBuilder_BuildWithTrafficTargetNamedTargetPort makes sure a TrafficTarget destination port is matched
+// against a named service target port resolved on each destination pod.
+func TestTopologyBuilder_BuildWithTrafficTargetNamedTargetPort(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	selectorAppB := map[string]string{"app": "app-b"}
+	svcPorts := []corev1.ServicePort{
+		{
+			Name:       "port-80",
+			Protocol:   "TCP",
+			Port:       80,
+			TargetPort: intstr.FromString("web"),
+		},
+	}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	podA := createPod("my-ns", "app-a", saA, selectorAppA, "10.10.1.1")
+
+	saB := createServiceAccount("my-ns", "service-account-b")
+	svcB := createService("my-ns", "svc-b", nil, svcPorts, selectorAppB, "10.10.1.16")
+	podB1 := createPod("my-ns", "app-b-1", saB, selectorAppB, "10.10.2.1")
+	podB2 := createPod("my-ns", "app-b-2", saB, selectorAppB, "10.10.2.2")
+
+	// The "web" port resolves to 8080 on app-b-1 and to 9090 on app-b-2.
+	podB1.Spec.Containers = []corev1.Container{{Ports: []corev1.ContainerPort{{Name: "web", Protocol: "TCP", ContainerPort: 8080}}}}
+	podB2.Spec.Containers = []corev1.Container{{Ports: []corev1.ContainerPort{{Name: "web", Protocol: "TCP", ContainerPort: 9090}}}}
+
+	epB := createEndpoints(svcB, createEndpointSubset(svcPorts, podB1, podB2))
+
+	tt := createTrafficTarget("my-ns", "tt", saB, intPtr(9090), []*corev1.ServiceAccount{saA}, nil, nil)
+
+	k8sClient := fake.NewSimpleClientset(saA, saB, podA, podB1, podB2, svcB, epB)
+	smiAccessClient := accessfake.NewSimpleClientset(tt)
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	stt, ok := got.ServiceTrafficTargets[ServiceTrafficTargetKey{Service: nn("svc-b", "my-ns"), TrafficTarget: nn("tt", "my-ns")}]
+	require.True(t, ok)
+
+	assert.Empty(t, stt.Errors)
+	assert.Equal(t, svcPorts, stt.Destination.Ports)
+
+	// The "web" port of app-b-1 doesn't resolve to the TrafficTarget port, it must not be a destination.
+	assert.Equal(t, []Key{nn("app-b-2", "my-ns")}, stt.Destination.Pods)
+	assert.Empty(t, got.Pods[nn("app-b-1", "my-ns")].DestinationOf)
+	assert.NotEmpty(t, got.Pods[nn("app-b-2", "my-ns")].DestinationOf)
+}
+
 // TestTopologyBuilder_BuildWithTrafficTargetSpecEmptyMatch makes sure that when TrafficTarget.Spec.Matches is empty,
 // the output list contains all the matches defined in the HTTPRouteGroup (as defined by the
 // spec https://github.com/servicemeshinterface/smi-spec/tree/master/apis/traffic-access/v1alpha2)
@@ -539,6 +761,140 @@ func TestTopologyBuilder_BuildTrafficTargetMultipleSourcesAndDestinations(t *tes
 	assertTopology(t, "testdata/topology-multi-sources-destinations.json", got)
 }
 
+func TestTopologyBuilder_BuildTrafficTargetRecordsAllSources(t *testing.T) {
+	selectorAppS := map[string]string{"app": "app-s"}
+	selectorClient := map[string]string{"app": "client"}
+	annotations := map[string]string{}
+	svcPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	podA := createPod("my-ns", "app-a", saA, selectorClient, "10.10.1.1")
+
+	saB := createServiceAccount("my-ns", "service-account-b")
+	podB := createPod("my-ns", "app-b", saB, selectorClient, "10.10.1.2")
+
+	saC := createServiceAccount("other-ns", "service-account-c")
+	podC := createPod("other-ns", "app-c", saC, selectorClient, "10.10.1.3")
+
+	saD := createServiceAccount("my-ns", "service-account-d")
+	podD := createPod("my-ns", "app-d", saD, selectorClient, "10.10.1.4")
+
+	saS := createServiceAccount("my-ns", "service-account-s")
+	svcS := createService("my-ns", "svc-s", annotations, svcPorts, selectorAppS, "10.10.1.16")
+	podS := createPod("my-ns", "app-s", saS, svcS.Spec.Selector, "10.10.2.1")
+
+	epS := createEndpoints(svcS, createEndpointSubset(svcPorts, podS))
+
+	tt := createTrafficTarget("my-ns", "tt", saS, intPtr(8080), []*corev1.ServiceAccount{saA, saB, saC}, nil, []string{})
+
+	k8sClient := fake.NewSimpleClientset(saA, podA, saB, podB, saC, podC, saD, podD, saS, svcS, podS, epS)
+	smiAccessClient := accessfake.NewSimpleClientset(tt)
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	svcTTKey := ServiceTrafficTargetKey{
+		Service:       nn(svcS.Name, svcS.Namespace),
+		TrafficTarget: nn(tt.Name, tt.Namespace),
+	}
+	require.Contains(t, got.ServiceTrafficTargets, svcTTKey)
+
+	wantSources := []ServiceTrafficTargetSource{
+		{ServiceAccount: saA.Name, Namespace: saA.Namespace, Pods: []Key{nn(podA.Name, podA.Namespace)}},
+		{ServiceAccount: saB.Name, Namespace: saB.Namespace, Pods: []Key{nn(podB.Name, podB.Namespace)}},
+		{ServiceAccount: saC.Name, Namespace: saC.Namespace, Pods: []Key{nn(podC.Name, podC.Namespace)}},
+	}
+	assert.Equal(t, wantSources, got.ServiceTrafficTargets[svcTTKey].Sources)
+
+	// Every source pod is aware of the traffic target, the pod of the unlisted service account is not.
+	for _, pod := range []*corev1.Pod{podA, podB, podC} {
+		assert.Equal(t, []ServiceTrafficTargetKey{svcTTKey}, got.Pods[nn(pod.Name, pod.Namespace)].SourceOf)
+	}
+
+	if podDTopo, ok := got.Pods[nn(podD.Name, podD.Namespace)]; ok {
+		assert.Empty(t, podDTopo.SourceOf)
+	}
+}
+
+func TestTopologyBuilder_BuildServiceSelectingPodsOfMultipleWorkloads(t *testing.T) {
+	selectorAPI := map[string]string{"app": "api"}
+	annotations := map[string]string{}
+	svcPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saClient := createServiceAccount("my-ns", "client")
+	podClient := createPod("my-ns", "client", saClient, map[string]string{"app": "client"}, "10.10.1.1")
+
+	// The Service selects the pods of a Deployment and of a StatefulSet, running with different service accounts.
+	saV1 := createServiceAccount("my-ns", "api-v1")
+	podV1 := createPod("my-ns", "api-v1-7d9f8-abcde", saV1, selectorAPI, "10.10.2.1")
+	podV1.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "api-v1-7d9f8"},
+	}
+
+	saV2 := createServiceAccount("my-ns", "api-v2")
+	podV2 := createPod("my-ns", "api-v2-0", saV2, selectorAPI, "10.10.2.2")
+	podV2.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "api-v2"},
+	}
+
+	svc := createService("my-ns", "api", annotations, svcPorts, selectorAPI, "10.10.1.16")
+	ep := createEndpoints(svc, createEndpointSubset(svcPorts, podV1, podV2))
+
+	ttV1 := createTrafficTarget("my-ns", "tt-v1", saV1, intPtr(8080), []*corev1.ServiceAccount{saClient}, nil, []string{})
+	ttV2 := createTrafficTarget("my-ns", "tt-v2", saV2, intPtr(8080), []*corev1.ServiceAccount{saClient}, nil, []string{})
+
+	k8sClient := fake.NewSimpleClientset(saClient, podClient, saV1, podV1, saV2, podV2, svc, ep)
+	smiAccessClient := accessfake.NewSimpleClientset(ttV1, ttV2)
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	svcKey := nn(svc.Name, svc.Namespace)
+	require.Contains(t, got.Services, svcKey)
+	assert.ElementsMatch(t, []Key{nn(podV1.Name, podV1.Namespace), nn(podV2.Name, podV2.Namespace)}, got.Services[svcKey].Pods)
+
+	// Each pod keeps its own identity and owner.
+	for _, pod := range []*corev1.Pod{podV1, podV2} {
+		podKey := nn(pod.Name, pod.Namespace)
+		require.Contains(t, got.Pods, podKey)
+
+		assert.Equal(t, pod.Spec.ServiceAccountName, got.Pods[podKey].ServiceAccount)
+		assert.Equal(t, pod.OwnerReferences, got.Pods[podKey].OwnerReferences)
+	}
+
+	// Each TrafficTarget only authorizes the pods running with its destination service account.
+	for _, test := range []struct {
+		tt  *access.TrafficTarget
+		sa  *corev1.ServiceAccount
+		pod *corev1.Pod
+	}{
+		{tt: ttV1, sa: saV1, pod: podV1},
+		{tt: ttV2, sa: saV2, pod: podV2},
+	} {
+		svcTTKey := ServiceTrafficTargetKey{
+			Service:       svcKey,
+			TrafficTarget: nn(test.tt.Name, test.tt.Namespace),
+		}
+		require.Contains(t, got.ServiceTrafficTargets, svcTTKey)
+
+		destination := got.ServiceTrafficTargets[svcTTKey].Destination
+		assert.Equal(t, test.sa.Name, destination.ServiceAccount)
+		assert.Equal(t, []Key{nn(test.pod.Name, test.pod.Namespace)}, destination.Pods)
+
+		assert.Equal(t, []ServiceTrafficTargetKey{svcTTKey}, got.Pods[nn(test.pod.Name, test.pod.Namespace)].DestinationOf)
+	}
+}
+
 func TestTopologyBuilder_EmptyTrafficTargetDestinationNamespace(t *testing.T) {
 	namespace := "foo"
 	tt := &access.TrafficTarget{
@@ -638,6 +994,356 @@ func TestTopologyBuilder_BuildServiceWithPodPortMixture(t *testing.T) {
 	assertTopology(t, "testdata/topology-service-with-pod-port-mixture.json", got)
 }
 
+// TestTopologyBuilder_BuildWithMultipleEndpointSlices makes sure service pods spread across multiple EndpointSlices are
+// listed exactly once, with their readiness.
+func TestTopologyBuilder_BuildWithMultipleEndpointSlices(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	svcaPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	svcA := createService("my-ns", "svc-a", nil, svcaPorts, selectorAppA, "10.10.1.16")
+	podA1 := createPod("my-ns", "app-a-1", saA, selectorAppA, "10.10.1.1")
+	podA2 := createPod("my-ns", "app-a-2", saA, selectorAppA, "10.10.1.2")
+	podA3 := createPod("my-ns", "app-a-3", saA, selectorAppA, "10.10.1.3")
+	podA4 := createPod("my-ns", "app-a-4", saA, selectorAppA, "10.10.1.4")
+
+	// app-a-2 is listed by both EndpointSlices, as ready in the first one and not ready in the second one.
+	sliceA1 := createEndpointSlice(svcA, "svc-a-1", svcaPorts,
+		createSliceEndpoint(podA1, true),
+		createSliceEndpoint(podA2, true),
+	)
+	sliceA2 := createEndpointSlice(svcA, "svc-a-2", svcaPorts,
+		createSliceEndpoint(podA2, false),
+		createSliceEndpoint(podA3, true),
+		createSliceEndpoint(podA4, false),
+	)
+
+	k8sClient := fake.NewSimpleClientset(svcA, podA1, podA2, podA3, podA4, sliceA1, sliceA2)
+	smiAccessClient := accessfake.NewSimpleClientset()
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	builder.endpointSliceLister, err = createEndpointSliceLister(k8sClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	svc, ok := got.Services[nn("svc-a", "my-ns")]
+	require.True(t, ok)
+
+	assert.ElementsMatch(t, []Key{
+		nn("app-a-1", "my-ns"),
+		nn("app-a-2", "my-ns"),
+		nn("app-a-3", "my-ns"),
+		nn("app-a-4", "my-ns"),
+	}, svc.Pods)
+
+	assert.False(t, got.Pods[nn("app-a-1", "my-ns")].NotReady)
+	assert.False(t, got.Pods[nn("app-a-2", "my-ns")].NotReady)
+	assert.False(t, got.Pods[nn("app-a-3", "my-ns")].NotReady)
+	assert.True(t, got.Pods[nn("app-a-4", "my-ns")].NotReady)
+}
+
+// TestTopologyBuilder_BuildWithEndpointConditions makes sure the readiness and terminating conditions of the endpoints
+// are reported on the pods.
+func TestTopologyBuilder_BuildWithEndpointConditions(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	svcaPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	svcA := createService("my-ns", "svc-a", nil, svcaPorts, selectorAppA, "10.10.1.16")
+	podA1 := createPod("my-ns", "app-a-1", saA, selectorAppA, "10.10.1.1")
+	podA2 := createPod("my-ns", "app-a-2", saA, selectorAppA, "10.10.1.2")
+	podA3 := createPod("my-ns", "app-a-3", saA, selectorAppA, "10.10.1.3")
+
+	terminating := true
+	terminatingEndpoint := createSliceEndpoint(podA3, false)
+	terminatingEndpoint.Conditions.Terminating = &terminating
+
+	sliceA := createEndpointSlice(svcA, "svc-a", svcaPorts,
+		createSliceEndpoint(podA1, true),
+		createSliceEndpoint(podA2, false),
+		terminatingEndpoint,
+	)
+
+	k8sClient := fake.NewSimpleClientset(svcA, podA1, podA2, podA3, sliceA)
+	smiAccessClient := accessfake.NewSimpleClientset()
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	builder.endpointSliceLister, err = createEndpointSliceLister(k8sClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	tests := []struct {
+		pod            Key
+		expNotReady    bool
+		expTerminating bool
+	}{
+		{pod: nn("app-a-1", "my-ns")},
+		{pod: nn("app-a-2", "my-ns"), expNotReady: true},
+		{pod: nn("app-a-3", "my-ns"), expNotReady: true, expTerminating: true},
+	}
+
+	for _, test := range tests {
+		pod, ok := got.Pods[test.pod]
+		require.True(t, ok, test.pod.String())
+
+		assert.Equal(t, test.expNotReady, pod.NotReady, test.pod.String())
+		assert.Equal(t, test.expTerminating, pod.Terminating, test.pod.String())
+	}
+}
+
+func TestTopologyBuilder_BuildWithNodeZones(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	svcaPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	svcA := createService("my-ns", "svc-a", nil, svcaPorts, selectorAppA, "10.10.1.16")
+	podA1 := createPod("my-ns", "app-a-1", saA, selectorAppA, "10.10.1.1")
+	podA2 := createPod("my-ns", "app-a-2", saA, selectorAppA, "10.10.1.2")
+	podA3 := createPod("my-ns", "app-a-3", saA, selectorAppA, "10.10.1.3")
+	podA4 := createPod("my-ns", "app-a-4", saA, selectorAppA, "10.10.1.4")
+
+	podA1.Spec.NodeName = "node-1"
+	podA2.Spec.NodeName = "node-2"
+	podA3.Spec.NodeName = "node-3"
+	podA4.Spec.NodeName = "unknown-node"
+
+	node1 := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-1",
+		Labels: map[string]string{corev1.LabelTopologyZone: "zone-a"},
+	}}
+	node2 := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-2",
+		Labels: map[string]string{corev1.LabelFailureDomainBetaZone: "zone-b"},
+	}}
+	node3 := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}}
+
+	epA := createEndpoints(svcA, createEndpointSubset(svcaPorts, podA1, podA2, podA3, podA4))
+
+	k8sClient := fake.NewSimpleClientset(svcA, podA1, podA2, podA3, podA4, epA, node1, node2, node3)
+	smiAccessClient := accessfake.NewSimpleClientset()
+	smiSplitClient := splitfake.NewSimpleClientset()
+	smiSpecClient := specsfake.NewSimpleClientset()
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	builder.nodeLister, err = createNodeLister(k8sClient)
+	require.NoError(t, err)
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	tests := []struct {
+		pod     Key
+		expZone string
+	}{
+		{pod: nn("app-a-1", "my-ns"), expZone: "zone-a"},
+		{pod: nn("app-a-2", "my-ns"), expZone: "zone-b"},
+		{pod: nn("app-a-3", "my-ns")},
+		{pod: nn("app-a-4", "my-ns")},
+	}
+
+	for _, test := range tests {
+		pod, ok := got.Pods[test.pod]
+		require.True(t, ok, test.pod.String())
+
+		assert.Equal(t, test.expZone, pod.Zone, test.pod.String())
+	}
+}
+
+// TestTopologyBuilder_BuildConcurrentlyMatchesSerialBuild makes sure resolving endpoints, services, traffic-splits and
+// traffic-targets concurrently builds the same topology as resolving them one at a time.
+func TestTopologyBuilder_BuildConcurrentlyMatchesSerialBuild(t *testing.T) {
+	k8sObjects, accessObjects, specsObjects, splitObjects := createSyntheticObjects(200)
+
+	k8sClient := fake.NewSimpleClientset(k8sObjects...)
+	smiAccessClient := accessfake.NewSimpleClientset(accessObjects...)
+	smiSplitClient := splitfake.NewSimpleClientset(splitObjects...)
+	smiSpecClient := specsfake.NewSimpleClientset(specsObjects...)
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	builder.workers = 1
+
+	want, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	builder.workers = 8
+
+	got, err := builder.Build(mk8s.NewResourceFilter())
+	require.NoError(t, err)
+
+	assert.Len(t, got.Services, 200)
+	assert.Len(t, got.TrafficSplits, 180)
+	assert.Len(t, got.ServiceTrafficTargets, 200)
+	assert.Equal(t, want, got)
+}
+
+func BenchmarkTopologyBuilder_Build(b *testing.B) {
+	k8sObjects, accessObjects, specsObjects, splitObjects := createSyntheticObjects(2000)
+
+	k8sClient := fake.NewSimpleClientset(k8sObjects...)
+	smiAccessClient := accessfake.NewSimpleClientset(accessObjects...)
+	smiSplitClient := splitfake.NewSimpleClientset(splitObjects...)
+	smiSpecClient := specsfake.NewSimpleClientset(specsObjects...)
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(b, err)
+
+	benchmarks := []struct {
+		desc    string
+		workers int
+	}{
+		{desc: "serial", workers: 1},
+		{desc: "concurrent", workers: 8},
+	}
+
+	for _, benchmark := range benchmarks {
+		builder.workers = benchmark.workers
+
+		b.Run(benchmark.desc, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := builder.Build(mk8s.NewResourceFilter()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// createSyntheticObjects creates the given number of services spread across 10 namespaces. Each service selects two pods
+// of its own and a pod shared by all the services of its namespace. In each namespace, a TrafficTarget authorizes a
+// client pod to reach all the services, and each service but the first two is split between the two previous services
+// of its namespace.
+func createSyntheticObjects(count int) (k8sObjects, accessObjects, specsObjects, splitObjects []runtime.Object) {
+	sharedPods := make(map[string]*corev1.Pod)
+	svcs := make([]*corev1.Service, count)
+
+	for i := 0; i < count; i++ {
+		namespace := fmt.Sprintf("ns-%d", i%10)
+		serviceAccount := createServiceAccount(namespace, "service-account")
+
+		sharedPod, ok := sharedPods[namespace]
+		if !ok {
+			sharedPod = createPod(namespace, "shared", serviceAccount, map[string]string{"shared": "true"}, fmt.Sprintf("10.20.%d.1", i%10))
+			sharedPods[namespace] = sharedPod
+
+			clientServiceAccount := createServiceAccount(namespace, "client")
+			clientPod := createPod(namespace, "client", clientServiceAccount, map[string]string{"app": "client"}, fmt.Sprintf("10.50.%d.1", i%10))
+
+			rtGrp := createHTTPRouteGroup(namespace, "http-rt-grp", []specs.HTTPMatch{
+				createHTTPMatch("api", []string{"GET"}, "/api", nil),
+			})
+			tt := createTrafficTarget(namespace, "tt", serviceAccount, nil, []*corev1.ServiceAccount{clientServiceAccount}, rtGrp, []string{"api"})
+
+			k8sObjects = append(k8sObjects, sharedPod, clientPod)
+			accessObjects = append(accessObjects, tt)
+			specsObjects = append(specsObjects, rtGrp)
+		}
+
+		name := fmt.Sprintf("svc-%d", i)
+		selector := map[string]string{"app": name}
+		svcPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+		svc := createService(namespace, name, nil, svcPorts, selector, fmt.Sprintf("10.10.%d.%d", i/250, i%250))
+		pod1 := createPod(namespace, name+"-1", serviceAccount, selector, fmt.Sprintf("10.30.%d.%d", i/250, i%250))
+		pod2 := createPod(namespace, name+"-2", serviceAccount, selector, fmt.Sprintf("10.40.%d.%d", i/250, i%250))
+		endpoints := createEndpoints(svc, createEndpointSubset(svcPorts, pod1, pod2, sharedPod))
+
+		k8sObjects = append(k8sObjects, svc, pod1, pod2, endpoints)
+		svcs[i] = svc
+
+		if i >= 20 {
+			splitObjects = append(splitObjects, createTrafficSplit(namespace, name+"-split", svc, svcs[i-10], svcs[i-20], nil))
+		}
+	}
+
+	return k8sObjects, accessObjects, specsObjects, splitObjects
+}
+
+// TestTopologyBuilder_BuildListsResourcesOncePerBuild makes sure the Services, Pods and Endpoints referenced by several
+// SMI resources are listed once per build, and that the listed resources are not reused by the next build.
+func TestTopologyBuilder_BuildListsResourcesOncePerBuild(t *testing.T) {
+	selectorAppA := map[string]string{"app": "app-a"}
+	selectorAppB := map[string]string{"app": "app-b"}
+	selectorAppC := map[string]string{"app": "app-c"}
+	selectorAppD := map[string]string{"app": "app-d"}
+	annotations := map[string]string{}
+	svcPorts := []corev1.ServicePort{svcPort("port-8080", 8080, 8080)}
+
+	saA := createServiceAccount("my-ns", "service-account-a")
+	podA := createPod("my-ns", "app-a", saA, selectorAppA, "10.10.1.1")
+
+	saB := createServiceAccount("my-ns", "service-account-b")
+	svcB := createService("my-ns", "svc-b", annotations, svcPorts, selectorAppB, "10.10.1.16")
+	podB := createPod("my-ns", "app-b", saB, svcB.Spec.Selector, "10.10.2.1")
+
+	saC := createServiceAccount("my-ns", "service-account-c")
+	svcC := createService("my-ns", "svc-c", annotations, svcPorts, selectorAppC, "10.10.1.17")
+	podC := createPod("my-ns", "app-c", saC, svcC.Spec.Selector, "10.10.2.2")
+	svcD := createService("my-ns", "svc-d", annotations, svcPorts, selectorAppD, "10.10.1.18")
+	podD := createPod("my-ns", "app-d", saC, svcD.Spec.Selector, "10.10.2.3")
+
+	epB := createEndpoints(svcB, createEndpointSubset(svcPorts, podB))
+	epC := createEndpoints(svcC, createEndpointSubset(svcPorts, podC))
+	epD := createEndpoints(svcD, createEndpointSubset(svcPorts, podD))
+
+	apiMatch := createHTTPMatch("api", []string{"GET"}, "/api", nil)
+	rtGrp := createHTTPRouteGroup("my-ns", "http-rt-grp", []specs.HTTPMatch{apiMatch})
+
+	// Both TrafficTargets and TrafficSplits reference the same services, pods and service accounts.
+	tt1 := createTrafficTarget("my-ns", "tt-1", saB, intPtr(8080), []*corev1.ServiceAccount{saA}, rtGrp, []string{apiMatch.Name})
+	tt2 := createTrafficTarget("my-ns", "tt-2", saB, intPtr(8080), []*corev1.ServiceAccount{saA}, rtGrp, []string{apiMatch.Name})
+	ts1 := createTrafficSplit("my-ns", "ts-1", svcB, svcC, svcD, nil)
+	ts2 := createTrafficSplit("my-ns", "ts-2", svcB, svcC, svcD, nil)
+
+	k8sClient := fake.NewSimpleClientset(saA, saB, saC, podA, podB, podC, podD, svcB, svcC, svcD, epB, epC, epD)
+	smiAccessClient := accessfake.NewSimpleClientset(tt1, tt2)
+	smiSplitClient := splitfake.NewSimpleClientset(ts1, ts2)
+	smiSpecClient := specsfake.NewSimpleClientset(rtGrp)
+
+	builder, err := createBuilder(k8sClient, smiAccessClient, smiSpecClient, smiSplitClient)
+	require.NoError(t, err)
+
+	serviceLister := &countingServiceLister{ServiceLister: builder.serviceLister}
+	podLister := &countingPodLister{PodLister: builder.podLister}
+	endpointsLister := &countingEndpointsLister{EndpointsLister: builder.endpointsLister}
+
+	builder.serviceLister = serviceLister
+	builder.podLister = podLister
+	builder.endpointsLister = endpointsLister
+
+	for build := 1; build <= 2; build++ {
+		got, err := builder.Build(mk8s.NewResourceFilter())
+		require.NoError(t, err)
+
+		assert.Len(t, got.Services, 3)
+		assert.Len(t, got.ServiceTrafficTargets, 2)
+		assert.Len(t, got.TrafficSplits, 2)
+
+		assert.Equal(t, build, serviceLister.lists)
+		assert.Equal(t, build, podLister.lists)
+		assert.Equal(t, build, endpointsLister.lists)
+	}
+
+	assert.Zero(t, serviceLister.namespaceLookups)
+	assert.Zero(t, podLister.namespaceLookups)
+	assert.Zero(t, endpointsLister.namespaceLookups)
+}
+
 // createBuilder initializes the different k8s factories and start them, initializes listers and create
 // a new topology.Builder.
 func createBuilder(k8sClient k8s.Interface, smiAccessClient accessclient.Interface, smiSpecClient specsclient.Interface, smiSplitClient splitclient.Interface) (*Builder, error) {
@@ -703,6 +1409,106 @@ func createBuilder(k8sClient k8s.Interface, smiAccessClient accessclient.Interfa
 	}, nil
 }
 
+// createEndpointSliceLister initializes and starts an EndpointSlice informer, and returns its lister.
+func createEndpointSliceLister(k8sClient k8s.Interface) (discoverylisters.EndpointSliceLister, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	k8sFactory := informers.NewSharedInformerFactoryWithOptions(k8sClient, mk8s.ResyncPeriod)
+
+	endpointSliceLister := k8sFactory.Discovery().V1().EndpointSlices().Lister()
+
+	k8sFactory.Start(ctx.Done())
+
+	for t, ok := range k8sFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return nil, fmt.Errorf("timed out while waiting for cache sync: %s", t.String())
+		}
+	}
+
+	return endpointSliceLister, nil
+}
+
+// createNodeLister initializes and starts a Node informer, and returns its lister.
+func createNodeLister(k8sClient k8s.Interface) (listers.NodeLister, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	k8sFactory := informers.NewSharedInformerFactoryWithOptions(k8sClient, mk8s.ResyncPeriod)
+
+	nodeLister := k8sFactory.Core().V1().Nodes().Lister()
+
+	k8sFactory.Start(ctx.Done())
+
+	for t, ok := range k8sFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return nil, fmt.Errorf("timed out while waiting for cache sync: %s", t.String())
+		}
+	}
+
+	return nodeLister, nil
+}
+
+// countingServiceLister counts the calls made to the wrapped ServiceLister.
+type countingServiceLister struct {
+	listers.ServiceLister
+
+	lists            int
+	namespaceLookups int
+}
+
+func (l *countingServiceLister) List(selector labels.Selector) ([]*corev1.Service, error) {
+	l.lists++
+
+	return l.ServiceLister.List(selector)
+}
+
+func (l *countingServiceLister) Services(namespace string) listers.ServiceNamespaceLister {
+	l.namespaceLookups++
+
+	return l.ServiceLister.Services(namespace)
+}
+
+// countingPodLister counts the calls made to the wrapped PodLister.
+type countingPodLister struct {
+	listers.PodLister
+
+	lists            int
+	namespaceLookups int
+}
+
+func (l *countingPodLister) List(selector labels.Selector) ([]*corev1.Pod, error) {
+	l.lists++
+
+	return l.PodLister.List(selector)
+}
+
+func (l *countingPodLister) Pods(namespace string) listers.PodNamespaceLister {
+	l.namespaceLookups++
+
+	return l.PodLister.Pods(namespace)
+}
+
+// countingEndpointsLister counts the calls made to the wrapped EndpointsLister.
+type countingEndpointsLister struct {
+	listers.EndpointsLister
+
+	lists            int
+	namespaceLookups int
+}
+
+func (l *countingEndpointsLister) List(selector labels.Selector) ([]*corev1.Endpoints, error) {
+	l.lists++
+
+	return l.EndpointsLister.List(selector)
+}
+
+func (l *countingEndpointsLister) Endpoints(namespace string) listers.EndpointsNamespaceLister {
+	l.namespaceLookups++
+
+	return l.EndpointsLister.Endpoints(namespace)
+}
+
 func nn(name, ns string) Key {
 	return Key{
 		Name:      name,
@@ -742,11 +1548,11 @@ func createTrafficSplit(namespace, name string, svc *corev1.Service, backend1 *c
 			Service: svc.Name,
 			Backends: []split.TrafficSplitBackend{
 				{
-					Service: backend1.Name,
+					Service: getTrafficSplitBackendService(namespace, backend1),
 					Weight:  80,
 				},
 				{
-					Service: backend2.Name,
+					Service: getTrafficSplitBackendService(namespace, backend2),
 					Weight:  20,
 				},
 			},
@@ -755,6 +1561,16 @@ func createTrafficSplit(namespace, name string, svc *corev1.Service, backend1 *c
 	}
 }
 
+// getTrafficSplitBackendService returns the service reference of a TrafficSplit backend, in the form name.namespace if
+// the backend lives in another namespace than the TrafficSplit.
+func getTrafficSplitBackendService(namespace string, backend *corev1.Service) string {
+	if backend.Namespace != namespace {
+		return backend.Name + "." + backend.Namespace
+	}
+
+	return backend.Name
+}
+
 func createTrafficTarget(namespace, name string, destSa *corev1.ServiceAccount, destPort *int, srcsSa []*corev1.ServiceAccount, rtGrp *specs.HTTPRouteGroup, rtGrpMatches []string) *access.TrafficTarget {
 	sources := make([]access.IdentityBindingSubject, len(srcsSa))
 	for i, sa := range srcsSa {
@@ -886,6 +1702,52 @@ func createEndpoints(svc *corev1.Service, subsets ...corev1.EndpointSubset) *cor
 	}
 }
 
+func createEndpointSlice(svc *corev1.Service, name string, svcPorts []corev1.ServicePort, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	ports := make([]discoveryv1.EndpointPort, len(svcPorts))
+	for i, port := range svcPorts {
+		portName := port.Name
+		portNumber := port.TargetPort.IntVal
+		portProtocol := port.Protocol
+
+		ports[i] = discoveryv1.EndpointPort{
+			Name:     &portName,
+			Port:     &portNumber,
+			Protocol: &portProtocol,
+		}
+	}
+
+	return &discoveryv1.EndpointSlice{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "EndpointSlice",
+			APIVersion: "discovery.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: svc.Namespace,
+			Labels: map[string]string{
+				discoveryv1.LabelServiceName: svc.Name,
+			},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   endpoints,
+		Ports:       ports,
+	}
+}
+
+func createSliceEndpoint(pod *corev1.Pod, ready bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses: []string{pod.Status.PodIP},
+		Conditions: discoveryv1.EndpointConditions{
+			Ready: &ready,
+		},
+		TargetRef: &corev1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+		},
+	}
+}
+
 func createPod(namespace, name string, sa *corev1.ServiceAccount, selector map[string]string, podIP string) *corev1.Pod {
 	return &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
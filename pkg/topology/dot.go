@@ -0,0 +1,121 @@
+package topology
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the topology as a Graphviz DOT directed graph. Services are box nodes, and the traffic authorized by
+// the TrafficTargets is drawn as edges from the services exposing the source pods, or from the source service account
+// when none of its pods is exposed, to the destination service. TrafficSplits are drawn as dashed edges from the split
+// service to its backends, labeled with their weight.
+func (t *Topology) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph mesh {\n")
+
+	svcKeys := make([]Key, 0, len(t.Services))
+	for svcKey := range t.Services {
+		svcKeys = append(svcKeys, svcKey)
+	}
+
+	sortKeys(svcKeys)
+
+	for _, svcKey := range svcKeys {
+		fmt.Fprintf(&b, "  %q [label=%q, shape=box];\n", svcKey.String(), svcKey.Namespace+"/"+svcKey.Name)
+	}
+
+	// Services exposing each pod, to find the services the sources of a TrafficTarget are reached through.
+	podServices := make(map[Key][]Key)
+
+	for _, svcKey := range svcKeys {
+		for _, podKey := range t.Services[svcKey].Pods {
+			podServices[podKey] = append(podServices[podKey], svcKey)
+		}
+	}
+
+	ttKeys := make([]ServiceTrafficTargetKey, 0, len(t.ServiceTrafficTargets))
+	for ttKey := range t.ServiceTrafficTargets {
+		ttKeys = append(ttKeys, ttKey)
+	}
+
+	sort.Slice(ttKeys, func(i, j int) bool {
+		return ttKeys[i].String() < ttKeys[j].String()
+	})
+
+	for _, ttKey := range ttKeys {
+		tt := t.ServiceTrafficTargets[ttKey]
+
+		for _, source := range tt.Sources {
+			for _, from := range getSourceNodes(source, podServices, &b) {
+				fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", from, tt.Service.String(), tt.Name)
+			}
+		}
+	}
+
+	tsKeys := make([]Key, 0, len(t.TrafficSplits))
+	for tsKey := range t.TrafficSplits {
+		tsKeys = append(tsKeys, tsKey)
+	}
+
+	sortKeys(tsKeys)
+
+	for _, tsKey := range tsKeys {
+		ts := t.TrafficSplits[tsKey]
+
+		for _, backend := range ts.Backends {
+			fmt.Fprintf(&b, "  %q -> %q [label=%q, style=dashed];\n", ts.Service.String(), backend.Service.String(), strconv.Itoa(backend.Weight))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// getSourceNodes returns the IDs of the nodes the given TrafficTarget source is drawn from: the services exposing its
+// pods or, if there is none, a node for its service account, which is written to the given builder.
+func getSourceNodes(source ServiceTrafficTargetSource, podServices map[Key][]Key, b *strings.Builder) []string {
+	seen := make(map[Key]struct{})
+
+	var nodes []string
+
+	for _, podKey := range source.Pods {
+		for _, svcKey := range podServices[podKey] {
+			if _, ok := seen[svcKey]; ok {
+				continue
+			}
+
+			seen[svcKey] = struct{}{}
+			nodes = append(nodes, svcKey.String())
+		}
+	}
+
+	sort.Strings(nodes)
+
+	if len(nodes) > 0 {
+		return nodes
+	}
+
+	saKey := Key{Name: source.ServiceAccount, Namespace: source.Namespace}
+	node := "sa:" + saKey.String()
+
+	fmt.Fprintf(b, "  %q [label=%q, shape=ellipse];\n", node, "serviceaccount "+saKey.Namespace+"/"+saKey.Name)
+
+	return []string{node}
+}
+
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+
+		return keys[i].Name < keys[j].Name
+	})
+}
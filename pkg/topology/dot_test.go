@@ -0,0 +1,60 @@
+package topology
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopology_WriteDOT(t *testing.T) {
+	svcA := Key{Name: "svc-a", Namespace: "my-ns"}
+	svcB := Key{Name: "svc-b", Namespace: "my-ns"}
+	svcC := Key{Name: "svc-c", Namespace: "my-ns"}
+	podA := Key{Name: "pod-a", Namespace: "my-ns"}
+	podB := Key{Name: "pod-b", Namespace: "my-ns"}
+	ttKey := ServiceTrafficTargetKey{Service: svcB, TrafficTarget: Key{Name: "tt", Namespace: "my-ns"}}
+
+	topo := NewTopology()
+	topo.Services[svcA] = &Service{Name: svcA.Name, Namespace: svcA.Namespace, Pods: []Key{podA}}
+	topo.Services[svcB] = &Service{Name: svcB.Name, Namespace: svcB.Namespace, Pods: []Key{podB}}
+	topo.Services[svcC] = &Service{Name: svcC.Name, Namespace: svcC.Namespace}
+	topo.ServiceTrafficTargets[ttKey] = &ServiceTrafficTarget{
+		Service:   svcB,
+		Name:      "tt",
+		Namespace: "my-ns",
+		Sources: []ServiceTrafficTargetSource{
+			{ServiceAccount: "client", Namespace: "my-ns", Pods: []Key{podA}},
+			{ServiceAccount: "job", Namespace: "other-ns"},
+		},
+	}
+	topo.TrafficSplits[Key{Name: "split", Namespace: "my-ns"}] = &TrafficSplit{
+		Name:      "split",
+		Namespace: "my-ns",
+		Service:   svcA,
+		Backends: []TrafficSplitBackend{
+			{Weight: 80, Service: svcB},
+			{Weight: 20, Service: svcC},
+		},
+	}
+
+	var buf bytes.Buffer
+
+	err := topo.WriteDOT(&buf)
+	require.NoError(t, err)
+
+	want := `digraph mesh {
+  "svc-a@my-ns" [label="my-ns/svc-a", shape=box];
+  "svc-b@my-ns" [label="my-ns/svc-b", shape=box];
+  "svc-c@my-ns" [label="my-ns/svc-c", shape=box];
+  "svc-a@my-ns" -> "svc-b@my-ns" [label="tt"];
+  "sa:job@other-ns" [label="serviceaccount other-ns/job", shape=ellipse];
+  "sa:job@other-ns" -> "svc-b@my-ns" [label="tt"];
+  "svc-a@my-ns" -> "svc-b@my-ns" [label="80", style=dashed];
+  "svc-a@my-ns" -> "svc-c@my-ns" [label="20", style=dashed];
+}
+`
+
+	assert.Equal(t, want, buf.String())
+}
@@ -1,10 +1,12 @@
 package topology
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	"github.com/traefik/mesh/v2/pkg/annotations"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -138,6 +140,9 @@ type Service struct {
 	ClusterIP   string               `json:"clusterIp"`
 	Pods        []Key                `json:"pods,omitempty"`
 
+	// External host targeted by this service when it is of type ExternalName.
+	ExternalName string `json:"externalName,omitempty"`
+
 	// List of TrafficTargets that are targeting pods which are selected by this service.
 	TrafficTargets []ServiceTrafficTargetKey `json:"trafficTargets,omitempty"`
 	// List of TrafficSplits that are targeting this service.
@@ -201,6 +206,12 @@ type Pod struct {
 	OwnerReferences []v1.OwnerReference    `json:"ownerReferences,omitempty"`
 	ContainerPorts  []corev1.ContainerPort `json:"containerPorts,omitempty"`
 	IP              string                 `json:"ip"`
+	// Zone is the zone of the node the Pod runs on, empty when unknown.
+	Zone string `json:"zone,omitempty"`
+
+	// Endpoint conditions of this Pod. A Pod is not ready or terminating only if the endpoints referencing it say so.
+	NotReady    bool `json:"notReady,omitempty"`
+	Terminating bool `json:"terminating,omitempty"`
 
 	SourceOf      []ServiceTrafficTargetKey `json:"sourceOf,omitempty"`
 	DestinationOf []ServiceTrafficTargetKey `json:"destinationOf,omitempty"`
@@ -236,6 +247,8 @@ func (ts *TrafficSplit) AddError(err error) {
 type TrafficSplitBackend struct {
 	Weight  int `json:"weight"`
 	Service Key `json:"service"`
+	// NormalizedWeight is the weight of the backend in the generated configuration, set by the provider.
+	NormalizedWeight int `json:"normalizedWeight,omitempty"`
 }
 
 // ResolveServicePort resolves the given service port against the given container port list, as described in the
@@ -257,3 +270,28 @@ func ResolveServicePort(svcPort corev1.ServicePort, containerPorts []corev1.Cont
 
 	return 0, false
 }
+
+// GetMeshedPorts returns the ports of the given service which are not excluded from the mesh by its ignore-ports
+// annotation.
+func GetMeshedPorts(svc *corev1.Service) ([]corev1.ServicePort, error) {
+	ignoredPorts, err := annotations.GetIgnoredPorts(svc.Annotations)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return svc.Spec.Ports, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var ports []corev1.ServicePort
+
+	for _, svcPort := range svc.Spec.Ports {
+		if ignoredPorts.Contains(svcPort.Name, svcPort.Port) {
+			continue
+		}
+
+		ports = append(ports, svcPort)
+	}
+
+	return ports, nil
+}